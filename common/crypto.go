@@ -1,6 +1,18 @@
 package common
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
 
 func Password2Hash(password string) (string, error) {
 	passwordBytes := []byte(password)
@@ -12,3 +24,68 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// ErrEncryptionKeyNotConfigured 未配置加密密钥，不能加密或解密敏感字段
+var ErrEncryptionKeyNotConfigured = errors.New("encryption key not configured, set ENCRYPTION_KEY or security.encryptionKey")
+
+// encryptionKey 加密数据库中敏感字段(如集群 kubeconfig)的密钥
+// 依次读取环境变量 ENCRYPTION_KEY、config.yaml 的 security.encryptionKey
+// 密文须在重启后仍能解密，因此不回退到每次启动随机生成的 SessionSecret
+func encryptionKey() ([]byte, error) {
+	key := os.Getenv("ENCRYPTION_KEY")
+	if key == "" {
+		key = viper.GetString("security.encryptionKey")
+	}
+	if key == "" {
+		return nil, ErrEncryptionKeyNotConfigured
+	}
+	sum := sha256.Sum256([]byte(key))
+	return sum[:], nil
+}
+
+// EncryptSecret 使用 AES-GCM 加密，返回 base64(nonce + 密文)
+func EncryptSecret(plaintext []byte) (string, error) {
+	gcm, err := newSecretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret 解密 EncryptSecret 的结果
+func DecryptSecret(ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newSecretCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt secret, check ENCRYPTION_KEY")
+	}
+	return plaintext, nil
+}
+
+func newSecretCipher() (cipher.AEAD, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
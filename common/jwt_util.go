@@ -1,13 +1,29 @@
 package common
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
 )
 
-var JWTSecret = []byte("my_secret_key_haha") // 在实际应用中，应该使用环境变量存储
+// JWTKeys 签名密钥，按 kid 索引；轮换时新增 kid 并切换 JWTCurrentKID，旧 kid 保留到其签发的令牌过期
+var JWTKeys = map[string][]byte{}
+var JWTCurrentKID = ""
+
+var AccessTokenTTL = 15 * time.Minute
+var RefreshTokenTTL = 7 * 24 * time.Hour
+
+func init() {
+	// iat/exp 保留毫秒，吊销全部令牌后同一秒内重新登录签发的令牌不会被误判为已吊销
+	jwt.TimePrecision = time.Millisecond
+}
 
 type Claims struct {
 	UserId   int    `json:"user_id"`
@@ -16,24 +32,104 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userId int, username string, role int) (string, error) {
-	claims := Claims{
+type jwtKeyConfig struct {
+	KID    string `mapstructure:"kid"`
+	Secret string `mapstructure:"secret"`
+}
+
+// InitJWTKeys 从 config.yaml 的 jwt 配置和环境变量 JWT_SECRET/JWT_KID 加载签名密钥
+// 环境变量中的密钥优先作为当前签名密钥
+func InitJWTKeys() error {
+	if ttl := viper.GetDuration("jwt.accessTokenTTL"); ttl > 0 {
+		AccessTokenTTL = ttl
+	}
+	if ttl := viper.GetDuration("jwt.refreshTokenTTL"); ttl > 0 {
+		RefreshTokenTTL = ttl
+	}
+
+	var keys []jwtKeyConfig
+	if err := viper.UnmarshalKey("jwt.keys", &keys); err != nil {
+		return fmt.Errorf("invalid jwt.keys: %w", err)
+	}
+	JWTKeys = map[string][]byte{}
+	for _, key := range keys {
+		if key.KID == "" || key.Secret == "" {
+			return errors.New("jwt.keys entries require both kid and secret")
+		}
+		JWTKeys[key.KID] = []byte(key.Secret)
+	}
+	JWTCurrentKID = viper.GetString("jwt.currentKid")
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		kid := os.Getenv("JWT_KID")
+		if kid == "" {
+			kid = "env"
+		}
+		JWTKeys[kid] = []byte(secret)
+		JWTCurrentKID = kid
+	}
+
+	if len(JWTKeys) == 0 {
+		// 未配置密钥时生成随机密钥，重启后所有令牌失效
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		JWTCurrentKID = "random-" + hex.EncodeToString(secret[:4])
+		JWTKeys[JWTCurrentKID] = secret
+		SysError("JWT secret not configured, using a random key; tokens will be invalid after restart")
+	}
+	if JWTCurrentKID == "" && len(keys) > 0 {
+		JWTCurrentKID = keys[0].KID
+	}
+	if _, ok := JWTKeys[JWTCurrentKID]; !ok {
+		return fmt.Errorf("jwt.currentKid %q not found in jwt.keys", JWTCurrentKID)
+	}
+	return nil
+}
+
+// GenerateToken 签发短期访问令牌，返回令牌及其 Claims(包含 jti 和过期时间)
+func GenerateToken(userId int, username string, role int) (string, *Claims, error) {
+	secret, ok := JWTKeys[JWTCurrentKID]
+	if !ok {
+		return "", nil, errors.New("jwt signing key not initialized")
+	}
+
+	now := time.Now()
+	claims := &Claims{
 		UserId:   userId,
 		Username: username,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(JWTSecret)
+	token.Header["kid"] = JWTCurrentKID
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
 func ParseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return JWTSecret, nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = JWTCurrentKID
+		}
+		secret, ok := JWTKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return secret, nil
 	})
 
 	if err != nil {
@@ -46,3 +142,12 @@ func ParseToken(tokenString string) (*Claims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// GenerateRefreshToken 生成不透明的刷新令牌，服务端只保存其哈希
+func GenerateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"MLcore-Engine/middleware"
+	"MLcore-Engine/model"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AccessTokenRequest 创建个人访问令牌
+type AccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100" example:"ci-pipeline"`
+	Scopes        []string `json:"scopes" binding:"required,min=1" example:"dataset:read,train:submit"`
+	ProjectIDs    []uint   `json:"project_ids"`
+	ExpiresInDays int      `json:"expires_in_days" example:"90"` // 0 表示永不过期
+}
+
+// AccessTokenDTO 个人访问令牌信息，Token 明文只在创建时返回
+type AccessTokenDTO struct {
+	ID         uint       `json:"id" example:"1"`
+	Name       string     `json:"name" example:"ci-pipeline"`
+	Prefix     string     `json:"prefix" example:"mlc_3fa9c1"`
+	Token      string     `json:"token,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ProjectIDs []uint     `json:"project_ids"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func convertToAccessTokenDTO(token model.PersonalAccessToken) AccessTokenDTO {
+	return AccessTokenDTO{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     token.ScopeList(),
+		ProjectIDs: token.ProjectIDList(),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// checkTokenProject 令牌不允许访问该项目时返回 403，返回 false 表示已响应
+func checkTokenProject(c *gin.Context, projectID uint) bool {
+	if middleware.TokenAllowsProject(c, projectID) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, ErrorResponse{
+		Success: false,
+		Message: "访问令牌无权访问项目 " + strconv.FormatUint(uint64(projectID), 10),
+	})
+	return false
+}
+
+// restrictTokenProjects 将列表查询限制在令牌允许的项目内
+func restrictTokenProjects(c *gin.Context, query *gorm.DB, column string) *gorm.DB {
+	if ids := middleware.TokenProjectIDs(c); ids != nil {
+		return query.Where(column+" IN ?", ids)
+	}
+	return query
+}
+
+// ListAccessTokens godoc
+// @Summary List personal access tokens
+// @Description List the personal access tokens of the current user
+// @Tags user
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /user/self/tokens [get]
+func ListAccessTokens(c *gin.Context) {
+	tokens, err := model.GetUserPersonalAccessTokens(uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve access tokens: " + err.Error(),
+		})
+		return
+	}
+
+	dtos := make([]AccessTokenDTO, 0, len(tokens))
+	for _, token := range tokens {
+		dtos = append(dtos, convertToAccessTokenDTO(token))
+	}
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "",
+		Data:    dtos,
+	})
+}
+
+// CreateAccessToken godoc
+// @Summary Create a personal access token
+// @Description Create a scoped personal access token for CI/SDK use; the token is only shown once
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param token body AccessTokenRequest true "Token details"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /user/self/tokens [post]
+func CreateAccessToken(c *gin.Context) {
+	var req AccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Message: "Invalid request payload: " + err.Error(),
+		})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !model.IsValidTokenScope(scope) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Success: false,
+				Message: "不支持的权限范围: " + scope + "，可选: " + strings.Join(model.AllTokenScopes, ", "),
+			})
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "expires_in_days 不能为负数"})
+		return
+	}
+
+	userID := uint(c.GetInt("user_id"))
+	// 只能限制到自己所属的项目
+	for _, projectID := range req.ProjectIDs {
+		var count int64
+		if err := model.DB.Model(&model.UserProject{}).
+			Where("project_id = ? AND user_id = ?", projectID, userID).
+			Count(&count).Error; err != nil || count == 0 {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Success: false,
+				Message: "您不是项目 " + strconv.FormatUint(uint64(projectID), 10) + " 的成员",
+			})
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	plain, token, err := model.CreatePersonalAccessToken(userID, req.Name, req.Scopes, req.ProjectIDs, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to create access token: " + err.Error(),
+		})
+		return
+	}
+
+	dto := convertToAccessTokenDTO(*token)
+	dto.Token = plain
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Access token created, it will not be shown again",
+		Data:    dto,
+	})
+}
+
+// RevokeAccessToken godoc
+// @Summary Revoke a personal access token
+// @Description Revoke one of the current user's personal access tokens
+// @Tags user
+// @Produce json
+// @Param id path int true "Token ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /user/self/tokens/{id} [delete]
+func RevokeAccessToken(c *gin.Context) {
+	tokenID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的令牌ID"})
+		return
+	}
+
+	err = model.DeleteUserPersonalAccessToken(uint(c.GetInt("user_id")), uint(tokenID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "令牌不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to revoke access token: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Access token revoked",
+	})
+}
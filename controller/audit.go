@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLogsListData 审计日志分页数据
+type AuditLogsListData struct {
+	Logs []model.AuditLog `json:"logs"`
+	PagedData
+}
+
+// auditOf 返回当前请求的审计记录，未经过审计中间件时返回一个不会保存的空记录
+func auditOf(c *gin.Context) *model.AuditLog {
+	if value, ok := c.Get(model.AuditContextKey); ok {
+		if entry, ok := value.(*model.AuditLog); ok {
+			return entry
+		}
+	}
+	return &model.AuditLog{}
+}
+
+// parseAuditTime 支持 RFC3339 和 2006-01-02 两种格式，end 为日期时取当天结束
+func parseAuditTime(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func parseAuditFilter(c *gin.Context) (model.AuditLogFilter, bool) {
+	filter := model.AuditLogFilter{
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		Action:       c.Query("action"),
+	}
+	var err error
+	if value := c.Query("user_id"); value != "" {
+		if filter.UserID, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的 user_id"})
+			return filter, false
+		}
+	}
+	if value := c.Query("project_id"); value != "" {
+		projectID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的 project_id"})
+			return filter, false
+		}
+		filter.ProjectID = uint(projectID)
+	}
+	if filter.Start, err = parseAuditTime(c.Query("start"), false); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的 start 时间，格式为 RFC3339 或 2006-01-02"})
+		return filter, false
+	}
+	if filter.End, err = parseAuditTime(c.Query("end"), true); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的 end 时间，格式为 RFC3339 或 2006-01-02"})
+		return filter, false
+	}
+	return filter, true
+}
+
+func respondAuditLogs(c *gin.Context, filter model.AuditLogFilter) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	logs, total, err := model.QueryAuditLogs(filter, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve audit logs: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "",
+		Data: AuditLogsListData{
+			Logs: logs,
+			PagedData: PagedData{
+				Total: total,
+				Page:  page,
+				Limit: limit,
+			},
+		},
+	})
+}
+
+// ListAuditLogs godoc
+// @Summary List audit logs
+// @Description List audit logs of state-changing API calls, filtered by user, project, resource and time range (admin only)
+// @Tags audit
+// @Produce json
+// @Param user_id query int false "Actor user ID"
+// @Param project_id query int false "Project ID"
+// @Param resource_type query string false "Resource type, e.g. notebook"
+// @Param resource_id query string false "Resource ID"
+// @Param action query string false "Action (method and route) substring"
+// @Param start query string false "Start time (RFC3339 or 2006-01-02)"
+// @Param end query string false "End time (RFC3339 or 2006-01-02)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /audit [get]
+func ListAuditLogs(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	respondAuditLogs(c, filter)
+}
+
+// ListProjectAuditLogs godoc
+// @Summary List audit logs of a project
+// @Description List audit logs scoped to one project, for project admins
+// @Tags audit
+// @Produce json
+// @Param projectId path int true "Project ID"
+// @Param user_id query int false "Actor user ID"
+// @Param resource_type query string false "Resource type, e.g. notebook"
+// @Param resource_id query string false "Resource ID"
+// @Param action query string false "Action (method and route) substring"
+// @Param start query string false "Start time (RFC3339 or 2006-01-02)"
+// @Param end query string false "End time (RFC3339 or 2006-01-02)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /audit/project/{projectId} [get]
+func ListProjectAuditLogs(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的项目ID"})
+		return
+	}
+	filter.ProjectID = uint(projectID)
+	respondAuditLogs(c, filter)
+}
+
+// auditResource 记录本次请求操作的资源、所属项目及变更前的状态(before 可为 nil)
+func auditResource(c *gin.Context, resourceType string, id uint, projectID uint, before interface{}) *model.AuditLog {
+	entry := auditOf(c)
+	entry.SetResource(resourceType, id)
+	entry.SetProject(projectID)
+	if before != nil {
+		entry.SetBefore(before)
+	}
+	return entry
+}
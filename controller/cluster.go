@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClusterResourceDTO CPU 核数、内存(GiB)与 nvidia.com/gpu 数量
+type ClusterResourceDTO struct {
+	CPU    float64 `json:"cpu" example:"16"`
+	Memory float64 `json:"memory" example:"64"`
+	GPU    int64   `json:"gpu" example:"2"`
+}
+
+// NodeWorkloadDTO 占用节点资源的 MLcore 工作负载
+type NodeWorkloadDTO struct {
+	Type      string             `json:"type" example:"notebook"`
+	ID        uint               `json:"id" example:"1"`
+	Name      string             `json:"name" example:"admin-ab12c"`
+	ProjectID uint               `json:"project_id" example:"1"`
+	UserID    uint               `json:"user_id" example:"1"`
+	Pod       string             `json:"pod" example:"admin-ab12c"`
+	Namespace string             `json:"namespace" example:"jupyter"`
+	Requested ClusterResourceDTO `json:"requested"`
+}
+
+// ClusterNodeDTO 节点容量视图
+type ClusterNodeDTO struct {
+	Name        string             `json:"name" example:"node1"`
+	HostIP      string             `json:"host_ip" example:"192.168.1.1"`
+	Labels      map[string]string  `json:"labels"`
+	Allocatable ClusterResourceDTO `json:"allocatable"`
+	Requested   ClusterResourceDTO `json:"requested"`
+	PodCount    int                `json:"pod_count" example:"3"`
+	Workloads   []NodeWorkloadDTO  `json:"workloads"`
+}
+
+// LabelNodesRequest 按节点 IP 批量设置标签
+type LabelNodesRequest struct {
+	ClusterID uint              `json:"cluster_id" example:"0"` // 0 表示 config.yaml 中的集群
+	IPs       []string          `json:"ips" binding:"required,min=1"`
+	Labels    map[string]string `json:"labels" binding:"required,min=1"`
+}
+
+// workloadIndex 将 Pod 对应到数据库中的工作负载
+type workloadIndex struct {
+	notebooks     map[string]model.Notebook     // namespace/name
+	trainingJobs  map[string]model.TrainingJob  // namespace/job-name
+	tritonDeploys map[string]model.TritonDeploy // namespace/app
+}
+
+// loadWorkloadIndex 加载指定集群上的工作负载
+func loadWorkloadIndex(clusterID uint) (*workloadIndex, error) {
+	index := &workloadIndex{
+		notebooks:     make(map[string]model.Notebook),
+		trainingJobs:  make(map[string]model.TrainingJob),
+		tritonDeploys: make(map[string]model.TritonDeploy),
+	}
+
+	var notebooks []model.Notebook
+	if err := model.DB.Where("cluster_id = ?", clusterID).Find(&notebooks).Error; err != nil {
+		return nil, err
+	}
+	for _, notebook := range notebooks {
+		index.notebooks[notebook.Namespace+"/"+notebook.Name] = notebook
+	}
+
+	var jobs []model.TrainingJob
+	if err := model.DB.Where("cluster_id = ?", clusterID).Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		index.trainingJobs[job.Namespace+"/"+job.Name] = job
+	}
+
+	var deploys []model.TritonDeploy
+	if err := model.DB.Where("cluster_id = ?", clusterID).Find(&deploys).Error; err != nil {
+		return nil, err
+	}
+	for _, deploy := range deploys {
+		index.tritonDeploys[deploy.Namespace+"/"+deploy.Name] = deploy
+	}
+
+	return index, nil
+}
+
+// lookup 返回 Pod 所属的工作负载，非 MLcore 创建的 Pod 返回 false
+func (w *workloadIndex) lookup(pod services.NodePod) (NodeWorkloadDTO, bool) {
+	workload := NodeWorkloadDTO{
+		Pod:       pod.Name,
+		Namespace: pod.Namespace,
+		Requested: ClusterResourceDTO{CPU: pod.CPU, Memory: pod.Memory, GPU: pod.GPU},
+	}
+	if notebook, ok := w.notebooks[pod.Namespace+"/"+pod.Name]; ok {
+		workload.Type, workload.ID, workload.Name = "notebook", notebook.ID, notebook.Name
+		workload.ProjectID, workload.UserID = notebook.ProjectID, notebook.UserID
+		return workload, true
+	}
+	if job, ok := w.trainingJobs[pod.Namespace+"/"+pod.Labels[services.PyTorchJobNameLabel]]; ok {
+		workload.Type, workload.ID, workload.Name = "training_job", job.ID, job.Name
+		workload.ProjectID, workload.UserID = job.ProjectID, job.UserID
+		return workload, true
+	}
+	if deploy, ok := w.tritonDeploys[pod.Namespace+"/"+pod.Labels["app"]]; ok {
+		workload.Type, workload.ID, workload.Name = "triton_deploy", deploy.ID, deploy.Name
+		workload.ProjectID, workload.UserID = deploy.ProjectID, deploy.UserID
+		return workload, true
+	}
+	return workload, false
+}
+
+// ListClusterNodes godoc
+// @Summary List cluster nodes
+// @Description List nodes with allocatable vs requested CPU, memory (GiB) and GPU, their labels and the MLcore workloads running on them
+// @Tags cluster
+// @Produce json
+// @Param label query string false "Label selector, e.g. notebook=true"
+// @Param cluster_id query int false "Cluster ID, 0 for the cluster in config.yaml"
+// @Success 200 {object} SuccessResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cluster/nodes [get]
+func ListClusterNodes(c *gin.Context) {
+	clusterID, ok := clusterIDQuery(c)
+	if !ok {
+		return
+	}
+	k8sClient, err := getClusterClient(clusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to create K8s client: " + err.Error(),
+		})
+		return
+	}
+
+	usages, err := k8sClient.GetNodeUsage(c.Query("label"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve nodes: " + err.Error(),
+		})
+		return
+	}
+
+	index, err := loadWorkloadIndex(clusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve workloads: " + err.Error(),
+		})
+		return
+	}
+
+	nodes := make([]ClusterNodeDTO, 0, len(usages))
+	for _, usage := range usages {
+		node := ClusterNodeDTO{
+			Name:   usage.Name,
+			HostIP: usage.HostIP,
+			Labels: usage.Labels,
+			Allocatable: ClusterResourceDTO{
+				CPU:    float64(usage.CPU),
+				Memory: float64(usage.Memory),
+				GPU:    int64(usage.GPU),
+			},
+			Requested: ClusterResourceDTO{
+				CPU:    usage.RequestedCPU,
+				Memory: usage.RequestedMemory,
+				GPU:    usage.RequestedGPU,
+			},
+			PodCount:  len(usage.Pods),
+			Workloads: []NodeWorkloadDTO{},
+		}
+		for _, pod := range usage.Pods {
+			if workload, ok := index.lookup(pod); ok {
+				node.Workloads = append(node.Workloads, workload)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "",
+		Data:    nodes,
+	})
+}
+
+// LabelClusterNodes godoc
+// @Summary Label cluster nodes
+// @Description Set labels (e.g. notebook=true) on nodes selected by internal IP to manage node pool membership
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param request body LabelNodesRequest true "Node IPs and labels"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cluster/nodes/labels [put]
+func LabelClusterNodes(c *gin.Context) {
+	var req LabelNodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Message: "Invalid request payload: " + err.Error(),
+		})
+		return
+	}
+
+	k8sClient, err := getClusterClient(req.ClusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to create K8s client: " + err.Error(),
+		})
+		return
+	}
+
+	labeled, err := k8sClient.LabelNode(req.IPs, req.Labels)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to label nodes: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Nodes labeled successfully",
+		Data: gin.H{
+			"labeled_ips": labeled,
+		},
+	})
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/services"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// newResourceRequest parses per-replica CPU/memory strings into a capacity request
+func newResourceRequest(cpu, memory string, gpu int64, replicas int, nodeSelector map[string]string) (services.ResourceRequest, error) {
+	cpuQuantity, err := resource.ParseQuantity(cpu)
+	if err != nil {
+		return services.ResourceRequest{}, fmt.Errorf("invalid cpu %q: %v", cpu, err)
+	}
+	memoryQuantity, err := resource.ParseQuantity(memory)
+	if err != nil {
+		return services.ResourceRequest{}, fmt.Errorf("invalid memory %q: %v", memory, err)
+	}
+	return services.ResourceRequest{
+		CPU:          cpuQuantity,
+		Memory:       memoryQuantity,
+		GPU:          *resource.NewQuantity(gpu, resource.DecimalSI),
+		Replicas:     replicas,
+		NodeSelector: nodeSelector,
+	}, nil
+}
+
+// parseNodeSelector parses "key=value,key2=value2" into a map
+func parseNodeSelector(selector string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(selector, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid node selector %q, expected key=value", pair)
+		}
+		result[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return result, nil
+}
+
+// checkClusterCapacity runs the scheduling pre-flight check and responds with 409 when nothing can fit.
+// It returns true when creation may proceed. Errors while reading cluster state are logged and do
+// not block creation, so a restricted service account does not make the platform unusable.
+func checkClusterCapacity(c *gin.Context, k8sClient KubeClient, request services.ResourceRequest) bool {
+	result, err := k8sClient.CheckClusterCapacity(request)
+	if err != nil {
+		common.SysError("cluster capacity check skipped: " + err.Error())
+		return true
+	}
+	if result.Fits {
+		return true
+	}
+
+	message := "Insufficient cluster resources: no node can fit the requested resources"
+	if result.BestFit == nil {
+		message = "Insufficient cluster resources: no schedulable node matches the node selector"
+	}
+	c.JSON(http.StatusConflict, ErrorResponse{
+		Success: false,
+		Message: message,
+		Data: gin.H{
+			"requested": gin.H{
+				"cpu":           request.CPU.String(),
+				"memory":        request.Memory.String(),
+				"gpu":           request.GPU.Value(),
+				"replicas":      request.Replicas,
+				"node_selector": request.NodeSelector,
+			},
+			"best_fit_node": result.BestFit,
+		},
+	})
+	return false
+}
+
+func defaultString(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClusterRequest 登记或修改集群
+// 修改时 kubeconfig 为 null 表示保持不变
+type ClusterRequest struct {
+	Name               string  `json:"name" binding:"required,max=100" example:"gpu-cluster"`
+	Description        string  `json:"description" binding:"max=500"`
+	Kubeconfig         *string `json:"kubeconfig"` // kubeconfig 文件内容，加密保存，不会在接口中返回
+	InCluster          bool    `json:"in_cluster"`
+	IsDefault          bool    `json:"is_default"`
+	NotebookNamespace  string  `json:"notebook_namespace" example:"jupyter"`
+	TrainingNamespace  string  `json:"training_namespace" example:"train"`
+	TritonNamespace    string  `json:"triton_namespace" example:"triton-serving"`
+	NotebookExternalIP string  `json:"notebook_external_ip" example:"192.168.8.208"`
+	TritonExternalIP   string  `json:"triton_external_ip" example:"192.168.12.121"`
+	GPUResourceName    string  `json:"gpu_resource_name" example:"nvidia.com/gpu"`
+}
+
+// clusterIDQuery 读取可选的 cluster_id 查询参数，缺省为 0 (config.yaml 中的集群)
+func clusterIDQuery(c *gin.Context) (uint, bool) {
+	value := c.Query("cluster_id")
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的 cluster_id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// applyClusterRequest 将请求写入集群记录，并校验 kubeconfig 能否解析
+func applyClusterRequest(cluster *model.Cluster, req *ClusterRequest) error {
+	cluster.Name = req.Name
+	cluster.Description = req.Description
+	cluster.InCluster = req.InCluster
+	cluster.IsDefault = req.IsDefault
+	cluster.NotebookNamespace = req.NotebookNamespace
+	cluster.TrainingNamespace = req.TrainingNamespace
+	cluster.TritonNamespace = req.TritonNamespace
+	cluster.NotebookExternalIP = req.NotebookExternalIP
+	cluster.TritonExternalIP = req.TritonExternalIP
+	cluster.GPUResourceName = req.GPUResourceName
+
+	if req.Kubeconfig != nil {
+		if *req.Kubeconfig != "" {
+			if _, err := services.NewK8sFromKubeconfig([]byte(*req.Kubeconfig)); err != nil {
+				return err
+			}
+		}
+		if err := cluster.SetKubeconfig([]byte(*req.Kubeconfig)); err != nil {
+			return err
+		}
+	}
+	if !cluster.InCluster && !cluster.HasKubeconfig {
+		return errors.New("kubeconfig is required unless in_cluster is set")
+	}
+	return nil
+}
+
+// ListClusters godoc
+// @Summary List clusters
+// @Description List registered Kubernetes clusters; kubeconfig content is never returned
+// @Tags cluster
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cluster [get]
+func ListClusters(c *gin.Context) {
+	clusters, err := model.ListClusters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve clusters: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "",
+		Data:    clusters,
+	})
+}
+
+// GetCluster godoc
+// @Summary Get a cluster
+// @Tags cluster
+// @Produce json
+// @Param id path int true "Cluster ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /cluster/{id} [get]
+func GetCluster(c *gin.Context) {
+	cluster, ok := getClusterFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "",
+		Data:    cluster,
+	})
+}
+
+// CreateCluster godoc
+// @Summary Register a cluster
+// @Description Register a Kubernetes cluster with its kubeconfig (encrypted at rest), default namespaces, external IPs and GPU resource name
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param cluster body ClusterRequest true "Cluster details"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cluster [post]
+func CreateCluster(c *gin.Context) {
+	var req ClusterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Message: "Invalid request payload: " + err.Error(),
+		})
+		return
+	}
+
+	var cluster model.Cluster
+	if err := applyClusterRequest(&cluster, &req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := model.SaveCluster(&cluster); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to create cluster: " + err.Error(),
+		})
+		return
+	}
+	auditResource(c, model.ResourceCluster, cluster.ID, 0, nil).SetAfter(cluster)
+	startClusterReconciler(cluster.ID)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Cluster created successfully",
+		Data:    cluster,
+	})
+}
+
+// UpdateCluster godoc
+// @Summary Update a cluster
+// @Description Update cluster settings; omit kubeconfig to keep the stored one
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param id path int true "Cluster ID"
+// @Param cluster body ClusterRequest true "Cluster details"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cluster/{id} [put]
+func UpdateCluster(c *gin.Context) {
+	cluster, ok := getClusterFromParam(c)
+	if !ok {
+		return
+	}
+	audit := auditResource(c, model.ResourceCluster, cluster.ID, 0, cluster)
+
+	var req ClusterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Message: "Invalid request payload: " + err.Error(),
+		})
+		return
+	}
+	kubeconfigChanged := req.Kubeconfig != nil
+	if err := applyClusterRequest(cluster, &req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := model.SaveCluster(cluster); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to update cluster: " + err.Error(),
+		})
+		return
+	}
+	audit.SetAfter(gin.H{
+		"name":                 cluster.Name,
+		"description":          cluster.Description,
+		"in_cluster":           cluster.InCluster,
+		"is_default":           cluster.IsDefault,
+		"notebook_namespace":   cluster.NotebookNamespace,
+		"training_namespace":   cluster.TrainingNamespace,
+		"triton_namespace":     cluster.TritonNamespace,
+		"notebook_external_ip": cluster.NotebookExternalIP,
+		"triton_external_ip":   cluster.TritonExternalIP,
+		"gpu_resource_name":    cluster.GPUResourceName,
+		"has_kubeconfig":       cluster.HasKubeconfig,
+		"kubeconfig_changed":   kubeconfigChanged,
+	})
+
+	// 丢弃旧的客户端，按新配置重新连接
+	invalidateClusterClient(cluster.ID)
+	startClusterReconciler(cluster.ID)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Cluster updated successfully",
+		Data:    cluster,
+	})
+}
+
+// DeleteCluster godoc
+// @Summary Delete a cluster
+// @Description Delete a cluster that is no longer used by any project or workload
+// @Tags cluster
+// @Produce json
+// @Param id path int true "Cluster ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cluster/{id} [delete]
+func DeleteCluster(c *gin.Context) {
+	cluster, ok := getClusterFromParam(c)
+	if !ok {
+		return
+	}
+	auditResource(c, model.ResourceCluster, cluster.ID, 0, cluster)
+
+	err := model.DeleteCluster(cluster.ID)
+	if errors.Is(err, model.ErrClusterInUse) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Message: "集群仍被项目或工作负载使用，无法删除",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to delete cluster: " + err.Error(),
+		})
+		return
+	}
+	invalidateClusterClient(cluster.ID)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Cluster deleted successfully",
+	})
+}
+
+func getClusterFromParam(c *gin.Context) (*model.Cluster, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的集群ID"})
+		return nil, false
+	}
+	cluster, err := model.GetClusterByID(uint(id))
+	if errors.Is(err, model.ErrClusterNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "集群不存在"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: err.Error()})
+		return nil, false
+	}
+	return cluster, true
+}
@@ -4,11 +4,12 @@ import (
 	"MLcore-Engine/common"
 	"MLcore-Engine/model"
 	"MLcore-Engine/services"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // CreateDataset 创建新数据集
@@ -29,6 +30,8 @@ func CreateDataset(c *gin.Context) {
 		TemplateType     string `json:"template_type"`
 		ProjectID        uint   `json:"project_id"`
 		SchemaDefinition string `json:"schema_definition"`
+		// 每个条目需要的标注人数(1或2)，0 表示不修改
+		AnnotatorsRequired int `json:"annotators_required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -49,7 +52,7 @@ func CreateDataset(c *gin.Context) {
 		return
 	}
 
-	// 验证项目权限
+	// 项目成员权限已由 ProjectPermissionFromBody 中间件校验
 	if input.ProjectID != 0 {
 		var project model.Project
 		if err := model.DB.First(&project, input.ProjectID).Error; err != nil {
@@ -59,16 +62,6 @@ func CreateDataset(c *gin.Context) {
 			})
 			return
 		}
-
-		// 验证用户是否为项目成员
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", input.ProjectID, userID).First(&projectUser).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{
-				"success": false,
-				"message": "您不是该项目的成员，无法关联此项目",
-			})
-			return
-		}
 	}
 
 	// 创建数据集
@@ -87,7 +80,14 @@ func CreateDataset(c *gin.Context) {
 		dataset.StorageType = "database"
 	}
 	if dataset.TemplateType == "" {
-		dataset.TemplateType = "instruction_io"
+		dataset.TemplateType = services.TemplateInstructionIO
+	}
+	if _, err := services.CompileDatasetSchema(dataset.TemplateType, dataset.SchemaDefinition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "数据集Schema无效: " + err.Error(),
+		})
+		return
 	}
 
 	// 保存到数据库
@@ -98,23 +98,9 @@ func CreateDataset(c *gin.Context) {
 		})
 		return
 	}
+	auditResource(c, model.ResourceDataset, dataset.ID, dataset.ProjectID, nil).SetAfter(convertToDatasetDTO(dataset))
 
-	// 如果使用MinIO存储，初始化存储
-	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
-		bucketName := "datasets"
-		objectPath := "dataset_" + dataset.Name + "_" + strconv.FormatUint(uint64(dataset.ID), 10) + ".jsonl"
-
-		// 初始化MinIO存储
-		if err := services.InitDatasetMinioStorage(bucketName, objectPath); err != nil {
-			// 记录错误但继续处理
-			common.SysError(err.Error())
-		}
-
-		// 更新数据集存储信息
-		dataset.BucketName = bucketName
-		dataset.ObjectPath = objectPath
-		model.DB.Save(&dataset)
-	}
+	initDatasetStorage(&dataset)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -123,6 +109,24 @@ func CreateDataset(c *gin.Context) {
 	})
 }
 
+// initDatasetStorage 使用MinIO存储的新数据集初始化存储信息，分片在写入条目时创建
+func initDatasetStorage(dataset *model.Dataset) {
+	if dataset.StorageType != "minio" && dataset.StorageType != "both" {
+		return
+	}
+	bucketName := "datasets"
+	if err := services.EnsureMinioBucket(bucketName); err != nil {
+		// 记录错误但继续处理
+		common.SysError(err.Error())
+	}
+
+	// 更新数据集存储信息
+	dataset.BucketName = bucketName
+	dataset.ObjectPath = datasetShardPrefix(dataset.ID)
+	dataset.StorageLayout = model.DatasetLayoutSharded
+	model.DB.Save(dataset)
+}
+
 // ListDatasets 获取数据集列表
 // @Summary 获取数据集列表
 // @Description 获取当前用户有权限访问的数据集列表
@@ -166,6 +170,7 @@ func ListDatasets(c *gin.Context) {
 		Joins("LEFT JOIN user_projects ON user_projects.project_id = datasets.project_id").
 		Where("datasets.user_id = ? OR (user_projects.user_id = ? AND user_projects.deleted_at IS NULL)", userID, userID).
 		Group("datasets.id")
+	query = restrictTokenProjects(c, query, "datasets.project_id")
 
 	// 计算总数
 	query.Count(&total)
@@ -213,14 +218,6 @@ func ListDatasets(c *gin.Context) {
 // @Router /api/dataset/{id} [get]
 func GetDataset(c *gin.Context) {
 	id := c.Param("id")
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
 
 	var dataset model.Dataset
 	err := model.DB.
@@ -236,27 +233,6 @@ func GetDataset(c *gin.Context) {
 		return
 	}
 
-	// 验证访问权限
-	if dataset.UserID != uint(userID.(int)) {
-		// 检查是否为项目成员
-		if dataset.ProjectID != 0 {
-			var projectUser model.UserProject
-			if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err != nil {
-				c.JSON(http.StatusForbidden, gin.H{
-					"success": false,
-					"message": "您没有权限访问此数据集",
-				})
-				return
-			}
-		} else {
-			c.JSON(http.StatusForbidden, gin.H{
-				"success": false,
-				"message": "您没有权限访问此数据集",
-			})
-			return
-		}
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -295,15 +271,7 @@ func UpdateDataset(c *gin.Context) {
 		})
 		return
 	}
-
-	// 验证是否为创建者
-	if dataset.UserID != uint(userID.(int)) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "只有数据集创建者可以修改数据集信息",
-		})
-		return
-	}
+	audit := auditResource(c, model.ResourceDataset, dataset.ID, dataset.ProjectID, convertToDatasetDTO(dataset))
 
 	// 绑定输入
 	var input struct {
@@ -311,6 +279,8 @@ func UpdateDataset(c *gin.Context) {
 		Description      string `json:"description"`
 		ProjectID        uint   `json:"project_id"`
 		SchemaDefinition string `json:"schema_definition"`
+		// 每个条目需要的标注人数(1或2)，0 表示不修改
+		AnnotatorsRequired int `json:"annotators_required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -323,6 +293,9 @@ func UpdateDataset(c *gin.Context) {
 
 	// 验证项目权限
 	if input.ProjectID != 0 && input.ProjectID != dataset.ProjectID {
+		if !checkTokenProject(c, input.ProjectID) {
+			return
+		}
 		var project model.Project
 		if err := model.DB.First(&project, input.ProjectID).Error; err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -332,9 +305,10 @@ func UpdateDataset(c *gin.Context) {
 			return
 		}
 
-		// 验证用户是否为项目成员
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", input.ProjectID, userID).First(&projectUser).Error; err != nil {
+		// 移动到其他项目需要在目标项目中有创建权限
+		allowed, err := model.CheckProjectPermission(uint(userID.(int)), c.GetInt("role"),
+			&model.ResourceOwner{ProjectID: input.ProjectID}, model.ActionCreate)
+		if err != nil || !allowed {
 			c.JSON(http.StatusForbidden, gin.H{
 				"success": false,
 				"message": "您不是该项目的成员，无法关联此项目",
@@ -359,9 +333,27 @@ func UpdateDataset(c *gin.Context) {
 	}
 
 	if input.SchemaDefinition != "" {
+		if _, err := services.CompileDatasetSchema(dataset.TemplateType, input.SchemaDefinition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "数据集Schema无效: " + err.Error(),
+			})
+			return
+		}
 		updates["schema_definition"] = input.SchemaDefinition
 	}
 
+	if input.AnnotatorsRequired != 0 {
+		if input.AnnotatorsRequired < 1 || input.AnnotatorsRequired > maxAnnotatorsRequired {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("每个条目的标注人数须在1到%d之间", maxAnnotatorsRequired),
+			})
+			return
+		}
+		updates["annotators_required"] = input.AnnotatorsRequired
+	}
+
 	// 执行更新
 	if err := model.DB.Model(&dataset).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -376,6 +368,7 @@ func UpdateDataset(c *gin.Context) {
 		Preload("User").
 		Preload("Project").
 		First(&dataset, id)
+	audit.SetAfter(convertToDatasetDTO(dataset))
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -396,14 +389,6 @@ func UpdateDataset(c *gin.Context) {
 // @Router /api/dataset/{id} [delete]
 func DeleteDataset(c *gin.Context) {
 	id := c.Param("id")
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
 
 	// 先获取数据集
 	var dataset model.Dataset
@@ -414,15 +399,7 @@ func DeleteDataset(c *gin.Context) {
 		})
 		return
 	}
-
-	// 验证是否为创建者
-	if dataset.UserID != uint(userID.(int)) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "只有数据集创建者可以删除数据集",
-		})
-		return
-	}
+	auditResource(c, model.ResourceDataset, dataset.ID, dataset.ProjectID, convertToDatasetDTO(dataset))
 
 	// 开始事务
 	tx := model.DB.Begin()
@@ -436,6 +413,14 @@ func DeleteDataset(c *gin.Context) {
 		})
 		return
 	}
+	if err := tx.Where("dataset_id = ?", id).Delete(&model.DatasetShard{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除数据集分片失败: " + err.Error(),
+		})
+		return
+	}
 
 	// 删除数据集
 	if err := tx.Delete(&dataset).Error; err != nil {
@@ -456,14 +441,29 @@ func DeleteDataset(c *gin.Context) {
 		return
 	}
 
+	if err := model.DeleteDatasetSearchDocs(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+	if err := model.DeleteDatasetQualityReports(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+	if err := model.DeleteDatasetAnnotations(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+	if err := model.DeleteDatasetEntryRevisions(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+	// 派生数据集保留 Lineage 中的来源记录
+	if err := model.DB.Model(&model.Dataset{}).Where("parent_id = ?", dataset.ID).UpdateColumn("parent_id", nil).Error; err != nil {
+		common.SysError(err.Error())
+	}
+
 	// 如果使用MinIO存储，尝试删除MinIO中的对象
 	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
-		if dataset.BucketName != "" && dataset.ObjectPath != "" {
-			// 删除MinIO对象
-			if err := services.DeleteDatasetMinioObject(dataset.BucketName, dataset.ObjectPath); err != nil {
-				// 记录错误但不阻止处理
-				common.SysError(err.Error())
-			}
+		// 删除MinIO对象
+		if err := deleteMinioDataset(&dataset); err != nil {
+			// 记录错误但不阻止处理
+			common.SysError(err.Error())
 		}
 	}
 
@@ -475,20 +475,26 @@ func DeleteDataset(c *gin.Context) {
 
 // convertToDatasetDTO 将模型对象转换为DTO
 func convertToDatasetDTO(dataset model.Dataset) DatasetDTO {
-	return DatasetDTO{
-		ID:               dataset.ID,
-		Name:             dataset.Name,
-		Description:      dataset.Description,
-		StorageType:      dataset.StorageType,
-		TemplateType:     dataset.TemplateType,
-		EntryCount:       dataset.EntryCount,
-		TotalSize:        dataset.TotalSize,
-		ProjectID:        dataset.ProjectID,
-		UserID:           dataset.UserID,
-		SchemaDefinition: dataset.SchemaDefinition,
-		CreatedAt:        dataset.CreatedAt,
-		UpdatedAt:        dataset.UpdatedAt,
-	}
+	dto := DatasetDTO{
+		ID:                 dataset.ID,
+		Name:               dataset.Name,
+		Description:        dataset.Description,
+		StorageType:        dataset.StorageType,
+		TemplateType:       dataset.TemplateType,
+		EntryCount:         dataset.EntryCount,
+		TotalSize:          dataset.TotalSize,
+		ProjectID:          dataset.ProjectID,
+		UserID:             dataset.UserID,
+		SchemaDefinition:   dataset.SchemaDefinition,
+		ParentID:           dataset.ParentID,
+		AnnotatorsRequired: annotatorsRequired(&dataset),
+		CreatedAt:          dataset.CreatedAt,
+		UpdatedAt:          dataset.UpdatedAt,
+	}
+	if dataset.Lineage != "" {
+		dto.Lineage = json.RawMessage(dataset.Lineage)
+	}
+	return dto
 }
 
 // convertToDatasetDTOList 将模型对象列表转换为DTO列表
@@ -551,6 +557,7 @@ func SearchDatasets(c *gin.Context) {
 
 	// 去重
 	dbQuery = dbQuery.Group("datasets.id")
+	dbQuery = restrictTokenProjects(c, dbQuery, "datasets.project_id")
 
 	// 计算总数
 	dbQuery.Count(&total)
@@ -611,33 +618,6 @@ func GetProjectDatasets(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
-
-	// 验证用户是否为项目成员
-	var projectUser model.UserProject
-	if err := model.DB.Where("project_id = ? AND user_id = ?", projectID, userID).First(&projectUser).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusForbidden, gin.H{
-				"success": false,
-				"message": "您不是该项目的成员，无法访问项目数据集",
-			})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "验证项目成员失败: " + err.Error(),
-			})
-		}
-		return
-	}
-
 	var datasets []model.Dataset
 	var total int64
 
@@ -0,0 +1,779 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 领取后超过该时长仍未提交的标注任务会被释放，条目重新进入队列
+const annotationLeaseDuration = 2 * time.Hour
+
+// 同步标注记录时每批读取的条目数
+const annotationSyncBatchSize = 1000
+
+// 每个条目最多需要的标注人数
+const maxAnnotatorsRequired = 2
+
+// AnnotationSubmissionDTO 标注人领取的条目及其提交的结果
+type AnnotationSubmissionDTO struct {
+	UserID      uint                   `json:"user_id"`
+	Status      string                 `json:"status"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	AssignedAt  time.Time              `json:"assigned_at"`
+	SubmittedAt *time.Time             `json:"submitted_at,omitempty"`
+}
+
+// DatasetAnnotationDTO 条目的标注状态，详情接口同时返回条目内容和各标注人的结果
+type DatasetAnnotationDTO struct {
+	model.DatasetAnnotation
+	Entry       *DatasetEntryDTO          `json:"entry,omitempty"`
+	Submissions []AnnotationSubmissionDTO `json:"submissions,omitempty"`
+}
+
+// AnnotationTaskData 分配给标注人的条目
+type AnnotationTaskData struct {
+	Task  model.DatasetAnnotationTask `json:"task"`
+	Entry DatasetEntryDTO             `json:"entry"`
+}
+
+// DatasetAnnotationsListData 标注记录列表
+type DatasetAnnotationsListData struct {
+	Annotations []model.DatasetAnnotation `json:"annotations"`
+	PagedData
+}
+
+// DatasetAnnotationStatsData 数据集的标注进度和各标注人、审核人的工作量
+type DatasetAnnotationStatsData struct {
+	AnnotatorsRequired int                           `json:"annotators_required"`
+	Status             map[string]int64              `json:"status"`
+	Annotators         []model.DatasetAnnotatorStats `json:"annotators"`
+	Reviewers          []model.DatasetReviewerStats  `json:"reviewers"`
+}
+
+// AnnotationDisagreementDTO 多位标注人结果不一致的条目
+type AnnotationDisagreementDTO struct {
+	EntryIndex  int                       `json:"entry_index"`
+	Status      string                    `json:"status"`
+	Fields      []string                  `json:"fields"` // 取值不一致的字段
+	Submissions []AnnotationSubmissionDTO `json:"submissions"`
+}
+
+// AnnotationDisagreementsData 标注分歧列表和整体一致性
+type AnnotationDisagreementsData struct {
+	Disagreements []AnnotationDisagreementDTO  `json:"disagreements"`
+	Agreement     services.AnnotationAgreement `json:"agreement"`
+	PagedData
+}
+
+// ReviewAnnotationRequest 审核标注结果的请求
+type ReviewAnnotationRequest struct {
+	Decision string          `json:"decision" binding:"required"` // accept/reject
+	Comment  string          `json:"comment"`
+	UserID   uint            `json:"user_id"`                   // 采用该标注人提交的结果，各标注人结果一致时可以省略
+	Data     json.RawMessage `json:"data" swaggertype:"object"` // 审核人修改后的最终内容，优先于 user_id
+	Requeue  bool            `json:"requeue"`                   // 驳回后清除已有结果，将条目重新放回标注队列
+}
+
+// annotatorsRequired 返回数据集每个条目需要的标注人数
+func annotatorsRequired(dataset *model.Dataset) int {
+	if dataset.AnnotatorsRequired < 1 {
+		return 1
+	}
+	return dataset.AnnotatorsRequired
+}
+
+// acceptedEntryFilter acceptedOnly 为 true 时返回只保留标注审核通过条目的过滤函数，否则返回 nil
+func acceptedEntryFilter(dataset *model.Dataset, acceptedOnly bool) (func(int) bool, error) {
+	if !acceptedOnly {
+		return nil, nil
+	}
+	accepted, err := model.GetAcceptedAnnotationIndexes(dataset.ID)
+	if err != nil {
+		return nil, err
+	}
+	return func(entryIndex int) bool { return accepted[entryIndex] }, nil
+}
+
+// syncDatasetAnnotations 为还没有标注记录的条目创建 unlabeled 记录
+// MinIO存储只检查最后一条标注记录之后追加的行，数据库存储检查全部条目
+func syncDatasetAnnotations(dataset *model.Dataset) error {
+	if dataset.StorageType != "minio" {
+		for {
+			indexes, err := model.UnannotatedDatasetEntryIndexes(dataset.ID, annotationSyncBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(indexes) == 0 {
+				return nil
+			}
+			if err := model.CreateDatasetAnnotations(dataset.ID, indexes); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dataset.BucketName == "" || dataset.ObjectPath == "" {
+		return fmt.Errorf("数据集MinIO存储信息不完整")
+	}
+	last, err := model.MaxDatasetAnnotationIndex(dataset.ID)
+	if err != nil {
+		return err
+	}
+	shards, err := loadDatasetShards(dataset)
+	if err != nil {
+		return err
+	}
+	total := shardsLineCount(shards)
+	for offset := last + 1; offset < total; offset += annotationSyncBatchSize {
+		lines, err := readMinioDatasetLines(dataset, offset, annotationSyncBatchSize)
+		if err != nil {
+			return err
+		}
+		var indexes []int
+		for i, line := range lines {
+			if !isEmptyEntryLine(line) {
+				indexes = append(indexes, offset+i)
+			}
+		}
+		if err := model.CreateDatasetAnnotations(dataset.ID, indexes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertToAnnotationSubmissionDTO 将标注任务转换为DTO，未提交的任务不包含内容
+func convertToAnnotationSubmissionDTO(task model.DatasetAnnotationTask) AnnotationSubmissionDTO {
+	return AnnotationSubmissionDTO{
+		UserID:      task.UserID,
+		Status:      task.Status,
+		Data:        entryData(task.Content),
+		AssignedAt:  task.AssignedAt,
+		SubmittedAt: task.SubmittedAt,
+	}
+}
+
+// annotationEntryIndex 解析路径中的条目索引，失败时写入错误响应
+func annotationEntryIndex(c *gin.Context) (int, bool) {
+	entryIndex, err := strconv.Atoi(c.Param("entryIndex"))
+	if err != nil || entryIndex < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的条目索引",
+		})
+		return 0, false
+	}
+	return entryIndex, true
+}
+
+// annotationErrorStatus 返回标注操作错误对应的HTTP状态码
+func annotationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrAnnotationNotFound), errors.Is(err, model.ErrAnnotationTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrAnnotationClosed):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ClaimDatasetAnnotation 领取下一个待标注条目
+// @Summary 领取待标注条目
+// @Description 返回当前用户已领取未提交的条目，没有时按顺序分配下一个仍需标注的条目；
+// @Description 领取超过2小时未提交的条目会重新分配给其他标注人，队列为空时 data 为 null
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Success 200 {object} SuccessResponse{data=AnnotationTaskData}
+// @Router /api/dataset/{id}/annotation/next [post]
+func ClaimDatasetAnnotation(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	if err := syncDatasetAnnotations(dataset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "同步标注队列失败: " + err.Error(),
+		})
+		return
+	}
+
+	userID := uint(c.GetInt("user_id"))
+	for {
+		task, err := model.ClaimDatasetAnnotation(dataset.ID, userID, annotatorsRequired(dataset), time.Now().Add(-annotationLeaseDuration))
+		if errors.Is(err, model.ErrAnnotationQueueEmpty) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"message": "没有待标注的条目",
+				"data":    nil,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "领取标注任务失败: " + err.Error(),
+			})
+			return
+		}
+
+		entry, err := loadDatasetEntry(dataset, task.EntryIndex)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 条目已被删除，清除其标注记录后继续分配
+			if err := model.DeleteDatasetAnnotations(dataset.ID, task.EntryIndex); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "领取标注任务失败: " + err.Error(),
+				})
+				return
+			}
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "读取数据集条目失败: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data": AnnotationTaskData{
+				Task:  *task,
+				Entry: convertToDatasetEntryDTO(entry),
+			},
+		})
+		return
+	}
+}
+
+// SubmitDatasetAnnotation 提交标注结果
+// @Summary 提交标注结果
+// @Description 提交当前用户领取的条目的标注结果，请求体为完整的条目内容并按数据集Schema校验；
+// @Description 所需的标注人都已提交后条目进入审核(in_review)，审核前可以重复提交
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param entryIndex path int true "条目索引"
+// @Param entry body object true "条目内容"
+// @Success 200 {object} SuccessResponse{data=model.DatasetAnnotation}
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/dataset/{id}/annotation/entry/{entryIndex}/submit [post]
+func SubmitDatasetAnnotation(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	entryIndex, ok := annotationEntryIndex(c)
+	if !ok {
+		return
+	}
+	record, ok := bindDatasetRecord(c, dataset)
+	if !ok {
+		return
+	}
+
+	annotation, err := model.SubmitDatasetAnnotation(dataset.ID, entryIndex, uint(c.GetInt("user_id")),
+		record.RawContent, annotatorsRequired(dataset))
+	if err != nil {
+		message := "提交标注结果失败: " + err.Error()
+		switch {
+		case errors.Is(err, model.ErrAnnotationNotFound), errors.Is(err, model.ErrAnnotationTaskNotFound):
+			message = "没有领取该条目，或领取已过期"
+		case errors.Is(err, model.ErrAnnotationClosed):
+			message = "条目已审核，不能再提交"
+		}
+		c.JSON(annotationErrorStatus(err), gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "标注结果已提交",
+		"data":    annotation,
+	})
+}
+
+// GetDatasetAnnotation 获取条目的标注详情
+// @Summary 获取条目的标注详情
+// @Description 返回条目的标注状态、当前内容和各标注人提交的结果
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param entryIndex path int true "条目索引"
+// @Success 200 {object} SuccessResponse{data=DatasetAnnotationDTO}
+// @Failure 404 {object} ErrorResponse
+// @Router /api/dataset/{id}/annotation/entry/{entryIndex} [get]
+func GetDatasetAnnotation(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	entryIndex, ok := annotationEntryIndex(c)
+	if !ok {
+		return
+	}
+
+	annotation, err := model.GetDatasetAnnotation(dataset.ID, entryIndex)
+	if err != nil {
+		c.JSON(annotationErrorStatus(err), gin.H{
+			"success": false,
+			"message": "获取标注记录失败: " + err.Error(),
+		})
+		return
+	}
+	tasks, err := model.GetDatasetAnnotationTasks(dataset.ID, []int{entryIndex})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取标注结果失败: " + err.Error(),
+		})
+		return
+	}
+
+	dto := DatasetAnnotationDTO{DatasetAnnotation: *annotation}
+	if entry, err := loadDatasetEntry(dataset, entryIndex); err == nil {
+		entryDTO := convertToDatasetEntryDTO(entry)
+		dto.Entry = &entryDTO
+	}
+	for _, task := range tasks {
+		dto.Submissions = append(dto.Submissions, convertToAnnotationSubmissionDTO(task))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    dto,
+	})
+}
+
+// ReviewDatasetAnnotation 审核标注结果
+// @Summary 审核标注结果
+// @Description 审核处于 in_review 状态的条目。accept 时将采用的结果写入条目：优先使用 data，其次为 user_id 对应标注人的结果，
+// @Description 各标注人结果一致时可以都省略；reject 时 requeue 为 true 会清除已有结果并重新放回标注队列
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param entryIndex path int true "条目索引"
+// @Param request body ReviewAnnotationRequest true "审核结果"
+// @Success 200 {object} SuccessResponse{data=model.DatasetAnnotation}
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/dataset/{id}/annotation/entry/{entryIndex}/review [post]
+func ReviewDatasetAnnotation(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	entryIndex, ok := annotationEntryIndex(c)
+	if !ok {
+		return
+	}
+
+	var req ReviewAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.Decision != "accept" && req.Decision != "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "decision 必须为 accept 或 reject",
+		})
+		return
+	}
+
+	annotation, err := model.GetDatasetAnnotation(dataset.ID, entryIndex)
+	if err != nil {
+		c.JSON(annotationErrorStatus(err), gin.H{
+			"success": false,
+			"message": "获取标注记录失败: " + err.Error(),
+		})
+		return
+	}
+	if annotation.Status != model.AnnotationInReview {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "条目当前状态为 " + annotation.Status + "，不能审核",
+		})
+		return
+	}
+
+	reviewerID := uint(c.GetInt("user_id"))
+	if req.Decision == "reject" {
+		if err := model.ReviewDatasetAnnotation(annotation, model.AnnotationRejected, reviewerID, req.Comment, req.Requeue); err != nil {
+			c.JSON(annotationErrorStatus(err), gin.H{
+				"success": false,
+				"message": "审核失败: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "标注结果已驳回",
+			"data":    annotation,
+		})
+		return
+	}
+
+	content, ok := reviewedAnnotationContent(c, dataset, entryIndex, req)
+	if !ok {
+		return
+	}
+	record, ok := parseDatasetRecord(c, dataset, content)
+	if !ok {
+		return
+	}
+	if err := model.ReviewDatasetAnnotation(annotation, model.AnnotationAccepted, reviewerID, req.Comment, false); err != nil {
+		c.JSON(annotationErrorStatus(err), gin.H{
+			"success": false,
+			"message": "审核失败: " + err.Error(),
+		})
+		return
+	}
+	if _, err := saveDatasetEntry(dataset, entryIndex, record, reviewerID, ""); err != nil {
+		if restoreErr := model.RestoreDatasetAnnotationStatus(annotation); restoreErr != nil {
+			err = fmt.Errorf("%v; %v", err, restoreErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "写入标注结果失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "标注结果已通过",
+		"data":    annotation,
+	})
+}
+
+// reviewedAnnotationContent 返回审核通过时写入条目的内容，无法确定时写入错误响应
+func reviewedAnnotationContent(c *gin.Context, dataset *model.Dataset, entryIndex int, req ReviewAnnotationRequest) ([]byte, bool) {
+	if len(req.Data) > 0 && string(req.Data) != "null" {
+		return req.Data, true
+	}
+
+	tasks, err := model.GetDatasetAnnotationTasks(dataset.ID, []int{entryIndex})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取标注结果失败: " + err.Error(),
+		})
+		return nil, false
+	}
+	var submitted []model.DatasetAnnotationTask
+	var submissions []map[string]interface{}
+	for _, task := range tasks {
+		if task.Status != model.AnnotationTaskSubmitted {
+			continue
+		}
+		if req.UserID != 0 && task.UserID == req.UserID {
+			return []byte(task.Content), true
+		}
+		submitted = append(submitted, task)
+		submissions = append(submissions, entryData(task.Content))
+	}
+
+	if req.UserID != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("用户 %d 没有提交该条目的标注结果", req.UserID),
+		})
+		return nil, false
+	}
+	if len(submitted) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "条目没有已提交的标注结果，请在 data 中提供最终内容",
+		})
+		return nil, false
+	}
+	if diff := services.AnnotationDiff(submissions); len(diff) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "各标注人的结果不一致，请通过 user_id 指定采用的结果或在 data 中提供最终内容",
+			"data":    gin.H{"fields": diff},
+		})
+		return nil, false
+	}
+	return []byte(submitted[0].Content), true
+}
+
+// ListDatasetAnnotations 获取数据集的标注记录列表
+// @Summary 获取标注记录列表
+// @Description 按 entry_index 顺序分页返回条目的标注状态
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param status query string false "标注状态(unlabeled/in_review/accepted/rejected)"
+// @Param assignee_id query int false "领取过条目的标注人"
+// @Param reviewer_id query int false "审核人"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} SuccessResponse{data=DatasetAnnotationsListData}
+// @Router /api/dataset/{id}/annotations [get]
+func ListDatasetAnnotations(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	assigneeID, _ := strconv.Atoi(c.Query("assignee_id"))
+	reviewerID, _ := strconv.Atoi(c.Query("reviewer_id"))
+	filter := model.DatasetAnnotationFilter{
+		Status:     c.Query("status"),
+		AssigneeID: uint(assigneeID),
+		ReviewerID: uint(reviewerID),
+	}
+	if filter.Status != "" && !validAnnotationStatus(filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "不支持的标注状态: " + filter.Status,
+		})
+		return
+	}
+
+	if err := syncDatasetAnnotations(dataset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "同步标注队列失败: " + err.Error(),
+		})
+		return
+	}
+	annotations, total, err := model.ListDatasetAnnotations(dataset.ID, filter, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取标注记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": DatasetAnnotationsListData{
+			Annotations: annotations,
+			PagedData: PagedData{
+				Total: total,
+				Page:  page,
+				Limit: limit,
+			},
+		},
+	})
+}
+
+func validAnnotationStatus(status string) bool {
+	for _, s := range model.AnnotationStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDatasetAnnotationStats 获取标注进度和工作量统计
+// @Summary 获取标注统计
+// @Description 返回各状态的条目数，以及各标注人的领取、提交、通过、驳回数和平均耗时、各审核人的审核数；
+// @Description days 大于0时只统计最近若干天，标注人的 per_day 为平均每天提交数
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param days query int false "统计最近的天数，默认全部"
+// @Success 200 {object} SuccessResponse{data=DatasetAnnotationStatsData}
+// @Router /api/dataset/{id}/annotation/stats [get]
+func GetDatasetAnnotationStats(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "0"))
+	var since *time.Time
+	if days > 0 {
+		t := time.Now().AddDate(0, 0, -days)
+		since = &t
+	}
+
+	if err := syncDatasetAnnotations(dataset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "同步标注队列失败: " + err.Error(),
+		})
+		return
+	}
+	status, err := model.CountDatasetAnnotations(dataset.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取标注统计失败: " + err.Error(),
+		})
+		return
+	}
+	annotators, err := model.GetDatasetAnnotatorStats(dataset.ID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取标注统计失败: " + err.Error(),
+		})
+		return
+	}
+	reviewers, err := model.GetDatasetReviewerStats(dataset.ID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取标注统计失败: " + err.Error(),
+		})
+		return
+	}
+	if days > 0 {
+		for i := range annotators {
+			annotators[i].PerDay = float64(annotators[i].Submitted) / float64(days)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": DatasetAnnotationStatsData{
+			AnnotatorsRequired: annotatorsRequired(dataset),
+			Status:             status,
+			Annotators:         annotators,
+			Reviewers:          reviewers,
+		},
+	})
+}
+
+// GetDatasetAnnotationDisagreements 获取多人标注结果不一致的条目
+// @Summary 获取标注分歧
+// @Description 比较有多位标注人提交结果的条目，分页返回结果不一致的条目及各标注人的结果，并返回整体一致率
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param status query string false "只比较该标注状态的条目，默认全部"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} SuccessResponse{data=AnnotationDisagreementsData}
+// @Router /api/dataset/{id}/annotation/disagreements [get]
+func GetDatasetAnnotationDisagreements(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	status := c.Query("status")
+	if status != "" && !validAnnotationStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "不支持的标注状态: " + status,
+		})
+		return
+	}
+
+	// 一致率需要比较全部多人标注的条目，分批读取，只保留当前页的详细结果
+	offset := (page - 1) * limit
+	data := AnnotationDisagreementsData{
+		Disagreements: []AnnotationDisagreementDTO{},
+		PagedData:     PagedData{Page: page, Limit: limit},
+	}
+	for after := -1; ; {
+		indexes, err := model.MultiAnnotatedEntryIndexes(dataset.ID, status, after, annotationSyncBatchSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "获取标注结果失败: " + err.Error(),
+			})
+			return
+		}
+		if len(indexes) == 0 {
+			break
+		}
+		after = indexes[len(indexes)-1]
+
+		tasks, err := model.GetDatasetAnnotationTasks(dataset.ID, indexes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "获取标注结果失败: " + err.Error(),
+			})
+			return
+		}
+		byEntry := map[int][]model.DatasetAnnotationTask{}
+		for _, task := range tasks {
+			if task.Status == model.AnnotationTaskSubmitted {
+				byEntry[task.EntryIndex] = append(byEntry[task.EntryIndex], task)
+			}
+		}
+
+		for _, index := range indexes {
+			submitted := byEntry[index]
+			submissions := make([]map[string]interface{}, len(submitted))
+			for i, task := range submitted {
+				submissions[i] = entryData(task.Content)
+			}
+			diff := services.AnnotationDiff(submissions)
+			data.Agreement.Add(diff)
+			if len(diff) == 0 {
+				continue
+			}
+
+			if data.Total >= int64(offset) && len(data.Disagreements) < limit {
+				disagreement := AnnotationDisagreementDTO{EntryIndex: index, Fields: diff}
+				for _, task := range submitted {
+					disagreement.Submissions = append(disagreement.Submissions, convertToAnnotationSubmissionDTO(task))
+				}
+				data.Disagreements = append(data.Disagreements, disagreement)
+			}
+			data.Total++
+		}
+	}
+
+	for i := range data.Disagreements {
+		if annotation, err := model.GetDatasetAnnotation(dataset.ID, data.Disagreements[i].EntryIndex); err == nil {
+			data.Disagreements[i].Status = annotation.Status
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
@@ -0,0 +1,500 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 切分和筛选在后台任务中生成新的数据集，新数据集沿用来源数据集的存储方式、模板和 Schema，
+// ParentID/Lineage 记录来源和生成参数。任务失败或取消时删除已创建的数据集
+
+// SplitDatasetRequest 数据集切分请求
+type SplitDatasetRequest struct {
+	Splits     []services.DatasetSplit `json:"splits" binding:"required"` // 如 train 0.8 / validation 0.1 / test 0.1
+	Seed       int64                   `json:"seed"`
+	Stratify   string                  `json:"stratify"`    // 分层字段：instruction/input/output 或原始记录中的字段名
+	NamePrefix string                  `json:"name_prefix"` // 新数据集名称前缀，默认为来源数据集名称
+}
+
+// SubsetDatasetRequest 按检索条件筛选数据集的请求
+type SubsetDatasetRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Query string `json:"q" binding:"required"` // 检索语句，语法同 GET /api/dataset/{id}/entries 的 q
+	Field string `json:"field"`                // 未限定字段的检索词所在字段，默认 all
+}
+
+// DerivedDatasetsData 派生任务及其生成的数据集
+type DerivedDatasetsData struct {
+	Job      DatasetJobDTO `json:"job"`
+	Datasets []DatasetDTO  `json:"datasets"`
+}
+
+// datasetEntryWriter 向新数据集顺序写入条目，entry_index 从 0 开始连续分配
+// 数据库存储分批写入，MinIO存储先写入临时文件，Close 时一次追加为分片
+type datasetEntryWriter struct {
+	dataset     *model.Dataset
+	saveToDB    bool
+	saveToMinio bool
+	batch       []model.DatasetEntry
+	staging     *os.File
+	count       int64
+	size        int64
+}
+
+func newDatasetEntryWriter(dataset *model.Dataset) (*datasetEntryWriter, error) {
+	w := &datasetEntryWriter{
+		dataset:     dataset,
+		saveToDB:    dataset.StorageType == "database" || dataset.StorageType == "both",
+		saveToMinio: dataset.StorageType == "minio" || dataset.StorageType == "both",
+		batch:       make([]model.DatasetEntry, 0, datasetImportBatchSize),
+	}
+	if w.saveToMinio {
+		staging, err := os.CreateTemp("", "dataset_derive_*.jsonl")
+		if err != nil {
+			return nil, fmt.Errorf("创建临时文件失败: %v", err)
+		}
+		w.staging = staging
+	}
+	return w, nil
+}
+
+// Write 写入一个条目，条目原有的ID和 entry_index 被忽略
+func (w *datasetEntryWriter) Write(entry model.DatasetEntry) error {
+	line, err := entryToLine(entry)
+	if err != nil {
+		return err
+	}
+	if w.staging != nil {
+		if err := writeCompactLine(w.staging, line); err != nil {
+			return fmt.Errorf("写入临时文件失败: %v", err)
+		}
+	}
+	w.batch = append(w.batch, model.DatasetEntry{
+		DatasetID:   w.dataset.ID,
+		EntryIndex:  int(w.count),
+		Instruction: entry.Instruction,
+		Input:       entry.Input,
+		Output:      entry.Output,
+		RawContent:  line,
+	})
+	w.count++
+	w.size += int64(len(line) + 1)
+	if len(w.batch) == datasetImportBatchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *datasetEntryWriter) flush() error {
+	if len(w.batch) > 0 && w.saveToDB {
+		if err := model.DB.CreateInBatches(&w.batch, datasetImportBatchSize).Error; err != nil {
+			return fmt.Errorf("保存到数据库失败: %v", err)
+		}
+	}
+	w.batch = w.batch[:0]
+	return nil
+}
+
+// Close 写入剩余条目和MinIO分片，更新数据集的条目数和大小并建立检索索引
+func (w *datasetEntryWriter) Close() error {
+	defer w.Abort()
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.staging != nil && w.count > 0 {
+		if _, err := appendImportToMinio(w.dataset, w.staging); err != nil {
+			return err
+		}
+	}
+
+	err := model.DB.Model(w.dataset).UpdateColumns(map[string]interface{}{
+		"entry_count": w.count,
+		"total_size":  w.size,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if err := rebuildDatasetSearchIndex(w.dataset); err != nil {
+		common.SysError(fmt.Sprintf("failed to rebuild search index of dataset %d: %v", w.dataset.ID, err))
+	}
+	return nil
+}
+
+// Abort 删除临时文件，已写入数据库的条目由调用方随数据集一起删除
+func (w *datasetEntryWriter) Abort() {
+	if w.staging != nil {
+		w.staging.Close()
+		os.Remove(w.staging.Name())
+		w.staging = nil
+	}
+}
+
+// removeDerivedDataset 删除生成失败的派生数据集及其条目、分片和检索文档
+func removeDerivedDataset(datasetID uint) {
+	var dataset model.Dataset
+	if err := model.DB.First(&dataset, datasetID).Error; err != nil {
+		return
+	}
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dataset_id = ?", dataset.ID).Delete(&model.DatasetEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dataset_id = ?", dataset.ID).Delete(&model.DatasetShard{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&dataset).Error
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to remove derived dataset %d: %v", dataset.ID, err))
+		return
+	}
+	if err := model.DeleteDatasetSearchDocs(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
+		if err := deleteMinioDataset(&dataset); err != nil {
+			common.SysError(err.Error())
+		}
+	}
+}
+
+// createDerivedDatasets 创建派生任务和空的派生数据集，任务未成功结束时删除这些数据集
+func createDerivedDatasets(c *gin.Context, parent *model.Dataset, jobType, description string, names, splits []string, params interface{}) (*model.DatasetJob, []model.Dataset, error) {
+	job := newDatasetJob(c, parent, jobType)
+	job.Total = parent.EntryCount
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	children := make([]model.Dataset, len(names))
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		for i, name := range names {
+			children[i] = model.Dataset{
+				Name:             name,
+				Description:      description,
+				StorageType:      parent.StorageType,
+				TemplateType:     parent.TemplateType,
+				SchemaDefinition: parent.SchemaDefinition,
+				ProjectID:        parent.ProjectID,
+				UserID:           job.UserID,
+			}
+			children[i].SetLineage(model.DatasetLineage{
+				Operation: jobType,
+				ParentID:  parent.ID,
+				JobID:     job.ID,
+				Split:     splits[i],
+				Params:    paramsJSON,
+			})
+			if err := tx.Create(&children[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range children {
+		initDatasetStorage(&children[i])
+	}
+	auditResource(c, model.ResourceDatasetJob, job.ID, job.ProjectID, nil).
+		SetAfter(gin.H{"job": job, "datasets": convertToDatasetDTOList(children)})
+	return job, children, nil
+}
+
+// cleanupDerivedDatasets 返回任务结束后的清理函数，任务未成功时删除派生数据集
+func cleanupDerivedDatasets(jobID uint, children []model.Dataset) func() {
+	return func() {
+		job, err := model.GetDatasetJob(jobID)
+		if err == nil && job.Status == model.DatasetJobSucceeded {
+			return
+		}
+		for _, child := range children {
+			removeDerivedDataset(child.ID)
+		}
+	}
+}
+
+func respondDerivedDatasets(c *gin.Context, message string, job *model.DatasetJob, children []model.Dataset) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data": DerivedDatasetsData{
+			Job:      convertToDatasetJobDTO(job),
+			Datasets: convertToDatasetDTOList(children),
+		},
+	})
+}
+
+// entryStratum 返回条目在分层字段上的取值，非字符串的值按JSON表示
+func entryStratum(entry model.DatasetEntry, field string) string {
+	if value, ok := entrySearchFields(entry)[field]; ok {
+		return value
+	}
+	fields, err := services.DecodeDatasetRecord([]byte(entry.RawContent))
+	if err != nil {
+		return ""
+	}
+	switch value := fields[field].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+}
+
+// runDatasetSplit 第一遍读取全部 entry_index 和分层取值并分配子集，第二遍按分配结果写入各数据集
+func runDatasetSplit(parentID uint, req SplitDatasetRequest, children []model.Dataset) datasetJobFunc {
+	return func(ctx context.Context, job *model.DatasetJob) (map[string]interface{}, error) {
+		var parent model.Dataset
+		if err := model.DB.First(&parent, parentID).Error; err != nil {
+			return nil, fmt.Errorf("数据集不存在: %v", err)
+		}
+
+		var indexes []int
+		var strata []string
+		err := forEachDatasetEntry(&parent, func(entry model.DatasetEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			indexes = append(indexes, entry.EntryIndex)
+			if req.Stratify != "" {
+				strata = append(strata, entryStratum(entry, req.Stratify))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, derivedReadError(ctx, err)
+		}
+
+		assignment := make(map[int]int, len(indexes))
+		for split, members := range services.AssignSplits(indexes, strata, req.Splits, req.Seed) {
+			for _, index := range members {
+				assignment[index] = split
+			}
+		}
+
+		writers := make([]*datasetEntryWriter, len(children))
+		defer func() {
+			for _, w := range writers {
+				if w != nil {
+					w.Abort()
+				}
+			}
+		}()
+		for i := range children {
+			if writers[i], err = newDatasetEntryWriter(&children[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		var processed int64
+		err = forEachDatasetEntry(&parent, func(entry model.DatasetEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// 第一遍之后新增的条目不参与切分
+			split, ok := assignment[entry.EntryIndex]
+			if !ok {
+				return nil
+			}
+			if err := writers[split].Write(entry); err != nil {
+				return err
+			}
+			processed++
+			if processed%datasetExportProgressInterval == 0 {
+				return model.UpdateDatasetJobProgress(job.ID, processed, processed)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, derivedReadError(ctx, err)
+		}
+
+		counts := make([]string, len(children))
+		for i, w := range writers {
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			counts[i] = fmt.Sprintf("%s %d条", req.Splits[i].Name, w.count)
+		}
+		return map[string]interface{}{
+			"processed": processed,
+			"succeeded": processed,
+			"message":   "切分完成: " + strings.Join(counts, "，"),
+		}, nil
+	}
+}
+
+// runDatasetSubset 将满足检索条件的条目写入新数据集，按与无索引检索相同的子串匹配逐条判断
+func runDatasetSubset(parentID uint, query *services.SearchQuery, child *model.Dataset) datasetJobFunc {
+	return func(ctx context.Context, job *model.DatasetJob) (map[string]interface{}, error) {
+		var parent model.Dataset
+		if err := model.DB.First(&parent, parentID).Error; err != nil {
+			return nil, fmt.Errorf("数据集不存在: %v", err)
+		}
+		w, err := newDatasetEntryWriter(child)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Abort()
+
+		var processed int64
+		err = forEachDatasetEntry(&parent, func(entry model.DatasetEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			processed++
+			if processed%datasetExportProgressInterval == 0 {
+				if err := model.UpdateDatasetJobProgress(job.ID, processed, w.count); err != nil {
+					return err
+				}
+			}
+			if !query.Match(entrySearchFields(entry)) {
+				return nil
+			}
+			return w.Write(entry)
+		})
+		if err != nil {
+			return nil, derivedReadError(ctx, err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"processed": processed,
+			"succeeded": w.count,
+			"message":   fmt.Sprintf("筛选完成: %d条中%d条满足条件", processed, w.count),
+		}, nil
+	}
+}
+
+func derivedReadError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("生成数据集失败: %v", err)
+}
+
+// SplitDataset 切分数据集
+// @Summary 切分数据集
+// @Description 按比例将数据集随机切分为多个新数据集(如 train/validation/test)，同一 seed 在数据不变时结果相同
+// @Description 指定 stratify 时按该字段分层抽样；切分在后台进行，通过 GET /api/dataset/jobs/{id} 查询进度
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param request body SplitDatasetRequest true "切分参数"
+// @Success 200 {object} SuccessResponse{data=DerivedDatasetsData}
+// @Failure 400 {object} ErrorResponse
+// @Router /api/dataset/{id}/split [post]
+func SplitDataset(c *gin.Context) {
+	parent, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req SplitDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err := services.ValidateSplits(req.Splits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.NamePrefix == "" {
+		req.NamePrefix = parent.Name
+	}
+
+	names := make([]string, len(req.Splits))
+	splits := make([]string, len(req.Splits))
+	for i, split := range req.Splits {
+		names[i] = req.NamePrefix + "-" + split.Name
+		splits[i] = split.Name
+	}
+	job, children, err := createDerivedDatasets(c, parent, model.DatasetJobSplit,
+		fmt.Sprintf("由数据集「%s」切分生成", parent.Name), names, splits, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "创建切分任务失败: " + err.Error(),
+		})
+		return
+	}
+	startDatasetJob(job, runDatasetSplit(parent.ID, req, children), cleanupDerivedDatasets(job.ID, children))
+	respondDerivedDatasets(c, "切分任务已创建", job, children)
+}
+
+// SubsetDataset 按检索条件筛选生成新数据集
+// @Summary 筛选数据集
+// @Description 将满足检索语句的条目复制到新数据集，检索语法同条目列表的 q 参数；筛选在后台进行
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param request body SubsetDatasetRequest true "筛选参数"
+// @Success 200 {object} SuccessResponse{data=DerivedDatasetsData}
+// @Failure 400 {object} ErrorResponse
+// @Router /api/dataset/{id}/subset [post]
+func SubsetDataset(c *gin.Context) {
+	parent, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req SubsetDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.Field == "" {
+		req.Field = "all"
+	}
+	query, err := services.ParseSearchQuery(req.Query, req.Field)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "检索语句错误: " + err.Error(),
+		})
+		return
+	}
+
+	job, children, err := createDerivedDatasets(c, parent, model.DatasetJobSubset,
+		fmt.Sprintf("由数据集「%s」按「%s」筛选生成", parent.Name, req.Query), []string{req.Name}, []string{""}, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "创建筛选任务失败: " + err.Error(),
+		})
+		return
+	}
+	startDatasetJob(job, runDatasetSubset(parent.ID, query, &children[0]), cleanupDerivedDatasets(job.ID, children))
+	respondDerivedDatasets(c, "筛选任务已创建", job, children)
+}
@@ -3,10 +3,14 @@ package controller
 import (
 	"MLcore-Engine/model"
 	"MLcore-Engine/services"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,22 +18,23 @@ import (
 
 // GetDatasetEntries 获取数据集条目列表
 // @Summary 获取数据集条目列表
-// @Description 获取指定数据集的条目列表
+// @Description 获取指定数据集的条目列表，q 不为空时使用全文索引检索并返回高亮片段
+// @Description q 支持短语("a b")、OR、排除(-word 或 NOT word)和字段限定(instruction:/input:/output:)
 // @Tags Dataset
 // @Accept json
 // @Produce json
 // @Param id path int true "数据集ID"
 // @Param page query int false "页码"
 // @Param limit query int false "每页数量"
-// @Param q query string false "搜索关键词"
-// @Param field query string false "搜索字段(instruction/input/output/all)"
+// @Param q query string false "检索语句"
+// @Param field query string false "未限定字段的检索词所在字段(instruction/input/output/all)"
 // @Success 200 {object} DatasetEntriesResponse
 // @Router /api/dataset/{id}/entries [get]
 func GetDatasetEntries(c *gin.Context) {
 	id := c.Param("id")
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	query := c.Query("q")
+	query := strings.TrimSpace(c.Query("q"))
 	field := c.DefaultQuery("field", "all")
 
 	if page < 1 {
@@ -41,37 +46,60 @@ func GetDatasetEntries(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
+	// 获取数据集
+	var dataset model.Dataset
+	if err := model.DB.First(&dataset, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
-			"message": "未授权访问",
+			"message": "数据集不存在",
 		})
 		return
 	}
 
-	// 获取数据集并验证权限
-	var dataset model.Dataset
-	if err := model.DB.First(&dataset, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+	if dataset.StorageType == "minio" && (dataset.BucketName == "" || dataset.ObjectPath == "") {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "数据集不存在",
+			"message": "数据集MinIO存储信息不完整",
 		})
 		return
 	}
 
-	// 验证访问权限
-	if dataset.UserID != uint(userID.(int)) && dataset.ProjectID != 0 {
-		// 检查是否为项目成员
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{
+	// 全文检索
+	if query != "" {
+		searchQuery, err := services.ParseSearchQuery(query, field)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"message": "您没有权限访问此数据集",
+				"message": "检索语句错误: " + err.Error(),
 			})
 			return
 		}
+		entries, total, err := searchDatasetEntries(&dataset, searchQuery, offset, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "检索数据集条目失败: " + err.Error(),
+			})
+			return
+		}
+
+		dtos := convertToDatasetEntryDTOList(entries)
+		for i := range dtos {
+			dtos[i].Highlights = searchQuery.Highlights(entrySearchFields(entries[i]))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data": DatasetEntriesListData{
+				Entries: dtos,
+				PagedData: PagedData{
+					Total: total,
+					Page:  page,
+					Limit: limit,
+				},
+			},
+		})
+		return
 	}
 
 	// 根据存储类型获取数据
@@ -82,22 +110,6 @@ func GetDatasetEntries(c *gin.Context) {
 		// 从数据库获取
 		dbQuery := model.DB.Model(&model.DatasetEntry{}).Where("dataset_id = ?", id)
 
-		// 添加搜索条件
-		if query != "" {
-			searchQuery := "%" + query + "%"
-			switch field {
-			case "instruction":
-				dbQuery = dbQuery.Where("instruction LIKE ?", searchQuery)
-			case "input":
-				dbQuery = dbQuery.Where("input LIKE ?", searchQuery)
-			case "output":
-				dbQuery = dbQuery.Where("output LIKE ?", searchQuery)
-			default:
-				dbQuery = dbQuery.Where("instruction LIKE ? OR input LIKE ? OR output LIKE ?",
-					searchQuery, searchQuery, searchQuery)
-			}
-		}
-
 		// 计算总数
 		dbQuery.Count(&total)
 
@@ -120,7 +132,7 @@ func GetDatasetEntries(c *gin.Context) {
 		}
 
 		// 读取JSONL行
-		lines, err := services.ReadJSONLFromMinio(dataset.BucketName, dataset.ObjectPath, offset, limit)
+		lines, err := readMinioDatasetLines(&dataset, offset, limit)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
@@ -130,50 +142,18 @@ func GetDatasetEntries(c *gin.Context) {
 		}
 
 		// 解析为条目
+		schema, _ := datasetSchema(&dataset)
 		entries = make([]model.DatasetEntry, 0, len(lines))
 		for i, line := range lines {
-			if line == "" {
+			if isEmptyEntryLine(line) {
 				continue
 			}
 
-			var data map[string]string
-			if err := json.Unmarshal([]byte(line), &data); err != nil {
+			entry, err := entryFromLine(schema, dataset.ID, offset+i, line)
+			if err != nil {
 				continue
 			}
 
-			entry := model.DatasetEntry{
-				DatasetID:   dataset.ID,
-				EntryIndex:  offset + i,
-				Instruction: data["instruction"],
-				Input:       data["input"],
-				Output:      data["output"],
-				RawContent:  line,
-			}
-
-			// 应用搜索过滤
-			if query != "" {
-				switch field {
-				case "instruction":
-					if !contains(entry.Instruction, query) {
-						continue
-					}
-				case "input":
-					if !contains(entry.Input, query) {
-						continue
-					}
-				case "output":
-					if !contains(entry.Output, query) {
-						continue
-					}
-				default:
-					if !contains(entry.Instruction, query) &&
-						!contains(entry.Input, query) &&
-						!contains(entry.Output, query) {
-						continue
-					}
-				}
-			}
-
 			entries = append(entries, entry)
 		}
 
@@ -214,17 +194,7 @@ func GetDatasetEntry(c *gin.Context) {
 	id := c.Param("id")
 	entryID := c.Param("entryId")
 
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
-
-	// 获取数据集并验证权限
+	// 获取数据集
 	var dataset model.Dataset
 	if err := model.DB.First(&dataset, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -234,19 +204,6 @@ func GetDatasetEntry(c *gin.Context) {
 		return
 	}
 
-	// 验证访问权限
-	if dataset.UserID != uint(userID.(int)) && dataset.ProjectID != 0 {
-		// 检查是否为项目成员
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{
-				"success": false,
-				"message": "您没有权限访问此数据集",
-			})
-			return
-		}
-	}
-
 	// 根据存储类型获取条目
 	var entry model.DatasetEntry
 
@@ -291,7 +248,7 @@ func GetDatasetEntry(c *gin.Context) {
 		}
 
 		// 读取单行
-		lines, err := services.ReadJSONLFromMinio(dataset.BucketName, dataset.ObjectPath, entryIndex, 1)
+		lines, err := readMinioDatasetLines(&dataset, entryIndex, 1)
 		if err != nil || len(lines) == 0 {
 			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
@@ -300,30 +257,29 @@ func GetDatasetEntry(c *gin.Context) {
 			return
 		}
 
-		line := lines[0]
-		var data map[string]string
-		if err := json.Unmarshal([]byte(line), &data); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+		if isEmptyEntryLine(lines[0]) {
+			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
-				"message": "解析数据集条目失败: " + err.Error(),
+				"message": "数据集条目不存在",
 			})
 			return
 		}
 
-		entry = model.DatasetEntry{
-			DatasetID:   dataset.ID,
-			EntryIndex:  entryIndex,
-			Instruction: data["instruction"],
-			Input:       data["input"],
-			Output:      data["output"],
-			RawContent:  line,
+		schema, _ := datasetSchema(&dataset)
+		entry, err = entryFromLine(schema, dataset.ID, entryIndex, lines[0])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "解析数据集条目失败: " + err.Error(),
+			})
+			return
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    entry,
+		"data":    convertToDatasetEntryDTO(entry),
 	})
 }
 
@@ -340,17 +296,7 @@ func GetDatasetEntry(c *gin.Context) {
 func CreateDatasetEntry(c *gin.Context) {
 	id := c.Param("id")
 
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
-
-	// 获取数据集并验证权限
+	// 获取数据集
 	var dataset model.Dataset
 	if err := model.DB.First(&dataset, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -360,123 +306,26 @@ func CreateDatasetEntry(c *gin.Context) {
 		return
 	}
 
-	// 验证编辑权限
-	hasEditPermission := false
-	if dataset.UserID == uint(userID.(int)) {
-		hasEditPermission = true
-	} else if dataset.ProjectID != 0 {
-		// 检查是否为项目成员且有编辑权限
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err == nil {
-			// 假设角色值大于1拥有编辑权限
-			if projectUser.Role > 1 {
-				hasEditPermission = true
-			}
-		}
-	}
-
-	if !hasEditPermission {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "您没有权限编辑此数据集",
-		})
-		return
-	}
-
-	// 绑定输入
-	var input struct {
-		Instruction string `json:"instruction" binding:"required"`
-		Input       string `json:"input"`
-		Output      string `json:"output"`
-	}
-
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "参数错误: " + err.Error(),
-		})
+	// 绑定并校验输入
+	record, ok := bindDatasetRecord(c, &dataset)
+	if !ok {
 		return
 	}
 
-	// 准备新条目
-	entry := model.DatasetEntry{
-		DatasetID:   dataset.ID,
-		Instruction: input.Instruction,
-		Input:       input.Input,
-		Output:      input.Output,
-	}
-
-	// 创建JSON字符串
-	jsonData := map[string]string{
-		"instruction": input.Instruction,
-		"input":       input.Input,
-		"output":      input.Output,
-	}
-	jsonBytes, err := json.Marshal(jsonData)
+	// 追加到末尾，分配索引到写入完成之间持有条目锁，避免与导入、批量修改等并发操作分配到相同的索引
+	entry, err := saveDatasetEntry(&dataset, appendEntryIndex, record, uint(c.GetInt("user_id")), model.EntryRevisionCreate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "生成JSON数据失败: " + err.Error(),
+			"message": err.Error(),
 		})
 		return
 	}
-	entry.RawContent = string(jsonBytes)
-
-	// 根据存储类型保存
-	if dataset.StorageType == "database" || dataset.StorageType == "both" {
-		// 获取最大索引
-		var maxIndex struct {
-			MaxIndex int
-		}
-		model.DB.Model(&model.DatasetEntry{}).
-			Select("COALESCE(MAX(entry_index), -1) as max_index").
-			Where("dataset_id = ?", dataset.ID).
-			Scan(&maxIndex)
-
-		entry.EntryIndex = maxIndex.MaxIndex + 1
-
-		// 保存到数据库
-		if err := model.DB.Create(&entry).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "创建数据集条目失败: " + err.Error(),
-			})
-			return
-		}
-	}
-
-	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
-		// 保存到MinIO
-		if dataset.BucketName == "" || dataset.ObjectPath == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "数据集MinIO存储信息不完整",
-			})
-			return
-		}
-
-		// 追加到JSONL文件
-		if err := services.AppendJSONLToMinio(dataset.BucketName, dataset.ObjectPath, string(jsonBytes)); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "保存到MinIO失败: " + err.Error(),
-			})
-			return
-		}
-
-		// 如果是仅MinIO模式，则设置条目索引
-		if dataset.StorageType == "minio" {
-			entry.EntryIndex = int(dataset.EntryCount)
-		}
-	}
-
-	// 更新数据集条目计数
-	model.DB.Model(&dataset).Update("entry_count", gorm.Expr("entry_count + 1"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "数据集条目创建成功",
-		"data":    entry,
+		"data":    convertToDatasetEntryDTO(entry),
 	})
 }
 
@@ -493,19 +342,8 @@ func CreateDatasetEntry(c *gin.Context) {
 // @Router /api/dataset/{id}/entry/{entryId} [put]
 func UpdateDatasetEntry(c *gin.Context) {
 	id := c.Param("id")
-	entryID := c.Param("entryId")
 
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
-
-	// 获取数据集并验证权限
+	// 获取数据集
 	var dataset model.Dataset
 	if err := model.DB.First(&dataset, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -515,145 +353,257 @@ func UpdateDatasetEntry(c *gin.Context) {
 		return
 	}
 
-	// 验证编辑权限
-	hasEditPermission := false
-	if dataset.UserID == uint(userID.(int)) {
-		hasEditPermission = true
-	} else if dataset.ProjectID != 0 {
-		// 检查是否为项目成员且有编辑权限
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err == nil {
-			// 假设角色值大于1拥有编辑权限
-			if projectUser.Role > 1 {
-				hasEditPermission = true
-			}
-		}
-	}
-
-	if !hasEditPermission {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "您没有权限编辑此数据集",
-		})
+	// 绑定并校验输入
+	record, ok := bindDatasetRecord(c, &dataset)
+	if !ok {
 		return
 	}
 
-	// 绑定输入
-	var input struct {
-		Instruction string `json:"instruction" binding:"required"`
-		Input       string `json:"input"`
-		Output      string `json:"output"`
-	}
-
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "参数错误: " + err.Error(),
-		})
+	entryID, ok := parseEntryID(c)
+	if !ok {
 		return
 	}
 
-	// 转换为JSON
-	jsonData := map[string]string{
-		"instruction": input.Instruction,
-		"input":       input.Input,
-		"output":      input.Output,
-	}
-	jsonBytes, err := json.Marshal(jsonData)
+	// 持有条目锁后再解析条目ID，批量删除不会在解析和写入之间平移 entry_index
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	entryIndex := lookupEntryIndex(&dataset, entryID)
+	updated, err := writeDatasetEntry(&dataset, entryIndex, record, model.DatasetEntryRevision{UserID: uint(c.GetInt("user_id"))})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "生成JSON数据失败: " + err.Error(),
+			"message": err.Error(),
 		})
 		return
 	}
-	rawContent := string(jsonBytes)
 
-	// 获取条目索引
-	entryIndex, err := strconv.Atoi(entryID)
-	if err != nil {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "数据集条目更新成功",
+		"data":    convertToDatasetEntryDTO(updated),
+	})
+}
+
+// parseEntryID 解析路径中的 entryId，失败时写入错误响应
+func parseEntryID(c *gin.Context) (int, bool) {
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil || entryID < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "无效的条目ID",
 		})
-		return
+		return 0, false
 	}
+	return entryID, true
+}
 
-	// 根据存储类型更新
-	if dataset.StorageType == "database" || dataset.StorageType == "both" {
-		// 尝试查找现有条目
+// lookupEntryIndex 将条目ID转换为 entry_index，数据库存储中优先按条目ID查找，找不到时视为条目索引
+// 批量删除会平移 entry_index，修改条目时须持有条目锁后再转换
+func lookupEntryIndex(dataset *model.Dataset, entryID int) int {
+	if dataset.StorageType != "minio" {
 		var entry model.DatasetEntry
-		result := model.DB.Where("dataset_id = ? AND (id = ? OR entry_index = ?)", dataset.ID, entryIndex, entryIndex).First(&entry)
-
-		if result.Error != nil {
-			// 条目不存在，创建新条目
-			entry = model.DatasetEntry{
-				DatasetID:   dataset.ID,
-				EntryIndex:  entryIndex,
-				Instruction: input.Instruction,
-				Input:       input.Input,
-				Output:      input.Output,
-				RawContent:  rawContent,
-			}
+		result := model.DB.Select("entry_index").Where("dataset_id = ? AND id = ?", dataset.ID, entryID).Limit(1).Find(&entry)
+		if result.Error == nil && result.RowsAffected > 0 {
+			return entry.EntryIndex
+		}
+	}
+	return entryID
+}
+
+// resolveEntryIndex 解析路径中的 entryId 并转换为 entry_index，只用于读取
+func resolveEntryIndex(c *gin.Context, dataset *model.Dataset) (int, bool) {
+	entryID, ok := parseEntryID(c)
+	if !ok {
+		return 0, false
+	}
+	return lookupEntryIndex(dataset, entryID), true
+}
 
-			if err := model.DB.Create(&entry).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"message": "创建数据集条目失败: " + err.Error(),
-				})
-				return
+// appendEntryIndex 作为 saveDatasetEntry 的 entryIndex 时表示追加到数据集末尾
+const appendEntryIndex = -1
+
+// errDatasetEntryExists 恢复条目时原来的位置已有条目
+var errDatasetEntryExists = errors.New("该位置已有条目，无法恢复")
+
+// saveDatasetEntry 持有条目锁写入 entry_index 处的条目，见 writeDatasetEntry
+func saveDatasetEntry(dataset *model.Dataset, entryIndex int, record services.ImportRecord, editorID uint, action string) (model.DatasetEntry, error) {
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	return writeDatasetEntry(dataset, entryIndex, record, model.DatasetEntryRevision{Action: action, UserID: editorID})
+}
+
+// writeDatasetEntry 按存储类型写入 entry_index 处的条目，entryIndex 为 appendEntryIndex 时追加到末尾，调用方持有条目锁
+// 修订记录与条目在同一事务中保存，revision 提供操作类型、修改人等字段，Action 为空时按条目原本是否存在记为 create/update；
+// 恢复(restore)时条目必须不存在。条目原本不存在时数据集条目数加一
+func writeDatasetEntry(dataset *model.Dataset, entryIndex int, record services.ImportRecord, revision model.DatasetEntryRevision) (model.DatasetEntry, error) {
+	if dataset.StorageType != "database" && (dataset.BucketName == "" || dataset.ObjectPath == "") {
+		return model.DatasetEntry{}, fmt.Errorf("数据集MinIO存储信息不完整")
+	}
+	var before *model.DatasetEntry
+	if entryIndex != appendEntryIndex {
+		previous, err := loadDatasetEntry(dataset, entryIndex)
+		if err == nil {
+			before = &previous
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.DatasetEntry{}, fmt.Errorf("读取数据集条目失败: %v", err)
+		}
+	}
+	if revision.Action == "" {
+		revision.Action = model.EntryRevisionUpdate
+		if before == nil {
+			revision.Action = model.EntryRevisionCreate
+		}
+	}
+	// 删除后该位置可能已写入新的条目(如导入)，此时不能覆盖
+	if revision.Action == model.EntryRevisionRestore && before != nil {
+		return model.DatasetEntry{}, errDatasetEntryExists
+	}
+
+	entry := model.DatasetEntry{
+		DatasetID:   dataset.ID,
+		EntryIndex:  entryIndex,
+		Instruction: record.Instruction,
+		Input:       record.Input,
+		Output:      record.Output,
+		RawContent:  record.RawContent,
+	}
+
+	// MinIO中追加的条目写入最后一个分片
+	var lines map[int]string
+	var appended []string
+	if entryIndex == appendEntryIndex {
+		appended = []string{record.RawContent}
+	} else {
+		lines = map[int]string{entryIndex: record.RawContent}
+	}
+	err := writeDatasetEntries(dataset, lines, appended, func(tx *gorm.DB, next int) error {
+		if entryIndex == appendEntryIndex {
+			// 仅MinIO模式使用追加行的索引
+			entry.EntryIndex = next
+		}
+		if dataset.StorageType == "database" || dataset.StorageType == "both" {
+			if entryIndex == appendEntryIndex {
+				// 获取最大索引
+				var maxIndex struct {
+					MaxIndex int
+				}
+				if err := tx.Model(&model.DatasetEntry{}).
+					Select("COALESCE(MAX(entry_index), -1) as max_index").
+					Where("dataset_id = ?", dataset.ID).
+					Scan(&maxIndex).Error; err != nil {
+					return fmt.Errorf("分配条目索引失败: %v", err)
+				}
+				entry.EntryIndex = maxIndex.MaxIndex + 1
 			}
-		} else {
-			// 更新现有条目
-			updates := map[string]interface{}{
-				"instruction": input.Instruction,
-				"input":       input.Input,
-				"output":      input.Output,
-				"raw_content": rawContent,
+			if before == nil {
+				// 条目不存在，创建新条目
+				if err := tx.Create(&entry).Error; err != nil {
+					return fmt.Errorf("创建数据集条目失败: %v", err)
+				}
+			} else {
+				updates := map[string]interface{}{
+					"instruction": record.Instruction,
+					"input":       record.Input,
+					"output":      record.Output,
+					"raw_content": record.RawContent,
+				}
+				if err := tx.Model(&model.DatasetEntry{ID: before.ID}).Updates(updates).Error; err != nil {
+					return fmt.Errorf("更新数据集条目失败: %v", err)
+				}
+				entry.ID = before.ID
+				entry.CreatedAt = before.CreatedAt
 			}
+		}
 
-			if err := model.DB.Model(&entry).Updates(updates).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"message": "更新数据集条目失败: " + err.Error(),
-				})
-				return
-			}
+		counters := map[string]interface{}{"content_updated_at": time.Now()}
+		if before == nil {
+			counters["entry_count"] = gorm.Expr("entry_count + 1")
+		}
+		if err := tx.Model(dataset).UpdateColumns(counters).Error; err != nil {
+			return err
 		}
+		revision.DatasetID = dataset.ID
+		revision.EntryIndex = entry.EntryIndex
+		revision.Before = entryRevisionContent(before)
+		revision.After = entryRevisionContent(&entry)
+		return model.CreateDatasetEntryRevisions(tx, []model.DatasetEntryRevision{revision})
+	})
+	if err != nil {
+		return entry, err
 	}
+	indexDatasetEntries(dataset, entry)
+	return entry, nil
+}
 
-	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
-		// 更新MinIO中的内容
-		if dataset.BucketName == "" || dataset.ObjectPath == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "数据集MinIO存储信息不完整",
-			})
-			return
+// loadDatasetEntry 读取 entry_index 处的条目，条目不存在或已删除时返回 gorm.ErrRecordNotFound
+// 与 forEachDatasetEntry 一致，只有纯MinIO存储从MinIO读取
+func loadDatasetEntry(dataset *model.Dataset, entryIndex int) (model.DatasetEntry, error) {
+	if dataset.StorageType != "minio" {
+		var entry model.DatasetEntry
+		err := model.DB.Where("dataset_id = ? AND entry_index = ?", dataset.ID, entryIndex).First(&entry).Error
+		return entry, err
+	}
+
+	lines, err := readMinioDatasetLines(dataset, entryIndex, 1)
+	if err != nil {
+		return model.DatasetEntry{}, err
+	}
+	if len(lines) == 0 || isEmptyEntryLine(lines[0]) {
+		return model.DatasetEntry{}, gorm.ErrRecordNotFound
+	}
+	schema, _ := datasetSchema(dataset)
+	return entryFromLine(schema, dataset.ID, entryIndex, lines[0])
+}
+
+// 批量读取MinIO条目时，超过该数量改为顺序扫描整个数据集
+const datasetEntryLookupLimit = 50
+
+// loadDatasetEntries 读取多个条目，返回 entry_index 到条目的映射，不存在的条目不包含在内
+func loadDatasetEntries(dataset *model.Dataset, entryIndexes []int) (map[int]model.DatasetEntry, error) {
+	entries := make(map[int]model.DatasetEntry, len(entryIndexes))
+	if dataset.StorageType != "minio" {
+		for start := 0; start < len(entryIndexes); start += datasetDeleteBatchSize {
+			end := start + datasetDeleteBatchSize
+			if end > len(entryIndexes) {
+				end = len(entryIndexes)
+			}
+			var batch []model.DatasetEntry
+			if err := model.DB.Where("dataset_id = ? AND entry_index IN ?", dataset.ID, entryIndexes[start:end]).
+				Find(&batch).Error; err != nil {
+				return nil, err
+			}
+			for _, entry := range batch {
+				entries[entry.EntryIndex] = entry
+			}
 		}
+		return entries, nil
+	}
 
-		// 更新指定行
-		if err := services.UpdateJSONLInMinio(dataset.BucketName, dataset.ObjectPath, entryIndex, rawContent); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "更新MinIO数据失败: " + err.Error(),
-			})
-			return
+	if len(entryIndexes) <= datasetEntryLookupLimit {
+		for _, index := range entryIndexes {
+			entry, err := loadDatasetEntry(dataset, index)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			entries[index] = entry
 		}
+		return entries, nil
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "数据集条目更新成功",
-		"data": model.DatasetEntry{
-			DatasetID:   dataset.ID,
-			EntryIndex:  entryIndex,
-			Instruction: input.Instruction,
-			Input:       input.Input,
-			Output:      input.Output,
-			RawContent:  rawContent,
-		},
+	wanted := make(map[int]bool, len(entryIndexes))
+	for _, index := range entryIndexes {
+		wanted[index] = true
+	}
+	err := forEachDatasetEntry(dataset, func(entry model.DatasetEntry) error {
+		if wanted[entry.EntryIndex] {
+			entries[entry.EntryIndex] = entry
+		}
+		return nil
 	})
+	return entries, err
 }
 
 // DeleteDatasetEntry 删除数据集条目
@@ -668,19 +618,8 @@ func UpdateDatasetEntry(c *gin.Context) {
 // @Router /api/dataset/{id}/entry/{entryId} [delete]
 func DeleteDatasetEntry(c *gin.Context) {
 	id := c.Param("id")
-	entryID := c.Param("entryId")
 
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
-
-	// 获取数据集并验证权限
+	// 获取数据集
 	var dataset model.Dataset
 	if err := model.DB.First(&dataset, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -690,92 +629,208 @@ func DeleteDatasetEntry(c *gin.Context) {
 		return
 	}
 
-	// 验证编辑权限
-	hasEditPermission := false
-	if dataset.UserID == uint(userID.(int)) {
-		hasEditPermission = true
-	} else if dataset.ProjectID != 0 {
-		// 检查是否为项目成员且有编辑权限
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err == nil {
-			// 假设角色值大于1拥有编辑权限
-			if projectUser.Role > 1 {
-				hasEditPermission = true
-			}
-		}
+	entryID, ok := parseEntryID(c)
+	if !ok {
+		return
 	}
 
-	if !hasEditPermission {
-		c.JSON(http.StatusForbidden, gin.H{
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	deleted, err := deleteDatasetEntriesLocked(&dataset, []int{lookupEntryIndex(&dataset, entryID)}, uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "您没有权限编辑此数据集",
+			"message": "删除数据集条目失败: " + err.Error(),
 		})
 		return
 	}
-
-	// 获取条目索引
-	entryIndex, err := strconv.Atoi(entryID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
-			"message": "无效的条目ID",
+			"message": "数据集条目不存在",
 		})
 		return
 	}
 
-	// 根据存储类型删除
-	if dataset.StorageType == "database" || dataset.StorageType == "both" {
-		// 删除数据库中的条目
-		result := model.DB.Where("dataset_id = ? AND (id = ? OR entry_index = ?)", dataset.ID, entryIndex, entryIndex).Delete(&model.DatasetEntry{})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "数据集条目删除成功",
+	})
+}
+
+// 批量删除时每条 SQL 语句涉及的条目数，SQLite 单条语句最多 999 个参数
+const datasetDeleteBatchSize = 500
 
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "删除数据集条目失败: " + result.Error.Error(),
-			})
-			return
+// deleteDatasetEntries 持有条目锁批量删除条目，见 deleteDatasetEntriesLocked
+func deleteDatasetEntries(dataset *model.Dataset, entryIndexes []int, editorID uint) (int64, error) {
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	return deleteDatasetEntriesLocked(dataset, entryIndexes, editorID)
+}
+
+// deleteDatasetEntriesLocked 批量删除条目并记录删除修订，删除的条目可以从回收站恢复，调用方持有条目锁
+// 数据库中按读取到的条目主键删除，MinIO中的行用 {} 占位且每个分片只重写一次；不存在的条目被忽略，返回删除的条目数
+func deleteDatasetEntriesLocked(dataset *model.Dataset, entryIndexes []int, editorID uint) (int64, error) {
+	if len(entryIndexes) == 0 {
+		return 0, nil
+	}
+	if dataset.StorageType != "database" && (dataset.BucketName == "" || dataset.ObjectPath == "") {
+		return 0, fmt.Errorf("数据集MinIO存储信息不完整")
+	}
+
+	before, err := loadDatasetEntries(dataset, entryIndexes)
+	if err != nil {
+		return 0, fmt.Errorf("读取数据集条目失败: %v", err)
+	}
+	existing := make([]int, 0, len(before))
+	seen := make(map[int]bool, len(before))
+	for _, index := range entryIndexes {
+		if _, ok := before[index]; ok && !seen[index] {
+			existing = append(existing, index)
+			seen[index] = true
 		}
+	}
+	if len(existing) == 0 {
+		return 0, nil
+	}
 
-		if result.RowsAffected == 0 {
-			// 如果是both模式，可能只在MinIO中，继续处理
-			if dataset.StorageType != "both" {
-				c.JSON(http.StatusNotFound, gin.H{
-					"success": false,
-					"message": "数据集条目不存在",
-				})
-				return
+	lines := make(map[int]string, len(existing))
+	for _, index := range existing {
+		lines[index] = "{}"
+	}
+	deleted := int64(len(existing))
+	err = writeDatasetEntries(dataset, lines, nil, func(tx *gorm.DB, _ int) error {
+		if dataset.StorageType == "database" || dataset.StorageType == "both" {
+			ids := make([]uint, len(existing))
+			for i, index := range existing {
+				ids[i] = before[index].ID
 			}
+			for start := 0; start < len(ids); start += datasetDeleteBatchSize {
+				end := start + datasetDeleteBatchSize
+				if end > len(ids) {
+					end = len(ids)
+				}
+				if err := tx.Where("dataset_id = ? AND id IN ?", dataset.ID, ids[start:end]).
+					Delete(&model.DatasetEntry{}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := model.DeleteDatasetEntryAnnotations(tx, dataset.ID, existing); err != nil {
+			return err
+		}
+		if err := tx.Model(dataset).UpdateColumns(map[string]interface{}{
+			"entry_count":        gorm.Expr("CASE WHEN entry_count > ? THEN entry_count - ? ELSE 0 END", deleted, deleted),
+			"content_updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		// 删除修订保存删除前的内容，回收站据此恢复条目，必须与删除一起提交
+		revisions := make([]model.DatasetEntryRevision, len(existing))
+		for i, index := range existing {
+			entry := before[index]
+			revisions[i] = newEntryRevision(dataset.ID, index, model.EntryRevisionDelete, &entry, nil, editorID)
 		}
+		return model.CreateDatasetEntryRevisions(tx, revisions)
+	})
+	if err != nil {
+		return 0, err
 	}
+	unindexDatasetEntries(dataset, existing...)
+	return deleted, nil
+}
 
+// writeDatasetEntries 在一个事务中修改数据库和MinIO中的条目，任何一步失败时二者都保持修改前的状态
+// MinIO存储先上传修改后的分片：lines 按 entry_index 替换行，超出末尾时用 {} 补齐，appended 追加在末尾；
+// 随后在同一事务中执行 update 并使新分片生效。next 为追加的第一行的 entry_index，仅数据库存储时为 -1
+func writeDatasetEntries(dataset *model.Dataset, lines map[int]string, appended []string, update func(tx *gorm.DB, next int) error) error {
+	var staged *stagedDatasetShards
+	next := -1
 	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
-		// MinIO中的删除(用空对象覆盖)
-		if dataset.BucketName == "" || dataset.ObjectPath == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "数据集MinIO存储信息不完整",
-			})
-			return
+		var err error
+		if staged, err = stageMinioDatasetEdit(dataset, lines, nil, appended); err != nil {
+			return fmt.Errorf("写入MinIO失败: %v", err)
 		}
+		next = staged.next
+	}
 
-		// 更新为空对象
-		emptyJSON := "{}"
-		if err := services.UpdateJSONLInMinio(dataset.BucketName, dataset.ObjectPath, entryIndex, emptyJSON); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "更新MinIO数据失败: " + err.Error(),
-			})
-			return
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := update(tx, next); err != nil {
+			return err
+		}
+		if staged != nil {
+			return staged.apply(tx)
 		}
+		return nil
+	})
+	if staged != nil {
+		staged.finish(err == nil)
 	}
+	return err
+}
 
-	// 更新数据集条目计数
-	model.DB.Model(&dataset).Update("entry_count", gorm.Expr("GREATEST(entry_count - 1, 0)"))
+// datasetSchema 编译数据集的模板和自定义 JSON Schema
+func datasetSchema(dataset *model.Dataset) (*services.DatasetSchema, error) {
+	return services.CompileDatasetSchema(dataset.TemplateType, dataset.SchemaDefinition)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "数据集条目删除成功",
-	})
+// bindDatasetRecord 读取请求体中的条目JSON对象并按数据集Schema校验，失败时写入错误响应
+// 条目可以包含模板字段以外的任意字段，完整内容保存在 RawContent 中
+func bindDatasetRecord(c *gin.Context, dataset *model.Dataset) (services.ImportRecord, bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "读取请求失败: " + err.Error(),
+		})
+		return services.ImportRecord{}, false
+	}
+	return parseDatasetRecord(c, dataset, body)
+}
+
+// parseDatasetRecord 解析条目JSON对象并按数据集Schema校验，失败时写入错误响应
+func parseDatasetRecord(c *gin.Context, dataset *model.Dataset, body []byte) (services.ImportRecord, bool) {
+	fields, err := services.DecodeDatasetRecord(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return services.ImportRecord{}, false
+	}
+
+	schema, err := datasetSchema(dataset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "数据集Schema无效: " + err.Error(),
+		})
+		return services.ImportRecord{}, false
+	}
+	record, err := schema.Record(fields, services.ImportFieldMapping{})
+	if err != nil {
+		var fieldErrs services.FieldErrors
+		if !errors.As(err, &fieldErrs) {
+			fieldErrs = services.FieldErrors{{Message: err.Error()}}
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "条目未通过数据集Schema校验: " + err.Error(),
+			"data":    gin.H{"errors": fieldErrs},
+		})
+		return services.ImportRecord{}, false
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return services.ImportRecord{}, false
+	}
+	record.RawContent = compact.String()
+	return record, true
 }
 
 // 辅助函数: 检查字符串是否包含子串(不区分大小写)
@@ -794,11 +849,24 @@ func convertToDatasetEntryDTO(entry model.DatasetEntry) DatasetEntryDTO {
 		Instruction: entry.Instruction,
 		Input:       entry.Input,
 		Output:      entry.Output,
+		Data:        entryData(entry.RawContent),
 		CreatedAt:   entry.CreatedAt,
 		UpdatedAt:   entry.UpdatedAt,
 	}
 }
 
+// entryData 解析条目的完整内容，解析失败时返回 nil
+func entryData(rawContent string) map[string]interface{} {
+	if rawContent == "" {
+		return nil
+	}
+	data, err := services.DecodeDatasetRecord([]byte(rawContent))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
 // convertToDatasetEntryDTOList 将模型对象列表转换为DTO列表
 func convertToDatasetEntryDTOList(entries []model.DatasetEntry) []DatasetEntryDTO {
 	dtos := make([]DatasetEntryDTO, len(entries))
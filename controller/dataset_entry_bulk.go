@@ -0,0 +1,474 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 一次批量操作最多涉及的条目数，按检索条件选择的条目也受此限制
+const maxBulkEntryOperations = 10000
+
+// BulkEntrySelector 按检索条件选择条目并统一修改
+type BulkEntrySelector struct {
+	Query string                 `json:"q" binding:"required"`  // 检索语句，语法同 GET /api/dataset/{id}/entries 的 q
+	Field string                 `json:"field"`                 // 未限定字段的检索词所在字段，默认 all
+	Op    string                 `json:"op" binding:"required"` // update/delete
+	Set   map[string]interface{} `json:"set"`                   // update 时合并到条目中的字段，值为 null 时删除该字段
+}
+
+// BulkDatasetEntriesRequest 批量修改条目的请求，operations 和 selector 二选一
+type BulkDatasetEntriesRequest struct {
+	Operations []services.BulkEntryOperation `json:"operations"`
+	Selector   *BulkEntrySelector            `json:"selector"`
+}
+
+// BulkDatasetEntriesResult 批量修改的结果
+type BulkDatasetEntriesResult struct {
+	Created        int   `json:"created"`
+	Updated        int   `json:"updated"`
+	Deleted        int   `json:"deleted"`
+	CreatedIndexes []int `json:"created_indexes"` // 新条目的 entry_index，与 create 操作的顺序一致
+	Compacted      bool  `json:"compacted"`       // 有删除时其后条目的 entry_index 已前移
+	EntryCount     int64 `json:"entry_count"`
+}
+
+// BulkOperationError 批量操作中未通过校验的一项
+type BulkOperationError struct {
+	Operation  int    `json:"operation"` // 在 operations 中的位置，按 selector 修改时为 -1
+	EntryIndex *int   `json:"entry_index,omitempty"`
+	Message    string `json:"message"`
+}
+
+// datasetEntryBulk 校验后的一批修改，entry_index 均为修改前的索引
+type datasetEntryBulk struct {
+	creates []services.ImportRecord
+	updates map[int]services.ImportRecord
+	deletes []int // 升序
+}
+
+// bulkDatasetRecord 将条目JSON对象按数据集Schema转换为记录，RawContent 为压缩后的JSON
+func bulkDatasetRecord(schema *services.DatasetSchema, data []byte) (services.ImportRecord, error) {
+	fields, err := services.DecodeDatasetRecord(data)
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	record, err := schema.Record(fields, services.ImportFieldMapping{})
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return services.ImportRecord{}, err
+	}
+	record.RawContent = compact.String()
+	return record, nil
+}
+
+// planBulkOperations 校验 operations 并解析其中的条目内容
+func planBulkOperations(schema *services.DatasetSchema, ops []services.BulkEntryOperation) (*datasetEntryBulk, []BulkOperationError) {
+	bulk := &datasetEntryBulk{updates: map[int]services.ImportRecord{}}
+	var errs []BulkOperationError
+	for i, op := range ops {
+		if op.Op == services.BulkOpDelete {
+			bulk.deletes = append(bulk.deletes, *op.EntryIndex)
+			continue
+		}
+		record, err := bulkDatasetRecord(schema, op.Data)
+		if err != nil {
+			errs = append(errs, BulkOperationError{Operation: i, EntryIndex: op.EntryIndex, Message: err.Error()})
+			continue
+		}
+		if op.Op == services.BulkOpCreate {
+			bulk.creates = append(bulk.creates, record)
+		} else {
+			bulk.updates[*op.EntryIndex] = record
+		}
+	}
+	sort.Ints(bulk.deletes)
+	return bulk, errs
+}
+
+// planBulkSelector 按检索条件选择条目，生成删除或字段合并后的修改
+func planBulkSelector(dataset *model.Dataset, schema *services.DatasetSchema, selector *BulkEntrySelector) (*datasetEntryBulk, []BulkOperationError, error) {
+	if selector.Op != services.BulkOpUpdate && selector.Op != services.BulkOpDelete {
+		return nil, nil, fmt.Errorf("selector 只支持 update 和 delete")
+	}
+	if selector.Op == services.BulkOpUpdate && len(selector.Set) == 0 {
+		return nil, nil, fmt.Errorf("selector 的 update 需要 set")
+	}
+	if selector.Field == "" {
+		selector.Field = "all"
+	}
+	query, err := services.ParseSearchQuery(selector.Query, selector.Field)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无效的检索语句: %v", err)
+	}
+	entries, total, err := searchDatasetEntries(dataset, query, 0, maxBulkEntryOperations)
+	if err != nil {
+		return nil, nil, fmt.Errorf("检索数据集条目失败: %v", err)
+	}
+	if total > maxBulkEntryOperations {
+		return nil, nil, fmt.Errorf("匹配的条目有%d条，超过单次批量操作的上限%d条", total, maxBulkEntryOperations)
+	}
+
+	bulk := &datasetEntryBulk{updates: map[int]services.ImportRecord{}}
+	var errs []BulkOperationError
+	for _, entry := range entries {
+		if selector.Op == services.BulkOpDelete {
+			bulk.deletes = append(bulk.deletes, entry.EntryIndex)
+			continue
+		}
+		record, err := mergedEntryRecord(schema, entry, selector.Set)
+		if err != nil {
+			index := entry.EntryIndex
+			errs = append(errs, BulkOperationError{Operation: -1, EntryIndex: &index, Message: err.Error()})
+			continue
+		}
+		bulk.updates[entry.EntryIndex] = record
+	}
+	sort.Ints(bulk.deletes)
+	return bulk, errs, nil
+}
+
+// mergedEntryRecord 将 set 合并到条目的完整内容后重新按Schema校验
+func mergedEntryRecord(schema *services.DatasetSchema, entry model.DatasetEntry, set map[string]interface{}) (services.ImportRecord, error) {
+	line, err := entryToLine(entry)
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	fields, err := services.DecodeDatasetRecord([]byte(line))
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	data, err := json.Marshal(services.MergeRecordFields(fields, set))
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	return bulkDatasetRecord(schema, data)
+}
+
+// entryLineSize 条目在JSONL中占用的字节数(含换行符)，与导入和派生时统计 total_size 的方式一致
+func entryLineSize(line string) int64 {
+	return int64(len(line) + 1)
+}
+
+// applyDatasetEntryBulk 在一个事务中执行一批修改，任何一步失败时数据库和MinIO都保持修改前的状态
+// MinIO的新分片先上传，随事务一起生效；有删除时其后的 entry_index 依次前移，标注和修订记录同步平移
+// 调用方从选择条目到执行完成持有条目锁
+func applyDatasetEntryBulk(dataset *model.Dataset, bulk *datasetEntryBulk, editorID uint) (*BulkDatasetEntriesResult, []BulkOperationError, error) {
+	if dataset.StorageType != "database" && (dataset.BucketName == "" || dataset.ObjectPath == "") {
+		return nil, nil, fmt.Errorf("数据集MinIO存储信息不完整")
+	}
+
+	targets := append([]int{}, bulk.deletes...)
+	for index := range bulk.updates {
+		targets = append(targets, index)
+	}
+	before, err := loadDatasetEntries(dataset, targets)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取数据集条目失败: %v", err)
+	}
+	var missing []BulkOperationError
+	for _, index := range targets {
+		if _, ok := before[index]; !ok {
+			index := index
+			missing = append(missing, BulkOperationError{Operation: -1, EntryIndex: &index, Message: "数据集条目不存在"})
+		}
+	}
+	if len(missing) > 0 {
+		sort.Slice(missing, func(i, j int) bool { return *missing[i].EntryIndex < *missing[j].EntryIndex })
+		return nil, missing, nil
+	}
+
+	var sizeDelta int64
+	lines := make(map[int]string, len(bulk.updates))
+	for index, record := range bulk.updates {
+		previous, _ := entryToLine(before[index])
+		lines[index] = record.RawContent
+		sizeDelta += entryLineSize(record.RawContent) - entryLineSize(previous)
+	}
+	for _, index := range bulk.deletes {
+		previous, _ := entryToLine(before[index])
+		sizeDelta -= entryLineSize(previous)
+	}
+	appended := make([]string, len(bulk.creates))
+	for i, record := range bulk.creates {
+		appended[i] = record.RawContent
+		sizeDelta += entryLineSize(record.RawContent)
+	}
+
+	saveToDB := dataset.StorageType == "database" || dataset.StorageType == "both"
+	var staged *stagedDatasetShards
+	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
+		if staged, err = stageMinioDatasetEdit(dataset, lines, bulk.deletes, appended); err != nil {
+			return nil, nil, fmt.Errorf("写入MinIO失败: %v", err)
+		}
+	}
+
+	// 修订记录中更新后的条目使用压缩后的 entry_index，被删除条目的修改历史随索引一起脱离
+	revisions := make([]model.DatasetEntryRevision, 0, len(bulk.deletes)+len(bulk.updates)+len(bulk.creates))
+	updated := make([]model.DatasetEntry, 0, len(bulk.updates))
+	for index, record := range bulk.updates {
+		previous := before[index]
+		previous.EntryIndex = services.CompactedIndex(bulk.deletes, index)
+		entry := previous
+		entry.Instruction = record.Instruction
+		entry.Input = record.Input
+		entry.Output = record.Output
+		entry.RawContent = record.RawContent
+		updated = append(updated, entry)
+		revisions = append(revisions, newEntryRevision(dataset.ID, entry.EntryIndex, model.EntryRevisionUpdate, &previous, &entry, editorID))
+	}
+	sort.Slice(updated, func(i, j int) bool { return updated[i].EntryIndex < updated[j].EntryIndex })
+	for _, index := range bulk.deletes {
+		entry, deletedIndex := before[index], index
+		// 原来的位置被压缩，回收站按修订恢复时追加到末尾
+		revision := newEntryRevision(dataset.ID, model.DetachedEntryIndex, model.EntryRevisionDelete, &entry, nil, editorID)
+		revision.DeletedIndex = &deletedIndex
+		revisions = append(revisions, revision)
+	}
+
+	created := make([]model.DatasetEntry, len(bulk.creates))
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		if saveToDB {
+			for start := 0; start < len(bulk.deletes); start += datasetDeleteBatchSize {
+				end := start + datasetDeleteBatchSize
+				if end > len(bulk.deletes) {
+					end = len(bulk.deletes)
+				}
+				if err := tx.Where("dataset_id = ? AND entry_index IN ?", dataset.ID, bulk.deletes[start:end]).
+					Delete(&model.DatasetEntry{}).Error; err != nil {
+					return err
+				}
+			}
+			for index, record := range bulk.updates {
+				if err := tx.Model(&model.DatasetEntry{ID: before[index].ID}).Updates(map[string]interface{}{
+					"instruction": record.Instruction,
+					"input":       record.Input,
+					"output":      record.Output,
+					"raw_content": record.RawContent,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		// 压缩被删除条目留下的索引位置
+		if len(bulk.deletes) > 0 {
+			if err := model.RemoveDatasetEntryIndexes(tx, dataset.ID, bulk.deletes); err != nil {
+				return err
+			}
+			for _, shift := range services.CompactionShifts(bulk.deletes) {
+				if err := model.ShiftDatasetEntryIndexes(tx, dataset.ID, shift.After, shift.Before, shift.By); err != nil {
+					return err
+				}
+			}
+		}
+
+		next := 0
+		if staged != nil {
+			next = staged.next
+		} else if err := tx.Model(&model.DatasetEntry{}).Where("dataset_id = ?", dataset.ID).
+			Select("COALESCE(MAX(entry_index), -1) + 1").Scan(&next).Error; err != nil {
+			return err
+		}
+		for i, record := range bulk.creates {
+			created[i] = model.DatasetEntry{
+				DatasetID:   dataset.ID,
+				EntryIndex:  next + i,
+				Instruction: record.Instruction,
+				Input:       record.Input,
+				Output:      record.Output,
+				RawContent:  record.RawContent,
+			}
+		}
+		if saveToDB && len(created) > 0 {
+			if err := tx.CreateInBatches(&created, datasetImportBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		for i := range created {
+			revisions = append(revisions, newEntryRevision(dataset.ID, created[i].EntryIndex, model.EntryRevisionCreate, nil, &created[i], editorID))
+		}
+		if err := model.CreateDatasetEntryRevisions(tx, revisions); err != nil {
+			return err
+		}
+
+		if staged != nil {
+			if err := staged.apply(tx); err != nil {
+				return err
+			}
+		}
+
+		count := int64(len(bulk.creates) - len(bulk.deletes))
+		if err := tx.Model(dataset).UpdateColumns(map[string]interface{}{
+			"entry_count":        gorm.Expr("CASE WHEN entry_count + ? > 0 THEN entry_count + ? ELSE 0 END", count, count),
+			"total_size":         gorm.Expr("CASE WHEN total_size + ? > 0 THEN total_size + ? ELSE 0 END", sizeDelta, sizeDelta),
+			"content_updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		// 条目已变化，质量报告中的条目不能再用于删除
+		return tx.Model(&model.DatasetQualityReport{}).Where("dataset_id = ?", dataset.ID).
+			UpdateColumn("dataset_entry_count", -1).Error
+	})
+	if staged != nil {
+		staged.finish(err == nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(bulk.deletes) > 0 {
+		// 删除后检索文档的 entry_index 全部失效，重新建立
+		if err := rebuildDatasetSearchIndex(dataset); err != nil {
+			common.SysError(fmt.Sprintf("failed to rebuild search index of dataset %d: %v", dataset.ID, err))
+		}
+	} else {
+		indexDatasetEntries(dataset, append(updated, created...)...)
+	}
+
+	var entryCount int64
+	model.DB.Model(&model.Dataset{}).Where("id = ?", dataset.ID).Select("entry_count").Scan(&entryCount)
+
+	result := &BulkDatasetEntriesResult{
+		Created:        len(created),
+		Updated:        len(updated),
+		Deleted:        len(bulk.deletes),
+		CreatedIndexes: make([]int, len(created)),
+		Compacted:      len(bulk.deletes) > 0,
+		EntryCount:     entryCount,
+	}
+	for i := range created {
+		result.CreatedIndexes[i] = created[i].EntryIndex
+	}
+	return result, nil, nil
+}
+
+// BulkDatasetEntries 批量修改数据集条目
+// @Summary 批量修改数据集条目
+// @Description operations 为 create/update/delete 操作列表；selector 按检索条件选择条目后统一删除或合并 set 中的字段，二者只能选一个
+// @Description 全部条目先按数据集Schema校验，任一项失败时不做任何修改。整批修改在一个事务中执行，MinIO存储只重写一次
+// @Description 有删除时其后条目的 entry_index 依次前移(标注和修改历史同步平移)，被删除的条目进入回收站，按修订恢复时追加到末尾
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param request body BulkDatasetEntriesRequest true "批量操作"
+// @Success 200 {object} SuccessResponse{data=BulkDatasetEntriesResult}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/dataset/{id}/entries/bulk [post]
+func BulkDatasetEntries(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	var req BulkDatasetEntriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if (len(req.Operations) == 0) == (req.Selector == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "operations 和 selector 必须且只能提供一个",
+		})
+		return
+	}
+	if len(req.Operations) > maxBulkEntryOperations {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("单次最多%d项操作", maxBulkEntryOperations),
+		})
+		return
+	}
+
+	schema, err := datasetSchema(dataset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "数据集Schema无效: " + err.Error(),
+		})
+		return
+	}
+
+	// 所有存储类型都持有条目锁，避免与其他修改并发分配或平移 entry_index，选中的条目在执行前也不会移动
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+
+	var bulk *datasetEntryBulk
+	var errs []BulkOperationError
+	if req.Selector != nil {
+		if bulk, errs, err = planBulkSelector(dataset, schema, req.Selector); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	} else {
+		if err := services.ValidateBulkOperations(req.Operations); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "参数错误: " + err.Error(),
+			})
+			return
+		}
+		bulk, errs = planBulkOperations(schema, req.Operations)
+	}
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("%d项操作未通过数据集Schema校验，未做任何修改", len(errs)),
+			"data":    gin.H{"errors": errs},
+		})
+		return
+	}
+	if len(bulk.creates)+len(bulk.updates)+len(bulk.deletes) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "没有匹配的条目",
+			"data":    BulkDatasetEntriesResult{CreatedIndexes: []int{}, EntryCount: dataset.EntryCount},
+		})
+		return
+	}
+
+	audit := auditResource(c, model.ResourceDataset, dataset.ID, dataset.ProjectID, gin.H{"entry_count": dataset.EntryCount})
+	result, missing, err := applyDatasetEntryBulk(dataset, bulk, uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "批量修改数据集条目失败: " + err.Error(),
+		})
+		return
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("%d个条目不存在，未做任何修改", len(missing)),
+			"data":    gin.H{"errors": missing},
+		})
+		return
+	}
+	audit.SetAfter(result)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("已创建%d条、更新%d条、删除%d条数据", result.Created, result.Updated, result.Deleted),
+		"data":    result,
+	})
+}
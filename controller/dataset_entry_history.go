@@ -0,0 +1,524 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DatasetEntryRevisionDTO 条目的一次修订
+type DatasetEntryRevisionDTO struct {
+	ID         uint                   `json:"id"`
+	DatasetID  uint                   `json:"dataset_id"`
+	EntryIndex int                    `json:"entry_index"`
+	Action     string                 `json:"action"` // create/update/delete/restore/revert
+	UserID     uint                   `json:"user_id"`
+	Before     map[string]interface{} `json:"before"` // 修改前的内容，条目原本不存在时为 null
+	After      map[string]interface{} `json:"after"`  // 修改后的内容，删除时为 null
+	CreatedAt  time.Time              `json:"created_at"`
+
+	DeletedIndex *int `json:"deleted_index,omitempty"` // 批量删除的条目压缩前的 entry_index，此时 entry_index 为 -1
+	RestoredFrom uint `json:"restored_from,omitempty"` // 恢复时对应的删除修订
+}
+
+// DatasetEntryRevisionsData 修订记录列表
+type DatasetEntryRevisionsData struct {
+	Revisions []DatasetEntryRevisionDTO `json:"revisions"`
+	PagedData
+}
+
+// DatasetChangelogData 数据集的修改记录及按操作类型的统计
+type DatasetChangelogData struct {
+	Revisions []DatasetEntryRevisionDTO `json:"revisions"`
+	Summary   map[string]int64          `json:"summary"`
+	PagedData
+}
+
+// RevertDatasetEntryRequest 回滚条目的请求
+type RevertDatasetEntryRequest struct {
+	RevisionID uint `json:"revision_id" binding:"required"` // 回滚到该次修订之前的内容
+}
+
+// entryRevisionContent 返回修订中保存的条目内容，条目不存在时为空
+func entryRevisionContent(entry *model.DatasetEntry) string {
+	if entry == nil {
+		return ""
+	}
+	line, err := entryToLine(*entry)
+	if err != nil {
+		return ""
+	}
+	return line
+}
+
+// newEntryRevision 创建一条修订记录，before/after 为 nil 表示条目在修改前/后不存在
+func newEntryRevision(datasetID uint, entryIndex int, action string, before, after *model.DatasetEntry, userID uint) model.DatasetEntryRevision {
+	return model.DatasetEntryRevision{
+		DatasetID:  datasetID,
+		EntryIndex: entryIndex,
+		Action:     action,
+		Before:     entryRevisionContent(before),
+		After:      entryRevisionContent(after),
+		UserID:     userID,
+	}
+}
+
+// datasetRevisionSummary 数据集级别修订(导入、激活版本)的摘要，保存在修订的 After 中
+type datasetRevisionSummary struct {
+	JobID      uint   `json:"job_id,omitempty"`      // 导入任务
+	FirstIndex *int   `json:"first_index,omitempty"` // 导入的第一个条目的 entry_index
+	VersionID  uint   `json:"version_id,omitempty"`  // 激活的版本
+	Version    string `json:"version,omitempty"`
+	EntryCount int64  `json:"entry_count"` // 导入的条目数或激活后的条目数
+}
+
+// newDatasetRevision 创建一条数据集级别的修订记录，不对应单个条目
+func newDatasetRevision(datasetID uint, action string, summary datasetRevisionSummary, userID uint) model.DatasetEntryRevision {
+	content, _ := json.Marshal(summary)
+	return model.DatasetEntryRevision{
+		DatasetID:  datasetID,
+		EntryIndex: model.DetachedEntryIndex,
+		Action:     action,
+		After:      string(content),
+		UserID:     userID,
+	}
+}
+
+// convertToDatasetEntryRevisionDTOList 将修订记录转换为DTO列表
+func convertToDatasetEntryRevisionDTOList(revisions []model.DatasetEntryRevision) []DatasetEntryRevisionDTO {
+	dtos := make([]DatasetEntryRevisionDTO, len(revisions))
+	for i, revision := range revisions {
+		dtos[i] = DatasetEntryRevisionDTO{
+			ID:         revision.ID,
+			DatasetID:  revision.DatasetID,
+			EntryIndex: revision.EntryIndex,
+			Action:     revision.Action,
+			UserID:     revision.UserID,
+			Before:     entryData(revision.Before),
+			After:      entryData(revision.After),
+			CreatedAt:  revision.CreatedAt,
+
+			DeletedIndex: revision.DeletedIndex,
+			RestoredFrom: revision.RestoredFrom,
+		}
+	}
+	return dtos
+}
+
+// revisionRecord 按数据集当前的Schema校验修订中保存的内容
+func revisionRecord(dataset *model.Dataset, content string) (services.ImportRecord, error) {
+	fields, err := services.DecodeDatasetRecord([]byte(content))
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	schema, err := datasetSchema(dataset)
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	record, err := schema.Record(fields, services.ImportFieldMapping{})
+	if err != nil {
+		return services.ImportRecord{}, err
+	}
+	record.RawContent = content
+	return record, nil
+}
+
+// pageParams 读取分页参数，limit 默认为 10，最大为 100
+func pageParams(c *gin.Context) (page, limit int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return page, limit
+}
+
+// GetDatasetEntryHistory 获取条目的修改历史
+// @Summary 获取条目修改历史
+// @Description 分页返回条目的修订记录(最新的在前)，包含修改人、时间和修改前后的内容
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param entryId path int true "条目ID或索引"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} SuccessResponse{data=DatasetEntryRevisionsData}
+// @Router /api/dataset/{id}/entry/{entryId}/history [get]
+func GetDatasetEntryHistory(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	entryIndex, ok := resolveEntryIndex(c, dataset)
+	if !ok {
+		return
+	}
+	page, limit := pageParams(c)
+
+	revisions, total, err := model.GetDatasetEntryRevisions(dataset.ID, entryIndex, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取修改历史失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": DatasetEntryRevisionsData{
+			Revisions: convertToDatasetEntryRevisionDTOList(revisions),
+			PagedData: PagedData{
+				Total: total,
+				Page:  page,
+				Limit: limit,
+			},
+		},
+	})
+}
+
+// RevertDatasetEntry 将条目回滚到某次修订之前的内容
+// @Summary 回滚条目
+// @Description 将条目恢复为指定修订之前的内容；该修订创建了条目时回滚会删除条目(可从回收站恢复)。回滚本身也会记录为一次修订
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param entryId path int true "条目ID或索引"
+// @Param request body RevertDatasetEntryRequest true "回滚到的修订"
+// @Success 200 {object} DatasetEntryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/dataset/{id}/entry/{entryId}/revert [post]
+func RevertDatasetEntry(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	entryID, ok := parseEntryID(c)
+	if !ok {
+		return
+	}
+	var req RevertDatasetEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 持有条目锁后再解析条目ID和读取修订，批量删除不会在此期间平移 entry_index
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	entryIndex := lookupEntryIndex(dataset, entryID)
+	revision, err := model.GetDatasetEntryRevision(dataset.ID, req.RevisionID)
+	if err != nil || revision.EntryIndex != entryIndex {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "该条目没有这条修订记录",
+		})
+		return
+	}
+	userID := uint(c.GetInt("user_id"))
+
+	if revision.Before == "" {
+		// 修订之前条目不存在，回滚即删除
+		deleted, err := deleteDatasetEntriesLocked(dataset, []int{entryIndex}, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "回滚数据集条目失败: " + err.Error(),
+			})
+			return
+		}
+		message := "条目已回滚，该修订之前条目不存在，已删除"
+		if deleted == 0 {
+			message = "条目已是该修订之前的状态"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": message,
+		})
+		return
+	}
+
+	record, err := revisionRecord(dataset, revision.Before)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "修订中的内容未通过数据集当前的Schema校验: " + err.Error(),
+		})
+		return
+	}
+	entry, err := writeDatasetEntry(dataset, entryIndex, record, model.DatasetEntryRevision{Action: model.EntryRevisionRevert, UserID: userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "回滚数据集条目失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "数据集条目已回滚",
+		"data":    convertToDatasetEntryDTO(entry),
+	})
+}
+
+// ListDeletedDatasetEntries 获取回收站中的条目
+// @Summary 获取已删除的条目
+// @Description 分页返回已删除且尚未恢复的条目(最近删除的在前)，before 为删除前的内容
+// @Description 批量删除的条目 entry_index 为 -1，deleted_index 为删除前的位置，需按修订ID恢复
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} SuccessResponse{data=DatasetEntryRevisionsData}
+// @Router /api/dataset/{id}/entries/deleted [get]
+func ListDeletedDatasetEntries(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	page, limit := pageParams(c)
+
+	revisions, total, err := model.ListDeletedDatasetEntries(dataset.ID, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取已删除的条目失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": DatasetEntryRevisionsData{
+			Revisions: convertToDatasetEntryRevisionDTOList(revisions),
+			PagedData: PagedData{
+				Total: total,
+				Page:  page,
+				Limit: limit,
+			},
+		},
+	})
+}
+
+// RestoreDatasetEntry 从回收站恢复条目
+// @Summary 恢复已删除的条目
+// @Description 将最近一次被删除的条目恢复到原来的 entry_index
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param entryId path int true "条目索引"
+// @Success 200 {object} DatasetEntryResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/dataset/{id}/entry/{entryId}/restore [post]
+func RestoreDatasetEntry(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	entryID, ok := parseEntryID(c)
+	if !ok {
+		return
+	}
+
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	revision, err := model.GetLatestDatasetEntryRevision(dataset.ID, lookupEntryIndex(dataset, entryID))
+	if err != nil || revision.Action != model.EntryRevisionDelete {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "回收站中没有该条目",
+		})
+		return
+	}
+	restoreDeletedEntry(c, dataset, revision)
+}
+
+// RestoreDeletedDatasetEntry 按删除修订从回收站恢复条目
+// @Summary 按修订恢复已删除的条目
+// @Description 恢复回收站中的一条删除修订：位置仍然有效的条目恢复到原来的 entry_index，
+// @Description 批量删除的条目原来的位置已被压缩，恢复后追加到数据集末尾
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param revisionId path int true "删除修订ID"
+// @Success 200 {object} DatasetEntryResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/dataset/{id}/entries/deleted/{revisionId}/restore [post]
+func RestoreDeletedDatasetEntry(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	revisionID, err := strconv.Atoi(c.Param("revisionId"))
+	if err != nil || revisionID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的修订ID",
+		})
+		return
+	}
+
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	revision, err := model.GetDeletedDatasetEntry(dataset.ID, uint(revisionID))
+	if err != nil {
+		status, message := http.StatusInternalServerError, "读取删除记录失败: "+err.Error()
+		if errors.Is(err, model.ErrEntryRevisionNotFound) {
+			status, message = http.StatusNotFound, "回收站中没有该条目"
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	restoreDeletedEntry(c, dataset, revision)
+}
+
+// restoreDeletedEntry 按删除修订恢复条目并写入响应，调用方持有条目锁
+func restoreDeletedEntry(c *gin.Context, dataset *model.Dataset, revision *model.DatasetEntryRevision) {
+	record, err := revisionRecord(dataset, revision.Before)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "删除前的内容未通过数据集当前的Schema校验: " + err.Error(),
+		})
+		return
+	}
+	entryIndex := revision.EntryIndex
+	if entryIndex == model.DetachedEntryIndex {
+		entryIndex = appendEntryIndex
+	}
+	entry, err := writeDatasetEntry(dataset, entryIndex, record, model.DatasetEntryRevision{
+		Action:       model.EntryRevisionRestore,
+		UserID:       uint(c.GetInt("user_id")),
+		RestoredFrom: revision.ID,
+	})
+	if err != nil {
+		status, message := http.StatusInternalServerError, "恢复数据集条目失败: "+err.Error()
+		if errors.Is(err, errDatasetEntryExists) {
+			status, message = http.StatusConflict, err.Error()
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "数据集条目已恢复",
+		"data":    convertToDatasetEntryDTO(entry),
+	})
+}
+
+// changelogVersionTime 返回版本的创建时间，ref 为空或 current 时返回 nil
+func changelogVersionTime(datasetID uint, ref string) (*time.Time, error) {
+	if ref == "" || ref == "current" {
+		return nil, nil
+	}
+	versionID, err := strconv.Atoi(ref)
+	if err != nil {
+		return nil, fmt.Errorf("无效的版本ID: %s", ref)
+	}
+	version, err := model.GetDatasetVersion(datasetID, uint(versionID))
+	if err != nil {
+		return nil, fmt.Errorf("版本不存在: %s", ref)
+	}
+	return &version.CreatedAt, nil
+}
+
+// GetDatasetChangelog 获取数据集的修改记录
+// @Summary 获取数据集修改记录
+// @Description 分页返回数据集全部条目的修订记录(最新的在前)。from/to 为版本ID时只返回两个版本创建时间之间的修改，
+// @Description 可与 GET /api/dataset/{id}/versions/diff 对照查看每处差异的修改人和时间；导入和激活版本不逐条记录，
+// @Description 各记录一条 entry_index 为 -1 的 import/activate 修订，after 为任务、版本和条目数的摘要
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param from query string false "起始版本ID，只返回该版本之后的修改"
+// @Param to query string false "目标版本ID，默认 current 表示到当前为止"
+// @Param action query string false "操作类型(create/update/delete/restore/revert/import/activate)"
+// @Param user_id query int false "修改人"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} SuccessResponse{data=DatasetChangelogData}
+// @Failure 400 {object} ErrorResponse
+// @Router /api/dataset/{id}/changelog [get]
+func GetDatasetChangelog(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+	page, limit := pageParams(c)
+	userID, _ := strconv.Atoi(c.Query("user_id"))
+	filter := model.DatasetChangelogFilter{
+		Action: c.Query("action"),
+		UserID: uint(userID),
+	}
+
+	var err error
+	if filter.Since, err = changelogVersionTime(dataset.ID, c.Query("from")); err == nil {
+		filter.Until, err = changelogVersionTime(dataset.ID, c.Query("to"))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	revisions, total, err := model.GetDatasetChangelog(dataset.ID, filter, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取修改记录失败: " + err.Error(),
+		})
+		return
+	}
+	summary, err := model.CountDatasetChangelog(dataset.ID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取修改记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": DatasetChangelogData{
+			Revisions: convertToDatasetEntryRevisionDTOList(revisions),
+			Summary:   summary,
+			PagedData: PagedData{
+				Total: total,
+				Page:  page,
+				Limit: limit,
+			},
+		},
+	})
+}
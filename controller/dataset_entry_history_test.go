@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupEntryDataset 创建数据库存储的 instruction_io 数据集
+func setupEntryDataset(t *testing.T) *model.Dataset {
+	t.Helper()
+	setupTestDB(t, &model.Dataset{}, &model.DatasetEntry{}, &model.DatasetEntryRevision{},
+		&model.DatasetAnnotation{}, &model.DatasetAnnotationTask{}, &model.DatasetVersion{}, &model.DatasetQualityReport{}, &model.DatasetQualityFlag{})
+	dataset := &model.Dataset{Name: "d1", StorageType: "database", UserID: 1}
+	if err := model.DB.Create(dataset).Error; err != nil {
+		t.Fatalf("create dataset: %v", err)
+	}
+	return dataset
+}
+
+// callEntryHandler 以 user 1 的身份调用条目接口，ref 同时作为路径中的 entryId 和 revisionId，query 附加在请求地址上
+func callEntryHandler(handler gin.HandlerFunc, datasetID uint, ref, query, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/dataset?"+query, bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{
+		{Key: "id", Value: strconv.Itoa(int(datasetID))},
+		{Key: "entryId", Value: ref},
+		{Key: "revisionId", Value: ref},
+	}
+	c.Set("user_id", 1)
+	handler(c)
+	return w
+}
+
+func decodeData(t *testing.T, w *httptest.ResponseRecorder, data interface{}) {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	body := struct {
+		Data interface{} `json:"data"`
+	}{data}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+}
+
+func TestDatasetEntryRevertAndRestore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataset := setupEntryDataset(t)
+
+	var entry DatasetEntryDTO
+	decodeData(t, callEntryHandler(CreateDatasetEntry, dataset.ID, "", "", `{"instruction": "q", "output": "a1"}`), &entry)
+	entryID := strconv.Itoa(entry.EntryIndex)
+	decodeData(t, callEntryHandler(UpdateDatasetEntry, dataset.ID, entryID, "", `{"instruction": "q", "output": "a2"}`), &entry)
+
+	var history DatasetEntryRevisionsData
+	decodeData(t, callEntryHandler(GetDatasetEntryHistory, dataset.ID, entryID, "", ""), &history)
+	if history.Total != 2 || history.Revisions[0].Action != model.EntryRevisionUpdate || history.Revisions[0].Before["output"] != "a1" {
+		t.Fatalf("history %+v", history)
+	}
+
+	// 回滚到更新之前的内容
+	update := history.Revisions[0].ID
+	decodeData(t, callEntryHandler(RevertDatasetEntry, dataset.ID, entryID, "", `{"revision_id": `+strconv.Itoa(int(update))+`}`), &entry)
+	if entry.Output != "a1" {
+		t.Errorf("reverted entry %+v", entry)
+	}
+
+	// 删除后进入回收站，恢复到原来的位置
+	decodeData(t, callEntryHandler(DeleteDatasetEntry, dataset.ID, entryID, "", ""), nil)
+	var deleted DatasetEntryRevisionsData
+	decodeData(t, callEntryHandler(ListDeletedDatasetEntries, dataset.ID, "", "", ""), &deleted)
+	if deleted.Total != 1 || deleted.Revisions[0].Before["output"] != "a1" {
+		t.Fatalf("deleted entries %+v", deleted)
+	}
+	decodeData(t, callEntryHandler(RestoreDatasetEntry, dataset.ID, entryID, "", ""), &entry)
+	if entry.Output != "a1" || entry.EntryIndex != 0 {
+		t.Errorf("restored entry %+v", entry)
+	}
+	decodeData(t, callEntryHandler(ListDeletedDatasetEntries, dataset.ID, "", "", ""), &deleted)
+	if deleted.Total != 0 {
+		t.Errorf("restored entry still in the recycle bin: %+v", deleted)
+	}
+	if w := callEntryHandler(RestoreDatasetEntry, dataset.ID, entryID, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second restore: status %d", w.Code)
+	}
+
+	var changelog DatasetChangelogData
+	decodeData(t, callEntryHandler(GetDatasetChangelog, dataset.ID, "", "", ""), &changelog)
+	want := map[string]int64{"create": 1, "update": 1, "revert": 1, "delete": 1, "restore": 1}
+	if changelog.Total != 5 || len(changelog.Summary) != len(want) {
+		t.Fatalf("changelog %+v", changelog)
+	}
+	for action, count := range want {
+		if changelog.Summary[action] != count {
+			t.Errorf("changelog summary %v, want %v", changelog.Summary, want)
+			break
+		}
+	}
+	decodeData(t, callEntryHandler(GetDatasetChangelog, dataset.ID, "", "action=delete", ""), &changelog)
+	if changelog.Total != 1 || changelog.Revisions[0].After != nil {
+		t.Errorf("delete changelog %+v", changelog)
+	}
+	if w := callEntryHandler(GetDatasetChangelog, dataset.ID, "", "from=99", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown version: status %d", w.Code)
+	}
+}
+
+func TestRestoreBulkDeletedEntry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataset := setupEntryDataset(t)
+	for _, output := range []string{"a0", "a1", "a2"} {
+		decodeData(t, callEntryHandler(CreateDatasetEntry, dataset.ID, "", "", `{"instruction": "q", "output": "`+output+`"}`), nil)
+	}
+
+	var result BulkDatasetEntriesResult
+	decodeData(t, callEntryHandler(BulkDatasetEntries, dataset.ID, "", "", `{"operations": [{"op": "delete", "entry_index": 1}]}`), &result)
+	if result.Deleted != 1 || !result.Compacted || result.EntryCount != 2 {
+		t.Fatalf("bulk result %+v", result)
+	}
+
+	// 批量删除的条目保留删除前的位置和内容
+	var deleted DatasetEntryRevisionsData
+	decodeData(t, callEntryHandler(ListDeletedDatasetEntries, dataset.ID, "", "", ""), &deleted)
+	if deleted.Total != 1 {
+		t.Fatalf("deleted entries %+v", deleted)
+	}
+	revision := deleted.Revisions[0]
+	if revision.EntryIndex != model.DetachedEntryIndex || revision.DeletedIndex == nil || *revision.DeletedIndex != 1 || revision.Before["output"] != "a1" {
+		t.Fatalf("bulk delete revision %+v", revision)
+	}
+
+	// 原来的位置已被压缩，恢复后追加到末尾
+	revisionID := strconv.Itoa(int(revision.ID))
+	var entry DatasetEntryDTO
+	decodeData(t, callEntryHandler(RestoreDeletedDatasetEntry, dataset.ID, revisionID, "", ""), &entry)
+	if entry.EntryIndex != 2 || entry.Output != "a1" {
+		t.Errorf("restored entry %+v", entry)
+	}
+	decodeData(t, callEntryHandler(ListDeletedDatasetEntries, dataset.ID, "", "", ""), &deleted)
+	if deleted.Total != 0 {
+		t.Errorf("restored entry still in the recycle bin: %+v", deleted)
+	}
+	if w := callEntryHandler(RestoreDeletedDatasetEntry, dataset.ID, revisionID, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second restore: status %d", w.Code)
+	}
+
+	var history DatasetEntryRevisionsData
+	decodeData(t, callEntryHandler(GetDatasetEntryHistory, dataset.ID, "2", "", ""), &history)
+	if history.Total != 1 || history.Revisions[0].Action != model.EntryRevisionRestore || history.Revisions[0].RestoredFrom != revision.ID {
+		t.Errorf("history of the restored entry %+v", history)
+	}
+}
+
+func TestDeleteDatasetEntryRevisionFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataset := setupEntryDataset(t)
+
+	var entry DatasetEntryDTO
+	decodeData(t, callEntryHandler(CreateDatasetEntry, dataset.ID, "", "", `{"instruction": "q", "output": "a"}`), &entry)
+
+	// 删除修订写入失败时条目不能被删除，否则无法从回收站恢复
+	if err := model.DB.Migrator().DropTable(&model.DatasetEntryRevision{}); err != nil {
+		t.Fatalf("drop revisions: %v", err)
+	}
+	if w := callEntryHandler(DeleteDatasetEntry, dataset.ID, strconv.Itoa(entry.EntryIndex), "", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var count int64
+	model.DB.Model(&model.DatasetEntry{}).Where("dataset_id = ?", dataset.ID).Count(&count)
+	var current model.Dataset
+	model.DB.First(&current, dataset.ID)
+	if count != 1 || current.EntryCount != 1 {
+		t.Errorf("entries %d, entry_count %d after failed delete", count, current.EntryCount)
+	}
+}
+
+func TestDeleteDatasetEntryByIDAfterCompaction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataset := setupEntryDataset(t)
+	var entries []DatasetEntryDTO
+	for _, output := range []string{"a0", "a1", "a2"} {
+		var entry DatasetEntryDTO
+		decodeData(t, callEntryHandler(CreateDatasetEntry, dataset.ID, "", "", `{"instruction": "q", "output": "`+output+`"}`), &entry)
+		entries = append(entries, entry)
+	}
+	decodeData(t, callEntryHandler(BulkDatasetEntries, dataset.ID, "", "", `{"operations": [{"op": "delete", "entry_index": 0}]}`), nil)
+
+	// 压缩后按条目ID删除的是该条目，而不是当前位于旧索引处的条目
+	last := strconv.Itoa(int(entries[2].ID))
+	decodeData(t, callEntryHandler(DeleteDatasetEntry, dataset.ID, last, "", ""), nil)
+	var remaining []model.DatasetEntry
+	model.DB.Where("dataset_id = ?", dataset.ID).Find(&remaining)
+	if len(remaining) != 1 || remaining[0].ID != entries[1].ID || remaining[0].EntryIndex != 0 {
+		t.Errorf("remaining entries %+v", remaining)
+	}
+}
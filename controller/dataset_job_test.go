@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func cancelDatasetJob(t *testing.T, id uint) model.DatasetJob {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(id))}}
+	CancelDatasetJob(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data model.DatasetJob `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body.Data
+}
+
+func TestCancelDatasetJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t, &model.DatasetJob{})
+
+	// 不在本进程中运行的任务直接标记为取消
+	pending := model.DatasetJob{DatasetID: 1, UserID: 1, Type: model.DatasetJobImport, Status: model.DatasetJobPending}
+	model.DB.Create(&pending)
+	if job := cancelDatasetJob(t, pending.ID); job.Status != model.DatasetJobCanceled || job.FinishedAt == nil {
+		t.Errorf("pending job after cancel: %+v", job)
+	}
+
+	// 运行中的任务回滚结束后返回取消状态
+	running := model.DatasetJob{DatasetID: 1, UserID: 1, Type: model.DatasetJobImport, Status: model.DatasetJobPending}
+	model.DB.Create(&running)
+	started := make(chan struct{})
+	rolledBack := false
+	startDatasetJob(&running, func(ctx context.Context, job *model.DatasetJob) (map[string]interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		rolledBack = true
+		return nil, ctx.Err()
+	}, nil)
+	<-started
+	if job := cancelDatasetJob(t, running.ID); job.Status != model.DatasetJobCanceled || !rolledBack {
+		t.Errorf("running job after cancel: %+v, rolled back %v", job, rolledBack)
+	}
+}
+
+func TestDatasetImportRevision(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataset := setupEntryDataset(t)
+	if err := model.DB.AutoMigrate(&model.DatasetJob{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	decodeData(t, callEntryHandler(CreateDatasetEntry, dataset.ID, "", "", `{"instruction": "q0", "output": "a0"}`), nil)
+
+	path := filepath.Join(t.TempDir(), "import.jsonl")
+	content := "{\"instruction\": \"q1\", \"output\": \"a1\"}\n{\"instruction\": \"q2\", \"output\": \"a2\"}\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	schema, err := datasetSchema(dataset)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	job := model.DatasetJob{DatasetID: dataset.ID, UserID: 2, Type: model.DatasetJobImport, Status: model.DatasetJobRunning}
+	model.DB.Create(&job)
+	options := datasetImportOptions{
+		filePath: path,
+		size:     int64(len(content)),
+		format:   services.ImportFormatJSONL,
+		schema:   schema,
+		mapping:  schema.Mapping(services.ImportFieldMapping{}),
+	}
+	if _, err := runDatasetImport(dataset.ID, options)(context.Background(), &job); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	// 导入只记录一条数据集级别的修订
+	var changelog DatasetChangelogData
+	decodeData(t, callEntryHandler(GetDatasetChangelog, dataset.ID, "", "action=import", ""), &changelog)
+	if changelog.Total != 1 {
+		t.Fatalf("import changelog %+v", changelog)
+	}
+	revision := changelog.Revisions[0]
+	if revision.EntryIndex != model.DetachedEntryIndex || revision.UserID != 2 ||
+		revision.After["job_id"] != float64(job.ID) || revision.After["first_index"] != float64(1) || revision.After["entry_count"] != float64(2) {
+		t.Errorf("import revision %+v", revision)
+	}
+}
@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 合并流水线最多读取的来源数据集数
+const maxPipelineSources = 20
+
+// errPipelineLimitReached 流水线的抽样上限已达到，停止读取后续条目
+var errPipelineLimitReached = errors.New("pipeline limit reached")
+
+// DatasetPipelineRequest 合并处理数据集的请求，完整保存在输出数据集的 Lineage.Params 中，可原样重新执行
+type DatasetPipelineRequest struct {
+	Name             string                  `json:"name" binding:"required"`
+	Description      string                  `json:"description"`
+	ProjectID        uint                    `json:"project_id"`
+	StorageType      string                  `json:"storage_type"`  // database(默认)/minio/both
+	TemplateType     string                  `json:"template_type"` // 默认与第一个来源数据集相同
+	SchemaDefinition string                  `json:"schema_definition"`
+	SourceIDs        []uint                  `json:"source_ids" binding:"required"` // 按顺序读取，去重时保留先出现的条目
+	Steps            []services.PipelineStep `json:"steps"`
+}
+
+// RerunDatasetPipelineRequest 重新执行流水线的请求
+type RerunDatasetPipelineRequest struct {
+	Name string `json:"name"` // 新数据集名称，默认在原名称后追加时间
+}
+
+// checkDatasetAccess 校验当前用户对数据集的权限，失败时写入错误响应
+func checkDatasetAccess(c *gin.Context, dataset *model.Dataset, action model.ProjectAction) bool {
+	if !checkTokenProject(c, dataset.ProjectID) {
+		return false
+	}
+	owner := &model.ResourceOwner{ProjectID: dataset.ProjectID, UserID: dataset.UserID}
+	allowed, err := model.CheckProjectPermission(uint(c.GetInt("user_id")), c.GetInt("role"), owner, action)
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("无权访问数据集 %d", dataset.ID),
+		})
+		return false
+	}
+	return true
+}
+
+// pipelineFields 返回条目的原始记录，没有原始内容的旧条目使用 instruction/input/output 三列
+func pipelineFields(entry model.DatasetEntry) (map[string]interface{}, error) {
+	if entry.RawContent == "" {
+		return map[string]interface{}{
+			"instruction": entry.Instruction,
+			"input":       entry.Input,
+			"output":      entry.Output,
+		}, nil
+	}
+	return services.DecodeDatasetRecord([]byte(entry.RawContent))
+}
+
+// runDatasetPipeline 依次流式读取来源数据集，经流水线处理并按输出数据集的 Schema 校验后写入
+func runDatasetPipeline(req DatasetPipelineRequest, pipeline *services.Pipeline, output *model.Dataset) datasetJobFunc {
+	return func(ctx context.Context, job *model.DatasetJob) (map[string]interface{}, error) {
+		schema, err := datasetSchema(output)
+		if err != nil {
+			return nil, err
+		}
+		w, err := newDatasetEntryWriter(output)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Abort()
+
+		var processed, invalid int64
+		for _, sourceID := range req.SourceIDs {
+			var source model.Dataset
+			if err := model.DB.First(&source, sourceID).Error; err != nil {
+				return nil, fmt.Errorf("数据集 %d 不存在", sourceID)
+			}
+			err := forEachDatasetEntry(&source, func(entry model.DatasetEntry) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if pipeline.Done() {
+					return errPipelineLimitReached
+				}
+				processed++
+				if processed%datasetExportProgressInterval == 0 {
+					if err := model.UpdateDatasetJobProgress(job.ID, processed, w.count); err != nil {
+						return err
+					}
+				}
+
+				fields, err := pipelineFields(entry)
+				if err != nil {
+					invalid++
+					return nil
+				}
+				fields, ok := pipeline.Process(services.PipelineRecord{SourceID: source.ID, Index: entry.EntryIndex, Fields: fields})
+				if !ok {
+					return nil
+				}
+				record, err := schema.Record(fields, services.ImportFieldMapping{})
+				if err != nil {
+					invalid++
+					return nil
+				}
+				raw, err := json.Marshal(fields)
+				if err != nil {
+					invalid++
+					return nil
+				}
+				return w.Write(model.DatasetEntry{
+					Instruction: record.Instruction,
+					Input:       record.Input,
+					Output:      record.Output,
+					RawContent:  string(raw),
+				})
+			})
+			if errors.Is(err, errPipelineLimitReached) {
+				break
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("处理数据集 %d 失败: %v", source.ID, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		steps := make([]string, 0, len(req.Steps))
+		for _, stats := range pipeline.Stats() {
+			steps = append(steps, fmt.Sprintf("%s 丢弃%d条", stats.Type, stats.Dropped))
+		}
+		message := fmt.Sprintf("处理完成: 读取%d条，写入%d条，不符合Schema %d条", processed, w.count, invalid)
+		if len(steps) > 0 {
+			message += "；" + strings.Join(steps, "，")
+		}
+		return map[string]interface{}{
+			"processed": processed,
+			"succeeded": w.count,
+			"failed":    invalid,
+			"message":   message,
+		}, nil
+	}
+}
+
+// startDatasetPipeline 校验请求、创建输出数据集并启动流水线任务
+func startDatasetPipeline(c *gin.Context, req DatasetPipelineRequest) {
+	if len(req.SourceIDs) == 0 || len(req.SourceIDs) > maxPipelineSources {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("来源数据集数量须在1到%d之间", maxPipelineSources),
+		})
+		return
+	}
+	if req.StorageType == "" {
+		req.StorageType = "database"
+	}
+	if req.StorageType != "database" && req.StorageType != "minio" && req.StorageType != "both" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "不支持的存储类型: " + req.StorageType,
+		})
+		return
+	}
+
+	var sources []model.Dataset
+	var total int64
+	for _, id := range req.SourceIDs {
+		var source model.Dataset
+		if err := model.DB.First(&source, id).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("数据集 %d 不存在", id),
+			})
+			return
+		}
+		if !checkDatasetAccess(c, &source, model.ActionView) {
+			return
+		}
+		sources = append(sources, source)
+		total += source.EntryCount
+	}
+	if req.TemplateType == "" {
+		req.TemplateType = sources[0].TemplateType
+	}
+
+	output := model.Dataset{
+		Name:             req.Name,
+		Description:      req.Description,
+		StorageType:      req.StorageType,
+		TemplateType:     req.TemplateType,
+		SchemaDefinition: req.SchemaDefinition,
+		ProjectID:        req.ProjectID,
+		UserID:           uint(c.GetInt("user_id")),
+	}
+	schema, err := datasetSchema(&output)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "数据集Schema无效: " + err.Error(),
+		})
+		return
+	}
+	pipeline, err := services.CompilePipeline(req.Steps, schema)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "流水线定义错误: " + err.Error(),
+		})
+		return
+	}
+	params, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := model.DB.Create(&output).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "创建数据集失败: " + err.Error(),
+		})
+		return
+	}
+	job := newDatasetJob(c, &output, model.DatasetJobPipeline)
+	job.Total = total
+	if err := model.DB.Create(job).Error; err != nil {
+		removeDerivedDataset(output.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "创建处理任务失败: " + err.Error(),
+		})
+		return
+	}
+	lineage := model.DatasetLineage{
+		Operation: model.DatasetJobPipeline,
+		JobID:     job.ID,
+		Sources:   req.SourceIDs,
+		Params:    params,
+	}
+	if len(req.SourceIDs) == 1 {
+		lineage.ParentID = req.SourceIDs[0]
+	}
+	output.SetLineage(lineage)
+	initDatasetStorage(&output)
+	model.DB.Model(&output).Select("parent_id", "lineage").Updates(&output)
+	auditResource(c, model.ResourceDataset, output.ID, output.ProjectID, nil).
+		SetAfter(gin.H{"job": job, "dataset": convertToDatasetDTO(output)})
+
+	children := []model.Dataset{output}
+	startDatasetJob(job, runDatasetPipeline(req, pipeline, &children[0]), cleanupDerivedDatasets(job.ID, children))
+	respondDerivedDatasets(c, "处理任务已创建", job, children)
+}
+
+// RunDatasetPipeline 合并处理数据集
+// @Summary 合并处理数据集
+// @Description 按顺序读取多个来源数据集，依次执行流水线步骤(rename/filter_regex/filter_length/dedupe/sample)，
+// @Description 结果写入新的数据集；流水线定义保存在新数据集的 lineage 中，可通过 POST /api/dataset/{id}/pipeline/rerun 重新执行
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param request body DatasetPipelineRequest true "来源数据集和流水线定义"
+// @Success 200 {object} SuccessResponse{data=DerivedDatasetsData}
+// @Failure 400 {object} ErrorResponse
+// @Router /api/dataset/pipeline [post]
+func RunDatasetPipeline(c *gin.Context) {
+	var req DatasetPipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	startDatasetPipeline(c, req)
+}
+
+// RerunDatasetPipeline 按数据集记录的流水线定义重新生成数据集
+// @Summary 重新执行数据处理流水线
+// @Description 读取数据集 lineage 中保存的来源和流水线定义，以来源数据集的当前内容生成新的数据集
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "流水线生成的数据集ID"
+// @Param request body RerunDatasetPipelineRequest false "新数据集名称"
+// @Success 200 {object} SuccessResponse{data=DerivedDatasetsData}
+// @Failure 400 {object} ErrorResponse
+// @Router /api/dataset/{id}/pipeline/rerun [post]
+func RerunDatasetPipeline(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input RerunDatasetPipelineRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	lineage := dataset.GetLineage()
+	var req DatasetPipelineRequest
+	if lineage == nil || lineage.Operation != model.DatasetJobPipeline || json.Unmarshal(lineage.Params, &req) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "该数据集不是由数据处理流水线生成的",
+		})
+		return
+	}
+
+	// 在原数据集当前所属的项目中重新生成
+	req.ProjectID = dataset.ProjectID
+	req.Name = input.Name
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("%s-%s", dataset.Name, time.Now().Format("20060102150405"))
+	}
+	if req.ProjectID != 0 {
+		if !checkDatasetAccess(c, &model.Dataset{ProjectID: req.ProjectID}, model.ActionCreate) {
+			return
+		}
+	}
+	startDatasetPipeline(c, req)
+}
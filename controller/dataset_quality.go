@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyzeDatasetRequest 质量分析请求，字段均可省略
+type AnalyzeDatasetRequest struct {
+	services.QualityOptions
+}
+
+// DeleteFlaggedEntriesRequest 按质量报告批量删除条目的请求
+type DeleteFlaggedEntriesRequest struct {
+	ReportID uint     `json:"report_id" binding:"required"`
+	Issues   []string `json:"issues" binding:"required"` // duplicate/near_duplicate/empty/too_long
+}
+
+// DatasetQualityReportDTO 数据集质量报告
+type DatasetQualityReportDTO struct {
+	model.DatasetQualityReport
+	Report json.RawMessage `json:"report"`
+	// 报告生成后数据集条目已被修改，不能再按报告删除条目
+	Stale bool `json:"stale"`
+}
+
+// qualityEntry 将条目转为分析输入，原始内容中没有对应源字段的列计为缺失
+func qualityEntry(schema *services.DatasetSchema, entry model.DatasetEntry) services.QualityEntry {
+	result := services.QualityEntry{Index: entry.EntryIndex, Fields: entrySearchFields(entry)}
+	if entry.RawContent != "" {
+		if fields, err := services.DecodeDatasetRecord([]byte(entry.RawContent)); err == nil {
+			result.Missing = schema.MissingFields(fields)
+		}
+	}
+	return result
+}
+
+// runDatasetAnalysis 遍历数据集生成质量报告，只保留最近一次的报告
+func runDatasetAnalysis(datasetID uint, options services.QualityOptions) datasetJobFunc {
+	return func(ctx context.Context, job *model.DatasetJob) (map[string]interface{}, error) {
+		var dataset model.Dataset
+		if err := model.DB.First(&dataset, datasetID).Error; err != nil {
+			return nil, fmt.Errorf("数据集不存在: %v", err)
+		}
+		schema, _ := datasetSchema(&dataset)
+
+		analyzer := services.NewQualityAnalyzer(options)
+		var processed int64
+		err := forEachDatasetEntry(&dataset, func(entry model.DatasetEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			analyzer.Add(qualityEntry(schema, entry))
+			processed++
+			if processed%datasetExportProgressInterval == 0 {
+				return model.UpdateDatasetJobProgress(job.ID, processed, processed)
+			}
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("读取数据集失败: %v", err)
+		}
+
+		report, flagged := analyzer.Finish()
+		data, err := json.Marshal(report)
+		if err != nil {
+			return nil, err
+		}
+		err = model.SaveDatasetQualityReport(&model.DatasetQualityReport{
+			DatasetID:               dataset.ID,
+			JobID:                   job.ID,
+			UserID:                  job.UserID,
+			DatasetEntryCount:       dataset.EntryCount,
+			DatasetContentUpdatedAt: dataset.ContentUpdatedAt,
+			Report:                  string(data),
+		}, flagged)
+		if err != nil {
+			return nil, fmt.Errorf("保存质量报告失败: %v", err)
+		}
+
+		return map[string]interface{}{
+			"processed": processed,
+			"succeeded": processed,
+			"message": fmt.Sprintf("分析完成: %d条数据，重复%d条，近似重复%d条，字段为空%d条，超长%d条",
+				processed,
+				report.Issues[services.QualityIssueDuplicate],
+				report.Issues[services.QualityIssueNearDuplicate],
+				report.Issues[services.QualityIssueEmpty],
+				report.Issues[services.QualityIssueTooLong]),
+		}, nil
+	}
+}
+
+// AnalyzeDataset 创建数据集质量分析任务
+// @Summary 分析数据集质量
+// @Description 在后台统计字段长度分布、完全重复和近似重复(MinHash)、空字段、超长条目及语言分布，
+// @Description 通过 GET /api/dataset/jobs/{id} 查询进度，完成后通过 GET /api/dataset/{id}/analysis 获取报告
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param options body AnalyzeDatasetRequest false "分析参数"
+// @Success 200 {object} SuccessResponse{data=DatasetJobDTO}
+// @Failure 409 {object} ErrorResponse
+// @Router /api/dataset/{id}/analyze [post]
+func AnalyzeDataset(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req AnalyzeDatasetRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	if err := req.QualityOptions.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	active, err := model.HasActiveDatasetJob(dataset.ID, model.DatasetJobAnalyze)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "查询分析任务失败: " + err.Error(),
+		})
+		return
+	}
+	if active {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "该数据集已有进行中的分析任务",
+		})
+		return
+	}
+
+	job := newDatasetJob(c, dataset, model.DatasetJobAnalyze)
+	job.Total = dataset.EntryCount
+	if err := model.DB.Create(job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "创建分析任务失败: " + err.Error(),
+		})
+		return
+	}
+	auditResource(c, model.ResourceDatasetJob, job.ID, job.ProjectID, nil).SetAfter(job)
+
+	startDatasetJob(job, runDatasetAnalysis(dataset.ID, req.QualityOptions), nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "分析任务已创建",
+		"data":    convertToDatasetJobDTO(job),
+	})
+}
+
+// GetDatasetAnalysis 获取数据集最近一次的质量报告
+// @Summary 获取数据集质量报告
+// @Description 报告中的重复组和问题条目只列出示例，各类问题的完整条目数见 report.issues
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Success 200 {object} SuccessResponse{data=DatasetQualityReportDTO}
+// @Failure 404 {object} ErrorResponse
+// @Router /api/dataset/{id}/analysis [get]
+func GetDatasetAnalysis(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	report, ok := getLatestQualityReport(c, dataset)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": DatasetQualityReportDTO{
+			DatasetQualityReport: *report,
+			Report:               json.RawMessage(report.Report),
+			Stale:                report.IsStale(dataset),
+		},
+	})
+}
+
+func getLatestQualityReport(c *gin.Context, dataset *model.Dataset) (*model.DatasetQualityReport, bool) {
+	report, err := model.GetLatestDatasetQualityReport(dataset.ID)
+	if err != nil {
+		if errors.Is(err, model.ErrDatasetQualityReportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "数据集尚未进行质量分析",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "获取质量报告失败: " + err.Error(),
+			})
+		}
+		return nil, false
+	}
+	return report, true
+}
+
+// DeleteFlaggedEntries 删除质量报告中标记的条目
+// @Summary 删除质量报告标记的条目
+// @Description 删除最近一次质量报告中属于指定问题类型的全部条目；完全重复和近似重复只删除每组第一个条目之外的条目
+// @Description 报告生成后数据集条目被修改过时返回409，需要重新分析
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param request body DeleteFlaggedEntriesRequest true "报告ID和问题类型"
+// @Success 200 {object} SuccessResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/dataset/{id}/analysis/delete [post]
+func DeleteFlaggedEntries(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req DeleteFlaggedEntriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	for _, issue := range req.Issues {
+		if !isQualityIssue(issue) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "不支持的问题类型: " + issue,
+			})
+			return
+		}
+	}
+
+	// 在条目锁内重新读取数据集并检查报告，避免检查之后、删除之前条目被修改
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+	if err := model.DB.First(dataset, dataset.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "数据集不存在",
+		})
+		return
+	}
+	report, ok := getLatestQualityReport(c, dataset)
+	if !ok {
+		return
+	}
+	if report.ID != req.ReportID || report.IsStale(dataset) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "质量报告已过期，请重新分析",
+		})
+		return
+	}
+
+	indexes, err := model.GetDatasetQualityFlags(report.ID, req.Issues)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取问题条目失败: " + err.Error(),
+		})
+		return
+	}
+	audit := auditResource(c, model.ResourceDataset, dataset.ID, dataset.ProjectID, gin.H{"entry_count": dataset.EntryCount})
+
+	deleted, err := deleteDatasetEntriesLocked(dataset, indexes, uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除数据集条目失败: " + err.Error(),
+		})
+		return
+	}
+	// 条目已变化，报告中的条目不能再用于删除
+	_ = model.DB.Model(report).UpdateColumn("dataset_entry_count", -1).Error
+	audit.SetAfter(gin.H{"report_id": report.ID, "issues": req.Issues, "deleted": deleted})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("已删除%d条数据", deleted),
+		"data": gin.H{
+			"deleted":       deleted,
+			"entry_indexes": indexes,
+		},
+	})
+}
+
+func isQualityIssue(issue string) bool {
+	for _, name := range services.QualityIssues {
+		if issue == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// saveQualityReport 按数据集当前状态保存质量报告，flagged 为标记的问题条目
+func saveQualityReport(t *testing.T, datasetID uint, flagged map[string][]int) *model.DatasetQualityReport {
+	t.Helper()
+	var dataset model.Dataset
+	if err := model.DB.First(&dataset, datasetID).Error; err != nil {
+		t.Fatalf("load dataset: %v", err)
+	}
+	report := &model.DatasetQualityReport{
+		DatasetID:               dataset.ID,
+		DatasetEntryCount:       dataset.EntryCount,
+		DatasetContentUpdatedAt: dataset.ContentUpdatedAt,
+		Report:                  "{}",
+	}
+	if err := model.SaveDatasetQualityReport(report, flagged); err != nil {
+		t.Fatalf("save report: %v", err)
+	}
+	return report
+}
+
+func TestDeleteFlaggedEntriesStaleReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataset := setupEntryDataset(t)
+	for i := 0; i < 2; i++ {
+		decodeData(t, callEntryHandler(CreateDatasetEntry, dataset.ID, "", "", `{"instruction": "q", "output": "a"}`), nil)
+	}
+	flagged := map[string][]int{services.QualityIssueDuplicate: {1}}
+	request := func(report *model.DatasetQualityReport) string {
+		return `{"report_id": ` + strconv.Itoa(int(report.ID)) + `, "issues": ["duplicate"]}`
+	}
+
+	// 条目数不变的修改同样使报告过期
+	report := saveQualityReport(t, dataset.ID, flagged)
+	decodeData(t, callEntryHandler(UpdateDatasetEntry, dataset.ID, "0", "", `{"instruction": "q", "output": "b"}`), nil)
+	var analysis DatasetQualityReportDTO
+	decodeData(t, callEntryHandler(GetDatasetAnalysis, dataset.ID, "", "", ""), &analysis)
+	if !analysis.Stale {
+		t.Errorf("report not stale after update: %+v", analysis)
+	}
+	if w := callEntryHandler(DeleteFlaggedEntries, dataset.ID, "", "", request(report)); w.Code != http.StatusConflict {
+		t.Fatalf("stale report: status %d: %s", w.Code, w.Body.String())
+	}
+
+	report = saveQualityReport(t, dataset.ID, flagged)
+	decodeData(t, callEntryHandler(DeleteFlaggedEntries, dataset.ID, "", "", request(report)), nil)
+	var count int64
+	model.DB.Model(&model.DatasetEntry{}).Where("dataset_id = ?", dataset.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("entries after delete: %d", count)
+	}
+}
@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"bufio"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 数据集条目的全文索引按 entry_index 保存检索文档，数据库和MinIO存储的数据集共用
+// 单条增删改时同步更新索引，导入完成后整体重建；SearchIndexedAt 为空的数据集在检索前重建
+
+// 重建索引时每批写入的文档数
+const datasetSearchBatchSize = 500
+
+// datasetSearchLocks 每个数据集一把锁，避免并发重建同一数据集的索引
+var datasetSearchLocks sync.Map
+
+func lockDatasetSearch(datasetID uint) func() {
+	value, _ := datasetSearchLocks.LoadOrStore(datasetID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// newDatasetSearchDoc 生成条目的检索文档，FTS5 按单字索引中日韩文本
+func newDatasetSearchDoc(entry model.DatasetEntry) model.DatasetSearchDoc {
+	doc := model.DatasetSearchDoc{
+		DatasetID:   entry.DatasetID,
+		EntryIndex:  entry.EntryIndex,
+		Instruction: entry.Instruction,
+		Input:       entry.Input,
+		Output:      entry.Output,
+	}
+	if model.SearchBackend == model.SearchBackendFTS5 {
+		doc.Instruction = services.SegmentCJK(doc.Instruction)
+		doc.Input = services.SegmentCJK(doc.Input)
+		doc.Output = services.SegmentCJK(doc.Output)
+	}
+	return doc
+}
+
+// indexDatasetEntries 更新条目的检索文档，索引尚未建立时跳过；更新失败时标记索引待重建
+func indexDatasetEntries(dataset *model.Dataset, entries ...model.DatasetEntry) {
+	if model.SearchBackend == model.SearchBackendNone || dataset.SearchIndexedAt == nil {
+		return
+	}
+	docs := make([]model.DatasetSearchDoc, len(entries))
+	for i, entry := range entries {
+		docs[i] = newDatasetSearchDoc(entry)
+	}
+	if err := model.SaveDatasetSearchDocs(docs); err != nil {
+		common.SysError(fmt.Sprintf("failed to index entries of dataset %d: %v", dataset.ID, err))
+		_ = model.MarkDatasetSearchStale(dataset.ID)
+	}
+}
+
+// unindexDatasetEntries 删除条目的检索文档
+func unindexDatasetEntries(dataset *model.Dataset, entryIndexes ...int) {
+	if model.SearchBackend == model.SearchBackendNone || dataset.SearchIndexedAt == nil {
+		return
+	}
+	if err := model.DeleteDatasetSearchDocs(dataset.ID, entryIndexes...); err != nil {
+		common.SysError(fmt.Sprintf("failed to unindex entries of dataset %d: %v", dataset.ID, err))
+		_ = model.MarkDatasetSearchStale(dataset.ID)
+	}
+}
+
+// rebuildDatasetSearchIndex 重新生成数据集的全部检索文档
+func rebuildDatasetSearchIndex(dataset *model.Dataset) error {
+	if model.SearchBackend == model.SearchBackendNone {
+		return nil
+	}
+	unlock := lockDatasetSearch(dataset.ID)
+	defer unlock()
+	return rebuildDatasetSearchIndexLocked(dataset)
+}
+
+func rebuildDatasetSearchIndexLocked(dataset *model.Dataset) error {
+	if err := model.MarkDatasetSearchStale(dataset.ID); err != nil {
+		return err
+	}
+	dataset.SearchIndexedAt = nil
+	if err := model.DeleteDatasetSearchDocs(dataset.ID); err != nil {
+		return err
+	}
+
+	docs := make([]model.DatasetSearchDoc, 0, datasetSearchBatchSize)
+	err := forEachDatasetEntry(dataset, func(entry model.DatasetEntry) error {
+		docs = append(docs, newDatasetSearchDoc(entry))
+		if len(docs) < datasetSearchBatchSize {
+			return nil
+		}
+		err := model.SaveDatasetSearchDocs(docs)
+		docs = docs[:0]
+		return err
+	})
+	if err == nil {
+		err = model.SaveDatasetSearchDocs(docs)
+	}
+	if err != nil {
+		return fmt.Errorf("重建检索索引失败: %v", err)
+	}
+
+	if err := model.MarkDatasetSearchIndexed(dataset.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	dataset.SearchIndexedAt = &now
+	return nil
+}
+
+// ensureDatasetSearchIndex 数据集的索引尚未建立或已失效时先重建
+func ensureDatasetSearchIndex(dataset *model.Dataset) error {
+	if dataset.SearchIndexedAt != nil {
+		return nil
+	}
+	unlock := lockDatasetSearch(dataset.ID)
+	defer unlock()
+
+	// 等待锁期间可能已被其他请求重建
+	var current model.Dataset
+	if err := model.DB.Select("search_indexed_at").First(&current, dataset.ID).Error; err != nil {
+		return err
+	}
+	if current.SearchIndexedAt != nil {
+		dataset.SearchIndexedAt = current.SearchIndexedAt
+		return nil
+	}
+	return rebuildDatasetSearchIndexLocked(dataset)
+}
+
+// forEachDatasetEntry 按 entry_index 顺序遍历数据集的全部条目，跳过已删除的占位行
+func forEachDatasetEntry(dataset *model.Dataset, fn func(model.DatasetEntry) error) error {
+	if dataset.StorageType != "minio" {
+		var batch []model.DatasetEntry
+		var fnErr error
+		result := model.DB.Where("dataset_id = ?", dataset.ID).Order("entry_index ASC").
+			FindInBatches(&batch, datasetSearchBatchSize, func(tx *gorm.DB, _ int) error {
+				for _, entry := range batch {
+					if fnErr = fn(entry); fnErr != nil {
+						return fnErr
+					}
+				}
+				return nil
+			})
+		if fnErr != nil {
+			return fnErr
+		}
+		return result.Error
+	}
+
+	if dataset.BucketName == "" || dataset.ObjectPath == "" {
+		return fmt.Errorf("数据集MinIO存储信息不完整")
+	}
+	object, err := openMinioDataset(dataset)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	schema, _ := datasetSchema(dataset)
+	reader := bufio.NewReader(object)
+	for index := 0; ; index++ {
+		line, ok, err := readJSONLLine(reader)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if isEmptyEntryLine(line) {
+			continue
+		}
+		entry, err := entryFromLine(schema, dataset.ID, index, line)
+		if err != nil {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// searchDatasetEntries 检索数据集条目，返回按 entry_index 排序的一页结果和命中总数
+// 数据库不支持全文索引时，数据库存储用 LIKE 条件查询，MinIO存储逐条扫描
+func searchDatasetEntries(dataset *model.Dataset, query *services.SearchQuery, offset, limit int) ([]model.DatasetEntry, int64, error) {
+	var indexes []int
+	var total int64
+	var err error
+	switch model.SearchBackend {
+	case model.SearchBackendFTS5, model.SearchBackendMySQL:
+		if err := ensureDatasetSearchIndex(dataset); err != nil {
+			return nil, 0, err
+		}
+		if model.SearchBackend == model.SearchBackendFTS5 {
+			indexes, total, err = model.SearchDatasetFTS(dataset.ID, query.FTS5Expr(), offset, limit)
+		} else {
+			condition, args := query.MySQLCondition()
+			indexes, total, err = model.SearchDatasetFulltext(dataset.ID, condition, args, offset, limit)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		entries, err := loadDatasetEntriesByIndex(dataset, indexes)
+		return entries, total, err
+	}
+
+	if dataset.StorageType != "minio" {
+		condition, args := query.LikeCondition()
+		dbQuery := model.DB.Model(&model.DatasetEntry{}).Where("dataset_id = ?", dataset.ID).Where(condition, args...)
+		if err := dbQuery.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+		var entries []model.DatasetEntry
+		err := dbQuery.Order("entry_index ASC").Offset(offset).Limit(limit).Find(&entries).Error
+		return entries, total, err
+	}
+
+	var entries []model.DatasetEntry
+	err = forEachDatasetEntry(dataset, func(entry model.DatasetEntry) error {
+		if !query.Match(entrySearchFields(entry)) {
+			return nil
+		}
+		if total >= int64(offset) && len(entries) < limit {
+			entries = append(entries, entry)
+		}
+		total++
+		return nil
+	})
+	return entries, total, err
+}
+
+// loadDatasetEntriesByIndex 按 entry_index 读取条目，已不存在的条目被跳过
+func loadDatasetEntriesByIndex(dataset *model.Dataset, indexes []int) ([]model.DatasetEntry, error) {
+	if len(indexes) == 0 {
+		return []model.DatasetEntry{}, nil
+	}
+	if dataset.StorageType != "minio" {
+		var entries []model.DatasetEntry
+		err := model.DB.Where("dataset_id = ? AND entry_index IN ?", dataset.ID, indexes).
+			Order("entry_index ASC").Find(&entries).Error
+		return entries, err
+	}
+
+	schema, _ := datasetSchema(dataset)
+	entries := make([]model.DatasetEntry, 0, len(indexes))
+	for start := 0; start < len(indexes); {
+		// 连续的条目合并为一次范围读取
+		end := start + 1
+		for end < len(indexes) && indexes[end] == indexes[end-1]+1 {
+			end++
+		}
+		lines, err := readMinioDatasetLines(dataset, indexes[start], end-start)
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range lines {
+			if isEmptyEntryLine(line) {
+				continue
+			}
+			entry, err := entryFromLine(schema, dataset.ID, indexes[start]+i, line)
+			if err == nil {
+				entries = append(entries, entry)
+			}
+		}
+		start = end
+	}
+	return entries, nil
+}
+
+func entrySearchFields(entry model.DatasetEntry) map[string]string {
+	return map[string]string{
+		"instruction": entry.Instruction,
+		"input":       entry.Input,
+		"output":      entry.Output,
+	}
+}
@@ -0,0 +1,548 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MinIO存储的数据集按 services.DatasetShardLines 行切分为多个分片对象，分片的行偏移索引保存在数据库中
+// 修改条目只重写所在分片，分页通过范围读取完成。分片每次重写都使用新的对象名，
+// 数据库记录更新后再删除旧对象，写入中途失败不会破坏已有数据
+
+// datasetStorageLocks 每个数据集一把锁，串行化本进程内对分片的读改写
+var datasetStorageLocks sync.Map
+
+func lockDatasetStorage(datasetID uint) func() {
+	value, _ := datasetStorageLocks.LoadOrStore(datasetID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// datasetEntryLocks 每个数据集一把锁，串行化本进程内分配 entry_index 和增删条目的操作(新增、导入、批量修改等)
+// 需要同时持有分片锁时先取此锁
+var datasetEntryLocks sync.Map
+
+func lockDatasetEntries(datasetID uint) func() {
+	value, _ := datasetEntryLocks.LoadOrStore(datasetID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func datasetShardPrefix(datasetID uint) string {
+	return fmt.Sprintf("shards/dataset_%d/", datasetID)
+}
+
+func newDatasetShardPath(datasetID uint, seq int) string {
+	return fmt.Sprintf("%s%06d_%s.jsonl", datasetShardPrefix(datasetID), seq, strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+func shardIndexOf(shard *model.DatasetShard) services.ShardIndex {
+	return services.ShardIndex{
+		LineCount: shard.LineCount,
+		Size:      shard.Size,
+		Offsets:   shard.Offsets(),
+	}
+}
+
+// shardsFromIndexes 根据上传结果生成分片记录，第一个分片的序号为 seq、起始行为 startIndex
+func shardsFromIndexes(datasetID uint, seq, startIndex int, paths []string, indexes []services.ShardIndex) []model.DatasetShard {
+	shards := make([]model.DatasetShard, len(indexes))
+	for i, index := range indexes {
+		shards[i] = model.DatasetShard{
+			DatasetID:  datasetID,
+			Seq:        seq + i,
+			StartIndex: startIndex,
+			LineCount:  index.LineCount,
+			Size:       index.Size,
+			ObjectPath: paths[i],
+		}
+		shards[i].SetOffsets(index.Offsets)
+		startIndex += index.LineCount
+	}
+	return shards
+}
+
+// splitToShards 将 r 切分上传为从 seq 开始的分片，first 为第一个分片已有的行
+func splitToShards(dataset *model.Dataset, r io.Reader, first []string, seq, startIndex int) ([]model.DatasetShard, error) {
+	var paths []string
+	indexes, err := services.SplitJSONLToShards(dataset.BucketName, r, first, func(i int) string {
+		paths = append(paths, newDatasetShardPath(dataset.ID, seq+i))
+		return paths[i]
+	})
+	if err != nil {
+		removeShardObjects(dataset.BucketName, paths)
+		return nil, err
+	}
+	return shardsFromIndexes(dataset.ID, seq, startIndex, paths, indexes), nil
+}
+
+// removeShardObjects 删除不再使用的分片对象，失败只记录日志
+func removeShardObjects(bucketName string, paths []string) {
+	for _, path := range paths {
+		if err := services.DeleteDatasetMinioObject(bucketName, path); err != nil {
+			common.SysError(err.Error())
+		}
+	}
+}
+
+// ensureDatasetSharded 旧版单对象存储的数据集在首次访问时迁移为分片存储
+func ensureDatasetSharded(dataset *model.Dataset) error {
+	if dataset.StorageLayout == model.DatasetLayoutSharded {
+		return nil
+	}
+
+	unlock := lockDatasetStorage(dataset.ID)
+	defer unlock()
+
+	var current model.Dataset
+	if err := model.DB.Select("id", "bucket_name", "object_path", "storage_layout").First(&current, dataset.ID).Error; err != nil {
+		return err
+	}
+	dataset.BucketName = current.BucketName
+	dataset.ObjectPath = current.ObjectPath
+	dataset.StorageLayout = current.StorageLayout
+	if dataset.StorageLayout == model.DatasetLayoutSharded {
+		return nil
+	}
+
+	legacyPath := dataset.ObjectPath
+	if dataset.BucketName == "" {
+		dataset.BucketName = "datasets"
+	}
+	if err := services.EnsureMinioBucket(dataset.BucketName); err != nil {
+		return err
+	}
+
+	var shards []model.DatasetShard
+	legacyExists := false
+	if legacyPath != "" {
+		exists, err := services.MinioObjectExists(dataset.BucketName, legacyPath)
+		if err != nil {
+			return err
+		}
+		if exists {
+			legacyExists = true
+			object, err := services.GetMinioObject(dataset.BucketName, legacyPath)
+			if err != nil {
+				return err
+			}
+			shards, err = splitToShards(dataset, object, nil, 0, 0)
+			object.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	tx := model.DB.Begin()
+	if err := model.ReplaceDatasetShards(tx, dataset.ID, shards); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&model.Dataset{}).Where("id = ?", dataset.ID).Updates(map[string]interface{}{
+		"bucket_name":    dataset.BucketName,
+		"object_path":    datasetShardPrefix(dataset.ID),
+		"storage_layout": model.DatasetLayoutSharded,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	dataset.ObjectPath = datasetShardPrefix(dataset.ID)
+	dataset.StorageLayout = model.DatasetLayoutSharded
+
+	if legacyExists {
+		removeShardObjects(dataset.BucketName, []string{legacyPath})
+	}
+	common.SysLog(fmt.Sprintf("migrated dataset %d to sharded storage (%d shards)", dataset.ID, len(shards)))
+	return nil
+}
+
+// loadDatasetShards 获取数据集的全部分片，必要时先迁移存储
+func loadDatasetShards(dataset *model.Dataset) ([]model.DatasetShard, error) {
+	if err := ensureDatasetSharded(dataset); err != nil {
+		return nil, fmt.Errorf("迁移数据集存储失败: %v", err)
+	}
+	return model.GetDatasetShards(dataset.ID)
+}
+
+func shardsLineCount(shards []model.DatasetShard) int {
+	if len(shards) == 0 {
+		return 0
+	}
+	last := shards[len(shards)-1]
+	return last.StartIndex + last.LineCount
+}
+
+// readMinioDatasetLines 读取 [offset, offset+limit) 行，只读取涉及的分片范围
+func readMinioDatasetLines(dataset *model.Dataset, offset, limit int) ([]string, error) {
+	shards, err := loadDatasetShards(dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, limit)
+	for i := range shards {
+		shard := &shards[i]
+		if len(lines) >= limit {
+			break
+		}
+		next := offset + len(lines)
+		if next >= shard.StartIndex+shard.LineCount {
+			continue
+		}
+		part, err := services.ReadShardLines(dataset.BucketName, shard.ObjectPath, shardIndexOf(shard), next-shard.StartIndex, limit-len(lines))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, part...)
+	}
+	return lines, nil
+}
+
+// openMinioDataset 按顺序读取全部分片，返回完整的JSONL内容
+func openMinioDataset(dataset *model.Dataset) (io.ReadCloser, error) {
+	shards, err := loadDatasetShards(dataset)
+	if err != nil {
+		return nil, err
+	}
+	return openDatasetShards(dataset, shards), nil
+}
+
+func openDatasetShards(dataset *model.Dataset, shards []model.DatasetShard) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		for _, shard := range shards {
+			object, err := services.GetMinioObject(dataset.BucketName, shard.ObjectPath)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(pw, object)
+			object.Close()
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return pr
+}
+
+// appendMinioDatasetLines 追加JSONL内容，只重写最后一个未写满的分片，返回第一行新内容的 entry_index
+func appendMinioDatasetLines(dataset *model.Dataset, r io.Reader) (int, error) {
+	if err := ensureDatasetSharded(dataset); err != nil {
+		return 0, fmt.Errorf("迁移数据集存储失败: %v", err)
+	}
+	unlock := lockDatasetStorage(dataset.ID)
+	defer unlock()
+
+	shards, err := model.GetDatasetShards(dataset.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	firstIndex := shardsLineCount(shards)
+	seq, startIndex := 0, 0
+	var first []string
+	var rewritten *model.DatasetShard
+	if n := len(shards); n > 0 {
+		last := &shards[n-1]
+		seq, startIndex = last.Seq+1, last.StartIndex+last.LineCount
+		if last.LineCount < services.DatasetShardLines {
+			lines, err := services.ReadShard(dataset.BucketName, last.ObjectPath)
+			if err != nil {
+				return 0, err
+			}
+			first, rewritten = lines, last
+			seq, startIndex = last.Seq, last.StartIndex
+		}
+	}
+
+	written, err := splitToShards(dataset, r, first, seq, startIndex)
+	if err != nil {
+		return 0, err
+	}
+	if len(written) > 0 && rewritten != nil {
+		written[0].ID = rewritten.ID
+	}
+	if err := model.SaveDatasetShards(model.DB, written); err != nil {
+		paths := make([]string, len(written))
+		for i := range written {
+			paths[i] = written[i].ObjectPath
+		}
+		removeShardObjects(dataset.BucketName, paths)
+		return 0, err
+	}
+	if len(written) > 0 && rewritten != nil {
+		removeShardObjects(dataset.BucketName, []string{rewritten.ObjectPath})
+	}
+	return firstIndex, nil
+}
+
+// stageMinioDatasetReplace 将 r 中的JSONL上传为数据集的全部分片，apply 后替换原有分片
+func stageMinioDatasetReplace(dataset *model.Dataset, r io.Reader) (*stagedDatasetShards, error) {
+	if err := ensureDatasetSharded(dataset); err != nil {
+		return nil, fmt.Errorf("迁移数据集存储失败: %v", err)
+	}
+	unlock := lockDatasetStorage(dataset.ID)
+	old, err := model.GetDatasetShards(dataset.ID)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	shards, err := splitToShards(dataset, r, nil, 0, 0)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	staged := &stagedDatasetShards{
+		dataset: dataset,
+		replace: true,
+		shards:  shards,
+		next:    shardsLineCount(shards),
+		unlock:  unlock,
+	}
+	for i := range old {
+		staged.obsolete = append(staged.obsolete, old[i].ObjectPath)
+	}
+	return staged, nil
+}
+
+// deleteMinioDataset 删除数据集在MinIO中的全部对象
+func deleteMinioDataset(dataset *model.Dataset) error {
+	if dataset.BucketName == "" || dataset.ObjectPath == "" {
+		return nil
+	}
+	if dataset.StorageLayout == model.DatasetLayoutSharded {
+		return services.RemoveMinioPrefix(dataset.BucketName, dataset.ObjectPath)
+	}
+	return services.DeleteDatasetMinioObject(dataset.BucketName, dataset.ObjectPath)
+}
+
+// stagedDatasetShards 已上传但尚未生效的分片，期间持有数据集的存储锁
+// apply 在调用方的事务中更新分片记录，事务结束后必须调用 finish
+type stagedDatasetShards struct {
+	dataset  *model.Dataset
+	replace  bool // 替换全部分片记录，否则只保存重写和新增的分片
+	shards   []model.DatasetShard
+	obsolete []string // 生效后不再使用的旧对象
+	next     int      // 追加的第一行的 entry_index
+	unlock   func()
+}
+
+// stageMinioDatasetEdit 为一批修改上传新的分片：lines 按原 entry_index 替换行，removed(升序)中的行被移除，
+// 其后的行依次前移，appended 追加在末尾。有移除时重写全部分片，否则只重写涉及的分片和最后一个未写满的分片
+func stageMinioDatasetEdit(dataset *model.Dataset, lines map[int]string, removed []int, appended []string) (*stagedDatasetShards, error) {
+	if err := ensureDatasetSharded(dataset); err != nil {
+		return nil, fmt.Errorf("迁移数据集存储失败: %v", err)
+	}
+	unlock := lockDatasetStorage(dataset.ID)
+	shards, err := model.GetDatasetShards(dataset.ID)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	staged := &stagedDatasetShards{dataset: dataset}
+	if len(removed) > 0 {
+		err = staged.rewrite(shards, lines, removed, appended)
+	} else {
+		err = staged.edit(shards, lines, appended)
+	}
+	if err != nil {
+		staged.finish(false)
+		unlock()
+		return nil, err
+	}
+	staged.unlock = unlock
+	return staged, nil
+}
+
+// rewrite 顺序读取全部分片，去掉移除的行后重新切分上传
+func (s *stagedDatasetShards) rewrite(shards []model.DatasetShard, lines map[int]string, removed []int, appended []string) error {
+	skip := make(map[int]bool, len(removed))
+	for _, index := range removed {
+		skip[index] = true
+	}
+	source := openDatasetShards(s.dataset, shards)
+	defer source.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		reader := bufio.NewReader(source)
+		w := bufio.NewWriter(pw)
+		for index := 0; ; index++ {
+			line, ok, err := readJSONLLine(reader)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if !ok {
+				break
+			}
+			if skip[index] {
+				continue
+			}
+			if replacement, ok := lines[index]; ok {
+				line = replacement
+			}
+			w.WriteString(line + "\n")
+		}
+		for _, line := range appended {
+			w.WriteString(line + "\n")
+		}
+		pw.CloseWithError(w.Flush())
+	}()
+
+	written, err := splitToShards(s.dataset, pr, nil, 0, 0)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	s.replace = true
+	s.shards = written
+	for _, shard := range shards {
+		s.obsolete = append(s.obsolete, shard.ObjectPath)
+	}
+	s.next = shardsLineCount(shards) - len(removed)
+	return nil
+}
+
+// edit 只重写包含替换行的分片，追加的行写入最后一个未写满的分片和新分片
+// 超出末尾的替换行用 {} 补齐后排在 appended 之前
+func (s *stagedDatasetShards) edit(shards []model.DatasetShard, lines map[int]string, appended []string) error {
+	n := len(shards)
+	total := shardsLineCount(shards)
+	var beyond []int
+	for index := range lines {
+		if index >= total {
+			beyond = append(beyond, index)
+		}
+	}
+	sort.Ints(beyond)
+	var tail []string
+	for _, index := range beyond {
+		for total+len(tail) < index {
+			tail = append(tail, "{}")
+		}
+		tail = append(tail, lines[index])
+	}
+	s.next = total + len(tail)
+	appended = append(tail, appended...)
+	appendToLast := len(appended) > 0 && n > 0 && shards[n-1].LineCount < services.DatasetShardLines
+
+	var first []string
+	for i := range shards {
+		shard := shards[i]
+		end := shard.StartIndex + shard.LineCount
+		var content []string
+		read := func() error {
+			var err error
+			if content, err = services.ReadShard(s.dataset.BucketName, shard.ObjectPath); err != nil {
+				return err
+			}
+			if len(content) != shard.LineCount {
+				return fmt.Errorf("分片 %s 的行数与索引不一致", shard.ObjectPath)
+			}
+			return nil
+		}
+		for index, line := range lines {
+			if index < shard.StartIndex || index >= end {
+				continue
+			}
+			if content == nil {
+				if err := read(); err != nil {
+					return err
+				}
+			}
+			content[index-shard.StartIndex] = line
+		}
+		if appendToLast && i == n-1 {
+			// 与追加的行一起重新切分
+			if content == nil {
+				if err := read(); err != nil {
+					return err
+				}
+			}
+			first = content
+			continue
+		}
+		if content == nil {
+			continue
+		}
+
+		oldPath := shard.ObjectPath
+		shard.ObjectPath = newDatasetShardPath(s.dataset.ID, shard.Seq)
+		index, err := services.WriteShard(s.dataset.BucketName, shard.ObjectPath, content)
+		if err != nil {
+			return err
+		}
+		shard.Size = index.Size
+		shard.SetOffsets(index.Offsets)
+		s.shards = append(s.shards, shard)
+		s.obsolete = append(s.obsolete, oldPath)
+	}
+
+	if len(appended) == 0 {
+		return nil
+	}
+	seq, startIndex := 0, 0
+	if n > 0 {
+		seq, startIndex = shards[n-1].Seq+1, total
+	}
+	if appendToLast {
+		seq, startIndex = shards[n-1].Seq, shards[n-1].StartIndex
+	}
+	written, err := splitToShards(s.dataset, strings.NewReader(strings.Join(appended, "\n")+"\n"), first, seq, startIndex)
+	if err != nil {
+		return err
+	}
+	if appendToLast && len(written) > 0 {
+		written[0].ID = shards[n-1].ID
+		s.obsolete = append(s.obsolete, shards[n-1].ObjectPath)
+	}
+	s.shards = append(s.shards, written...)
+	return nil
+}
+
+// apply 在 tx 中使新分片生效
+func (s *stagedDatasetShards) apply(tx *gorm.DB) error {
+	if s.replace {
+		return model.ReplaceDatasetShards(tx, s.dataset.ID, s.shards)
+	}
+	return model.SaveDatasetShards(tx, s.shards)
+}
+
+// finish 释放存储锁，committed 为 true 时删除被替换的旧对象，否则删除新上传的对象
+func (s *stagedDatasetShards) finish(committed bool) {
+	if s.unlock != nil {
+		defer s.unlock()
+	}
+	if committed {
+		removeShardObjects(s.dataset.BucketName, s.obsolete)
+		return
+	}
+	paths := make([]string, len(s.shards))
+	for i := range s.shards {
+		paths[i] = s.shards[i].ObjectPath
+	}
+	removeShardObjects(s.dataset.BucketName, paths)
+}
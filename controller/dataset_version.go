@@ -0,0 +1,694 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 版本快照统一存放在数据集所在桶的 versions/ 目录下
+const datasetVersionPrefix = "versions"
+
+// getDatasetFromParam 获取路径参数 id 对应的数据集
+// 项目权限由路由上的 ProjectPermission 中间件统一校验
+func getDatasetFromParam(c *gin.Context) (*model.Dataset, bool) {
+	var dataset model.Dataset
+	if err := model.DB.First(&dataset, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "数据集不存在",
+		})
+		return nil, false
+	}
+	return &dataset, true
+}
+
+// isEmptyEntryLine 判断JSONL行是否为空行或删除后留下的占位对象
+func isEmptyEntryLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || line == "{}"
+}
+
+// entryFromLine 将JSONL行解析为数据集条目
+// instruction/input/output 列按数据集模板的字段映射读取，schema 为 nil 时使用默认映射
+func entryFromLine(schema *services.DatasetSchema, datasetID uint, index int, line string) (model.DatasetEntry, error) {
+	data, err := services.DecodeDatasetRecord([]byte(line))
+	if err != nil {
+		return model.DatasetEntry{}, err
+	}
+	record := schema.Columns(data)
+	return model.DatasetEntry{
+		DatasetID:   datasetID,
+		EntryIndex:  index,
+		Instruction: record.Instruction,
+		Input:       record.Input,
+		Output:      record.Output,
+		RawContent:  line,
+	}, nil
+}
+
+// entryToLine 返回条目的JSONL表示，优先使用原始内容
+func entryToLine(entry model.DatasetEntry) (string, error) {
+	if entry.RawContent != "" {
+		return entry.RawContent, nil
+	}
+	jsonBytes, err := json.Marshal(map[string]string{
+		"instruction": entry.Instruction,
+		"input":       entry.Input,
+		"output":      entry.Output,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// readJSONLLine 读取一行(不含换行符)，ok 为 false 表示已到达末尾
+func readJSONLLine(reader *bufio.Reader) (string, bool, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	if err == io.EOF && line == "" {
+		return "", false, nil
+	}
+	return strings.TrimRight(line, "\r\n"), true, nil
+}
+
+// writeDatasetJSONL 将数据集当前的全部条目按 entry_index 顺序写为JSONL
+// keepIndex 为 true 时保留 {} 占位行(数据库存储按索引补齐)，保证行号与 entry_index 一致；导出时跳过
+// include 不为空时只写出其返回 true 的条目，其余条目按空行处理
+func writeDatasetJSONL(w io.Writer, dataset *model.Dataset, keepIndex bool, include func(entryIndex int) bool) (entryCount int64, totalSize int64, err error) {
+	if dataset.StorageType == "minio" {
+		if dataset.BucketName == "" || dataset.ObjectPath == "" {
+			return 0, 0, fmt.Errorf("数据集MinIO存储信息不完整")
+		}
+		object, err := openMinioDataset(dataset)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer object.Close()
+
+		reader := bufio.NewReader(object)
+		for index := 0; ; index++ {
+			line, ok, err := readJSONLLine(reader)
+			if err != nil {
+				return entryCount, totalSize, err
+			}
+			if !ok {
+				break
+			}
+			if include != nil && !include(index) {
+				line = "{}"
+			}
+			if !keepIndex && isEmptyEntryLine(line) {
+				continue
+			}
+			n, err := fmt.Fprintln(w, line)
+			if err != nil {
+				return entryCount, totalSize, err
+			}
+			totalSize += int64(n)
+			if !isEmptyEntryLine(line) {
+				entryCount++
+			}
+		}
+		return entryCount, totalSize, nil
+	}
+
+	// 使用游标逐行读取，避免一次性加载全部条目
+	rows, err := model.DB.Model(&model.DatasetEntry{}).
+		Where("dataset_id = ?", dataset.ID).
+		Order("entry_index ASC").
+		Rows()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	nextIndex := 0
+	for rows.Next() {
+		var entry model.DatasetEntry
+		if err := model.DB.ScanRows(rows, &entry); err != nil {
+			return entryCount, totalSize, err
+		}
+		if include != nil && !include(entry.EntryIndex) {
+			continue
+		}
+		for ; keepIndex && nextIndex < entry.EntryIndex; nextIndex++ {
+			n, err := fmt.Fprintln(w, "{}")
+			if err != nil {
+				return entryCount, totalSize, err
+			}
+			totalSize += int64(n)
+		}
+		line, err := entryToLine(entry)
+		if err != nil {
+			return entryCount, totalSize, err
+		}
+		n, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return entryCount, totalSize, err
+		}
+		totalSize += int64(n)
+		entryCount++
+		nextIndex = entry.EntryIndex + 1
+	}
+	return entryCount, totalSize, rows.Err()
+}
+
+// openDatasetRevision 打开数据集某个版本的JSONL内容，ref 为版本ID或 current(当前数据)
+func openDatasetRevision(dataset *model.Dataset, ref string) (io.ReadCloser, error) {
+	if ref == "" || ref == "current" {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _, err := writeDatasetJSONL(pw, dataset, true, nil)
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+
+	versionID, err := strconv.Atoi(ref)
+	if err != nil {
+		return nil, fmt.Errorf("无效的版本ID: %s", ref)
+	}
+	version, err := model.GetDatasetVersion(dataset.ID, uint(versionID))
+	if err != nil {
+		return nil, fmt.Errorf("版本不存在: %s", ref)
+	}
+	return services.GetMinioObject(version.BucketName, version.ObjectPath)
+}
+
+// createDatasetSnapshot 将数据集当前内容上传为MinIO中的不可变快照，并设为激活版本
+// acceptedOnly 为 true 时快照只包含标注审核通过的条目，与当前内容不一致，因此不设为激活版本
+func createDatasetSnapshot(dataset *model.Dataset, description string, userID uint, acceptedOnly bool) (*model.DatasetVersion, error) {
+	include, err := acceptedEntryFilter(dataset, acceptedOnly)
+	if err != nil {
+		return nil, fmt.Errorf("读取标注状态失败: %v", err)
+	}
+
+	// 快照读取的是该时间之后的数据，以此作为版本时间，读取期间的修改会被视为快照之后的修改
+	startedAt := time.Now()
+	versionName, err := model.NextDatasetVersionName(model.DB, dataset.ID)
+	if err != nil {
+		return nil, fmt.Errorf("生成版本号失败: %v", err)
+	}
+
+	bucketName := dataset.BucketName
+	if bucketName == "" {
+		bucketName = "datasets"
+	}
+	// 对象名带随机后缀，快照一经写入不会被其他版本覆盖
+	objectPath := fmt.Sprintf("%s/dataset_%d/%s_%s.jsonl", datasetVersionPrefix, dataset.ID, versionName, uuid.NewString())
+
+	if err := services.EnsureMinioBucket(bucketName); err != nil {
+		return nil, err
+	}
+
+	// 边读取边上传，避免将整个数据集加载到内存
+	type snapshotResult struct {
+		entryCount int64
+		totalSize  int64
+	}
+	resultCh := make(chan snapshotResult, 1)
+	pr, pw := io.Pipe()
+	go func() {
+		entryCount, totalSize, err := writeDatasetJSONL(pw, dataset, true, include)
+		resultCh <- snapshotResult{entryCount: entryCount, totalSize: totalSize}
+		pw.CloseWithError(err)
+	}()
+
+	if err := services.UploadJSONLToMinio(bucketName, objectPath, pr); err != nil {
+		pr.CloseWithError(err)
+		<-resultCh
+		return nil, err
+	}
+	snapshot := <-resultCh
+
+	version := model.DatasetVersion{
+		DatasetID:    dataset.ID,
+		Version:      versionName,
+		Description:  description,
+		BucketName:   bucketName,
+		ObjectPath:   objectPath,
+		EntryCount:   snapshot.entryCount,
+		TotalSize:    snapshot.totalSize,
+		AcceptedOnly: acceptedOnly,
+		UserID:       userID,
+		CreatedAt:    startedAt,
+	}
+	if acceptedOnly {
+		if err := model.DB.Create(&version).Error; err != nil {
+			return nil, fmt.Errorf("保存版本信息失败: %v", err)
+		}
+		return &version, nil
+	}
+
+	// 新快照与数据集当前内容一致，因此成为激活版本
+	tx := model.DB.Begin()
+	if err := tx.Create(&version).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("保存版本信息失败: %v", err)
+	}
+	if err := model.SetActiveDatasetVersion(tx, dataset.ID, version.ID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("设置激活版本失败: %v", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("保存版本信息失败: %v", err)
+	}
+	version.IsActive = true
+
+	return &version, nil
+}
+
+// CreateDatasetVersion 创建数据集版本快照
+// @Summary 创建数据集版本
+// @Description 将数据集当前的全部条目冻结为MinIO中不可变的JSONL快照
+// @Description accepted_only 为 true 时只包含标注审核通过的条目，该版本不会成为激活版本
+// @Tags Dataset
+// @Accept json
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param version body object false "版本描述(description)和 accepted_only"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /api/dataset/{id}/versions [post]
+func CreateDatasetVersion(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Description  string `json:"description"`
+		AcceptedOnly bool   `json:"accepted_only"` // 只包含标注审核通过的条目
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	version, err := createDatasetSnapshot(dataset, input.Description, uint(c.GetInt("user_id")), input.AcceptedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "创建数据集版本失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "数据集版本创建成功",
+		"data":    version,
+	})
+}
+
+// ListDatasetVersions 获取数据集版本列表
+// @Summary 获取数据集版本列表
+// @Description 获取指定数据集的全部版本，最新的在前
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Success 200 {object} SuccessResponse
+// @Router /api/dataset/{id}/versions [get]
+func ListDatasetVersions(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	versions, err := model.GetDatasetVersions(dataset.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取数据集版本失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    versions,
+	})
+}
+
+// DatasetEntryChange 描述两个版本之间某一条目的差异
+type DatasetEntryChange struct {
+	EntryIndex int    `json:"entry_index"`
+	Type       string `json:"type"` // added, removed, modified
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+}
+
+// DiffDatasetVersions 比较数据集的两个版本
+// @Summary 比较数据集版本
+// @Description 按条目索引比较两个版本(或版本与当前数据)的差异
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param from query string true "起始版本ID"
+// @Param to query string false "目标版本ID，默认 current 表示当前数据"
+// @Param limit query int false "返回的差异明细数量上限"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /api/dataset/{id}/versions/diff [get]
+func DiffDatasetVersions(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	from := c.Query("from")
+	to := c.DefaultQuery("to", "current")
+	if from == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "缺少from参数",
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	fromReader, err := openDatasetRevision(dataset, from)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	defer fromReader.Close()
+
+	toReader, err := openDatasetRevision(dataset, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	defer toReader.Close()
+
+	var added, removed, modified, unchanged int
+	changes := make([]DatasetEntryChange, 0)
+	record := func(change DatasetEntryChange) {
+		if len(changes) < limit {
+			changes = append(changes, change)
+		}
+	}
+
+	fromLines := bufio.NewReader(fromReader)
+	toLines := bufio.NewReader(toReader)
+	for index := 0; ; index++ {
+		before, fromOK, err := readJSONLLine(fromLines)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "读取版本数据失败: " + err.Error(),
+			})
+			return
+		}
+		after, toOK, err := readJSONLLine(toLines)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "读取版本数据失败: " + err.Error(),
+			})
+			return
+		}
+		if !fromOK && !toOK {
+			break
+		}
+
+		beforeEmpty := !fromOK || isEmptyEntryLine(before)
+		afterEmpty := !toOK || isEmptyEntryLine(after)
+		switch {
+		case beforeEmpty && afterEmpty:
+			continue
+		case beforeEmpty:
+			added++
+			record(DatasetEntryChange{EntryIndex: index, Type: "added", After: after})
+		case afterEmpty:
+			removed++
+			record(DatasetEntryChange{EntryIndex: index, Type: "removed", Before: before})
+		case before != after:
+			modified++
+			record(DatasetEntryChange{EntryIndex: index, Type: "modified", Before: before, After: after})
+		default:
+			unchanged++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"from":      from,
+			"to":        to,
+			"added":     added,
+			"removed":   removed,
+			"modified":  modified,
+			"unchanged": unchanged,
+			"changes":   changes,
+			"truncated": added+removed+modified > len(changes),
+		},
+	})
+}
+
+// ActivateDatasetVersion 激活数据集版本(回滚)
+// @Summary 激活数据集版本
+// @Description 将数据集内容回滚到指定版本的快照，并将该版本设为唯一的激活版本
+// @Tags Dataset
+// @Produce json
+// @Param id path int true "数据集ID"
+// @Param vid path int true "版本ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/dataset/{id}/versions/{vid}/activate [put]
+func ActivateDatasetVersion(c *gin.Context) {
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
+		return
+	}
+
+	versionID, err := strconv.Atoi(c.Param("vid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的版本ID",
+		})
+		return
+	}
+	version, err := model.GetDatasetVersion(dataset.ID, uint(versionID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "数据集版本不存在",
+		})
+		return
+	}
+
+	// 条目整体替换期间持有条目锁，导入、单条修改和批量修改不会与回滚交错分配或平移 entry_index
+	unlock := lockDatasetEntries(dataset.ID)
+	defer unlock()
+
+	// MinIO存储：先用快照内容上传新的分片，事务提交前数据集仍读取原有分片
+	var staged *stagedDatasetShards
+	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
+		object, err := services.GetMinioObject(version.BucketName, version.ObjectPath)
+		if err == nil {
+			staged, err = stageMinioDatasetReplace(dataset, object)
+			object.Close()
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "恢复MinIO数据失败: " + err.Error(),
+			})
+			return
+		}
+	}
+	committed := false
+	defer func() {
+		if staged != nil {
+			staged.finish(committed)
+		}
+	}()
+
+	tx := model.DB.Begin()
+
+	// 数据库存储：用快照内容替换全部条目
+	if dataset.StorageType == "database" || dataset.StorageType == "both" {
+		if err := tx.Where("dataset_id = ?", dataset.ID).Delete(&model.DatasetEntry{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "删除数据集条目失败: " + err.Error(),
+			})
+			return
+		}
+
+		object, err := services.GetMinioObject(version.BucketName, version.ObjectPath)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "读取版本快照失败: " + err.Error(),
+			})
+			return
+		}
+		defer object.Close()
+
+		schema, _ := datasetSchema(dataset)
+		reader := bufio.NewReader(object)
+		batch := make([]model.DatasetEntry, 0, 500)
+		for index := 0; ; index++ {
+			line, ok, err := readJSONLLine(reader)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "读取版本快照失败: " + err.Error(),
+				})
+				return
+			}
+			if !ok {
+				break
+			}
+			if isEmptyEntryLine(line) {
+				continue
+			}
+			entry, err := entryFromLine(schema, dataset.ID, index, line)
+			if err != nil {
+				continue
+			}
+			batch = append(batch, entry)
+			if len(batch) == cap(batch) {
+				if err := tx.Create(&batch).Error; err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"success": false,
+						"message": "恢复数据集条目失败: " + err.Error(),
+					})
+					return
+				}
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			if err := tx.Create(&batch).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "恢复数据集条目失败: " + err.Error(),
+				})
+				return
+			}
+		}
+	}
+
+	// MinIO存储：快照内容生成的新分片随事务一起生效
+	if staged != nil {
+		if err := staged.apply(tx); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "恢复MinIO数据失败: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := tx.Model(dataset).Updates(map[string]interface{}{
+		"entry_count":        version.EntryCount,
+		"total_size":         version.TotalSize,
+		"content_updated_at": version.CreatedAt,
+		"bucket_name":        dataset.BucketName,
+		"object_path":        dataset.ObjectPath,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "更新数据集失败: " + err.Error(),
+		})
+		return
+	}
+
+	if err := model.SetActiveDatasetVersion(tx, dataset.ID, version.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "设置激活版本失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 条目已整体替换，之前的修订不再对应当前的条目；修改记录中保留一条激活记录，与 versions/diff 对照
+	err = model.DetachDatasetEntryRevisions(tx, dataset.ID)
+	if err == nil {
+		err = model.CreateDatasetEntryRevisions(tx, []model.DatasetEntryRevision{
+			newDatasetRevision(dataset.ID, model.EntryRevisionActivate, datasetRevisionSummary{
+				VersionID:  version.ID,
+				Version:    version.Version,
+				EntryCount: version.EntryCount,
+			}, uint(c.GetInt("user_id"))),
+		})
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "记录修改历史失败: " + err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "激活版本失败: " + err.Error(),
+		})
+		return
+	}
+	committed = true
+	version.IsActive = true
+
+	// 条目已整体替换，检索索引在下次检索时重建，之前的质量报告和标注状态不再适用
+	if err := model.MarkDatasetSearchStale(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+	if err := model.DeleteDatasetQualityReports(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+	if err := model.DeleteDatasetAnnotations(dataset.ID); err != nil {
+		common.SysError(err.Error())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "数据集已回滚到版本 " + version.Version,
+		"data":    version,
+	})
+}
@@ -1,6 +1,9 @@
 package controller
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // ========================= 通用响应结构 =========================
 
@@ -120,6 +123,7 @@ type ProjectDTO struct {
 	ID          uint        `json:"id" example:"1"`
 	Name        string      `json:"name" example:"项目名称"`
 	Description string      `json:"description" example:"项目描述"`
+	ClusterID   uint        `json:"cluster_id" example:"0"` // 项目默认集群，0 表示全局默认集群
 	CreatedAt   time.Time   `json:"created_at,omitempty"`
 	UpdatedAt   time.Time   `json:"updated_at,omitempty"`
 	Users       []MemberDTO `json:"users,omitempty"`
@@ -149,18 +153,21 @@ type ProjectResponse struct {
 
 // 数据集DTO
 type DatasetDTO struct {
-	ID               uint      `json:"id" example:"1"`
-	Name             string    `json:"name" example:"数据集名称"`
-	Description      string    `json:"description" example:"数据集描述"`
-	StorageType      string    `json:"storage_type" example:"database"`
-	TemplateType     string    `json:"template_type" example:"instruction_io"`
-	EntryCount       int64     `json:"entry_count" example:"100"`
-	TotalSize        int64     `json:"total_size" example:"1024"`
-	ProjectID        uint      `json:"project_id" example:"1"`
-	UserID           uint      `json:"user_id" example:"1"`
-	SchemaDefinition string    `json:"schema_definition,omitempty"`
-	CreatedAt        time.Time `json:"created_at,omitempty"`
-	UpdatedAt        time.Time `json:"updated_at,omitempty"`
+	ID                 uint            `json:"id" example:"1"`
+	Name               string          `json:"name" example:"数据集名称"`
+	Description        string          `json:"description" example:"数据集描述"`
+	StorageType        string          `json:"storage_type" example:"database"`
+	TemplateType       string          `json:"template_type" example:"instruction_io"`
+	EntryCount         int64           `json:"entry_count" example:"100"`
+	TotalSize          int64           `json:"total_size" example:"1024"`
+	ProjectID          uint            `json:"project_id" example:"1"`
+	UserID             uint            `json:"user_id" example:"1"`
+	SchemaDefinition   string          `json:"schema_definition,omitempty"`
+	ParentID           *uint           `json:"parent_id,omitempty" example:"1"`
+	Lineage            json.RawMessage `json:"lineage,omitempty" swaggertype:"object"`
+	AnnotatorsRequired int             `json:"annotators_required" example:"1"` // 每个条目需要的标注人数
+	CreatedAt          time.Time       `json:"created_at,omitempty"`
+	UpdatedAt          time.Time       `json:"updated_at,omitempty"`
 }
 
 // 数据集响应
@@ -185,14 +192,16 @@ type DatasetsListData struct {
 
 // 数据集条目DTO
 type DatasetEntryDTO struct {
-	ID          uint      `json:"id" example:"1"`
-	DatasetID   uint      `json:"dataset_id" example:"1"`
-	EntryIndex  int       `json:"entry_index" example:"0"`
-	Instruction string    `json:"instruction" example:"指令内容"`
-	Input       string    `json:"input" example:"输入内容"`
-	Output      string    `json:"output" example:"输出内容"`
-	CreatedAt   time.Time `json:"created_at,omitempty"`
-	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	ID          uint                   `json:"id" example:"1"`
+	DatasetID   uint                   `json:"dataset_id" example:"1"`
+	EntryIndex  int                    `json:"entry_index" example:"0"`
+	Instruction string                 `json:"instruction" example:"指令内容"`
+	Input       string                 `json:"input" example:"输入内容"`
+	Output      string                 `json:"output" example:"输出内容"`
+	Data        map[string]interface{} `json:"data,omitempty"`       // 条目完整内容，包含模板字段以外的字段
+	Highlights  map[string]string      `json:"highlights,omitempty"` // 检索命中的字段片段，命中部分以 <mark> 标记
+	CreatedAt   time.Time              `json:"created_at,omitempty"`
+	UpdatedAt   time.Time              `json:"updated_at,omitempty"`
 }
 
 // 数据集条目响应
@@ -253,14 +262,16 @@ type NotebooksListData struct {
 
 // 训练任务DTO
 type TrainingJobDTO struct {
-	ID        uint      `json:"id" example:"1"`
-	Name      string    `json:"name" example:"训练任务名称"`
-	Status    string    `json:"status" example:"running"`
-	ProjectID uint      `json:"project_id" example:"1"`
-	UserID    uint      `json:"user_id" example:"1"`
-	Image     string    `json:"image" example:"pytorch:latest"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	ID               uint      `json:"id" example:"1"`
+	Name             string    `json:"name" example:"训练任务名称"`
+	Status           string    `json:"status" example:"running"`
+	ProjectID        uint      `json:"project_id" example:"1"`
+	UserID           uint      `json:"user_id" example:"1"`
+	Image            string    `json:"image" example:"pytorch:latest"`
+	DatasetID        uint      `json:"dataset_id" example:"1"`
+	DatasetVersionID uint      `json:"dataset_version_id" example:"1"`
+	CreatedAt        time.Time `json:"created_at,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at,omitempty"`
 }
 
 // 训练任务响应
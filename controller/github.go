@@ -148,7 +148,7 @@ func GitHubOAuth(c *gin.Context) {
 	}
 
 	// 生成 JWT token
-	token, err := common.GenerateToken(int(user.ID), user.Username, user.Role)
+	token, refreshToken, err := model.IssueTokenPair(&user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -161,8 +161,10 @@ func GitHubOAuth(c *gin.Context) {
 		"success": true,
 		"message": "",
 		"data": gin.H{
-			"token": token,
-			"user":  user,
+			"token":         token,
+			"refresh_token": refreshToken,
+			"expires_in":    int(common.AccessTokenTTL.Seconds()),
+			"user":          user,
 		},
 	})
 }
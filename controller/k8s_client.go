@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// KubeClient 控制器用到的 Kubernetes 操作，由 *services.K8s 实现
+// 测试中可以注入 services.NewK8sWithClients(fake.NewSimpleClientset(), dfake.NewSimpleDynamicClient(...))
+type KubeClient interface {
+	GPUResourceName() corev1.ResourceName
+
+	CheckClusterCapacity(request services.ResourceRequest) (*services.CapacityResult, error)
+	GetNodeUsage(label string) ([]services.NodeUsage, error)
+	LabelNode(ips []string, labels map[string]string) ([]string, error)
+
+	GetPod(namespace, name string) (*corev1.Pod, error)
+	GetPods(namespace, serviceName, podName string, labels map[string]string) ([]services.PodInfo, error)
+	CreatePod(namespace string, pod *corev1.Pod) (*corev1.Pod, error)
+	DeletePod(namespace, podName string) error
+	StreamPodLogs(ctx context.Context, namespace, podName string, opts services.PodLogOptions) (io.ReadCloser, error)
+	GetJupyterStatus(namespace, serviceName string, port int) (*services.JupyterStatus, error)
+
+	GetService(namespace, name string) (*corev1.Service, error)
+	UpdateService(namespace string, service *corev1.Service) (*corev1.Service, error)
+	CreateServiceForNotebook(namespace, name string, port int, labels map[string]string) (*corev1.Service, error)
+	DeleteService(namespace string, name string, labels map[string]string) error
+	DeleteService2(namespace, name string) error
+	DeleteVirtualService(ctx context.Context, namespace, name string) error
+
+	CreatePyTorchJob(namespace string, config services.PyTorchJobConfig) (*unstructured.Unstructured, error)
+	DeletePyTorchJob(namespace string, name string) error
+
+	CreateTritonDeployment(namespace string, deployment *appsv1.Deployment) (*appsv1.Deployment, error)
+	CreateTritonService(namespace string, service *corev1.Service) (*corev1.Service, error)
+	DeleteDeployment(namespace, name string) error
+}
+
+var _ KubeClient = (*services.K8s)(nil)
+
+var errK8sNotConfigured = errors.New("kubernetes client is not configured")
+
+var (
+	// kubeClient 启动时由 SetK8sClient 注入，对应 config.yaml 中的集群(ClusterID 为 0)
+	kubeClient KubeClient
+
+	// clusterClients 数据库中登记的集群的客户端，按集群 ID 缓存
+	clusterClients   = map[uint]KubeClient{}
+	clusterClientsMu sync.Mutex
+)
+
+// SetK8sClient 设置 config.yaml 中集群的 Kubernetes 客户端
+func SetK8sClient(client KubeClient) {
+	kubeClient = client
+}
+
+// getK8sClient 返回 config.yaml 中集群的客户端，未配置时返回错误
+func getK8sClient() (KubeClient, error) {
+	if kubeClient == nil {
+		return nil, errK8sNotConfigured
+	}
+	return kubeClient, nil
+}
+
+// getClusterClient 返回指定集群的客户端，首次使用时根据集群的 kubeconfig 创建并缓存
+func getClusterClient(clusterID uint) (KubeClient, error) {
+	if clusterID == 0 {
+		return getK8sClient()
+	}
+
+	clusterClientsMu.Lock()
+	defer clusterClientsMu.Unlock()
+	if client, ok := clusterClients[clusterID]; ok {
+		return client, nil
+	}
+
+	cluster, err := model.GetClusterByID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClusterClient(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+	}
+	clusterClients[clusterID] = client
+	return client, nil
+}
+
+func newClusterClient(cluster *model.Cluster) (*services.K8s, error) {
+	var client *services.K8s
+	if cluster.InCluster {
+		k8s, err := services.NewK8sFromConfig("", true)
+		if err != nil {
+			return nil, err
+		}
+		client = k8s
+	} else {
+		kubeconfig, err := cluster.KubeconfigData()
+		if err != nil {
+			return nil, err
+		}
+		if len(kubeconfig) == 0 {
+			return nil, errors.New("kubeconfig is not set")
+		}
+		if client, err = services.NewK8sFromKubeconfig(kubeconfig); err != nil {
+			return nil, err
+		}
+	}
+	client.SetGPUResourceName(cluster.GPUResourceName)
+	return client, nil
+}
+
+// invalidateClusterClient 集群配置变更或删除后丢弃缓存的客户端和对账器
+func invalidateClusterClient(clusterID uint) {
+	clusterClientsMu.Lock()
+	delete(clusterClients, clusterID)
+	clusterClientsMu.Unlock()
+	stopClusterReconciler(clusterID)
+}
+
+// clusterTarget 工作负载所在集群的客户端，以及命名空间、对外 IP 等集群设置
+type clusterTarget struct {
+	ID                 uint
+	Client             KubeClient
+	NotebookNamespace  string
+	TrainingNamespace  string
+	TritonNamespace    string
+	NotebookExternalIP string
+	TritonExternalIP   string
+}
+
+// getClusterTarget 返回集群的客户端和设置，集群未设置的字段使用 config.yaml 中的配置
+func getClusterTarget(clusterID uint) (*clusterTarget, error) {
+	client, err := getClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	target := &clusterTarget{
+		ID:                 clusterID,
+		Client:             client,
+		NotebookNamespace:  defaultString(viper.GetString("notebook.namespace"), "jupyter"),
+		TrainingNamespace:  defaultString(viper.GetString("crds.pytorchjob.namespace"), "train"),
+		TritonNamespace:    defaultString(viper.GetString("triton.namespace"), "triton-serving"),
+		NotebookExternalIP: viper.GetString("notebook.externalIP"),
+		TritonExternalIP:   viper.GetString("triton.externalIP"),
+	}
+	if clusterID == 0 {
+		return target, nil
+	}
+
+	cluster, err := model.GetClusterByID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	target.NotebookNamespace = defaultString(cluster.NotebookNamespace, target.NotebookNamespace)
+	target.TrainingNamespace = defaultString(cluster.TrainingNamespace, target.TrainingNamespace)
+	target.TritonNamespace = defaultString(cluster.TritonNamespace, target.TritonNamespace)
+	target.NotebookExternalIP = defaultString(cluster.NotebookExternalIP, target.NotebookExternalIP)
+	target.TritonExternalIP = defaultString(cluster.TritonExternalIP, target.TritonExternalIP)
+	return target, nil
+}
+
+// resolveWorkloadCluster 确定新建工作负载的集群(请求指定 > 项目默认 > 全局默认)，出错时写入响应并返回 false
+// 只有管理员可以指定项目默认集群之外的集群
+func resolveWorkloadCluster(c *gin.Context, requested uint, projectID uint) (*clusterTarget, bool) {
+	clusterID, err := model.ResolveClusterID(requested, projectID, c.GetInt("role"))
+	if errors.Is(err, model.ErrClusterNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("Cluster %d not found", requested),
+			"data":    nil,
+		})
+		return nil, false
+	}
+	if errors.Is(err, model.ErrClusterNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("Cluster %d is not allowed for this project", requested),
+			"data":    nil,
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to resolve cluster: " + err.Error(),
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	target, err := getClusterTarget(clusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create K8s client: " + err.Error(),
+			"data":    nil,
+		})
+		return nil, false
+	}
+	return target, true
+}
+
+var (
+	reconcilerCtx     context.Context
+	reconcilerResync  time.Duration
+	reconcilerCancels = map[uint]context.CancelFunc{}
+	reconcilerMu      sync.Mutex
+)
+
+// StartStatusReconcilers 为 config.yaml 中的集群和所有登记的集群启动状态对账器
+// 之后新增或修改的集群在保存后由集群接口重新启动对账器
+func StartStatusReconcilers(ctx context.Context, resync time.Duration) {
+	reconcilerMu.Lock()
+	reconcilerCtx, reconcilerResync = ctx, resync
+	reconcilerMu.Unlock()
+
+	startClusterReconciler(0)
+	clusters, err := model.ListClusters()
+	if err != nil {
+		common.SysError("failed to list clusters: " + err.Error())
+		return
+	}
+	for _, cluster := range clusters {
+		startClusterReconciler(cluster.ID)
+	}
+}
+
+func startClusterReconciler(clusterID uint) {
+	reconcilerMu.Lock()
+	defer reconcilerMu.Unlock()
+	if reconcilerCtx == nil {
+		return
+	}
+	if _, ok := reconcilerCancels[clusterID]; ok {
+		return
+	}
+
+	target, err := getClusterTarget(clusterID)
+	if err != nil {
+		if clusterID != 0 {
+			common.SysError(fmt.Sprintf("status reconciler for cluster %d not started: %v", clusterID, err))
+		}
+		return
+	}
+	k8s, ok := target.Client.(*services.K8s)
+	if !ok {
+		return
+	}
+
+	reconciler := services.NewStatusReconciler(k8s, model.WorkloadStatusStore{}, reconcilerResync)
+	reconciler.NotebookNamespace = target.NotebookNamespace
+	reconciler.TritonNamespace = target.TritonNamespace
+
+	ctx, cancel := context.WithCancel(reconcilerCtx)
+	reconcilerCancels[clusterID] = cancel
+	go func() {
+		if err := reconciler.Run(ctx); err != nil {
+			common.SysError(fmt.Sprintf("status reconciler for cluster %d stopped: %v", clusterID, err))
+		}
+	}()
+}
+
+func stopClusterReconciler(clusterID uint) {
+	reconcilerMu.Lock()
+	defer reconcilerMu.Unlock()
+	if cancel, ok := reconcilerCancels[clusterID]; ok {
+		cancel()
+		delete(reconcilerCancels, clusterID)
+	}
+}
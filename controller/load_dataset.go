@@ -4,72 +4,33 @@ import (
 	"MLcore-Engine/common"
 	"MLcore-Engine/model"
 	"MLcore-Engine/services"
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // ImportDataset 导入数据集
 // @Summary 导入数据集
-// @Description 从JSONL文件导入数据到指定数据集
+// @Description 上传 JSONL、JSON 数组、CSV/TSV 或 Parquet 文件并创建后台导入任务，支持 messages/ShareGPT 对话格式和字段映射
+// @Description 记录按数据集模板和 JSON Schema 校验，字段映射为空时使用模板的默认字段
+// @Description 通过 GET /api/dataset/jobs/{id} 查询进度和逐行错误报告
 // @Tags Dataset
 // @Accept multipart/form-data
 // @Produce json
 // @Param id path int true "数据集ID"
-// @Param file formData file true "JSONL文件"
-// @Success 200 {object} SuccessResponse
+// @Param file formData file true "数据文件(.jsonl/.json/.csv/.tsv/.parquet)"
+// @Param format formData string false "文件格式(jsonl/json/csv/tsv/parquet/chat/sharegpt)，为空时按扩展名识别"
+// @Param mapping formData string false "字段映射JSON，如 {\"instruction\":\"question\",\"output\":\"answer\",\"messages\":\"dialog\"}"
+// @Success 200 {object} SuccessResponse{data=DatasetJobDTO}
 // @Router /api/dataset/{id}/import [post]
 func ImportDataset(c *gin.Context) {
-	id := c.Param("id")
-
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
-		return
-	}
-
-	// 获取数据集并验证权限
-	var dataset model.Dataset
-	if err := model.DB.First(&dataset, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"message": "数据集不存在",
-		})
-		return
-	}
-
-	// 验证编辑权限
-	hasEditPermission := false
-	if dataset.UserID == uint(userID.(int)) {
-		hasEditPermission = true
-	} else if dataset.ProjectID != 0 {
-		// 检查是否为项目成员且有编辑权限
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err == nil {
-			// 假设角色值大于1拥有编辑权限
-			if projectUser.Role > 1 {
-				hasEditPermission = true
-			}
-		}
-	}
-
-	if !hasEditPermission {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "您没有权限编辑此数据集",
-		})
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
 		return
 	}
 
@@ -84,283 +45,143 @@ func ImportDataset(c *gin.Context) {
 	}
 
 	// 验证文件格式
-	ext := filepath.Ext(file.Filename)
-	if ext != ".jsonl" && ext != ".json" {
+	format, messagesField, err := services.DetectImportFormat(file.Filename, c.PostForm("format"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "只支持JSONL或JSON文件导入",
+			"message": err.Error(),
 		})
 		return
 	}
 
-	// 创建临时文件
-	tempFile, err := os.CreateTemp("", "dataset_import_*.jsonl")
+	var mapping services.ImportFieldMapping
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "字段映射格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	if mapping.Messages == "" {
+		mapping.Messages = messagesField
+	}
+
+	// 导入的每条记录都按数据集模板和 JSON Schema 校验
+	schema, err := datasetSchema(dataset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "创建临时文件失败: " + err.Error(),
+			"message": "数据集Schema无效: " + err.Error(),
 		})
 		return
 	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
 
-	// 保存上传文件到临时目录
-	if err := c.SaveUploadedFile(file, tempFile.Name()); err != nil {
+	// 同一数据集的导入任务依次分配 entry_index，不允许并发导入
+	active, err := model.HasActiveDatasetJob(dataset.ID, model.DatasetJobImport)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "保存上传文件失败: " + err.Error(),
+			"message": "查询导入任务失败: " + err.Error(),
 		})
 		return
 	}
-
-	// 重新打开文件以读取
-	jsonlFile, err := os.Open(tempFile.Name())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if active {
+		c.JSON(http.StatusConflict, gin.H{
 			"success": false,
-			"message": "打开文件失败: " + err.Error(),
+			"message": "该数据集已有进行中的导入任务",
 		})
 		return
 	}
-	defer jsonlFile.Close()
-
-	// 读取并处理文件内容
-	scanner := bufio.NewScanner(jsonlFile)
-	lineCount := 0
-	validCount := 0
-
-	// 开始数据库事务
-	tx := model.DB.Begin()
-
-	// 获取当前最大索引
-	var maxIndex struct {
-		MaxIndex int
-	}
-	if dataset.StorageType == "database" || dataset.StorageType == "both" {
-		tx.Model(&model.DatasetEntry{}).
-			Select("COALESCE(MAX(entry_index), -1) as max_index").
-			Where("dataset_id = ?", dataset.ID).
-			Scan(&maxIndex)
-	}
-
-	currentIndex := maxIndex.MaxIndex + 1
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineCount++
-
-		if line == "" {
-			continue
-		}
-
-		// 解析JSON行
-		var data map[string]string
-		if err := json.Unmarshal([]byte(line), &data); err != nil {
-			common.SysLog(fmt.Sprintf("解析第%d行失败: %v", lineCount, err))
-			continue
-		}
-
-		// 检查必要字段
-		if data["instruction"] == "" {
-			common.SysLog(fmt.Sprintf("第%d行缺少instruction字段", lineCount))
-			continue
-		}
 
-		// 创建条目
-		entry := model.DatasetEntry{
-			DatasetID:   dataset.ID,
-			EntryIndex:  currentIndex,
-			Instruction: data["instruction"],
-			Input:       data["input"],
-			Output:      data["output"],
-			RawContent:  line,
-		}
-
-		// 保存到数据库
-		if dataset.StorageType == "database" || dataset.StorageType == "both" {
-			if err := tx.Create(&entry).Error; err != nil {
-				common.SysLog(fmt.Sprintf("保存第%d行到数据库失败: %v", lineCount, err))
-				continue
-			}
-		}
-
-		currentIndex++
-		validCount++
-	}
-
-	// 检查是否有扫描错误
-	if err := scanner.Err(); err != nil {
-		tx.Rollback()
+	// 保存上传文件到临时目录，由后台任务读取后删除
+	tempFile, err := os.CreateTemp("", "dataset_upload_*"+filepath.Ext(file.Filename))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "读取文件出错: " + err.Error(),
+			"message": "创建临时文件失败: " + err.Error(),
 		})
 		return
 	}
-
-	// 如果没有有效行
-	if validCount == 0 {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{
+	tempFile.Close()
+	if err := c.SaveUploadedFile(file, tempFile.Name()); err != nil {
+		os.Remove(tempFile.Name())
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "文件中没有有效的数据行",
+			"message": "保存上传文件失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 更新数据集条目计数
-	tx.Model(&dataset).Update("entry_count", gorm.Expr("entry_count + ?", validCount))
-
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
+	job := newDatasetJob(c, dataset, model.DatasetJobImport)
+	job.FileName = file.Filename
+	job.Format = string(format)
+	if err := model.DB.Create(job).Error; err != nil {
+		os.Remove(tempFile.Name())
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "保存数据失败: " + err.Error(),
+			"message": "创建导入任务失败: " + err.Error(),
 		})
 		return
 	}
+	auditResource(c, model.ResourceDatasetJob, job.ID, job.ProjectID, nil).SetAfter(job)
 
-	// 如果使用MinIO存储，上传到MinIO
-	if dataset.StorageType == "minio" || dataset.StorageType == "both" {
-		if dataset.BucketName == "" || dataset.ObjectPath == "" {
-			// 配置MinIO存储信息
-			bucketName := "datasets"
-			objectPath := "dataset_" + id + ".jsonl"
-
-			// 更新数据集
-			model.DB.Model(&dataset).Updates(map[string]interface{}{
-				"bucket_name": bucketName,
-				"object_path": objectPath,
-			})
-
-			dataset.BucketName = bucketName
-			dataset.ObjectPath = objectPath
-		}
-
-		// 将文件上传到MinIO
-		jsonlFile.Seek(0, 0) // 重置文件指针到开头
-		if err := services.UploadJSONLToMinio(dataset.BucketName, dataset.ObjectPath, jsonlFile); err != nil {
-			common.SysLog(fmt.Sprintf("上传到MinIO失败: %v", err))
-			// 不中断操作，数据已经保存到数据库
-		}
+	options := datasetImportOptions{
+		filePath: tempFile.Name(),
+		size:     file.Size,
+		format:   format,
+		schema:   schema,
+		mapping:  mapping,
 	}
+	startDatasetJob(job, runDatasetImport(dataset.ID, options), func() { os.Remove(options.filePath) })
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": fmt.Sprintf("成功导入%d条有效数据", validCount),
-		"data": map[string]interface{}{
-			"total_lines":   lineCount,
-			"valid_entries": validCount,
-		},
+		"message": "导入任务已创建",
+		"data":    convertToDatasetJobDTO(job),
 	})
 }
 
 // ExportDataset 导出数据集
 // @Summary 导出数据集
-// @Description 将数据集导出为JSONL文件
+// @Description 将数据集导出为JSONL文件，条目按游标逐行读取；大数据集建议使用 POST 创建后台导出任务
 // @Tags Dataset
 // @Produce application/octet-stream
 // @Param id path int true "数据集ID"
+// @Param accepted_only query bool false "只导出标注审核通过的条目"
 // @Success 200
 // @Router /api/dataset/{id}/export [get]
 func ExportDataset(c *gin.Context) {
-	id := c.Param("id")
-
-	// 获取当前用户ID
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未授权访问",
-		})
+	dataset, ok := getDatasetFromParam(c)
+	if !ok {
 		return
 	}
 
-	// 获取数据集并验证权限
-	var dataset model.Dataset
-	if err := model.DB.First(&dataset, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+	if dataset.StorageType == "minio" && (dataset.BucketName == "" || dataset.ObjectPath == "") {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "数据集不存在",
+			"message": "数据集MinIO存储信息不完整",
 		})
 		return
 	}
 
-	// 验证访问权限
-	hasViewPermission := false
-	if dataset.UserID == uint(userID.(int)) {
-		hasViewPermission = true
-	} else if dataset.ProjectID != 0 {
-		// 检查是否为项目成员
-		var projectUser model.UserProject
-		if err := model.DB.Where("project_id = ? AND user_id = ?", dataset.ProjectID, userID).First(&projectUser).Error; err == nil {
-			hasViewPermission = true
-		}
-	}
-
-	if !hasViewPermission {
-		c.JSON(http.StatusForbidden, gin.H{
+	include, err := acceptedEntryFilter(dataset, c.Query("accepted_only") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "您没有权限访问此数据集",
+			"message": "读取标注状态失败: " + err.Error(),
 		})
 		return
 	}
 
 	// 设置响应头
-	filename := fmt.Sprintf("dataset_%s_%s.jsonl", id, time.Now().Format("20060102"))
+	filename := fmt.Sprintf("dataset_%d_%s.jsonl", dataset.ID, time.Now().Format("20060102"))
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 	c.Header("Content-Type", "application/octet-stream")
 
-	// 根据存储类型导出
-	if dataset.StorageType == "minio" && dataset.BucketName != "" && dataset.ObjectPath != "" {
-		// 从MinIO直接导出
-		object, err := services.GetMinioObject(dataset.BucketName, dataset.ObjectPath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "从MinIO获取数据失败: " + err.Error(),
-			})
-			return
-		}
-		defer object.Close()
-
-		// 直接复制到响应
-		if _, err := io.Copy(c.Writer, object); err != nil {
-			common.SysLog(fmt.Sprintf("复制MinIO数据到响应失败: %v", err))
-			return
-		}
-	} else {
-		// 从数据库导出
-		var entries []model.DatasetEntry
-		if err := model.DB.Where("dataset_id = ?", id).Order("entry_index ASC").Find(&entries).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "获取数据集条目失败: " + err.Error(),
-			})
-			return
-		}
-
-		// 直接写入响应
-		for _, entry := range entries {
-			// 如果有原始内容，直接使用
-			if entry.RawContent != "" {
-				fmt.Fprintln(c.Writer, entry.RawContent)
-				continue
-			}
-
-			// 否则重新构建JSON
-			data := map[string]string{
-				"instruction": entry.Instruction,
-				"input":       entry.Input,
-				"output":      entry.Output,
-			}
-			jsonBytes, err := json.Marshal(data)
-			if err != nil {
-				common.SysLog(fmt.Sprintf("序列化条目失败: %v", err))
-				continue
-			}
-			fmt.Fprintln(c.Writer, string(jsonBytes))
-		}
+	// 直接写入响应
+	if _, _, err := writeDatasetJSONL(c.Writer, dataset, false, include); err != nil {
+		common.SysLog(fmt.Sprintf("导出数据集失败: %v", err))
 	}
 }
@@ -3,7 +3,6 @@ package controller
 import (
 	"MLcore-Engine/common"
 	"MLcore-Engine/model"
-	"MLcore-Engine/services"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -12,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -27,6 +27,7 @@ import (
 // @Param notebook body model.Notebook true "Notebook details"
 // @Success 200 {object} NotebookResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /notebook [post]
 func CreateNotebook(c *gin.Context) {
@@ -42,24 +43,60 @@ func CreateNotebook(c *gin.Context) {
 		return
 	}
 
-	notebook.Name = username + "-" + common.GenRandStr(5)
-	notebook.Namespace = viper.GetString("notebook.namespace")
+	// The notebook always belongs to the caller; user_id in the request body is ignored
+	notebook.UserID = uint(c.GetInt("user_id"))
 
-	if err := notebook.Insert(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	nodeSelector, err := parseNodeSelector(notebook.NodeSelector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "Failed to insert Notebook: " + err.Error(),
+			"message": "Invalid node selector: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+	if len(nodeSelector) == 0 {
+		nodeSelector = defaultNotebookNodeSelector()
+	}
+
+	usage := model.NotebookUsage(&notebook)
+	capacityRequest, err := newResourceRequest(defaultString(notebook.ResourceCPU, "4"), defaultString(notebook.ResourceMemory, "8G"), usage.GPU, 1, nodeSelector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid resource request: " + err.Error(),
 			"data":    nil,
 		})
 		return
 	}
 
-	// Create K8s client
-	k8sClient, err := services.NewK8s("./services/config")
+	// Resolve the target cluster: request > project default > global default
+	target, ok := resolveWorkloadCluster(c, notebook.ClusterID, notebook.ProjectID)
+	if !ok {
+		return
+	}
+	k8sClient := target.Client
+
+	// Check cluster capacity before persisting anything
+	if !checkClusterCapacity(c, k8sClient, capacityRequest) {
+		return
+	}
+
+	notebook.Name = username + "-" + common.GenRandStr(5)
+	notebook.ClusterID = target.ID
+	notebook.Namespace = target.NotebookNamespace
+
+	// Check project quota and insert under the project lock
+	err = model.ReserveProjectQuota(notebook.ProjectID, usage, func(tx *gorm.DB) error {
+		return tx.Create(&notebook).Error
+	})
 	if err != nil {
+		if respondQuotaExceeded(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "Failed to create K8s client: " + err.Error(),
+			"message": "Failed to insert Notebook: " + err.Error(),
 			"data":    nil,
 		})
 		return
@@ -95,7 +132,7 @@ func CreateNotebook(c *gin.Context) {
 	}
 
 	nodeport := createdService.Spec.Ports[0].NodePort
-	notebook.AccessURL = fmt.Sprintf("http://%s:%d/lab?#%s", viper.GetString("notebook.externalIP"), nodeport, notebook.Name)
+	notebook.AccessURL = fmt.Sprintf("http://%s:%d/lab?#%s", target.NotebookExternalIP, nodeport, notebook.Name)
 
 	notebook.Status = "Creating"
 	notebook.Name = createdPod.Name
@@ -107,11 +144,13 @@ func CreateNotebook(c *gin.Context) {
 		})
 		return
 	}
+	auditResource(c, model.ResourceNotebook, notebook.ID, notebook.ProjectID, nil).SetAfter(notebook)
 
 	simplifiedPodInfo := gin.H{
 		"Id":             notebook.ID,
 		"UserId":         notebook.UserID,
 		"ProjectId":      notebook.ProjectID,
+		"ClusterId":      notebook.ClusterID,
 		"Name":           createdPod.Name,
 		"Describe":       fmt.Sprintf("Notebook for user %s", notebook.User.Username),
 		"ResourceMemory": notebook.ResourceMemory,
@@ -130,7 +169,7 @@ func CreateNotebook(c *gin.Context) {
 }
 
 // createPodForNotebook creates a Pod for the Notebook
-func createPodForNotebook(k8sClient *services.K8s, notebook *model.Notebook, labels map[string]string) (*corev1.Pod, error) {
+func createPodForNotebook(k8sClient KubeClient, notebook *model.Notebook, labels map[string]string) (*corev1.Pod, error) {
 
 	command := []string{"sh", "-c"}
 	argTemplate := `jupyter lab --notebook-dir=/mnt/%s --ip=0.0.0.0 --no-browser --allow-root --port=3000 --NotebookApp.token='' --NotebookApp.password='' --ServerApp.disable_check_xsrf=True --NotebookApp.allow_origin='*' --NotebookApp.tornado_settings='{"headers": {"Content-Security-Policy": "frame-ancestors * 'self' "}}'`
@@ -159,6 +198,7 @@ func createPodForNotebook(k8sClient *services.K8s, notebook *model.Notebook, lab
 		// 	{Name: "K8S_POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
 	}
 
+	gpuResourceName := k8sClient.GPUResourceName()
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        notebook.Name,
@@ -181,19 +221,19 @@ func createPodForNotebook(k8sClient *services.K8s, notebook *model.Notebook, lab
 						Limits: corev1.ResourceList{
 							corev1.ResourceMemory: resource.MustParse(notebook.ResourceMemory),
 							corev1.ResourceCPU:    resource.MustParse(notebook.ResourceCPU),
-							"nvidia.com/gpu":      *resource.NewQuantity(notebook.ResourceGPU, resource.DecimalSI),
+							gpuResourceName:       *resource.NewQuantity(notebook.ResourceGPU, resource.DecimalSI),
 						},
 						Requests: corev1.ResourceList{
 							corev1.ResourceMemory: resource.MustParse(notebook.ResourceMemory),
 							corev1.ResourceCPU:    resource.MustParse(notebook.ResourceCPU),
-							"nvidia.com/gpu":      *resource.NewQuantity(notebook.ResourceGPU, resource.DecimalSI),
+							gpuResourceName:       *resource.NewQuantity(notebook.ResourceGPU, resource.DecimalSI),
 						},
 					},
 				},
 			},
 			Volumes:            volumes,
 			RestartPolicy:      corev1.RestartPolicyNever,
-			NodeSelector:       map[string]string{"notebook": "true"},
+			NodeSelector:       notebookNodeSelector(notebook),
 			ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "hubsecret"}},
 			ServiceAccountName: "default",
 			SchedulerName:      viper.GetString("notebook.schedule"),
@@ -203,13 +243,26 @@ func createPodForNotebook(k8sClient *services.K8s, notebook *model.Notebook, lab
 	return k8sClient.CreatePod(notebook.Namespace, pod)
 }
 
+func defaultNotebookNodeSelector() map[string]string {
+	return map[string]string{"notebook": "true"}
+}
+
+// notebookNodeSelector returns the node selector stored on the Notebook, falling back to notebook=true
+func notebookNodeSelector(notebook *model.Notebook) map[string]string {
+	selector, err := parseNodeSelector(notebook.NodeSelector)
+	if err != nil || len(selector) == 0 {
+		return defaultNotebookNodeSelector()
+	}
+	return selector
+}
+
 // createServiceForNotebook creates a Service for the Notebook
-func createServiceForNotebook(k8sClient *services.K8s, notebook *model.Notebook, labels map[string]string) (*corev1.Service, error) {
+func createServiceForNotebook(k8sClient KubeClient, notebook *model.Notebook, labels map[string]string) (*corev1.Service, error) {
 	port := viper.GetInt("notebook.defaultPort")
 	return k8sClient.CreateServiceForNotebook(notebook.Namespace, notebook.Name, port, labels)
 }
 
-func createNotebookResources(k8sClient *services.K8s, notebook *model.Notebook) (*corev1.Pod, *corev1.Service, error) {
+func createNotebookResources(k8sClient KubeClient, notebook *model.Notebook) (*corev1.Pod, *corev1.Service, error) {
 
 	labels := map[string]string{
 		"app":      notebook.Name,
@@ -266,8 +319,8 @@ func DeleteNotebook(c *gin.Context) {
 		})
 		return
 	}
-	// Create K8s client todo
-	k8sClient, err := services.NewK8s("./services/config")
+	auditResource(c, model.ResourceNotebook, notebook.ID, notebook.ProjectID, notebook)
+	k8sClient, err := getClusterClient(notebook.ClusterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -439,8 +492,16 @@ func ResetNotebook(c *gin.Context) {
 		return
 	}
 
-	// Create K8s client
-	k8sClient, err := services.NewK8s("./services/config")
+	if existingNotebook.Status == model.NotebookStatusStopped {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Notebook is stopped, start it instead",
+			"data":    nil,
+		})
+		return
+	}
+
+	target, err := getClusterTarget(existingNotebook.ClusterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -448,6 +509,7 @@ func ResetNotebook(c *gin.Context) {
 		})
 		return
 	}
+	k8sClient := target.Client
 
 	// Delete existing Kubernetes resources
 	if err := deleteNotebookResources(k8sClient, existingNotebook); err != nil {
@@ -478,7 +540,7 @@ func ResetNotebook(c *gin.Context) {
 		return
 	}
 	nodeport := createdService.Spec.Ports[0].NodePort
-	existingNotebook.AccessURL = fmt.Sprintf("http://%s:%d/lab?#%s", viper.GetString("notebook.externalIP"), nodeport, existingNotebook.Name)
+	existingNotebook.AccessURL = fmt.Sprintf("http://%s:%d/lab?#%s", target.NotebookExternalIP, nodeport, existingNotebook.Name)
 	existingNotebook.UpdatedAt = time.Now()
 	if err := existingNotebook.Update(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -538,6 +600,7 @@ func UpdateNotebook(c *gin.Context) {
 	}
 
 	// Update Notebook model
+	previous := *notebook
 	updated := updateNotebookModel(notebook, &updateReq)
 
 	if !updated {
@@ -545,26 +608,34 @@ func UpdateNotebook(c *gin.Context) {
 		return
 	}
 
-	// Create K8s client
-	k8sClient, err := services.NewK8s("./services/config")
+	k8sClient, err := getClusterClient(notebook.ClusterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to create K8s client"})
 		return
 	}
 
+	// Only the additional resources count against the project quota; they are saved
+	// under the project lock before the pod is recreated so concurrent updates see them
+	additional := model.NotebookUsage(notebook).Sub(model.NotebookUsage(&previous))
+	err = model.ReserveProjectQuota(notebook.ProjectID, additional, notebook.SaveResources)
+	if err != nil {
+		if respondQuotaExceeded(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to update notebook in database"})
+		return
+	}
+
 	// Update Kubernetes resources
 	if err := updateK8sResources(k8sClient, notebook, &updateReq); err != nil {
 		common.SysError(err.Error())
+		if restoreErr := previous.SaveResources(model.DB); restoreErr != nil {
+			common.SysError("failed to restore notebook resources: " + restoreErr.Error())
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to update Kubernetes resources"})
 		return
 	}
 
-	// update notebook in database
-	if err := notebook.Update(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to update notebook in database"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Notebook updated successfully", "data": notebook})
 }
 
@@ -588,7 +659,7 @@ func updateNotebookModel(notebook *model.Notebook, updateReq *NotebookUpdateRequ
 	return updated
 }
 
-func updateK8sResources(k8sClient *services.K8s, notebook *model.Notebook, updateReq *NotebookUpdateRequest) error {
+func updateK8sResources(k8sClient KubeClient, notebook *model.Notebook, updateReq *NotebookUpdateRequest) error {
 	// ctx := context.Background()
 
 	// update Pod
@@ -597,7 +668,7 @@ func updateK8sResources(k8sClient *services.K8s, notebook *model.Notebook, updat
 		return err
 	}
 	newPod := pod.DeepCopy()
-	updatePodSpec(newPod, updateReq)
+	updatePodSpec(newPod, updateReq, k8sClient.GPUResourceName())
 	err = k8sClient.DeletePod(notebook.Namespace, notebook.Name)
 	if err != nil {
 		return fmt.Errorf("failed to delete old pod: %v", err)
@@ -622,7 +693,7 @@ func updateK8sResources(k8sClient *services.K8s, notebook *model.Notebook, updat
 
 	return nil
 }
-func updatePodSpec(pod *corev1.Pod, updateReq *NotebookUpdateRequest) {
+func updatePodSpec(pod *corev1.Pod, updateReq *NotebookUpdateRequest, gpuResourceName corev1.ResourceName) {
 	container := &pod.Spec.Containers[0]
 
 	if updateReq.ResourceCPU != nil {
@@ -637,11 +708,11 @@ func updatePodSpec(pod *corev1.Pod, updateReq *NotebookUpdateRequest) {
 	}
 	if updateReq.ResourceGPU != nil {
 		quantity := resource.NewQuantity(int64(*updateReq.ResourceGPU), resource.DecimalSI)
-		container.Resources.Limits["nvidia.com/gpu"] = *quantity
-		container.Resources.Requests["nvidia.com/gpu"] = *quantity
+		container.Resources.Limits[gpuResourceName] = *quantity
+		container.Resources.Requests[gpuResourceName] = *quantity
 	}
 }
-func deleteNotebookResources(k8sClient *services.K8s, notebook *model.Notebook) error {
+func deleteNotebookResources(k8sClient KubeClient, notebook *model.Notebook) error {
 
 	// Delete Pod
 	err := k8sClient.DeletePod(notebook.Namespace, notebook.Name)
@@ -0,0 +1,468 @@
+package controller
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// NotebookPolicyRequest 空闲回收与定时启停策略
+type NotebookPolicyRequest struct {
+	IdleTimeoutMinutes *int    `json:"idle_timeout_minutes" example:"120"`
+	ScheduleStop       *string `json:"schedule_stop" example:"20:00"`
+	ScheduleStart      *string `json:"schedule_start" example:"08:30"`
+}
+
+// stopNotebook deletes the Notebook pod but keeps its Service, PVC subpath and DB row
+func stopNotebook(k8sClient KubeClient, notebook *model.Notebook) error {
+	err := k8sClient.DeletePod(notebook.Namespace, notebook.Name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Pod: %v", err)
+	}
+	return notebook.MarkStopped()
+}
+
+// startNotebook reserves the project quota for a stopped Notebook and recreates its workload,
+// releasing the reservation if the workload cannot be created
+func startNotebook(target *clusterTarget, notebook *model.Notebook) error {
+	if err := notebook.ReserveStart(notebookStartUsage(notebook)); err != nil {
+		return err
+	}
+	if err := recreateNotebookWorkload(target, notebook); err != nil {
+		if cancelErr := notebook.CancelStart(); cancelErr != nil {
+			common.SysError(fmt.Sprintf("failed to release start of %s: %v", notebook.Name, cancelErr))
+		}
+		return err
+	}
+	return notebook.MarkStarted()
+}
+
+// recreateNotebookWorkload recreates the pod of a stopped Notebook on its cluster, and its Service if it has gone missing
+func recreateNotebookWorkload(target *clusterTarget, notebook *model.Notebook) error {
+	k8sClient := target.Client
+	labels := map[string]string{
+		"app":      notebook.Name,
+		"pod-type": viper.GetString("notebook.podType"),
+		"user":     strings.Split(notebook.Name, "-")[0],
+	}
+
+	if _, err := createPodForNotebook(k8sClient, notebook, labels); err != nil {
+		return fmt.Errorf("failed to create Pod: %v", err)
+	}
+
+	if _, err := k8sClient.GetService(notebook.Namespace, notebook.Name); k8serrors.IsNotFound(err) {
+		createdService, err := createServiceForNotebook(k8sClient, notebook, labels)
+		if err != nil {
+			_ = k8sClient.DeletePod(notebook.Namespace, notebook.Name)
+			return fmt.Errorf("failed to create Service: %v", err)
+		}
+		nodeport := createdService.Spec.Ports[0].NodePort
+		notebook.AccessURL = fmt.Sprintf("http://%s:%d/lab?#%s", target.NotebookExternalIP, nodeport, notebook.Name)
+	} else if err != nil {
+		_ = k8sClient.DeletePod(notebook.Namespace, notebook.Name)
+		return fmt.Errorf("failed to get Service: %v", err)
+	}
+	return nil
+}
+
+// notebookStartRequest 启动已停止的 Notebook 时需要重新申请的资源
+func notebookStartRequest(notebook *model.Notebook) (services.ResourceRequest, error) {
+	return newResourceRequest(
+		defaultString(notebook.ResourceCPU, "4"),
+		defaultString(notebook.ResourceMemory, "8G"),
+		notebook.ResourceGPU,
+		1,
+		notebookNodeSelector(notebook),
+	)
+}
+
+// notebookStartUsage 停止的 Notebook 已计入数量，启动时只需再申请计算资源
+func notebookStartUsage(notebook *model.Notebook) model.ResourceUsage {
+	usage := model.NotebookUsage(notebook)
+	usage.Notebooks = 0
+	return usage
+}
+
+func getNotebookFromParam(c *gin.Context) (*model.Notebook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid id parameter",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	notebook, err := model.GetNotebookByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Notebook not found",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	return notebook, true
+}
+
+// StopNotebook godoc
+// @Summary Stop a Notebook
+// @Description Delete the Notebook pod while keeping its workspace and record so it can be started again
+// @Tags notebook
+// @Produce json
+// @Param id path int true "Notebook ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /notebook/{id}/stop [post]
+func StopNotebook(c *gin.Context) {
+	notebook, ok := getNotebookFromParam(c)
+	if !ok {
+		return
+	}
+	audit := auditResource(c, model.ResourceNotebook, notebook.ID, notebook.ProjectID, gin.H{"status": notebook.Status})
+	if notebook.Status == model.NotebookStatusStopped {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Notebook is already stopped",
+			"data":    nil,
+		})
+		return
+	}
+
+	k8sClient, err := getClusterClient(notebook.ClusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create K8s client: " + err.Error(),
+		})
+		return
+	}
+
+	if err := stopNotebook(k8sClient, notebook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to stop Notebook: " + err.Error(),
+		})
+		return
+	}
+
+	audit.SetAfter(gin.H{"status": notebook.Status})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Notebook stopped successfully",
+		"data": gin.H{
+			"notebook": notebook,
+		},
+	})
+}
+
+// StartNotebook godoc
+// @Summary Start a stopped Notebook
+// @Description Recreate the pod of a stopped Notebook on its existing workspace
+// @Tags notebook
+// @Produce json
+// @Param id path int true "Notebook ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /notebook/{id}/start [post]
+func StartNotebook(c *gin.Context) {
+	notebook, ok := getNotebookFromParam(c)
+	if !ok {
+		return
+	}
+	audit := auditResource(c, model.ResourceNotebook, notebook.ID, notebook.ProjectID, gin.H{"status": notebook.Status})
+	if notebook.Status != model.NotebookStatusStopped {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Notebook is not stopped",
+			"data":    nil,
+		})
+		return
+	}
+
+	capacityRequest, err := notebookStartRequest(notebook)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid resource request: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	target, err := getClusterTarget(notebook.ClusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create K8s client: " + err.Error(),
+		})
+		return
+	}
+
+	if !checkClusterCapacity(c, target.Client, capacityRequest) {
+		return
+	}
+
+	// Stopped notebooks do not hold compute resources, so the start checks quota again
+	if err := startNotebook(target, notebook); err != nil {
+		if respondQuotaExceeded(c, err) {
+			return
+		}
+		if errors.Is(err, model.ErrNotebookNotStopped) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Notebook is not stopped",
+				"data":    nil,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start Notebook: " + err.Error(),
+		})
+		return
+	}
+
+	audit.SetAfter(gin.H{"status": notebook.Status})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Notebook started successfully",
+		"data": gin.H{
+			"notebook": notebook,
+		},
+	})
+}
+
+// notebookPolicy 审计记录中使用的生命周期策略字段
+func notebookPolicy(notebook *model.Notebook) gin.H {
+	return gin.H{
+		"idle_timeout_minutes": notebook.IdleTimeoutMinutes,
+		"schedule_stop":        notebook.ScheduleStop,
+		"schedule_start":       notebook.ScheduleStart,
+	}
+}
+
+// UpdateNotebookPolicy godoc
+// @Summary Update Notebook lifecycle policy
+// @Description Set the idle timeout (minutes, 0 disables) and daily stop/start times (HH:MM, empty disables)
+// @Tags notebook
+// @Accept json
+// @Produce json
+// @Param id path int true "Notebook ID"
+// @Param policy body NotebookPolicyRequest true "Lifecycle policy"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /notebook/{id}/policy [put]
+func UpdateNotebookPolicy(c *gin.Context) {
+	notebook, ok := getNotebookFromParam(c)
+	if !ok {
+		return
+	}
+	audit := auditResource(c, model.ResourceNotebook, notebook.ID, notebook.ProjectID, notebookPolicy(notebook))
+
+	var req NotebookPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request payload: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.IdleTimeoutMinutes != nil {
+		if *req.IdleTimeoutMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "idle_timeout_minutes must not be negative",
+				"data":    nil,
+			})
+			return
+		}
+		updates["idle_timeout_minutes"] = *req.IdleTimeoutMinutes
+	}
+	for column, value := range map[string]*string{"schedule_stop": req.ScheduleStop, "schedule_start": req.ScheduleStart} {
+		if value == nil {
+			continue
+		}
+		if _, err := services.ScheduleDue(*value, time.Time{}, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+				"data":    nil,
+			})
+			return
+		}
+		updates[column] = *value
+	}
+
+	if len(updates) > 0 {
+		if err := model.DB.Model(notebook).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to update Notebook policy: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	policy := notebookPolicy(notebook)
+	for column, value := range updates {
+		policy[column] = value
+	}
+	audit.SetAfter(policy)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Notebook policy updated successfully",
+		"data": gin.H{
+			"notebook": notebook,
+		},
+	})
+}
+
+// NotebookCuller 定期检查 Notebook 的空闲时间和定时启停策略，按 Notebook 所在集群选择客户端
+type NotebookCuller struct {
+	interval time.Duration
+	port     int
+	lastTick time.Time
+}
+
+// NewNotebookCuller creates a culler polling every interval
+func NewNotebookCuller(interval time.Duration) *NotebookCuller {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &NotebookCuller{
+		interval: interval,
+		port:     viper.GetInt("notebook.defaultPort"),
+	}
+}
+
+// Run blocks until ctx is done
+func (n *NotebookCuller) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	n.lastTick = time.Now()
+	common.SysLog("notebook culler started")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n.tick(now)
+			n.lastTick = now
+		}
+	}
+}
+
+func (n *NotebookCuller) tick(now time.Time) {
+	notebooks, err := model.GetNotebooksWithLifecyclePolicy()
+	if err != nil {
+		common.SysError("notebook culler: failed to list notebooks: " + err.Error())
+		return
+	}
+
+	for i := range notebooks {
+		notebook := &notebooks[i]
+		if notebook.Status == model.NotebookStatusStopped {
+			n.scheduledStart(notebook, now)
+			continue
+		}
+		if due, err := services.ScheduleDue(notebook.ScheduleStop, n.lastTick, now); err == nil && due {
+			n.stop(notebook, "scheduled stop")
+			continue
+		}
+		n.cullIdle(notebook, now)
+	}
+}
+
+func (n *NotebookCuller) scheduledStart(notebook *model.Notebook, now time.Time) {
+	due, err := services.ScheduleDue(notebook.ScheduleStart, n.lastTick, now)
+	if err != nil || !due {
+		return
+	}
+	request, err := notebookStartRequest(notebook)
+	if err != nil {
+		common.SysError(fmt.Sprintf("notebook culler: skip scheduled start of %s: %v", notebook.Name, err))
+		return
+	}
+	target, err := getClusterTarget(notebook.ClusterID)
+	if err != nil {
+		common.SysError(fmt.Sprintf("notebook culler: skip scheduled start of %s: %v", notebook.Name, err))
+		return
+	}
+	if result, err := target.Client.CheckClusterCapacity(request); err == nil && !result.Fits {
+		common.SysError(fmt.Sprintf("notebook culler: skip scheduled start of %s: insufficient cluster resources", notebook.Name))
+		return
+	}
+	if err := startNotebook(target, notebook); err != nil {
+		common.SysError(fmt.Sprintf("notebook culler: failed to start %s: %v", notebook.Name, err))
+		return
+	}
+	common.SysLog(fmt.Sprintf("notebook culler: started %s on schedule", notebook.Name))
+}
+
+func (n *NotebookCuller) cullIdle(notebook *model.Notebook, now time.Time) {
+	if notebook.IdleTimeoutMinutes <= 0 || notebook.Status != "Running" {
+		return
+	}
+
+	k8sClient, err := getClusterClient(notebook.ClusterID)
+	if err != nil {
+		common.SysError("notebook culler: " + err.Error())
+		return
+	}
+	status, err := k8sClient.GetJupyterStatus(notebook.Namespace, notebook.Name, n.port)
+	if err != nil {
+		common.SysError("notebook culler: " + err.Error())
+		return
+	}
+
+	lastActivity := status.LastActivity
+	if notebook.LastActivityAt != nil && notebook.LastActivityAt.After(lastActivity) {
+		lastActivity = *notebook.LastActivityAt
+	}
+	if notebook.LastActivityAt == nil || lastActivity.After(*notebook.LastActivityAt) {
+		if err := notebook.TouchActivity(lastActivity); err != nil {
+			common.SysError(fmt.Sprintf("notebook culler: failed to record activity of %s: %v", notebook.Name, err))
+		}
+	}
+
+	if now.Sub(lastActivity) >= time.Duration(notebook.IdleTimeoutMinutes)*time.Minute {
+		n.stop(notebook, fmt.Sprintf("idle since %s", lastActivity.Format(time.RFC3339)))
+	}
+}
+
+func (n *NotebookCuller) stop(notebook *model.Notebook, reason string) {
+	k8sClient, err := getClusterClient(notebook.ClusterID)
+	if err != nil {
+		common.SysError(fmt.Sprintf("notebook culler: failed to stop %s: %v", notebook.Name, err))
+		return
+	}
+	if err := stopNotebook(k8sClient, notebook); err != nil {
+		common.SysError(fmt.Sprintf("notebook culler: failed to stop %s: %v", notebook.Name, err))
+		return
+	}
+	common.SysLog(fmt.Sprintf("notebook culler: stopped %s (%s)", notebook.Name, reason))
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// setupTestDB 使用内存 SQLite 作为 model.DB，测试结束后恢复
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("database handle: %v", err)
+	}
+	// 内存数据库按连接隔离，所有操作共用一个连接
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	previous := model.DB
+	model.DB = db
+	t.Cleanup(func() {
+		model.DB = previous
+		sqlDB.Close()
+	})
+}
+
+// setupFakeK8s 注入只有一个 notebook 节点的假集群
+func setupFakeK8s(t *testing.T) *fake.Clientset {
+	t.Helper()
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"notebook": "true"}},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("16"),
+			corev1.ResourceMemory: resource.MustParse("64Gi"),
+		}},
+	}
+	clientset := fake.NewSimpleClientset(node)
+	previous := kubeClient
+	SetK8sClient(services.NewK8sWithClients(clientset, dfake.NewSimpleDynamicClient(runtime.NewScheme())))
+	t.Cleanup(func() { SetK8sClient(previous) })
+	return clientset
+}
+
+func postNotebook(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/notebook", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("username", "alice")
+	c.Set("user_id", 1)
+	CreateNotebook(c)
+	return w
+}
+
+func TestCreateNotebook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t, &model.Project{}, &model.ProjectQuota{}, &model.Cluster{},
+		&model.Notebook{}, &model.TrainingJob{}, &model.TritonDeploy{})
+	clientset := setupFakeK8s(t)
+	model.DB.Create(&model.Project{Model: gorm.Model{ID: 1}, Name: "p1"})
+	if err := model.SaveProjectQuota(&model.ProjectQuota{ProjectID: 1, MaxNotebooks: 1}); err != nil {
+		t.Fatalf("SaveProjectQuota: %v", err)
+	}
+
+	// 请求体中的 user_id 被忽略，Notebook 属于当前用户
+	w := postNotebook(`{"project_id": 1, "user_id": 2, "image": "jupyter:latest", "resource_cpu": "2", "resource_memory": "4Gi"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	var notebook model.Notebook
+	if err := model.DB.First(&notebook).Error; err != nil {
+		t.Fatalf("notebook not saved: %v", err)
+	}
+	if notebook.Status != "Creating" || notebook.Namespace != "jupyter" || notebook.UserID != 1 {
+		t.Errorf("notebook %+v", notebook)
+	}
+	pod, err := clientset.CoreV1().Pods("jupyter").Get(context.TODO(), notebook.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("pod not created: %v", err)
+	}
+	container := pod.Spec.Containers[0]
+	if container.Image != "jupyter:latest" || pod.Labels["app"] != notebook.Name || pod.Labels["user"] != "alice" {
+		t.Errorf("pod %s image %s labels %v", pod.Name, container.Image, pod.Labels)
+	}
+	if _, err := clientset.CoreV1().Services("jupyter").Get(context.TODO(), notebook.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("service not created: %v", err)
+	}
+
+	// 项目最多一个 Notebook，第二次创建返回 403 且不创建 Pod
+	w = postNotebook(`{"project_id": 1, "image": "jupyter:latest"}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	pods, _ := clientset.CoreV1().Pods("jupyter").List(context.TODO(), metav1.ListOptions{})
+	if len(pods.Items) != 1 {
+		t.Errorf("expected 1 pod, got %d", len(pods.Items))
+	}
+}
@@ -67,6 +67,18 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	common.OptionMapRWMutex.RLock()
+	previous := common.OptionMap[option.Key]
+	common.OptionMapRWMutex.RUnlock()
+	current := option.Value
+	if model.IsSensitiveAuditField(option.Key) {
+		previous, current = "******", "******"
+	}
+	audit := auditOf(c)
+	audit.SetResource(model.ResourceOption, option.Key)
+	audit.SetBefore(gin.H{"value": previous})
+	audit.SetAfter(gin.H{"value": current})
+
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -27,11 +27,16 @@ func CreateProject(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validateProjectCluster(c, project.ClusterID) {
+		return
+	}
 
 	if err := model.DB.Create(&project).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed create project: " + err.Error()})
 		return
 	}
+	auditResource(c, model.ResourceProject, project.ID, project.ID, nil).
+		SetAfter(gin.H{"name": project.Name, "description": project.Description, "cluster_id": project.ClusterID})
 
 	c.JSON(http.StatusCreated, gin.H{"success": true, "data": project})
 }
@@ -67,6 +72,8 @@ func UpdateProject(c *gin.Context) {
 		})
 		return
 	}
+	audit := auditResource(c, model.ResourceProject, project.ID, project.ID,
+		gin.H{"name": project.Name, "description": project.Description, "cluster_id": project.ClusterID})
 
 	if err := c.ShouldBindJSON(&project); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -75,6 +82,9 @@ func UpdateProject(c *gin.Context) {
 		})
 		return
 	}
+	if !validateProjectCluster(c, project.ClusterID) {
+		return
+	}
 
 	if err := model.DB.Save(&project).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -84,12 +94,14 @@ func UpdateProject(c *gin.Context) {
 		return
 	}
 
+	audit.SetAfter(gin.H{"name": project.Name, "description": project.Description, "cluster_id": project.ClusterID})
+
 	// 将模型转换为DTO
 	projectDTO := ProjectDTO{
 		ID:          project.ID,
 		Name:        project.Name,
 		Description: project.Description,
-		// 添加其他必要字段
+		ClusterID:   project.ClusterID,
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse{
@@ -120,6 +132,12 @@ func DeleteProject(c *gin.Context) {
 		return
 	}
 
+	var project model.Project
+	if err := model.DB.First(&project, id).Error; err == nil {
+		auditResource(c, model.ResourceProject, project.ID, project.ID,
+			gin.H{"name": project.Name, "description": project.Description})
+	}
+
 	if err := model.DB.Delete(&model.Project{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
@@ -222,6 +240,7 @@ func ListProjects(c *gin.Context) {
 			ID:          project.ID,
 			Name:        project.Name,
 			Description: project.Description,
+			ClusterID:   project.ClusterID,
 			// 添加其他必要字段
 			Users: users,
 			// 可能需要根据实际情况添加更多字段
@@ -241,3 +260,18 @@ func ListProjects(c *gin.Context) {
 		},
 	})
 }
+
+// validateProjectCluster 项目默认集群必须已登记，0 表示使用全局默认集群
+func validateProjectCluster(c *gin.Context, clusterID uint) bool {
+	if clusterID == 0 {
+		return true
+	}
+	if _, err := model.GetClusterByID(clusterID); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Message: "集群不存在",
+		})
+		return false
+	}
+	return true
+}
@@ -152,6 +152,9 @@ func AddUserToProject(c *gin.Context) {
 		return
 	}
 
+	auditResource(c, model.ResourceProjectMember, userProject.UserID, userProject.ProjectID, nil).
+		SetAfter(gin.H{"role": userProject.Role})
+
 	if err := model.DB.Create(&userProject).Error; err != nil {
 		errMsg := fmt.Sprintf("创建用户项目关系失败: %s, userId=%d, projectId=%d", err.Error(), request.UserId, request.ProjectId)
 		common.SysLog(errMsg)
@@ -218,6 +221,11 @@ func RemoveUserFromProject(c *gin.Context) {
 		return
 	}
 
+	var member model.UserProject
+	if err := model.DB.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error; err == nil {
+		auditResource(c, model.ResourceProjectMember, uint(userID), uint(projectID), gin.H{"role": member.Role})
+	}
+
 	result := model.DB.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&model.UserProject{})
 
 	if result.Error != nil {
@@ -292,6 +300,10 @@ func UpdateUserProjectRole(c *gin.Context) {
 		return
 	}
 
+	previousRole, _ := model.GetProjectRole(request.ProjectId, request.UserId)
+	auditResource(c, model.ResourceProjectMember, request.UserId, request.ProjectId, gin.H{"role": previousRole}).
+		SetAfter(gin.H{"role": request.Role})
+
 	if err := model.DB.Model(&model.UserProject{}).Where("user_id = ? AND project_id = ?", request.UserId, request.ProjectId).Update("role", request.Role).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectQuotaDTO 项目配额及当前占用
+type ProjectQuotaDTO struct {
+	ProjectID   uint                `json:"project_id" example:"1"`
+	ProjectName string              `json:"project_name" example:"default"`
+	Quota       model.ProjectQuota  `json:"quota"`
+	Usage       model.ResourceUsage `json:"usage"`
+}
+
+// respondQuotaExceeded 对超出配额的错误返回 403，其他错误不处理，返回 true 表示已响应
+func respondQuotaExceeded(c *gin.Context, err error) bool {
+	var quotaErr *model.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, ErrorResponse{
+		Success: false,
+		Message: quotaErr.Error(),
+		Data:    quotaErr,
+	})
+	return true
+}
+
+// respondQuotaError 对配额校验错误返回结构化响应，返回 true 表示已响应
+func respondQuotaError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if respondQuotaExceeded(c, err) {
+		return true
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Success: false,
+		Message: "Failed to check project quota: " + err.Error(),
+	})
+	return true
+}
+
+func buildProjectQuotaDTO(project model.Project) (ProjectQuotaDTO, error) {
+	quota, err := model.GetProjectQuota(project.ID)
+	if err != nil {
+		return ProjectQuotaDTO{}, err
+	}
+	usage, err := model.GetProjectUsage(project.ID)
+	if err != nil {
+		return ProjectQuotaDTO{}, err
+	}
+	return ProjectQuotaDTO{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		Quota:       *quota,
+		Usage:       usage,
+	}, nil
+}
+
+// ListProjectQuotas godoc
+// @Summary List project quotas
+// @Description List quotas of all projects together with their live usage
+// @Tags quota
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /quota [get]
+func ListProjectQuotas(c *gin.Context) {
+	var projects []model.Project
+	if err := model.DB.Order("id ASC").Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve projects: " + err.Error(),
+		})
+		return
+	}
+
+	quotas := make([]ProjectQuotaDTO, 0, len(projects))
+	for _, project := range projects {
+		dto, err := buildProjectQuotaDTO(project)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Success: false,
+				Message: "Failed to retrieve project quota: " + err.Error(),
+			})
+			return
+		}
+		quotas = append(quotas, dto)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "",
+		Data:    quotas,
+	})
+}
+
+// GetProjectQuota godoc
+// @Summary Get project quota
+// @Description Get the quota of a project and its live usage
+// @Tags quota
+// @Produce json
+// @Param projectId path int true "Project ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /quota/{projectId} [get]
+func GetProjectQuota(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的项目ID"})
+		return
+	}
+
+	var project model.Project
+	if err := model.DB.First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "项目不存在"})
+		return
+	}
+
+	dto, err := buildProjectQuotaDTO(project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve project quota: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "",
+		Data:    dto,
+	})
+}
+
+// UpdateProjectQuota godoc
+// @Summary Update project quota
+// @Description Create or update the quota of a project, 0 means unlimited
+// @Tags quota
+// @Accept json
+// @Produce json
+// @Param projectId path int true "Project ID"
+// @Param quota body model.ProjectQuota true "Quota"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /quota/{projectId} [put]
+func UpdateProjectQuota(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "无效的项目ID"})
+		return
+	}
+
+	var project model.Project
+	if err := model.DB.First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "项目不存在"})
+		return
+	}
+
+	var quota model.ProjectQuota
+	if err := c.ShouldBindJSON(&quota); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+	if quota.CPU < 0 || quota.Memory < 0 || quota.GPU < 0 ||
+		quota.MaxNotebooks < 0 || quota.MaxTrainingJobs < 0 || quota.MaxTritonDeploys < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "配额不能为负数"})
+		return
+	}
+	quota.ProjectID = project.ID
+
+	audit := auditResource(c, model.ResourceProjectQuota, project.ID, project.ID, nil)
+	if previous, err := model.GetProjectQuota(project.ID); err == nil {
+		audit.SetBefore(quotaLimits(previous))
+	}
+	audit.SetAfter(quotaLimits(&quota))
+
+	if err := model.SaveProjectQuota(&quota); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to save project quota: " + err.Error(),
+		})
+		return
+	}
+
+	dto, err := buildProjectQuotaDTO(project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Message: "Failed to retrieve project quota: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Project quota updated successfully",
+		Data:    dto,
+	})
+}
+
+// quotaLimits 审计记录中使用的配额字段
+func quotaLimits(quota *model.ProjectQuota) gin.H {
+	return gin.H{
+		"cpu":                quota.CPU,
+		"memory":             quota.Memory,
+		"gpu":                quota.GPU,
+		"max_notebooks":      quota.MaxNotebooks,
+		"max_training_jobs":  quota.MaxTrainingJobs,
+		"max_triton_deploys": quota.MaxTritonDeploys,
+	}
+}
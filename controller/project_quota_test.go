@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondQuotaError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	quotaErr := &model.QuotaExceededError{
+		ProjectID:  1,
+		Violations: []model.QuotaViolation{{Resource: "gpu", Limit: 2, Used: 2, Requested: 1}},
+	}
+	cases := []struct {
+		err       error
+		responded bool
+		status    int
+	}{
+		{nil, false, http.StatusOK},
+		{fmt.Errorf("reserve: %w", quotaErr), true, http.StatusForbidden},
+		{errors.New("database is locked"), true, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if responded := respondQuotaError(c, tc.err); responded != tc.responded {
+			t.Errorf("%v: responded %v", tc.err, responded)
+		}
+		if w.Code != tc.status {
+			t.Errorf("%v: status %d, want %d", tc.err, w.Code, tc.status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	respondQuotaExceeded(c, quotaErr)
+	var body struct {
+		Success bool                     `json:"success"`
+		Data    model.QuotaExceededError `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Success || len(body.Data.Violations) != 1 || body.Data.Violations[0].Resource != "gpu" {
+		t.Errorf("response %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	if respondQuotaExceeded(c, errors.New("insert failed")) || w.Body.Len() != 0 {
+		t.Errorf("non-quota error was handled: %s", w.Body.String())
+	}
+}
+
+func TestUpdateTritonDeployQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t, &model.Project{}, &model.ProjectQuota{}, &model.Notebook{}, &model.TrainingJob{}, &model.TritonDeploy{})
+	project := &model.Project{Name: "p1"}
+	if err := model.DB.Create(project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	model.DB.Create(&model.ProjectQuota{ProjectID: project.ID, GPU: 1})
+	deploy := &model.TritonDeploy{Name: "tri", ProjectID: project.ID, Replicas: 1, GPU: 1}
+	if err := model.DB.Create(deploy).Error; err != nil {
+		t.Fatalf("create deploy: %v", err)
+	}
+
+	update := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/api/triton_deploy", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(deploy.ID))}}
+		c.Set("user_id", 1)
+		UpdateTritonDeploy(c)
+		return w
+	}
+
+	// 增加副本数会超出 GPU 配额，不保存修改
+	if w := update(`{"image": "triton:2", "replicas": 2, "gpu": 1}`); w.Code != http.StatusForbidden {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var current model.TritonDeploy
+	model.DB.First(&current, deploy.ID)
+	if current.Replicas != 1 || current.Image != "" {
+		t.Errorf("deploy changed after quota rejection: %+v", current)
+	}
+
+	// 不增加资源的修改不受已用满的配额限制
+	if w := update(`{"image": "triton:2", "replicas": 1, "gpu": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+}
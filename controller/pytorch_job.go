@@ -2,14 +2,20 @@ package controller
 
 import (
 	"MLcore-Engine/common"
+	"MLcore-Engine/middleware"
 	"MLcore-Engine/model"
 	"MLcore-Engine/services"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
@@ -22,6 +28,7 @@ import (
 // @Param training_job body model.TrainingJob true "Training Job details"
 // @Success 200 {object} TrainingJobResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /pytorchtrain [post]
 func CreateTrainingJob(c *gin.Context) {
@@ -38,31 +45,93 @@ func CreateTrainingJob(c *gin.Context) {
 		return
 	}
 
-	job.Name = username + "-pytorchjob-" + common.GenRandStr(5)
-	job.Status = "Pending"
+	// The job always belongs to the caller; user_id in the request body is ignored
+	job.UserID = uint(c.GetInt("user_id"))
 
-	// Insert TrainingJob into the database
-	if err := job.Insert(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	nodeSelector, err := trainingJobNodeSelector(&job)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "Failed to insert Training Job: " + err.Error(),
+			"message": err.Error(),
 			"data":    nil,
 		})
 		return
 	}
 
-	// Create Kubernetes client
-	k8sClient, err := services.NewK8s("./services/localconfig")
+	// Every master and worker replica requests the same resources
+	capacityRequest, err := newResourceRequest(
+		defaultString(job.CPULimit, "4"),
+		defaultString(job.MemoryLimit, "8Gi"),
+		job.GPUsPerNode,
+		int(max(job.MasterReplicas, 1)+max(job.WorkerReplicas, 1)),
+		nodeSelector,
+	)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid resource request: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	// Resolve the target cluster: request > project default > global default
+	target, ok := resolveWorkloadCluster(c, job.ClusterID, job.ProjectID)
+	if !ok {
+		return
+	}
+	k8sClient := target.Client
+
+	// Check cluster capacity before persisting anything
+	if !checkClusterCapacity(c, k8sClient, capacityRequest) {
+		return
+	}
+
+	// Check the project quota before a dataset snapshot may be taken; the check is repeated under the project lock below
+	if err := model.CheckProjectQuota(job.ProjectID, model.TrainingJobUsage(&job)); err != nil {
+		if respondQuotaExceeded(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "Failed to create K8s client: " + err.Error(),
+			"message": "Failed to check project quota: " + err.Error(),
 			"data":    nil,
 		})
-		// Optionally, rollback the database insertion if K8s client creation fails
-		if delErr := job.Delete(); delErr != nil {
-			fmt.Printf("Failed to rollback Training Job: %v\n", delErr)
+		return
+	}
+
+	// Pin the dataset version used by this job for reproducibility
+	discardSnapshot, err := resolveTrainingDatasetVersion(c, &job)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	job.Name = username + "-pytorchjob-" + common.GenRandStr(5)
+	job.ClusterID = target.ID
+	if job.Namespace == "" {
+		job.Namespace = target.TrainingNamespace
+	}
+	job.Status = "Pending"
+
+	// Check project quota and insert TrainingJob under the project lock
+	err = model.ReserveProjectQuota(job.ProjectID, model.TrainingJobUsage(&job), func(tx *gorm.DB) error {
+		return tx.Create(&job).Error
+	})
+	if err != nil {
+		discardSnapshot()
+		if respondQuotaExceeded(c, err) {
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to insert Training Job: " + err.Error(),
+			"data":    nil,
+		})
 		return
 	}
 
@@ -77,6 +146,8 @@ func CreateTrainingJob(c *gin.Context) {
 		// Optionally, rollback the database insertion if K8s Job creation fails
 		if delErr := job.Delete(); delErr != nil {
 			fmt.Printf("Failed to rollback Training Job: %v\n", delErr)
+		} else {
+			discardSnapshot()
 		}
 		return
 	}
@@ -91,6 +162,7 @@ func CreateTrainingJob(c *gin.Context) {
 		})
 		return
 	}
+	auditResource(c, model.ResourceTrainingJob, job.ID, job.ProjectID, nil).SetAfter(convertToTrainingJobDTO(job))
 
 	c.JSON(http.StatusOK, TrainingJobResponse{
 		Success: true,
@@ -100,7 +172,7 @@ func CreateTrainingJob(c *gin.Context) {
 }
 
 // createPyTorchJob creates a Kubernetes PyTorchJob and updates the TrainingJob with Kubernetes details
-func createPyTorchJob(k8sClient *services.K8s, job *model.TrainingJob) error {
+func createPyTorchJob(k8sClient KubeClient, job *model.TrainingJob) error {
 	// set default values
 	if job.Image == "" {
 		return fmt.Errorf("image is empty: %v", job.Image)
@@ -136,12 +208,10 @@ func createPyTorchJob(k8sClient *services.K8s, job *model.TrainingJob) error {
 	}
 
 	// parse NodeSelector string to map
-	nodeSelector := make(map[string]string)
-	// if job.NodeSelector != "" {
-	// 	if err := json.Unmarshal([]byte(job.NodeSelector), &nodeSelector); err != nil {
-	// 		return fmt.Errorf("invalid node selector format: %v", err)
-	// 	}
-	// }
+	nodeSelector, err := trainingJobNodeSelector(job)
+	if err != nil {
+		return err
+	}
 
 	// parse Env string to EnvVar slice
 	var envVars []services.EnvVar
@@ -159,6 +229,12 @@ func createPyTorchJob(k8sClient *services.K8s, job *model.TrainingJob) error {
 		job.ImagePullPolicy = "IfNotPresent"
 	}
 
+	// 训练数据集通过 init 容器下载到共享目录
+	dataset, err := trainingDatasetSource(job)
+	if err != nil {
+		return err
+	}
+
 	// 创建 PyTorchJob 配置
 	config := services.PyTorchJobConfig{
 		Name:            job.Name,
@@ -175,10 +251,11 @@ func createPyTorchJob(k8sClient *services.K8s, job *model.TrainingJob) error {
 		MemoryLimit:     job.MemoryLimit,
 		NodeSelector:    nodeSelector,
 		Env:             envVars,
+		Dataset:         dataset,
 	}
 
 	// 在 Kubernetes 中创建 PyTorchJob
-	_, err := k8sClient.CreatePyTorchJob(config.Namespace, config)
+	_, err = k8sClient.CreatePyTorchJob(config.Namespace, config)
 	if err != nil {
 		return fmt.Errorf("failed to create PyTorchJob: %v", err)
 	}
@@ -186,6 +263,143 @@ func createPyTorchJob(k8sClient *services.K8s, job *model.TrainingJob) error {
 	return nil
 }
 
+// trainingJobNodeSelector parses the JSON-encoded NodeSelector of a TrainingJob
+func trainingJobNodeSelector(job *model.TrainingJob) (map[string]string, error) {
+	nodeSelector := make(map[string]string)
+	if strings.TrimSpace(job.NodeSelector) == "" {
+		return nodeSelector, nil
+	}
+	if err := json.Unmarshal([]byte(job.NodeSelector), &nodeSelector); err != nil {
+		return nil, fmt.Errorf("invalid node selector format: %v", err)
+	}
+	return nodeSelector, nil
+}
+
+// resolveTrainingDatasetVersion checks access to the requested dataset and records the exact version the job will use.
+// Without dataset_version_id the active version is used; a new snapshot is taken if the dataset has no active version
+// or its entries were modified after the active version was created, so the job always trains on the current data.
+// The resolved version is stored in dataset_version_id and returned with the job.
+// The returned discard func removes a snapshot taken here and must be called when the job is rejected afterwards.
+func resolveTrainingDatasetVersion(c *gin.Context, job *model.TrainingJob) (discard func(), err error) {
+	discard = func() {}
+	userID := c.GetInt("user_id")
+	if job.DatasetID == 0 {
+		if job.DatasetVersionID != 0 {
+			return discard, fmt.Errorf("dataset_version_id requires dataset_id")
+		}
+		return discard, nil
+	}
+
+	var dataset model.Dataset
+	if err := model.DB.First(&dataset, job.DatasetID).Error; err != nil {
+		return discard, fmt.Errorf("dataset not found")
+	}
+	owner := &model.ResourceOwner{ProjectID: dataset.ProjectID, UserID: dataset.UserID}
+	allowed, err := model.CheckProjectPermission(uint(userID), c.GetInt("role"), owner, model.ActionView)
+	if err != nil || !allowed || !middleware.TokenAllowsProject(c, dataset.ProjectID) {
+		return discard, fmt.Errorf("no permission to access dataset %d", dataset.ID)
+	}
+
+	var version *model.DatasetVersion
+	if job.DatasetVersionID != 0 {
+		version, err = model.GetDatasetVersion(dataset.ID, job.DatasetVersionID)
+		if err != nil {
+			return discard, fmt.Errorf("dataset version not found")
+		}
+	} else if active, err := model.GetActiveDatasetVersion(dataset.ID); err != nil ||
+		(dataset.ContentUpdatedAt != nil && dataset.ContentUpdatedAt.After(active.CreatedAt)) {
+		version, err = createDatasetSnapshot(&dataset, "Created for training job", uint(userID), false)
+		if err != nil {
+			return discard, fmt.Errorf("failed to snapshot dataset: %v", err)
+		}
+		var previousID uint
+		if active != nil {
+			previousID = active.ID
+		}
+		discard = func() { discardTrainingSnapshot(version, previousID) }
+	} else {
+		version = active
+	}
+
+	job.DatasetVersionID = version.ID
+	return discard, nil
+}
+
+var errSnapshotInUse = errors.New("dataset snapshot is used by a training job")
+
+// discardTrainingSnapshot deletes a snapshot taken for a rejected training job. If the snapshot is still the
+// active version, the version that was active before it is reactivated (none when previousID is 0).
+// A snapshot already pinned by another job is kept.
+func discardTrainingSnapshot(version *model.DatasetVersion, previousID uint) {
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		var jobs int64
+		if err := tx.Model(&model.TrainingJob{}).Where("dataset_version_id = ?", version.ID).Count(&jobs).Error; err != nil {
+			return err
+		}
+		if jobs > 0 {
+			return errSnapshotInUse
+		}
+		var current model.DatasetVersion
+		if err := tx.Select("id", "is_active").First(&current, version.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.DatasetVersion{}, version.ID).Error; err != nil {
+			return err
+		}
+		if !current.IsActive || previousID == 0 {
+			return nil
+		}
+		return model.SetActiveDatasetVersion(tx, version.DatasetID, previousID)
+	})
+	if errors.Is(err, errSnapshotInUse) {
+		return
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to discard dataset version %d: %v", version.ID, err))
+		return
+	}
+	if err := services.DeleteDatasetMinioObject(version.BucketName, version.ObjectPath); err != nil {
+		common.SysError(fmt.Sprintf("failed to delete dataset snapshot %s: %v", version.ObjectPath, err))
+	}
+}
+
+// trainingDatasetSource builds the dataset download settings for the job's pinned dataset version
+func trainingDatasetSource(job *model.TrainingJob) (*services.DatasetSource, error) {
+	if job.DatasetVersionID == 0 {
+		return nil, nil
+	}
+
+	version, err := model.GetDatasetVersion(job.DatasetID, job.DatasetVersionID)
+	if err != nil {
+		return nil, fmt.Errorf("dataset version not found: %v", err)
+	}
+
+	// The URL must stay valid until the pods start; S3 presigned URLs are limited to 7 days
+	expiry := time.Duration(viper.GetInt64("crds.pytorchjob.timeout")) * time.Second
+	if expiry <= 0 {
+		expiry = 24 * time.Hour
+	}
+	if expiry > 7*24*time.Hour {
+		expiry = 7 * 24 * time.Hour
+	}
+	url, err := services.GetPresignedURL(version.BucketName, version.ObjectPath, expiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign dataset url: %v", err)
+	}
+
+	image := viper.GetString("crds.pytorchjob.datasetInitImage")
+	if image == "" {
+		image = "curlimages/curl:8.8.0"
+	}
+
+	return &services.DatasetSource{
+		URL:       url,
+		MountPath: "/data",
+		FileName:  fmt.Sprintf("dataset_%d_%s.jsonl", job.DatasetID, version.Version),
+		Image:     image,
+	}, nil
+}
+
 // interfaceSliceToStringSlice converts []interface{} to []string
 // func interfaceSliceToStringSlice(slice []interface{}) []string {
 // 	strSlice := make([]string, len(slice))
@@ -229,9 +443,9 @@ func DeleteTrainingJob(c *gin.Context) {
 		})
 		return
 	}
+	auditResource(c, model.ResourceTrainingJob, job.ID, job.ProjectID, convertToTrainingJobDTO(*job))
 
-	// Create Kubernetes client
-	k8sClient, err := services.NewK8s("./services/localconfig")
+	k8sClient, err := getClusterClient(job.ClusterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -342,6 +556,7 @@ func ListTrainingJobs(c *gin.Context) {
 	if role.(int) != 100 {
 		query = query.Where("user_id = ?", userId)
 	}
+	query = restrictTokenProjects(c, query, "project_id")
 
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
@@ -372,14 +587,16 @@ func ListTrainingJobs(c *gin.Context) {
 // convertToTrainingJobDTO 将模型对象转换为DTO
 func convertToTrainingJobDTO(job model.TrainingJob) TrainingJobDTO {
 	return TrainingJobDTO{
-		ID:        job.ID,
-		Name:      job.Name,
-		Status:    job.Status,
-		ProjectID: job.ProjectID,
-		UserID:    job.UserID,
-		Image:     job.Image,
-		CreatedAt: job.CreatedAt,
-		UpdatedAt: job.UpdatedAt,
+		ID:               job.ID,
+		Name:             job.Name,
+		Status:           job.Status,
+		ProjectID:        job.ProjectID,
+		UserID:           job.UserID,
+		Image:            job.Image,
+		DatasetID:        job.DatasetID,
+		DatasetVersionID: job.DatasetVersionID,
+		CreatedAt:        job.CreatedAt,
+		UpdatedAt:        job.UpdatedAt,
 	}
 }
 
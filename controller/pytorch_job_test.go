@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"testing"
+)
+
+func TestDiscardTrainingSnapshot(t *testing.T) {
+	setupTestDB(t, &model.Dataset{}, &model.DatasetVersion{}, &model.TrainingJob{})
+	dataset := &model.Dataset{Name: "d1", StorageType: "database", UserID: 1}
+	if err := model.DB.Create(dataset).Error; err != nil {
+		t.Fatalf("create dataset: %v", err)
+	}
+	versions := []*model.DatasetVersion{
+		{DatasetID: dataset.ID, Version: "v1", UserID: 1},
+		{DatasetID: dataset.ID, Version: "v2", UserID: 1},
+		{DatasetID: dataset.ID, Version: "v3", UserID: 1},
+	}
+	for _, version := range versions {
+		if err := model.DB.Create(version).Error; err != nil {
+			t.Fatalf("create version: %v", err)
+		}
+	}
+	previous, pinned, snapshot := versions[0], versions[1], versions[2]
+
+	// A snapshot pinned by another job is kept
+	model.DB.Create(&model.TrainingJob{Name: "job", DatasetID: dataset.ID, DatasetVersionID: pinned.ID})
+	discardTrainingSnapshot(pinned, previous.ID)
+	if _, err := model.GetDatasetVersion(dataset.ID, pinned.ID); err != nil {
+		t.Errorf("pinned snapshot was deleted: %v", err)
+	}
+
+	// The rejected job's snapshot is deleted and the previous version is active again
+	if err := model.SetActiveDatasetVersion(model.DB, dataset.ID, snapshot.ID); err != nil {
+		t.Fatalf("activate snapshot: %v", err)
+	}
+	discardTrainingSnapshot(snapshot, previous.ID)
+	if _, err := model.GetDatasetVersion(dataset.ID, snapshot.ID); err == nil {
+		t.Errorf("snapshot was not deleted")
+	}
+	active, err := model.GetActiveDatasetVersion(dataset.ID)
+	if err != nil || active.ID != previous.ID {
+		t.Errorf("active version %+v, err %v", active, err)
+	}
+}
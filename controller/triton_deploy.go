@@ -12,6 +12,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -24,6 +25,7 @@ import (
 // @Param triton_deploy body model.TritonDeploy true "Triton Deployment details"
 // @Success 200 {object} SuccessResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /triton [post]
 func CreateTritonDeploy(c *gin.Context) {
@@ -39,29 +41,62 @@ func CreateTritonDeploy(c *gin.Context) {
 		return
 	}
 
-	deploy.Name = username + "-tri" + common.GenRandStr(5)
-	if deploy.Namespace == "" {
-		deploy.Namespace = "triton-serving"
-	}
-	deploy.Status = "Creating"
+	// 部署始终属于当前用户，忽略请求体中的 user_id
+	deploy.UserID = uint(c.GetInt("user_id"))
 
-	deploy.Labels = "{\"app\":\"" + deploy.Name + "\"}"
-
-	if err := deploy.Insert(); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	// Triton 部署不限制节点，副本按 CPU 核数和 GiB 内存申请资源
+	replicas, cpu, memory := deploy.Replicas, deploy.CPU, deploy.Memory
+	if replicas == 0 {
+		replicas = 1
+	}
+	if cpu == 0 {
+		cpu = 2
+	}
+	if memory == 0 {
+		memory = 4
+	}
+	capacityRequest, err := newResourceRequest(fmt.Sprintf("%d", cpu), fmt.Sprintf("%dGi", memory), deploy.GPU, int(replicas), nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Success: false,
-			Message: "Failed to insert TritonDeploy: " + err.Error(),
+			Message: "Invalid resource request: " + err.Error(),
 			Data:    nil,
 		})
 		return
 	}
 
-	// Create K8s client
-	k8sClient, err := services.NewK8s("services/localconfig")
+	// Resolve the target cluster: request > project default > global default
+	target, ok := resolveWorkloadCluster(c, deploy.ClusterID, deploy.ProjectID)
+	if !ok {
+		return
+	}
+	k8sClient := target.Client
+
+	// Check cluster capacity before persisting anything
+	if !checkClusterCapacity(c, k8sClient, capacityRequest) {
+		return
+	}
+
+	deploy.Name = username + "-tri" + common.GenRandStr(5)
+	deploy.ClusterID = target.ID
+	if deploy.Namespace == "" {
+		deploy.Namespace = target.TritonNamespace
+	}
+	deploy.Status = "Creating"
+
+	deploy.Labels = "{\"app\":\"" + deploy.Name + "\"}"
+
+	// 在项目锁内校验配额并写入部署记录
+	err = model.ReserveProjectQuota(deploy.ProjectID, model.TritonDeployUsage(&deploy), func(tx *gorm.DB) error {
+		return tx.Create(&deploy).Error
+	})
 	if err != nil {
+		if respondQuotaExceeded(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
-			Message: "Failed to create K8s client: " + err.Error(),
+			Message: "Failed to insert TritonDeploy: " + err.Error(),
 			Data:    nil,
 		})
 		return
@@ -93,6 +128,8 @@ func CreateTritonDeploy(c *gin.Context) {
 		LogInfo:    deploy.LogInfo,
 		LogWarning: deploy.LogWarning,
 		LogError:   deploy.LogError,
+
+		GPUResourceName: string(k8sClient.GPUResourceName()),
 	}
 
 	// 生成部署配置
@@ -165,7 +202,7 @@ func CreateTritonDeploy(c *gin.Context) {
 		return
 	}
 	deploy.Ports = string(portsJSON)
-	deploy.AccessURL = fmt.Sprintf("http://%s:%d", viper.GetString("triton.externalIP"), nodePorts[0])
+	deploy.AccessURL = fmt.Sprintf("http://%s:%d", target.TritonExternalIP, nodePorts[0])
 	deploy.Status = "Running"
 
 	if err := deploy.Update(); err != nil {
@@ -175,6 +212,7 @@ func CreateTritonDeploy(c *gin.Context) {
 		})
 		return
 	}
+	auditResource(c, model.ResourceTritonDeploy, deploy.ID, deploy.ProjectID, nil).SetAfter(deploy)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -216,6 +254,8 @@ func UpdateTritonDeploy(c *gin.Context) {
 		return
 	}
 
+	audit := auditResource(c, model.ResourceTritonDeploy, deploy.ID, deploy.ProjectID, deploy)
+
 	var updateData model.TritonDeploy
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -226,6 +266,7 @@ func UpdateTritonDeploy(c *gin.Context) {
 	}
 
 	// 更新字段
+	previous := *deploy
 	deploy.Image = updateData.Image
 	deploy.Replicas = updateData.Replicas
 	deploy.Ports = updateData.Ports
@@ -264,7 +305,15 @@ func UpdateTritonDeploy(c *gin.Context) {
 	deploy.LogWarning = updateData.LogWarning
 	deploy.LogError = updateData.LogError
 
-	if err := deploy.Update(); err != nil {
+	// 只有增加的资源计入项目配额，在项目锁内校验并保存
+	additional := model.TritonDeployUsage(deploy).Sub(model.TritonDeployUsage(&previous))
+	err = model.ReserveProjectQuota(deploy.ProjectID, additional, func(tx *gorm.DB) error {
+		return tx.Model(deploy).Updates(deploy).Error
+	})
+	if err != nil {
+		if respondQuotaExceeded(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
 			Message: "Failed to update TritonDeploy: " + err.Error(),
@@ -272,6 +321,7 @@ func UpdateTritonDeploy(c *gin.Context) {
 		return
 	}
 
+	audit.SetAfter(deploy)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Triton Deployment updated successfully",
@@ -312,9 +362,9 @@ func DeleteTritonDeploy(c *gin.Context) {
 		})
 		return
 	}
+	auditResource(c, model.ResourceTritonDeploy, deploy.ID, deploy.ProjectID, deploy)
 
-	// Create K8s client
-	k8sClient, err := services.NewK8s("services/localconfig")
+	k8sClient, err := getClusterClient(deploy.ClusterID)
 	if err != nil {
 		common.SysError(err.Error())
 	} else {
@@ -403,6 +453,7 @@ func ListTritonDeploys(c *gin.Context) {
 	if role != 100 {
 		query = query.Where("user_id = ?", userID)
 	}
+	query = restrictTokenProjects(c, query, "project_id")
 
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -57,7 +58,7 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := common.GenerateToken(int(user.ID), user.Username, user.Role)
+	token, refreshToken, err := model.IssueTokenPair(&user)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "无法生成令牌",
@@ -93,20 +94,116 @@ func Login(c *gin.Context) {
 		"message": "",
 		"success": true,
 		"data": gin.H{
-			"token": token,
-			"user":  userWithoutPassword,
+			"token":         token,
+			"refresh_token": refreshToken,
+			"expires_in":    int(common.AccessTokenTTL.Seconds()),
+			"user":          userWithoutPassword,
 		},
 	})
 
 }
 
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+// Logout 吊销当前访问令牌和刷新令牌，all=true 时吊销该用户的所有令牌
 func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		_ = c.ShouldBindJSON(&req)
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken = c.Query("refresh_token")
+	}
+
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		if claims, err := common.ParseToken(strings.TrimPrefix(authHeader, "Bearer ")); err == nil {
+			if req.All {
+				err = model.RevokeUserTokens(claims.UserId)
+			} else {
+				err = model.RevokeAccessToken(claims)
+			}
+			if err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"message": "退出登录失败: " + err.Error(),
+					"success": false,
+				})
+				return
+			}
+		}
+	}
+	if req.RefreshToken != "" {
+		if err := model.RevokeRefreshToken(req.RefreshToken); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "退出登录失败: " + err.Error(),
+				"success": false,
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Successfully logged out",
 		"success": true,
 	})
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken 使用刷新令牌换取新的访问令牌，刷新令牌同时轮换
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的请求参数",
+		})
+		return
+	}
+
+	userID, err := model.ConsumeRefreshToken(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "刷新令牌无效或已过期，请重新登录",
+		})
+		return
+	}
+
+	user, err := model.GetUserById(uint(userID), false)
+	if err != nil || user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "用户不存在或已被封禁",
+		})
+		return
+	}
+
+	token, refreshToken, err := model.IssueTokenPair(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "生成 token 失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"token":         token,
+			"refresh_token": refreshToken,
+			"expires_in":    int(common.AccessTokenTTL.Seconds()),
+			"token_type":    "Bearer",
+		},
+	})
+}
+
 func Register(c *gin.Context) {
 	if !common.RegisterEnabled {
 		c.JSON(http.StatusOK, gin.H{
@@ -345,6 +442,7 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	audit := auditResource(c, model.ResourceUser, originUser.ID, 0, userAuditFields(originUser))
 	myRole := c.GetInt("role")
 	if myRole <= originUser.Role {
 		c.JSON(http.StatusOK, gin.H{
@@ -371,6 +469,21 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	after := userAuditFields(&updatedUser)
+	if updatePassword {
+		after["password_changed"] = true
+	}
+	audit.SetAfter(after)
+	// 修改密码或权限等级后，旧令牌中的信息不再可信
+	if updatePassword || updatedUser.Role != originUser.Role {
+		if err := model.RevokeUserTokens(int(updatedUser.ID)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -442,6 +555,7 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
+	auditResource(c, model.ResourceUser, originUser.ID, 0, userAuditFields(originUser))
 	myRole := c.GetInt("role")
 	if myRole <= originUser.Role {
 		c.JSON(http.StatusOK, gin.H{
@@ -548,6 +662,7 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	audit := auditResource(c, model.ResourceUser, user.ID, 0, userAuditFields(&user))
 	myRole := c.GetInt("role")
 	if myRole <= user.Role && myRole != common.RoleRootUser {
 		c.JSON(http.StatusOK, gin.H{
@@ -624,6 +739,18 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	if req.Action == "disable" || req.Action == "demote" {
+		if err := model.RevokeUserTokens(int(user.ID)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	if req.Action != "delete" {
+		audit.SetAfter(userAuditFields(&user))
+	}
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -704,7 +831,7 @@ func GetToken(c *gin.Context) {
 	}
 
 	// 生成 JWT token
-	token, err := common.GenerateToken(int(user.ID), user.Username, user.Role)
+	token, refreshToken, err := model.IssueTokenPair(&user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -717,9 +844,10 @@ func GetToken(c *gin.Context) {
 		"success": true,
 		"message": "认证成功",
 		"data": gin.H{
-			"token":      token,
-			"expires_in": 3600, // 假设 token 有效期为 1 小时
-			"token_type": "Bearer",
+			"token":         token,
+			"refresh_token": refreshToken,
+			"expires_in":    int(common.AccessTokenTTL.Seconds()),
+			"token_type":    "Bearer",
 		},
 	})
 }
@@ -797,6 +925,7 @@ func UpdateUserStatus(c *gin.Context) {
 		return
 	}
 
+	audit := auditResource(c, model.ResourceUser, user.ID, 0, gin.H{"status": user.Status})
 	myRole := c.GetInt("role")
 	if myRole <= user.Role {
 		c.JSON(http.StatusOK, gin.H{
@@ -807,6 +936,7 @@ func UpdateUserStatus(c *gin.Context) {
 	}
 
 	user.Status = statusData.Status
+	audit.SetAfter(gin.H{"status": user.Status})
 	if err := user.Update(false); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -814,9 +944,29 @@ func UpdateUserStatus(c *gin.Context) {
 		})
 		return
 	}
+	if user.Status != common.UserStatusEnabled {
+		if err := model.RevokeUserTokens(int(user.ID)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
 	})
 }
+
+// userAuditFields 审计记录中使用的用户字段
+func userAuditFields(user *model.User) gin.H {
+	return gin.H{
+		"username":     user.Username,
+		"display_name": user.DisplayName,
+		"email":        user.Email,
+		"role":         user.Role,
+		"status":       user.Status,
+	}
+}
@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"MLcore-Engine/model"
+	"MLcore-Engine/services"
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseLogOptions 解析 follow、tailLines、sinceSeconds 查询参数
+func parseLogOptions(c *gin.Context) (services.PodLogOptions, bool) {
+	var opts services.PodLogOptions
+
+	if follow := c.Query("follow"); follow != "" {
+		value, err := strconv.ParseBool(follow)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid follow parameter"})
+			return opts, false
+		}
+		opts.Follow = value
+	}
+	if tailLines := c.Query("tailLines"); tailLines != "" {
+		value, err := strconv.ParseInt(tailLines, 10, 64)
+		if err != nil || value <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid tailLines parameter"})
+			return opts, false
+		}
+		opts.TailLines = &value
+	}
+	if sinceSeconds := c.Query("sinceSeconds"); sinceSeconds != "" {
+		value, err := strconv.ParseInt(sinceSeconds, 10, 64)
+		if err != nil || value <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid sinceSeconds parameter"})
+			return opts, false
+		}
+		opts.SinceSeconds = &value
+	}
+	return opts, true
+}
+
+// wantsSSE 判断客户端是否要求以 Server-Sent Events 方式返回日志
+func wantsSSE(c *gin.Context) bool {
+	return c.Query("format") == "sse" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// streamPodLogs 将 Pod 日志逐行写回客户端，默认使用 chunked 纯文本，也支持 SSE
+// 客户端断开连接时请求的 context 会被取消，从而结束对 Kubernetes 的日志读取
+func streamPodLogs(c *gin.Context, k8sClient KubeClient, namespace, podName string, opts services.PodLogOptions) {
+	stream, err := k8sClient.StreamPodLogs(c.Request.Context(), namespace, podName, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to get pod logs: " + err.Error(),
+		})
+		return
+	}
+	defer stream.Close()
+
+	sse := wantsSSE(c)
+	if sse {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+	} else {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Header("X-Content-Type-Options", "nosniff")
+	}
+	// 避免反向代理缓冲日志输出
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if sse {
+				c.SSEvent("log", strings.TrimRight(line, "\r\n"))
+			} else if _, werr := io.WriteString(c.Writer, line); werr != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			break
+		}
+	}
+	if sse && c.Request.Context().Err() == nil {
+		c.SSEvent("end", podName)
+		c.Writer.Flush()
+	}
+}
+
+// GetTrainingJobLogs godoc
+// @Summary Stream Training Job logs
+// @Description Stream container logs of a PyTorchJob replica over chunked HTTP or Server-Sent Events
+// @Tags training
+// @Produce plain
+// @Param id path int true "Training Job ID"
+// @Param replica query string false "master or worker-N" default(master)
+// @Param follow query bool false "Keep streaming new logs"
+// @Param tailLines query int false "Number of lines from the end of the logs"
+// @Param sinceSeconds query int false "Only return logs newer than this many seconds"
+// @Param format query string false "Set to sse for Server-Sent Events"
+// @Success 200 {string} string "log stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /pytorchtrain/{id}/logs [get]
+func GetTrainingJobLogs(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid id parameter"})
+		return
+	}
+	opts, ok := parseLogOptions(c)
+	if !ok {
+		return
+	}
+
+	job, err := model.GetTrainingJobByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Training Job not found"})
+		return
+	}
+
+	replica := c.DefaultQuery("replica", "master")
+	labels, err := services.PyTorchJobReplicaLabels(job.Name, replica)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	k8sClient, err := getClusterClient(job.ClusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to create K8s client: " + err.Error()})
+		return
+	}
+
+	pods, err := k8sClient.GetPods(job.Namespace, "", "", labels)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to get pods: " + err.Error()})
+		return
+	}
+	if len(pods) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "No pod found for replica " + replica})
+		return
+	}
+
+	opts.Container = "pytorch"
+	streamPodLogs(c, k8sClient, job.Namespace, pods[0].Name, opts)
+}
+
+// GetNotebookLogs godoc
+// @Summary Stream Notebook logs
+// @Description Stream container logs of a Notebook pod over chunked HTTP or Server-Sent Events
+// @Tags notebook
+// @Produce plain
+// @Param id path int true "Notebook ID"
+// @Param follow query bool false "Keep streaming new logs"
+// @Param tailLines query int false "Number of lines from the end of the logs"
+// @Param sinceSeconds query int false "Only return logs newer than this many seconds"
+// @Param format query string false "Set to sse for Server-Sent Events"
+// @Success 200 {string} string "log stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /notebook/{id}/logs [get]
+func GetNotebookLogs(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid id parameter"})
+		return
+	}
+	opts, ok := parseLogOptions(c)
+	if !ok {
+		return
+	}
+
+	notebook, err := model.GetNotebookByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Notebook not found"})
+		return
+	}
+
+	k8sClient, err := getClusterClient(notebook.ClusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to create K8s client: " + err.Error()})
+		return
+	}
+
+	// Notebook 的 Pod 与其同名
+	streamPodLogs(c, k8sClient, notebook.Namespace, notebook.Name, opts)
+}
+
+// GetTritonDeployLogs godoc
+// @Summary Stream Triton Deployment logs
+// @Description Stream container logs of a Triton pod over chunked HTTP or Server-Sent Events
+// @Tags triton_deploy
+// @Produce plain
+// @Param id path int true "Triton Deployment ID"
+// @Param pod query string false "Pod name, defaults to the first replica"
+// @Param follow query bool false "Keep streaming new logs"
+// @Param tailLines query int false "Number of lines from the end of the logs"
+// @Param sinceSeconds query int false "Only return logs newer than this many seconds"
+// @Param format query string false "Set to sse for Server-Sent Events"
+// @Success 200 {string} string "log stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /triton/{id}/logs [get]
+func GetTritonDeployLogs(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid id parameter"})
+		return
+	}
+	opts, ok := parseLogOptions(c)
+	if !ok {
+		return
+	}
+
+	deploy, err := model.GetTritonDeployByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "TritonDeploy not found"})
+		return
+	}
+
+	k8sClient, err := getClusterClient(deploy.ClusterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to create K8s client: " + err.Error()})
+		return
+	}
+
+	pods, err := k8sClient.GetPods(deploy.Namespace, "", "", map[string]string{"app": deploy.Name})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to get pods: " + err.Error()})
+		return
+	}
+
+	podName := c.Query("pod")
+	found := false
+	for _, pod := range pods {
+		if podName == "" || pod.Name == podName {
+			podName = pod.Name
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "No pod found for TritonDeploy " + deploy.Name})
+		return
+	}
+
+	streamPodLogs(c, k8sClient, deploy.Namespace, podName, opts)
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"MLcore-Engine/common"
+	"MLcore-Engine/controller"
 	"MLcore-Engine/middleware"
 	"MLcore-Engine/model"
 	"MLcore-Engine/router"
+	"MLcore-Engine/services"
+	"context"
 	"embed"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	_ "MLcore-Engine/docs"
 
@@ -16,6 +20,7 @@ import (
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -61,9 +66,36 @@ func main() {
 		common.FatalLog(err)
 	}
 
+	// Load JWT signing keys
+	if err := common.InitJWTKeys(); err != nil {
+		common.FatalLog(err)
+	}
+
 	// Initialize options
 	model.InitOptionMap()
 
+	// Sync workload status from Kubernetes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// config.yaml 中的集群(ClusterID 为 0)，数据库中登记的其他集群在首次使用时创建客户端
+	k8sClient, err := services.NewK8sFromConfig(viper.GetString("kubernetes.kubeconfig"), viper.GetBool("kubernetes.inCluster"))
+	if err != nil {
+		common.SysError("failed to create K8s client for the default cluster: " + err.Error())
+	} else {
+		k8sClient.SetGPUResourceName(viper.GetString("kubernetes.gpuResourceName"))
+		controller.SetK8sClient(k8sClient)
+	}
+	controller.StartStatusReconcilers(ctx, 10*time.Minute)
+
+	// Stop idle notebooks and apply scheduled stop/start
+	if viper.GetBool("notebook.culler.enabled") {
+		culler := controller.NewNotebookCuller(viper.GetDuration("notebook.culler.interval"))
+		go culler.Run(ctx)
+	}
+
+	// Dataset import/export jobs do not survive a restart
+	controller.InitDatasetJobs()
+
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.Logger())
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// 审计时最多缓存的响应体大小，只用于解析 success/message
+const auditResponseLimit = 4096
+
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if remaining := auditResponseLimit - w.body.Len(); remaining > 0 {
+		if len(data) > remaining {
+			w.body.Write(data[:remaining])
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// AuditLog 记录所有状态变更(POST/PUT/PATCH/DELETE)请求
+// 控制器可通过 model.AuditContextKey 取出记录，补充资源 ID 及变更前后的内容
+func AuditLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isMutatingMethod(c.Request.Method) || (viper.IsSet("audit.enabled") && !viper.GetBool("audit.enabled")) {
+			c.Next()
+			return
+		}
+
+		entry := &model.AuditLog{
+			Action: c.Request.Method + " " + c.FullPath(),
+			IP:     c.ClientIP(),
+		}
+		if c.FullPath() == "" {
+			entry.Action = c.Request.Method + " " + c.Request.URL.Path
+		}
+		c.Set(model.AuditContextKey, entry)
+
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		entry.UserID = c.GetInt("user_id")
+		entry.Username = c.GetString("username")
+		if entry.ResourceType == "" {
+			entry.ResourceType = resourceTypeFromPath(c.FullPath())
+		}
+		if entry.ResourceID == "" {
+			entry.ResourceID = c.Param("id")
+		}
+		if entry.ProjectID == 0 {
+			// 由 ProjectPermission 中间件解析出的项目
+			if projectID, ok := c.Get("project_id"); ok {
+				entry.ProjectID, _ = projectID.(uint)
+			}
+		}
+
+		entry.StatusCode = writer.Status()
+		entry.Success = entry.StatusCode < http.StatusBadRequest
+		var response struct {
+			Success *bool  `json:"success"`
+			Message string `json:"message"`
+			Error   string `json:"error"`
+		}
+		if json.Unmarshal(writer.body.Bytes(), &response) == nil {
+			if response.Success != nil {
+				entry.Success = entry.Success && *response.Success
+			}
+			entry.Message = response.Message
+			if entry.Message == "" {
+				entry.Message = response.Error
+			}
+		}
+
+		if err := model.CreateAuditLog(entry); err != nil {
+			common.SysError("failed to write audit log: " + err.Error())
+		}
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// resourceTypeFromPath 未显式设置资源类型时，取 /api/ 之后的第一段路径
+func resourceTypeFromPath(path string) string {
+	path = strings.TrimPrefix(path, "/api/")
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
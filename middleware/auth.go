@@ -2,12 +2,30 @@ package middleware
 
 import (
 	"MLcore-Engine/common"
+	"MLcore-Engine/model"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ScopeRule 个人访问令牌访问路由组所需的权限范围，Write 范围同时允许读取
+type ScopeRule struct {
+	Read  string
+	Write string
+}
+
 func JWTAuthMiddleware(minRole int) gin.HandlerFunc {
+	return authMiddleware(minRole, nil)
+}
+
+// ScopedUserAuth 同时接受 JWT 和具备相应权限范围的个人访问令牌
+func ScopedUserAuth(read, write string) gin.HandlerFunc {
+	return authMiddleware(common.RoleCommonUser, &ScopeRule{Read: read, Write: write})
+}
+
+// authMiddleware 校验 JWT；rule 不为空时也接受个人访问令牌
+func authMiddleware(minRole int, rule *ScopeRule) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -19,7 +37,12 @@ func JWTAuthMiddleware(minRole int) gin.HandlerFunc {
 			return
 		}
 
-		tokenString := authHeader[7:] // Remove "Bearer " prefix
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if strings.HasPrefix(tokenString, model.PersonalAccessTokenPrefix) {
+			personalTokenAuth(c, tokenString, minRole, rule)
+			return
+		}
+
 		claims, err := common.ParseToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -30,6 +53,26 @@ func JWTAuthMiddleware(minRole int) gin.HandlerFunc {
 			return
 		}
 
+		// 退出登录、禁用用户或重置密码后令牌立即失效
+		revoked, err := model.IsAccessTokenRevoked(claims)
+		if err != nil {
+			common.SysError("failed to check token revocation: " + err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "认证令牌校验失败",
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "认证令牌已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
 		if claims.Role < minRole {
 			c.JSON(http.StatusForbidden, gin.H{
 				"success": false,
@@ -46,6 +89,66 @@ func JWTAuthMiddleware(minRole int) gin.HandlerFunc {
 	}
 }
 
+func personalTokenAuth(c *gin.Context, tokenString string, minRole int, rule *ScopeRule) {
+	if rule == nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "个人访问令牌无权访问此接口",
+		})
+		c.Abort()
+		return
+	}
+
+	token, user, err := model.ValidatePersonalAccessToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "无效的个人访问令牌",
+		})
+		c.Abort()
+		return
+	}
+
+	allowed := []string{rule.Write}
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		allowed = append(allowed, rule.Read)
+	}
+	if !hasAnyScope(token.ScopeList(), allowed) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "个人访问令牌缺少权限范围: " + strings.Join(allowed, " 或 "),
+		})
+		c.Abort()
+		return
+	}
+
+	if user.Role < minRole {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "无权进行此操作，权限不足",
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", int(user.ID))
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("token_project_ids", token.ProjectIDList())
+	c.Next()
+}
+
+func hasAnyScope(scopes []string, allowed []string) bool {
+	for _, scope := range scopes {
+		for _, a := range allowed {
+			if a != "" && scope == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // func authHelper(c *gin.Context, minRole int) {
 // 	session := sessions.Default(c)
 // 	username := session.Get("username")
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"MLcore-Engine/common"
+	"MLcore-Engine/model"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectPermission 根据路径参数解析资源所属项目，并按权限表校验当前用户的项目角色
+// 需要放在认证中间件之后
+func ProjectPermission(resource string, param string, action model.ProjectAction) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			abortPermission(c, http.StatusBadRequest, "无效的ID参数")
+			return
+		}
+
+		owner, err := model.GetResourceOwner(resource, uint(id))
+		if errors.Is(err, model.ErrResourceNotFound) {
+			abortPermission(c, http.StatusNotFound, "资源不存在")
+			return
+		}
+		if err != nil {
+			common.SysError("failed to resolve resource project: " + err.Error())
+			abortPermission(c, http.StatusInternalServerError, "权限校验失败")
+			return
+		}
+		checkProjectPermission(c, owner, action)
+	}
+}
+
+// ProjectPermissionFromBody 从 JSON 请求体的 field 字段读取项目 ID 并校验，用于创建资源等场景
+// 项目 ID 为 0 表示个人资源，不做项目角色校验
+func ProjectPermissionFromBody(field string, action model.ProjectAction) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortPermission(c, http.StatusBadRequest, "读取请求体失败")
+			return
+		}
+		// 还原请求体，供后续处理函数绑定
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var payload map[string]json.RawMessage
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &payload); err != nil {
+				// 交由处理函数返回参数错误
+				c.Next()
+				return
+			}
+		}
+		var projectID uint
+		if raw, ok := payload[field]; ok {
+			if err := json.Unmarshal(raw, &projectID); err != nil {
+				abortPermission(c, http.StatusBadRequest, "无效的项目ID")
+				return
+			}
+		}
+
+		if projectID == 0 {
+			if !TokenAllowsProject(c, 0) {
+				abortPermission(c, http.StatusForbidden, "访问令牌只能访问授权的项目")
+				return
+			}
+			c.Next()
+			return
+		}
+		checkProjectPermission(c, &model.ResourceOwner{ProjectID: projectID}, action)
+	}
+}
+
+func checkProjectPermission(c *gin.Context, owner *model.ResourceOwner, action model.ProjectAction) {
+	// 个人访问令牌限制了项目时，只能访问这些项目中的资源
+	if !TokenAllowsProject(c, owner.ProjectID) {
+		abortPermission(c, http.StatusForbidden, "访问令牌无权访问项目 "+strconv.FormatUint(uint64(owner.ProjectID), 10))
+		return
+	}
+
+	allowed, err := model.CheckProjectPermission(uint(c.GetInt("user_id")), c.GetInt("role"), owner, action)
+	if err != nil {
+		common.SysError("failed to check project permission: " + err.Error())
+		abortPermission(c, http.StatusInternalServerError, "权限校验失败")
+		return
+	}
+	if !allowed {
+		abortPermission(c, http.StatusForbidden, "您在该项目中没有"+actionName(action)+"权限")
+		return
+	}
+	c.Set("project_id", owner.ProjectID)
+	c.Next()
+}
+
+// TokenProjectIDs 返回个人访问令牌限制的项目，JWT 或不限制项目时返回 nil
+func TokenProjectIDs(c *gin.Context) []uint {
+	value, exists := c.Get("token_project_ids")
+	if !exists {
+		return nil
+	}
+	ids, _ := value.([]uint)
+	if len(ids) == 0 {
+		return nil
+	}
+	return ids
+}
+
+// TokenAllowsProject 判断当前请求的令牌能否访问该项目
+func TokenAllowsProject(c *gin.Context, projectID uint) bool {
+	ids := TokenProjectIDs(c)
+	if ids == nil {
+		return true
+	}
+	for _, id := range ids {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+func actionName(action model.ProjectAction) string {
+	switch action {
+	case model.ActionView:
+		return "查看"
+	case model.ActionCreate:
+		return "创建"
+	case model.ActionUpdate:
+		return "修改"
+	case model.ActionDelete:
+		return "删除"
+	case model.ActionManage:
+		return "管理"
+	default:
+		return string(action)
+	}
+}
+
+func abortPermission(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": message,
+	})
+	c.Abort()
+}
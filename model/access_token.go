@@ -0,0 +1,152 @@
+package model
+
+import (
+	"MLcore-Engine/common"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 个人访问令牌前缀，用于和 JWT 区分
+const PersonalAccessTokenPrefix = "mlc_"
+
+// 个人访问令牌的权限范围
+const (
+	ScopeDatasetRead  = "dataset:read"
+	ScopeDatasetWrite = "dataset:write"
+	ScopeTrainSubmit  = "train:submit"
+	ScopeTritonDeploy = "triton:deploy"
+)
+
+var AllTokenScopes = []string{ScopeDatasetRead, ScopeDatasetWrite, ScopeTrainSubmit, ScopeTritonDeploy}
+
+var ErrInvalidAccessToken = errors.New("access token is invalid or expired")
+
+// PersonalAccessToken 供 CI/SDK 使用的个人访问令牌，只保存哈希
+// Scopes、ProjectIDs 以逗号分隔保存，ProjectIDs 为空表示不限制项目
+type PersonalAccessToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	TokenHash  string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:16"` // 令牌前几位，便于用户辨认
+	Scopes     string     `json:"-" gorm:"size:200"`
+	ProjectIDs string     `json:"-" gorm:"size:500"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ScopeList 返回令牌的权限范围
+func (t *PersonalAccessToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// ProjectIDList 返回令牌限制的项目，空表示不限制
+func (t *PersonalAccessToken) ProjectIDList() []uint {
+	ids := []uint{}
+	for _, value := range strings.Split(t.ProjectIDs, ",") {
+		if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// IsValidTokenScope 判断是否为支持的权限范围
+func IsValidTokenScope(scope string) bool {
+	for _, s := range AllTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePersonalAccessToken 生成新的个人访问令牌，明文令牌只在创建时返回一次
+func CreatePersonalAccessToken(userID uint, name string, scopes []string, projectIDs []uint, expiresAt *time.Time) (string, *PersonalAccessToken, error) {
+	for _, scope := range scopes {
+		if !IsValidTokenScope(scope) {
+			return "", nil, fmt.Errorf("unsupported scope %q", scope)
+		}
+	}
+	secret, err := common.GenerateRefreshToken()
+	if err != nil {
+		return "", nil, err
+	}
+	plain := PersonalAccessTokenPrefix + secret
+
+	ids := make([]string, len(projectIDs))
+	for i, id := range projectIDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	token := &PersonalAccessToken{
+		UserID:     userID,
+		Name:       name,
+		TokenHash:  hashToken(plain),
+		Prefix:     plain[:len(PersonalAccessTokenPrefix)+6],
+		Scopes:     strings.Join(scopes, ","),
+		ProjectIDs: strings.Join(ids, ","),
+		ExpiresAt:  expiresAt,
+	}
+	if err := DB.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return plain, token, nil
+}
+
+// GetUserPersonalAccessTokens 列出用户的个人访问令牌
+func GetUserPersonalAccessTokens(userID uint) ([]PersonalAccessToken, error) {
+	var tokens []PersonalAccessToken
+	err := DB.Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteUserPersonalAccessToken 吊销用户的个人访问令牌
+func DeleteUserPersonalAccessToken(userID, tokenID uint) error {
+	result := DB.Where("id = ? AND user_id = ?", tokenID, userID).Delete(&PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ValidatePersonalAccessToken 校验明文令牌，返回令牌及其所属(仍启用的)用户
+func ValidatePersonalAccessToken(plain string) (*PersonalAccessToken, *User, error) {
+	var token PersonalAccessToken
+	err := DB.Where("token_hash = ?", hashToken(plain)).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(now) {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	var user User
+	if err := DB.First(&user, token.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidAccessToken
+	}
+	if user.Status != common.UserStatusEnabled {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	// 最近使用时间精确到分钟即可，避免每个请求都写库
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		DB.Model(&token).Update("last_used_at", now)
+	}
+	return &token, &user, nil
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// AuditContextKey 审计中间件在 gin.Context 中保存当前 *AuditLog 的键，控制器通过它补充资源信息
+const AuditContextKey = "audit_log"
+
+const auditRedacted = "******"
+
+// 除项目资源(ResourceProject 等)外，审计日志中使用的其他资源类型
+const (
+	ResourceProjectMember = "project_member"
+	ResourceProjectQuota  = "project_quota"
+	ResourceOption        = "option"
+	ResourceUser          = "user"
+	ResourceCluster       = "cluster"
+)
+
+// AuditLog 一次状态变更请求的审计记录
+type AuditLog struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+	UserID       int       `json:"user_id" gorm:"index"`
+	Username     string    `json:"username" gorm:"size:50"`
+	Action       string    `json:"action" gorm:"size:200;index"` // 如 "DELETE /api/notebook/:id"
+	ResourceType string    `json:"resource_type" gorm:"size:50;index"`
+	ResourceID   string    `json:"resource_id" gorm:"size:64;index"`
+	ProjectID    uint      `json:"project_id" gorm:"index"`
+	IP           string    `json:"ip" gorm:"size:64"`
+	Before       string    `json:"before" gorm:"type:text"`
+	After        string    `json:"after" gorm:"type:text"`
+	Diff         string    `json:"diff" gorm:"type:text"` // 发生变化的字段: {"field": {"before": x, "after": y}}
+	StatusCode   int       `json:"status_code"`
+	Success      bool      `json:"success" gorm:"index"`
+	Message      string    `json:"message" gorm:"size:500"`
+
+	beforeMap map[string]interface{}
+	afterMap  map[string]interface{}
+}
+
+// AuditLogFilter 审计日志查询条件，零值表示不过滤
+type AuditLogFilter struct {
+	UserID       int
+	ProjectID    uint
+	ResourceType string
+	ResourceID   string
+	Action       string
+	Start        *time.Time
+	End          *time.Time
+}
+
+// SetResource 设置被操作的资源
+func (a *AuditLog) SetResource(resourceType string, id interface{}) {
+	a.ResourceType = resourceType
+	a.ResourceID = fmt.Sprint(id)
+}
+
+// SetProject 设置资源所属项目
+func (a *AuditLog) SetProject(projectID uint) {
+	a.ProjectID = projectID
+}
+
+// SetBefore 记录变更前的状态，调用时立即序列化，之后修改原对象不影响记录
+func (a *AuditLog) SetBefore(value interface{}) {
+	a.Before, a.beforeMap = auditSnapshot(value)
+}
+
+// SetAfter 记录变更后的状态
+func (a *AuditLog) SetAfter(value interface{}) {
+	a.After, a.afterMap = auditSnapshot(value)
+}
+
+// computeDiff 比较变更前后的顶层字段
+func (a *AuditLog) computeDiff() {
+	if a.beforeMap == nil && a.afterMap == nil {
+		return
+	}
+	diff := map[string]map[string]interface{}{}
+	for key, before := range a.beforeMap {
+		after, ok := a.afterMap[key]
+		if !ok && a.afterMap != nil {
+			continue // 只记录了部分字段的情况下，未出现的字段视为未变化
+		}
+		if !reflect.DeepEqual(before, after) {
+			diff[key] = map[string]interface{}{"before": before, "after": after}
+		}
+	}
+	for key, after := range a.afterMap {
+		if _, ok := a.beforeMap[key]; !ok {
+			diff[key] = map[string]interface{}{"before": nil, "after": after}
+		}
+	}
+	if len(diff) == 0 {
+		return
+	}
+	data, err := json.Marshal(diff)
+	if err == nil {
+		a.Diff = string(data)
+	}
+}
+
+// IsSensitiveAuditField 密码、令牌、密钥等字段在审计记录中脱敏
+func IsSensitiveAuditField(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "password") || strings.Contains(name, "secret") || strings.Contains(name, "token")
+}
+
+func auditSnapshot(value interface{}) (string, map[string]interface{}) {
+	if value == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// 非对象类型直接保存
+		return string(data), nil
+	}
+	for key := range fields {
+		if IsSensitiveAuditField(key) {
+			fields[key] = auditRedacted
+		}
+	}
+	data, _ = json.Marshal(fields)
+	return string(data), fields
+}
+
+// CreateAuditLog 保存审计记录
+func CreateAuditLog(log *AuditLog) error {
+	log.computeDiff()
+	// 按字符截断，避免截断在多字节字符中间
+	if message := []rune(log.Message); len(message) > 500 {
+		log.Message = string(message[:500])
+	}
+	return DB.Create(log).Error
+}
+
+// QueryAuditLogs 按条件分页查询审计日志，按时间倒序
+func QueryAuditLogs(filter AuditLogFilter, offset, limit int) ([]AuditLog, int64, error) {
+	query := DB.Model(&AuditLog{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.ProjectID != 0 {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action LIKE ?", "%"+filter.Action+"%")
+	}
+	if filter.Start != nil {
+		query = query.Where("created_at >= ?", *filter.Start)
+	}
+	if filter.End != nil {
+		query = query.Where("created_at <= ?", *filter.End)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []AuditLog
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, total, err
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCreateAuditLogTruncatesMessage(t *testing.T) {
+	setupTestDB(t, &AuditLog{})
+	log := &AuditLog{Message: "a" + strings.Repeat("删除", 300)}
+	if err := CreateAuditLog(log); err != nil {
+		t.Fatalf("CreateAuditLog: %v", err)
+	}
+	if !utf8.ValidString(log.Message) || utf8.RuneCountInString(log.Message) != 500 {
+		t.Errorf("message truncated to %d runes, valid %v", utf8.RuneCountInString(log.Message), utf8.ValidString(log.Message))
+	}
+}
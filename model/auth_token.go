@@ -0,0 +1,265 @@
+package model
+
+import (
+	"MLcore-Engine/common"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 启用 Redis 时令牌状态保存在 Redis 中，否则保存在数据库中
+
+// RefreshToken 服务端保存的刷新令牌(只保存哈希)
+type RefreshToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    int       `json:"user_id" gorm:"index;not null"`
+	TokenHash string    `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevokedToken 在过期前被吊销的访问令牌
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+// UserTokenRevocation 记录用户令牌的统一吊销时间，早于该时间签发的访问令牌全部失效
+type UserTokenRevocation struct {
+	UserID    int       `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+var ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+
+const (
+	redisRefreshTokenPrefix = "jwt:refresh:"
+	redisUserRefreshPrefix  = "jwt:refresh:user:"
+	redisRevokedJTIPrefix   = "jwt:revoked:jti:"
+	redisUserRevokedPrefix  = "jwt:revoked:user:"
+)
+
+// hashToken 令牌只以 SHA-256 哈希形式保存
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefreshToken 为用户生成并保存新的刷新令牌
+func IssueRefreshToken(userID int) (string, error) {
+	token, err := common.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	hash := hashToken(token)
+
+	if common.RedisEnabled {
+		ctx := context.Background()
+		pipe := common.RDB.TxPipeline()
+		pipe.Set(ctx, redisRefreshTokenPrefix+hash, userID, common.RefreshTokenTTL)
+		pipe.SAdd(ctx, redisUserRefreshPrefix+strconv.Itoa(userID), hash)
+		pipe.Expire(ctx, redisUserRefreshPrefix+strconv.Itoa(userID), common.RefreshTokenTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return "", err
+		}
+		return token, nil
+	}
+
+	now := time.Now()
+	// 顺便清理过期记录
+	DB.Where("expires_at < ?", now).Delete(&RefreshToken{})
+	DB.Where("expires_at < ?", now).Delete(&RevokedToken{})
+	err = DB.Create(&RefreshToken{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: now.Add(common.RefreshTokenTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeRefreshToken 校验并作废刷新令牌(每次刷新都会轮换)，返回所属用户 ID
+func ConsumeRefreshToken(token string) (int, error) {
+	hash := hashToken(token)
+
+	if common.RedisEnabled {
+		ctx := context.Background()
+		// GET + DEL 放在同一事务中，保证刷新令牌只能使用一次
+		pipe := common.RDB.TxPipeline()
+		get := pipe.Get(ctx, redisRefreshTokenPrefix+hash)
+		pipe.Del(ctx, redisRefreshTokenPrefix+hash)
+		_, err := pipe.Exec(ctx)
+		value := get.Val()
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return 0, err
+		}
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, ErrInvalidRefreshToken
+		}
+		common.RDB.SRem(ctx, redisUserRefreshPrefix+value, hash)
+		return userID, nil
+	}
+
+	var refreshToken RefreshToken
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", hash).First(&refreshToken).Error; err != nil {
+			return err
+		}
+		// 按令牌哈希删除并检查删除行数，并发使用同一令牌时只有一个请求能删除成功
+		result := tx.Where("token_hash = ?", hash).Delete(&RefreshToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	if refreshToken.ExpiresAt.Before(time.Now()) {
+		return 0, ErrInvalidRefreshToken
+	}
+	return refreshToken.UserID, nil
+}
+
+// RevokeRefreshToken 作废单个刷新令牌，令牌不存在时忽略
+func RevokeRefreshToken(token string) error {
+	_, err := ConsumeRefreshToken(token)
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		return nil
+	}
+	return err
+}
+
+// RevokeAccessToken 将访问令牌加入吊销列表直到其过期
+func RevokeAccessToken(claims *common.Claims) error {
+	if claims == nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+
+	if common.RedisEnabled {
+		return common.RDB.Set(context.Background(), redisRevokedJTIPrefix+claims.ID, 1, ttl).Err()
+	}
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&RevokedToken{
+		JTI:       claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}).Error
+}
+
+// RevokeUserTokens 使用户已签发的所有访问令牌和刷新令牌立即失效
+// 用于退出全部会话、禁用用户、重置密码等场景
+func RevokeUserTokens(userID int) error {
+	now := time.Now()
+
+	if common.RedisEnabled {
+		ctx := context.Background()
+		userKey := redisUserRefreshPrefix + strconv.Itoa(userID)
+		hashes, err := common.RDB.SMembers(ctx, userKey).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		pipe := common.RDB.TxPipeline()
+		for _, hash := range hashes {
+			pipe.Del(ctx, redisRefreshTokenPrefix+hash)
+		}
+		pipe.Del(ctx, userKey)
+		// 访问令牌最长存活 AccessTokenTTL，之后无需再保留吊销时间
+		pipe.Set(ctx, redisUserRevokedPrefix+strconv.Itoa(userID), now.UnixMilli(), common.AccessTokenTTL)
+		_, err = pipe.Exec(ctx)
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RefreshToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+		}).Create(&UserTokenRevocation{UserID: userID, RevokedAt: now}).Error
+	})
+}
+
+// IsAccessTokenRevoked 检查访问令牌是否已被单独吊销，或签发时间不晚于用户的统一吊销时间(毫秒精度)
+func IsAccessTokenRevoked(claims *common.Claims) (bool, error) {
+	var issuedAt int64
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.UnixMilli()
+	}
+
+	if common.RedisEnabled {
+		ctx := context.Background()
+		if claims.ID != "" {
+			exists, err := common.RDB.Exists(ctx, redisRevokedJTIPrefix+claims.ID).Result()
+			if err != nil {
+				return false, err
+			}
+			if exists > 0 {
+				return true, nil
+			}
+		}
+		value, err := common.RDB.Get(ctx, redisUserRevokedPrefix+strconv.Itoa(claims.UserId)).Int64()
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return issuedAt <= value, nil
+	}
+
+	if claims.ID != "" {
+		var count int64
+		if err := DB.Model(&RevokedToken{}).Where("jti = ?", claims.ID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	var revocation UserTokenRevocation
+	err := DB.Where("user_id = ?", claims.UserId).First(&revocation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return issuedAt <= revocation.RevokedAt.UnixMilli(), nil
+}
+
+// IssueTokenPair 签发访问令牌和刷新令牌
+func IssueTokenPair(user *User) (accessToken string, refreshToken string, err error) {
+	accessToken, _, err = common.GenerateToken(int(user.ID), user.Username, user.Role)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+	refreshToken, err = IssueRefreshToken(int(user.ID))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return accessToken, refreshToken, nil
+}
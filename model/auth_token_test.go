@@ -0,0 +1,71 @@
+package model
+
+import (
+	"MLcore-Engine/common"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestIsAccessTokenRevoked(t *testing.T) {
+	setupTestDB(t, &RefreshToken{}, &RevokedToken{}, &UserTokenRevocation{})
+	redisEnabled := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() { common.RedisEnabled = redisEnabled })
+	common.JWTKeys = map[string][]byte{"test": []byte("secret")}
+	common.JWTCurrentKID = "test"
+
+	before, _, err := common.GenerateToken(1, "alice", common.RoleCommonUser)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := RevokeUserTokens(1); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	// 吊销后同一秒内重新登录签发的令牌仍然有效
+	after, _, err := common.GenerateToken(1, "alice", common.RoleCommonUser)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	for token, want := range map[string]bool{before: true, after: false} {
+		claims, err := common.ParseToken(token)
+		if err != nil {
+			t.Fatalf("ParseToken: %v", err)
+		}
+		revoked, err := IsAccessTokenRevoked(claims)
+		if err != nil {
+			t.Fatalf("IsAccessTokenRevoked: %v", err)
+		}
+		if revoked != want {
+			t.Errorf("token issued at %v: revoked %v, want %v", claims.IssuedAt, revoked, want)
+		}
+	}
+
+	other := &common.Claims{UserId: 2, RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(time.Now())}}
+	if revoked, err := IsAccessTokenRevoked(other); err != nil || revoked {
+		t.Errorf("other user: revoked %v, err %v", revoked, err)
+	}
+}
+
+func TestConsumeRefreshTokenOnce(t *testing.T) {
+	setupTestDB(t, &RefreshToken{})
+	redisEnabled := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() { common.RedisEnabled = redisEnabled })
+
+	token, err := IssueRefreshToken(1)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken: %v", err)
+	}
+	if userID, err := ConsumeRefreshToken(token); err != nil || userID != 1 {
+		t.Fatalf("first use: user %d, err %v", userID, err)
+	}
+	if _, err := ConsumeRefreshToken(token); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("second use: err %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"MLcore-Engine/common"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrClusterNotFound = errors.New("cluster not found")
+	ErrClusterInUse    = errors.New("cluster is still used by projects or workloads")
+	// ErrClusterNotAllowed 普通用户指定了项目默认集群之外的集群
+	ErrClusterNotAllowed = errors.New("cluster is not allowed for the project")
+)
+
+// Cluster 登记的 Kubernetes 集群
+// ClusterID 为 0 的项目和工作负载使用 config.yaml 中 kubernetes 配置的集群
+type Cluster struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"size:100;uniqueIndex;not null"`
+	Description string `json:"description" gorm:"size:500"`
+	Kubeconfig  string `json:"-" gorm:"type:text"` // 加密后的 kubeconfig，InCluster 为 true 时可为空
+	InCluster   bool   `json:"in_cluster" gorm:"default:false"`
+	IsDefault   bool   `json:"is_default" gorm:"default:false"` // 未指定集群的项目使用默认集群
+
+	// 以下字段为空时使用 config.yaml 中 notebook/triton 的配置
+	NotebookNamespace  string `json:"notebook_namespace" gorm:"size:200"`
+	TrainingNamespace  string `json:"training_namespace" gorm:"size:200"`
+	TritonNamespace    string `json:"triton_namespace" gorm:"size:200"`
+	NotebookExternalIP string `json:"notebook_external_ip" gorm:"size:100"`
+	TritonExternalIP   string `json:"triton_external_ip" gorm:"size:100"`
+	GPUResourceName    string `json:"gpu_resource_name" gorm:"size:100"` // 如 nvidia.com/gpu
+
+	HasKubeconfig bool      `json:"has_kubeconfig" gorm:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (c *Cluster) AfterFind(tx *gorm.DB) error {
+	c.HasKubeconfig = c.Kubeconfig != ""
+	return nil
+}
+
+// SetKubeconfig 加密保存 kubeconfig 内容，传入空值时清除
+func (c *Cluster) SetKubeconfig(kubeconfig []byte) error {
+	if len(kubeconfig) == 0 {
+		c.Kubeconfig = ""
+		c.HasKubeconfig = false
+		return nil
+	}
+	encrypted, err := common.EncryptSecret(kubeconfig)
+	if err != nil {
+		return err
+	}
+	c.Kubeconfig = encrypted
+	c.HasKubeconfig = true
+	return nil
+}
+
+// KubeconfigData 返回解密后的 kubeconfig 内容
+func (c *Cluster) KubeconfigData() ([]byte, error) {
+	if c.Kubeconfig == "" {
+		return nil, nil
+	}
+	return common.DecryptSecret(c.Kubeconfig)
+}
+
+// GetClusterByID retrieves a Cluster by ID
+func GetClusterByID(id uint) (*Cluster, error) {
+	var cluster Cluster
+	if err := DB.First(&cluster, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClusterNotFound
+		}
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+// GetDefaultCluster 返回标记为默认的集群，没有时返回 nil
+func GetDefaultCluster() (*Cluster, error) {
+	var cluster Cluster
+	err := DB.Where("is_default = ?", true).First(&cluster).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+// ListClusters 返回所有登记的集群
+func ListClusters() ([]Cluster, error) {
+	var clusters []Cluster
+	err := DB.Order("id").Find(&clusters).Error
+	return clusters, err
+}
+
+// SaveCluster 创建或更新集群，设为默认集群时取消其他集群的默认标记
+func SaveCluster(cluster *Cluster) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if cluster.IsDefault {
+			if err := tx.Model(&Cluster{}).Where("id <> ? AND is_default = ?", cluster.ID, true).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(cluster).Error
+	})
+}
+
+// DeleteCluster 删除集群，仍有项目或工作负载使用时返回 ErrClusterInUse
+func DeleteCluster(id uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&Project{}, &Notebook{}, &TrainingJob{}, &TritonDeploy{}} {
+			var count int64
+			if err := tx.Model(model).Where("cluster_id = ?", id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrClusterInUse
+			}
+		}
+		result := tx.Delete(&Cluster{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrClusterNotFound
+		}
+		return nil
+	})
+}
+
+// ResolveClusterID 确定新建工作负载所在的集群
+// 依次使用请求中指定的集群、项目的默认集群、全局默认集群；都没有时返回 0，即 config.yaml 中的集群
+// 只有管理员可以指定任意集群，普通用户指定的集群必须是项目默认集群(项目未设置时为全局默认集群)，否则返回 ErrClusterNotAllowed
+func ResolveClusterID(requested uint, projectID uint, userRole int) (uint, error) {
+	if requested != 0 {
+		if _, err := GetClusterByID(requested); err != nil {
+			return 0, err
+		}
+		if userRole >= common.RoleAdminUser {
+			return requested, nil
+		}
+	}
+	clusterID, err := defaultClusterID(projectID)
+	if err != nil {
+		return 0, err
+	}
+	if requested != 0 && requested != clusterID {
+		return 0, ErrClusterNotAllowed
+	}
+	return clusterID, nil
+}
+
+// defaultClusterID 返回项目的默认集群，项目未设置时返回全局默认集群，都没有时返回 0
+func defaultClusterID(projectID uint) (uint, error) {
+	if projectID != 0 {
+		var project Project
+		if err := DB.Select("id", "cluster_id").First(&project, projectID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		if project.ClusterID != 0 {
+			return project.ClusterID, nil
+		}
+	}
+	cluster, err := GetDefaultCluster()
+	if err != nil || cluster == nil {
+		return 0, err
+	}
+	return cluster.ID, nil
+}
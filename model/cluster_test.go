@@ -0,0 +1,44 @@
+package model
+
+import (
+	"MLcore-Engine/common"
+	"errors"
+	"testing"
+)
+
+func TestResolveClusterID(t *testing.T) {
+	setupTestDB(t, &Cluster{}, &Project{})
+	projectCluster := &Cluster{Name: "c1"}
+	other := &Cluster{Name: "c2", IsDefault: true}
+	for _, cluster := range []*Cluster{projectCluster, other} {
+		if err := DB.Create(cluster).Error; err != nil {
+			t.Fatalf("create cluster: %v", err)
+		}
+	}
+	project := &Project{Name: "p1", ClusterID: projectCluster.ID}
+	if err := DB.Create(project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		requested uint
+		projectID uint
+		role      int
+		want      uint
+		err       error
+	}{
+		{"project default", 0, project.ID, common.RoleCommonUser, projectCluster.ID, nil},
+		{"global default", 0, 0, common.RoleCommonUser, other.ID, nil},
+		{"project cluster requested", projectCluster.ID, project.ID, common.RoleCommonUser, projectCluster.ID, nil},
+		{"other cluster requested", other.ID, project.ID, common.RoleCommonUser, 0, ErrClusterNotAllowed},
+		{"admin override", other.ID, project.ID, common.RoleAdminUser, other.ID, nil},
+		{"unknown cluster", 99, project.ID, common.RoleAdminUser, 0, ErrClusterNotFound},
+	}
+	for _, tc := range cases {
+		got, err := ResolveClusterID(tc.requested, tc.projectID, tc.role)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("%s: got %d, %v; want %d, %v", tc.name, got, err, tc.want, tc.err)
+		}
+	}
+}
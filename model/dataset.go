@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -11,10 +12,21 @@ type Dataset struct {
 	StorageType      string `json:"storage_type" gorm:"size:20;default:'database'"`        // "minio", "database", "both"
 	TemplateType     string `json:"template_type" gorm:"size:20;default:'instruction_io'"` // 模板类型
 	BucketName       string `json:"bucket_name" gorm:"size:255"`                           // MinIO桶名
-	ObjectPath       string `json:"object_path" gorm:"size:255"`                           // MinIO对象路径
+	ObjectPath       string `json:"object_path" gorm:"size:255"`                           // MinIO对象路径，分片存储时为对象前缀
+	StorageLayout    string `json:"storage_layout" gorm:"size:20"`                         // MinIO存储布局，见 DatasetLayoutSharded
 	EntryCount       int64  `json:"entry_count" gorm:"default:0"`                          // 条目数量
 	TotalSize        int64  `json:"total_size" gorm:"default:0"`                           // 总大小(字节)
 	SchemaDefinition string `json:"schema_definition" gorm:"type:text"`                    // JSON Schema定义
+	VersionSeq       int64  `json:"-" gorm:"default:0"`                                    // 已分配的最大版本号
+
+	ContentUpdatedAt *time.Time `json:"content_updated_at"` // 条目最近一次被修改的时间，晚于激活版本时当前数据与该版本不一致
+
+	SearchIndexedAt *time.Time `json:"search_indexed_at"` // 全文索引最近一次重建的时间，为空时检索前重建
+
+	ParentID *uint  `json:"parent_id" gorm:"index"`   // 切分或筛选生成的数据集所来源的数据集
+	Lineage  string `json:"lineage" gorm:"type:text"` // 派生方式 DatasetLineage 的 JSON
+
+	AnnotatorsRequired int `json:"annotators_required" gorm:"default:1"` // 每个条目需要的标注人数，为 2 时可比较标注分歧
 
 	ProjectID uint    `json:"project_id" gorm:"index;constraint:OnDelete:RESTRICT"`
 	Project   Project `json:"project" gorm:"foreignKey:ProjectID;references:ID"`
@@ -25,3 +37,36 @@ type Dataset struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// DatasetLineage 派生数据集的来源，Params 为生成时使用的请求参数，可据此重新生成
+type DatasetLineage struct {
+	Operation string          `json:"operation"` // 生成数据集的任务类型，如 DatasetJobSplit
+	ParentID  uint            `json:"parent_id,omitempty"`
+	JobID     uint            `json:"job_id"`
+	Split     string          `json:"split,omitempty"`   // 切分生成的数据集对应的子集名
+	Sources   []uint          `json:"sources,omitempty"` // 合并多个数据集时的全部来源
+	Params    json.RawMessage `json:"params"`
+}
+
+// SetLineage 保存数据集的来源，ParentID 为 0(如合并多个数据集)时不设置父数据集
+func (d *Dataset) SetLineage(lineage DatasetLineage) {
+	data, _ := json.Marshal(lineage)
+	d.ParentID = nil
+	if lineage.ParentID != 0 {
+		parentID := lineage.ParentID
+		d.ParentID = &parentID
+	}
+	d.Lineage = string(data)
+}
+
+// GetLineage 解析数据集的来源，不是派生数据集时返回 nil
+func (d *Dataset) GetLineage() *DatasetLineage {
+	if d.Lineage == "" {
+		return nil
+	}
+	var lineage DatasetLineage
+	if err := json.Unmarshal([]byte(d.Lineage), &lineage); err != nil {
+		return nil
+	}
+	return &lineage
+}
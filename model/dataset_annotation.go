@@ -0,0 +1,482 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 条目的标注状态
+const (
+	AnnotationUnlabeled = "unlabeled" // 等待标注，或标注人数未达到要求
+	AnnotationInReview  = "in_review" // 所需的标注人都已提交，等待审核
+	AnnotationAccepted  = "accepted"  // 审核通过，标注结果已写入条目
+	AnnotationRejected  = "rejected"  // 审核未通过
+)
+
+// AnnotationStatuses 全部标注状态
+var AnnotationStatuses = []string{AnnotationUnlabeled, AnnotationInReview, AnnotationAccepted, AnnotationRejected}
+
+// 标注任务(某个标注人领取的条目)的状态
+const (
+	AnnotationTaskAssigned  = "assigned"
+	AnnotationTaskSubmitted = "submitted"
+)
+
+var (
+	ErrAnnotationNotFound     = errors.New("dataset annotation not found")
+	ErrAnnotationTaskNotFound = errors.New("dataset annotation task not found")
+	ErrAnnotationQueueEmpty   = errors.New("no dataset entry left to annotate")
+	ErrAnnotationClosed       = errors.New("dataset annotation is not open for this operation")
+
+	errAnnotationClaimConflict = errors.New("dataset annotation claimed concurrently")
+)
+
+// 并发领取同一条目时的重试次数
+const annotationClaimRetries = 3
+
+// 每批写入或删除的标注记录数
+const datasetAnnotationBatchSize = 500
+
+// DatasetAnnotation 数据集条目的标注状态，按 (dataset_id, entry_index) 对应条目，数据库和MinIO存储通用
+type DatasetAnnotation struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	DatasetID     uint       `json:"dataset_id" gorm:"not null;uniqueIndex:idx_dataset_annotation_entry"`
+	EntryIndex    int        `json:"entry_index" gorm:"not null;uniqueIndex:idx_dataset_annotation_entry"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:'unlabeled';index"`
+	AssigneeID    uint       `json:"assignee_id" gorm:"index"` // 最近领取该条目的标注人
+	ReviewerID    uint       `json:"reviewer_id" gorm:"index"`
+	ReviewComment string     `json:"review_comment" gorm:"type:text"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// DatasetAnnotationTask 标注人领取的条目及其提交的结果，需要多人标注时每个条目有多个任务
+type DatasetAnnotationTask struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	DatasetID       uint       `json:"dataset_id" gorm:"not null;uniqueIndex:idx_dataset_annotation_task"`
+	EntryIndex      int        `json:"entry_index" gorm:"not null;uniqueIndex:idx_dataset_annotation_task"`
+	UserID          uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_dataset_annotation_task;index"`
+	Status          string     `json:"status" gorm:"size:20;not null;index"`
+	Content         string     `json:"-" gorm:"type:text"` // 提交的条目 JSON
+	AssignedAt      time.Time  `json:"assigned_at" gorm:"index"`
+	SubmittedAt     *time.Time `json:"submitted_at"`
+	DurationSeconds int64      `json:"duration_seconds"` // 从领取到提交的耗时
+}
+
+// CreateDatasetAnnotations 为条目创建 unlabeled 状态的标注记录，已存在的跳过
+func CreateDatasetAnnotations(datasetID uint, entryIndexes []int) error {
+	if len(entryIndexes) == 0 {
+		return nil
+	}
+	annotations := make([]DatasetAnnotation, len(entryIndexes))
+	for i, index := range entryIndexes {
+		annotations[i] = DatasetAnnotation{DatasetID: datasetID, EntryIndex: index, Status: AnnotationUnlabeled}
+	}
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(annotations, datasetAnnotationBatchSize).Error
+}
+
+// MaxDatasetAnnotationIndex 返回已有标注记录的最大 entry_index，没有时返回 -1
+func MaxDatasetAnnotationIndex(datasetID uint) (int, error) {
+	var max int
+	err := DB.Model(&DatasetAnnotation{}).Where("dataset_id = ?", datasetID).
+		Select("COALESCE(MAX(entry_index), -1)").Scan(&max).Error
+	return max, err
+}
+
+// UnannotatedDatasetEntryIndexes 返回数据库存储中还没有标注记录的条目索引，最多 limit 个
+func UnannotatedDatasetEntryIndexes(datasetID uint, limit int) ([]int, error) {
+	annotated := DB.Model(&DatasetAnnotation{}).Select("entry_index").Where("dataset_id = ?", datasetID)
+	var indexes []int
+	err := DB.Model(&DatasetEntry{}).
+		Where("dataset_id = ? AND entry_index NOT IN (?)", datasetID, annotated).
+		Order("entry_index ASC").Limit(limit).
+		Pluck("entry_index", &indexes).Error
+	return indexes, err
+}
+
+// GetDatasetAnnotation 获取条目的标注记录
+func GetDatasetAnnotation(datasetID uint, entryIndex int) (*DatasetAnnotation, error) {
+	var annotation DatasetAnnotation
+	err := DB.Where("dataset_id = ? AND entry_index = ?", datasetID, entryIndex).First(&annotation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAnnotationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &annotation, nil
+}
+
+// ClaimDatasetAnnotation 为标注人分配下一个条目
+// 标注人有未提交的任务时返回其中最早的一个；否则按 entry_index 顺序选择仍需标注、且该标注人没有标注过的条目
+// 领取超过 expiredBefore 仍未提交的任务会被释放，条目重新进入队列
+func ClaimDatasetAnnotation(datasetID, userID uint, required int, expiredBefore time.Time) (*DatasetAnnotationTask, error) {
+	for attempt := 0; ; attempt++ {
+		task, err := claimDatasetAnnotation(datasetID, userID, required, expiredBefore)
+		if errors.Is(err, errAnnotationClaimConflict) && attempt < annotationClaimRetries {
+			continue
+		}
+		return task, err
+	}
+}
+
+func claimDatasetAnnotation(datasetID, userID uint, required int, expiredBefore time.Time) (*DatasetAnnotationTask, error) {
+	var task DatasetAnnotationTask
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("dataset_id = ? AND status = ? AND assigned_at < ?", datasetID, AnnotationTaskAssigned, expiredBefore).
+			Delete(&DatasetAnnotationTask{}).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("dataset_id = ? AND user_id = ? AND status = ?", datasetID, userID, AnnotationTaskAssigned).
+			Order("entry_index ASC").Limit(1).Find(&task)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+
+		full := tx.Model(&DatasetAnnotationTask{}).Select("entry_index").
+			Where("dataset_id = ?", datasetID).Group("entry_index").Having("COUNT(*) >= ?", required)
+		mine := tx.Model(&DatasetAnnotationTask{}).Select("entry_index").
+			Where("dataset_id = ? AND user_id = ?", datasetID, userID)
+		var annotation DatasetAnnotation
+		err = tx.Where("dataset_id = ? AND status = ?", datasetID, AnnotationUnlabeled).
+			Where("entry_index NOT IN (?)", full).
+			Where("entry_index NOT IN (?)", mine).
+			Order("entry_index ASC").First(&annotation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAnnotationQueueEmpty
+		}
+		if err != nil {
+			return err
+		}
+
+		// 锁定条目的标注记录，同一条目的领取依次进行；加锁读取不受事务快照影响，能看到先领取的人已提交的任务
+		// 条目在此期间被领满或不再等待标注时放弃本次领取并重试
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", annotation.ID, AnnotationUnlabeled).First(&annotation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errAnnotationClaimConflict
+		}
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&DatasetAnnotationTask{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("dataset_id = ? AND entry_index = ?", datasetID, annotation.EntryIndex).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(required) {
+			return errAnnotationClaimConflict
+		}
+
+		task = DatasetAnnotationTask{
+			DatasetID:  datasetID,
+			EntryIndex: annotation.EntryIndex,
+			UserID:     userID,
+			Status:     AnnotationTaskAssigned,
+			AssignedAt: time.Now(),
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		return tx.Model(&annotation).UpdateColumn("assignee_id", userID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// SubmitDatasetAnnotation 保存标注人提交的结果，所需的标注人都已提交时条目进入审核
+// 审核前可以重复提交以修改结果
+func SubmitDatasetAnnotation(datasetID uint, entryIndex int, userID uint, content string, required int) (*DatasetAnnotation, error) {
+	var annotation DatasetAnnotation
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("dataset_id = ? AND entry_index = ?", datasetID, entryIndex).First(&annotation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAnnotationNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if annotation.Status != AnnotationUnlabeled && annotation.Status != AnnotationInReview {
+			return ErrAnnotationClosed
+		}
+
+		var task DatasetAnnotationTask
+		err = tx.Where("dataset_id = ? AND entry_index = ? AND user_id = ?", datasetID, entryIndex, userID).First(&task).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAnnotationTaskNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":       AnnotationTaskSubmitted,
+			"content":      content,
+			"submitted_at": now,
+		}
+		if task.Status == AnnotationTaskAssigned {
+			updates["duration_seconds"] = int64(now.Sub(task.AssignedAt).Seconds())
+		}
+		if err := tx.Model(&task).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		var submitted int64
+		if err := tx.Model(&DatasetAnnotationTask{}).
+			Where("dataset_id = ? AND entry_index = ? AND status = ?", datasetID, entryIndex, AnnotationTaskSubmitted).
+			Count(&submitted).Error; err != nil {
+			return err
+		}
+		if submitted >= int64(required) && annotation.Status == AnnotationUnlabeled {
+			annotation.Status = AnnotationInReview
+			return tx.Model(&annotation).Update("status", AnnotationInReview).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &annotation, nil
+}
+
+// GetDatasetAnnotationTasks 获取条目的全部标注任务，按 entry_index、领取时间排序
+func GetDatasetAnnotationTasks(datasetID uint, entryIndexes []int) ([]DatasetAnnotationTask, error) {
+	var tasks []DatasetAnnotationTask
+	err := DB.Where("dataset_id = ? AND entry_index IN ?", datasetID, entryIndexes).
+		Order("entry_index ASC, assigned_at ASC").Find(&tasks).Error
+	return tasks, err
+}
+
+// ReviewDatasetAnnotation 将审核中的条目设为 accepted/rejected
+// requeue 为 true 时清除已有的标注任务并将条目重新放回标注队列
+func ReviewDatasetAnnotation(annotation *DatasetAnnotation, status string, reviewerID uint, comment string, requeue bool) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":         status,
+			"reviewer_id":    reviewerID,
+			"review_comment": comment,
+			"reviewed_at":    now,
+		}
+		if requeue {
+			updates["status"] = AnnotationUnlabeled
+		}
+		result := tx.Model(&DatasetAnnotation{}).
+			Where("id = ? AND status = ?", annotation.ID, AnnotationInReview).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAnnotationClosed
+		}
+		if requeue {
+			if err := tx.Where("dataset_id = ? AND entry_index = ?", annotation.DatasetID, annotation.EntryIndex).
+				Delete(&DatasetAnnotationTask{}).Error; err != nil {
+				return err
+			}
+		}
+		annotation.Status = updates["status"].(string)
+		annotation.ReviewerID = reviewerID
+		annotation.ReviewComment = comment
+		annotation.ReviewedAt = &now
+		return nil
+	})
+}
+
+// RestoreDatasetAnnotationStatus 审核结果未能写入条目时恢复为审核中
+func RestoreDatasetAnnotationStatus(annotation *DatasetAnnotation) error {
+	return DB.Model(&DatasetAnnotation{}).Where("id = ?", annotation.ID).
+		Updates(map[string]interface{}{"status": AnnotationInReview, "reviewer_id": 0, "reviewed_at": nil}).Error
+}
+
+// DatasetAnnotationFilter 标注记录的查询条件，零值表示不限制
+type DatasetAnnotationFilter struct {
+	Status     string
+	AssigneeID uint // 领取过该条目的标注人
+	ReviewerID uint
+}
+
+// ListDatasetAnnotations 分页获取数据集的标注记录，按 entry_index 升序
+func ListDatasetAnnotations(datasetID uint, filter DatasetAnnotationFilter, offset, limit int) ([]DatasetAnnotation, int64, error) {
+	query := DB.Model(&DatasetAnnotation{}).Where("dataset_id = ?", datasetID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.AssigneeID != 0 {
+		query = query.Where("entry_index IN (?)", DB.Model(&DatasetAnnotationTask{}).Select("entry_index").
+			Where("dataset_id = ? AND user_id = ?", datasetID, filter.AssigneeID))
+	}
+	if filter.ReviewerID != 0 {
+		query = query.Where("reviewer_id = ?", filter.ReviewerID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var annotations []DatasetAnnotation
+	err := query.Order("entry_index ASC").Offset(offset).Limit(limit).Find(&annotations).Error
+	return annotations, total, err
+}
+
+// MultiAnnotatedEntryIndexes 返回至少有两位标注人提交结果的条目索引，entry_index 大于 after，最多 limit 个
+func MultiAnnotatedEntryIndexes(datasetID uint, status string, after, limit int) ([]int, error) {
+	query := DB.Model(&DatasetAnnotationTask{}).
+		Where("dataset_id = ? AND status = ? AND entry_index > ?", datasetID, AnnotationTaskSubmitted, after)
+	if status != "" {
+		query = query.Where("entry_index IN (?)", DB.Model(&DatasetAnnotation{}).Select("entry_index").
+			Where("dataset_id = ? AND status = ?", datasetID, status))
+	}
+	var indexes []int
+	err := query.Group("entry_index").Having("COUNT(*) >= 2").
+		Order("entry_index ASC").Limit(limit).
+		Pluck("entry_index", &indexes).Error
+	return indexes, err
+}
+
+// CountDatasetAnnotations 按状态统计数据集的标注记录
+func CountDatasetAnnotations(datasetID uint) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := DB.Model(&DatasetAnnotation{}).Select("status, COUNT(*) AS count").
+		Where("dataset_id = ?", datasetID).Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(AnnotationStatuses))
+	for _, status := range AnnotationStatuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// DatasetAnnotatorStats 标注人在数据集上的工作量
+type DatasetAnnotatorStats struct {
+	UserID     uint    `json:"user_id"`
+	Username   string  `json:"username"`
+	InProgress int64   `json:"in_progress"` // 已领取未提交
+	Submitted  int64   `json:"submitted"`
+	Accepted   int64   `json:"accepted"` // 提交的条目中审核通过的
+	Rejected   int64   `json:"rejected"`
+	AvgSeconds float64 `json:"avg_seconds"`                // 从领取到提交的平均耗时
+	PerDay     float64 `json:"per_day,omitempty" gorm:"-"` // 统计区间内平均每天提交的条目数
+}
+
+// GetDatasetAnnotatorStats 统计各标注人的工作量，since 不为空时只统计之后领取的任务
+func GetDatasetAnnotatorStats(datasetID uint, since *time.Time) ([]DatasetAnnotatorStats, error) {
+	query := DB.Table("dataset_annotation_tasks AS t").
+		Select(`t.user_id, users.username,
+			SUM(CASE WHEN t.status = ? THEN 1 ELSE 0 END) AS in_progress,
+			SUM(CASE WHEN t.status = ? THEN 1 ELSE 0 END) AS submitted,
+			SUM(CASE WHEN t.status = ? AND a.status = ? THEN 1 ELSE 0 END) AS accepted,
+			SUM(CASE WHEN t.status = ? AND a.status = ? THEN 1 ELSE 0 END) AS rejected,
+			COALESCE(AVG(CASE WHEN t.status = ? THEN t.duration_seconds END), 0) AS avg_seconds`,
+			AnnotationTaskAssigned, AnnotationTaskSubmitted,
+			AnnotationTaskSubmitted, AnnotationAccepted,
+			AnnotationTaskSubmitted, AnnotationRejected,
+			AnnotationTaskSubmitted).
+		Joins("JOIN dataset_annotations AS a ON a.dataset_id = t.dataset_id AND a.entry_index = t.entry_index").
+		Joins("LEFT JOIN users ON users.id = t.user_id").
+		Where("t.dataset_id = ?", datasetID)
+	if since != nil {
+		query = query.Where("t.assigned_at >= ?", *since)
+	}
+	var stats []DatasetAnnotatorStats
+	err := query.Group("t.user_id, users.username").Order("submitted DESC").Scan(&stats).Error
+	return stats, err
+}
+
+// DatasetReviewerStats 审核人在数据集上的工作量
+type DatasetReviewerStats struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Accepted int64  `json:"accepted"`
+	Rejected int64  `json:"rejected"`
+}
+
+// GetDatasetReviewerStats 统计各审核人的审核结果，since 不为空时只统计之后的审核
+// 审核后重新放回队列的条目不再计入
+func GetDatasetReviewerStats(datasetID uint, since *time.Time) ([]DatasetReviewerStats, error) {
+	query := DB.Table("dataset_annotations AS a").
+		Select(`a.reviewer_id AS user_id, users.username,
+			SUM(CASE WHEN a.status = ? THEN 1 ELSE 0 END) AS accepted,
+			SUM(CASE WHEN a.status = ? THEN 1 ELSE 0 END) AS rejected`,
+			AnnotationAccepted, AnnotationRejected).
+		Joins("LEFT JOIN users ON users.id = a.reviewer_id").
+		Where("a.dataset_id = ? AND a.reviewer_id <> 0 AND a.status IN ?", datasetID,
+			[]string{AnnotationAccepted, AnnotationRejected})
+	if since != nil {
+		query = query.Where("a.reviewed_at >= ?", *since)
+	}
+	var stats []DatasetReviewerStats
+	err := query.Group("a.reviewer_id, users.username").Order("accepted DESC").Scan(&stats).Error
+	return stats, err
+}
+
+// GetAcceptedAnnotationIndexes 返回审核通过的条目索引
+func GetAcceptedAnnotationIndexes(datasetID uint) (map[int]bool, error) {
+	var indexes []int
+	err := DB.Model(&DatasetAnnotation{}).
+		Where("dataset_id = ? AND status = ?", datasetID, AnnotationAccepted).
+		Pluck("entry_index", &indexes).Error
+	if err != nil {
+		return nil, err
+	}
+	accepted := make(map[int]bool, len(indexes))
+	for _, index := range indexes {
+		accepted[index] = true
+	}
+	return accepted, nil
+}
+
+// DeleteDatasetAnnotations 删除条目的标注记录和任务，entryIndexes 为空时删除整个数据集的
+func DeleteDatasetAnnotations(datasetID uint, entryIndexes ...int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return DeleteDatasetEntryAnnotations(tx, datasetID, entryIndexes)
+	})
+}
+
+// DeleteDatasetEntryAnnotations 在 tx 中删除条目的标注记录和任务，entryIndexes 为空时删除整个数据集的
+func DeleteDatasetEntryAnnotations(tx *gorm.DB, datasetID uint, entryIndexes []int) error {
+	if len(entryIndexes) == 0 {
+		if err := tx.Where("dataset_id = ?", datasetID).Delete(&DatasetAnnotationTask{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("dataset_id = ?", datasetID).Delete(&DatasetAnnotation{}).Error
+	}
+	for start := 0; start < len(entryIndexes); start += datasetAnnotationBatchSize {
+		end := start + datasetAnnotationBatchSize
+		if end > len(entryIndexes) {
+			end = len(entryIndexes)
+		}
+		batch := entryIndexes[start:end]
+		if err := tx.Where("dataset_id = ? AND entry_index IN ?", datasetID, batch).
+			Delete(&DatasetAnnotationTask{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dataset_id = ? AND entry_index IN ?", datasetID, batch).
+			Delete(&DatasetAnnotation{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimDatasetAnnotationLimit(t *testing.T) {
+	setupTestDB(t, &DatasetAnnotation{}, &DatasetAnnotationTask{})
+	if err := CreateDatasetAnnotations(1, []int{0, 1}); err != nil {
+		t.Fatalf("CreateDatasetAnnotations: %v", err)
+	}
+
+	expiredBefore := time.Now().Add(-time.Hour)
+	claimed := map[int][]uint{}
+	for _, userID := range []uint{1, 2, 3} {
+		task, err := ClaimDatasetAnnotation(1, userID, 2, expiredBefore)
+		if err != nil {
+			t.Fatalf("user %d: %v", userID, err)
+		}
+		claimed[task.EntryIndex] = append(claimed[task.EntryIndex], userID)
+	}
+	if len(claimed[0]) != 2 || len(claimed[1]) != 1 {
+		t.Errorf("claimed %v, want two annotators on entry 0", claimed)
+	}
+
+	// 已领取任务的标注人继续领取时返回原任务
+	task, err := ClaimDatasetAnnotation(1, 1, 2, expiredBefore)
+	if err != nil || task.EntryIndex != 0 {
+		t.Errorf("reclaim returned %+v, %v", task, err)
+	}
+	if _, err := ClaimDatasetAnnotation(1, 4, 1, expiredBefore); !errors.Is(err, ErrAnnotationQueueEmpty) {
+		t.Errorf("expected empty queue, got %v", err)
+	}
+}
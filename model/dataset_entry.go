@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // model/dataset_entry.go
@@ -17,3 +19,61 @@ type DatasetEntry struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// 压缩 entry_index 时同步平移的、按 entry_index 对应条目的表
+// 标注相关的表在 entry_index 上有唯一索引，需要分两步平移
+var entryIndexedTables = []struct {
+	model  interface{}
+	unique bool
+}{
+	{&DatasetEntry{}, false},
+	{&DatasetAnnotation{}, true},
+	{&DatasetAnnotationTask{}, true},
+	{&DatasetEntryRevision{}, false},
+}
+
+// RemoveDatasetEntryIndexes 压缩前释放被移除的索引位置：删除这些条目的标注，
+// 修订记录改为 DetachedEntryIndex，仍出现在数据集的修改记录中但不再对应任何条目
+// 数据库存储的条目由调用方删除
+func RemoveDatasetEntryIndexes(tx *gorm.DB, datasetID uint, entryIndexes []int) error {
+	if err := DeleteDatasetEntryAnnotations(tx, datasetID, entryIndexes); err != nil {
+		return err
+	}
+	for start := 0; start < len(entryIndexes); start += datasetEntryRevisionBatchSize {
+		end := start + datasetEntryRevisionBatchSize
+		if end > len(entryIndexes) {
+			end = len(entryIndexes)
+		}
+		if err := tx.Model(&DatasetEntryRevision{}).
+			Where("dataset_id = ? AND entry_index IN ?", datasetID, entryIndexes[start:end]).
+			UpdateColumn("entry_index", DetachedEntryIndex).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShiftDatasetEntryIndexes 将 (after, before) 区间内的 entry_index 减去 by，before 为 -1 表示没有上界
+// 有唯一索引的表先移到负数区间再移回，避免平移过程中与尚未移动的行冲突
+func ShiftDatasetEntryIndexes(tx *gorm.DB, datasetID uint, after, before, by int) error {
+	for _, table := range entryIndexedTables {
+		query := tx.Model(table.model).Where("dataset_id = ? AND entry_index > ?", datasetID, after)
+		if before >= 0 {
+			query = query.Where("entry_index < ?", before)
+		}
+		if !table.unique {
+			if err := query.UpdateColumn("entry_index", gorm.Expr("entry_index - ?", by)).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := query.UpdateColumn("entry_index", gorm.Expr("? - 1 - entry_index", by)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(table.model).Where("dataset_id = ? AND entry_index < 0", datasetID).
+			UpdateColumn("entry_index", gorm.Expr("-1 - entry_index")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
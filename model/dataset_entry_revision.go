@@ -0,0 +1,197 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 条目修订的操作类型
+const (
+	EntryRevisionCreate  = "create"
+	EntryRevisionUpdate  = "update"
+	EntryRevisionDelete  = "delete"
+	EntryRevisionRestore = "restore" // 从回收站恢复
+	EntryRevisionRevert  = "revert"  // 回滚到某次修订之前的内容
+
+	// 数据集级别的修订，EntryIndex 为 DetachedEntryIndex，After 为操作摘要的 JSON
+	EntryRevisionImport   = "import"   // 导入任务追加的一批条目
+	EntryRevisionActivate = "activate" // 激活版本，条目整体替换为版本快照
+)
+
+var ErrEntryRevisionNotFound = errors.New("dataset entry revision not found")
+
+// DetachedEntryIndex 批量删除并压缩 entry_index 后，被删除条目的修订记录不再对应任何条目
+// 批量删除本身的删除修订也使用该索引，并在 DeletedIndex 中保存压缩前的位置
+const DetachedEntryIndex = -1
+
+// 每批写入的修订记录数
+const datasetEntryRevisionBatchSize = 500
+
+// DatasetEntryRevision 条目的修订记录，只追加不修改，按 (dataset_id, entry_index) 对应条目，数据库和MinIO存储通用
+// Before/After 为修改前后的条目 JSON，条目原本不存在时 Before 为空，删除时 After 为空
+// 导入和激活版本只记录一条数据集级别的修订，见 EntryRevisionImport 和 EntryRevisionActivate
+type DatasetEntryRevision struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DatasetID  uint      `json:"dataset_id" gorm:"not null;index:idx_dataset_entry_revision"`
+	EntryIndex int       `json:"entry_index" gorm:"not null;index:idx_dataset_entry_revision"`
+	Action     string    `json:"action" gorm:"size:20;not null;index"`
+	Before     string    `json:"-" gorm:"type:text"`
+	After      string    `json:"-" gorm:"type:text"`
+	UserID     uint      `json:"user_id" gorm:"index"` // 修改人
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+
+	DeletedIndex *int `json:"deleted_index"`              // 批量删除的条目压缩前的 entry_index，其余修订为空
+	RestoredFrom uint `json:"restored_from" gorm:"index"` // 从回收站恢复时对应的删除修订
+}
+
+// CreateDatasetEntryRevisions 在修改条目的事务 tx 中追加修订记录
+func CreateDatasetEntryRevisions(tx *gorm.DB, revisions []DatasetEntryRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(revisions, datasetEntryRevisionBatchSize).Error
+}
+
+// DetachDatasetEntryRevisions 条目整体替换(激活版本)时在 tx 中让数据集已有的修订脱离条目：
+// entry_index 改为 DetachedEntryIndex，批量删除的条目也不再能从回收站恢复
+func DetachDatasetEntryRevisions(tx *gorm.DB, datasetID uint) error {
+	return tx.Model(&DatasetEntryRevision{}).
+		Where("dataset_id = ? AND (entry_index <> ? OR deleted_index IS NOT NULL)", datasetID, DetachedEntryIndex).
+		UpdateColumns(map[string]interface{}{"entry_index": DetachedEntryIndex, "deleted_index": nil}).Error
+}
+
+// GetDatasetEntryRevision 获取数据集的某条修订记录
+func GetDatasetEntryRevision(datasetID, revisionID uint) (*DatasetEntryRevision, error) {
+	var revision DatasetEntryRevision
+	err := DB.Where("dataset_id = ? AND id = ?", datasetID, revisionID).First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEntryRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetLatestDatasetEntryRevision 获取条目最近一次的修订记录
+func GetLatestDatasetEntryRevision(datasetID uint, entryIndex int) (*DatasetEntryRevision, error) {
+	var revision DatasetEntryRevision
+	result := DB.Where("dataset_id = ? AND entry_index = ?", datasetID, entryIndex).
+		Order("id DESC").Limit(1).Find(&revision)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrEntryRevisionNotFound
+	}
+	return &revision, nil
+}
+
+// GetDatasetEntryRevisions 分页获取条目的修订记录，最新的在前
+func GetDatasetEntryRevisions(datasetID uint, entryIndex int, offset, limit int) ([]DatasetEntryRevision, int64, error) {
+	query := DB.Model(&DatasetEntryRevision{}).Where("dataset_id = ? AND entry_index = ?", datasetID, entryIndex)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var revisions []DatasetEntryRevision
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&revisions).Error
+	return revisions, total, err
+}
+
+// deletedDatasetEntries 回收站中的删除修订：最近一次修订为删除的条目，以及批量删除后尚未恢复的条目
+func deletedDatasetEntries(datasetID uint) *gorm.DB {
+	latest := DB.Model(&DatasetEntryRevision{}).Select("MAX(id)").
+		Where("dataset_id = ? AND entry_index >= 0", datasetID).Group("entry_index")
+	restored := DB.Model(&DatasetEntryRevision{}).Select("restored_from").
+		Where("dataset_id = ? AND restored_from > 0", datasetID)
+	return DB.Model(&DatasetEntryRevision{}).
+		Where("dataset_id = ? AND action = ?", datasetID, EntryRevisionDelete).
+		Where(DB.Where("id IN (?)", latest).
+			Or("entry_index = ? AND deleted_index IS NOT NULL AND id NOT IN (?)", DetachedEntryIndex, restored))
+}
+
+// ListDeletedDatasetEntries 分页获取回收站中的条目，最近删除的在前
+func ListDeletedDatasetEntries(datasetID uint, offset, limit int) ([]DatasetEntryRevision, int64, error) {
+	query := deletedDatasetEntries(datasetID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var revisions []DatasetEntryRevision
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&revisions).Error
+	return revisions, total, err
+}
+
+// GetDeletedDatasetEntry 获取回收站中的一条删除修订，已恢复或已被之后的修改覆盖时返回 ErrEntryRevisionNotFound
+func GetDeletedDatasetEntry(datasetID, revisionID uint) (*DatasetEntryRevision, error) {
+	var revision DatasetEntryRevision
+	err := deletedDatasetEntries(datasetID).Where("id = ?", revisionID).First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEntryRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// DatasetChangelogFilter 数据集修改记录的查询条件，零值表示不限制
+type DatasetChangelogFilter struct {
+	Since  *time.Time // 晚于该时间的修改
+	Until  *time.Time // 不晚于该时间的修改
+	Action string
+	UserID uint
+}
+
+func (f DatasetChangelogFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Since != nil {
+		query = query.Where("created_at > ?", *f.Since)
+	}
+	if f.Until != nil {
+		query = query.Where("created_at <= ?", *f.Until)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.UserID != 0 {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	return query
+}
+
+// GetDatasetChangelog 分页获取数据集全部条目的修订记录，最新的在前
+func GetDatasetChangelog(datasetID uint, filter DatasetChangelogFilter, offset, limit int) ([]DatasetEntryRevision, int64, error) {
+	query := filter.apply(DB.Model(&DatasetEntryRevision{}).Where("dataset_id = ?", datasetID))
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var revisions []DatasetEntryRevision
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&revisions).Error
+	return revisions, total, err
+}
+
+// CountDatasetChangelog 按操作类型统计数据集的修订记录
+func CountDatasetChangelog(datasetID uint, filter DatasetChangelogFilter) (map[string]int64, error) {
+	var rows []struct {
+		Action string
+		Count  int64
+	}
+	query := filter.apply(DB.Model(&DatasetEntryRevision{}).Where("dataset_id = ?", datasetID))
+	if err := query.Select("action, COUNT(*) AS count").Group("action").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Action] = row.Count
+	}
+	return counts, nil
+}
+
+// DeleteDatasetEntryRevisions 删除数据集的全部修订记录，只在删除数据集时使用
+func DeleteDatasetEntryRevisions(datasetID uint) error {
+	return DB.Where("dataset_id = ?", datasetID).Delete(&DatasetEntryRevision{}).Error
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetachDatasetEntryRevisions(t *testing.T) {
+	setupTestDB(t, &DatasetEntryRevision{})
+	deletedIndex := 3
+	revisions := []DatasetEntryRevision{
+		{DatasetID: 1, EntryIndex: 0, Action: EntryRevisionCreate, After: `{"a":1}`},
+		{DatasetID: 1, EntryIndex: 1, Action: EntryRevisionDelete, Before: `{"a":2}`},
+		{DatasetID: 1, EntryIndex: DetachedEntryIndex, Action: EntryRevisionDelete, Before: `{"a":3}`, DeletedIndex: &deletedIndex},
+		{DatasetID: 2, EntryIndex: 0, Action: EntryRevisionDelete, Before: `{"a":4}`},
+	}
+	if err := CreateDatasetEntryRevisions(DB, revisions); err != nil {
+		t.Fatalf("CreateDatasetEntryRevisions: %v", err)
+	}
+	if _, total, _ := ListDeletedDatasetEntries(1, 0, 10); total != 2 {
+		t.Fatalf("deleted entries before detaching: %d", total)
+	}
+
+	if err := DetachDatasetEntryRevisions(DB, 1); err != nil {
+		t.Fatalf("DetachDatasetEntryRevisions: %v", err)
+	}
+	// 整体替换前的删除不能再恢复，也不再出现在条目的历史中
+	if _, total, _ := ListDeletedDatasetEntries(1, 0, 10); total != 0 {
+		t.Errorf("deleted entries after detaching: %d", total)
+	}
+	if _, err := GetLatestDatasetEntryRevision(1, 0); !errors.Is(err, ErrEntryRevisionNotFound) {
+		t.Errorf("entry 0 still has revisions: %v", err)
+	}
+	if _, total, _ := GetDatasetChangelog(1, DatasetChangelogFilter{}, 0, 10); total != 3 {
+		t.Errorf("changelog lost revisions: %d", total)
+	}
+	if _, total, _ := ListDeletedDatasetEntries(2, 0, 10); total != 1 {
+		t.Errorf("other dataset was detached: %d", total)
+	}
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrDatasetJobNotFound = errors.New("dataset job not found")
+
+// 数据集后台任务类型
+const (
+	DatasetJobImport   = "import"
+	DatasetJobExport   = "export"
+	DatasetJobAnalyze  = "analyze"
+	DatasetJobSplit    = "split"    // 按比例切分为多个新数据集
+	DatasetJobSubset   = "subset"   // 按检索条件筛选为新数据集
+	DatasetJobPipeline = "pipeline" // 合并多个数据集并按流水线处理，任务属于输出数据集
+)
+
+// 数据集后台任务状态
+const (
+	DatasetJobPending   = "pending"
+	DatasetJobRunning   = "running"
+	DatasetJobSucceeded = "succeeded"
+	DatasetJobFailed    = "failed"
+	DatasetJobCanceled  = "canceled"
+)
+
+// DatasetJob 数据集导入/导出/质量分析后台任务
+// 导入任务的 Errors 为逐行错误报告(JSON)，导出任务完成后结果保存在 BucketName/ObjectPath
+type DatasetJob struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	DatasetID uint   `json:"dataset_id" gorm:"not null;index"`
+	ProjectID uint   `json:"project_id" gorm:"index"`
+	UserID    uint   `json:"user_id" gorm:"not null;index"`
+	Type      string `json:"type" gorm:"size:20;not null"`
+	Status    string `json:"status" gorm:"size:20;not null;index"`
+
+	FileName  string `json:"file_name" gorm:"size:255"`
+	Format    string `json:"format" gorm:"size:20"`
+	Processed int64  `json:"processed"` // 已处理的行数(导入)或条目数(导出)
+	Total     int64  `json:"total"`     // 导出时为数据集条目数，导入时文件读完才确定
+	Succeeded int64  `json:"succeeded"`
+	Failed    int64  `json:"failed"`
+	Errors    string `json:"-" gorm:"type:text"` // 导入错误报告 []services.ImportLineError 的 JSON
+	Message   string `json:"message" gorm:"type:text"`
+
+	BucketName string `json:"bucket_name" gorm:"size:255"`
+	ObjectPath string `json:"object_path" gorm:"size:255"`
+	ResultSize int64  `json:"result_size"`
+
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// IsFinished 任务是否已结束
+func (j *DatasetJob) IsFinished() bool {
+	return j.Status == DatasetJobSucceeded || j.Status == DatasetJobFailed || j.Status == DatasetJobCanceled
+}
+
+// GetDatasetJob 获取数据集后台任务
+func GetDatasetJob(id uint) (*DatasetJob, error) {
+	var job DatasetJob
+	if err := DB.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDatasetJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// HasActiveDatasetJob 数据集是否有未结束的指定类型任务
+func HasActiveDatasetJob(datasetID uint, jobType string) (bool, error) {
+	var count int64
+	err := DB.Model(&DatasetJob{}).
+		Where("dataset_id = ? AND type = ? AND status IN ?", datasetID, jobType, []string{DatasetJobPending, DatasetJobRunning}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UpdateDatasetJobProgress 更新任务进度，已结束的任务不再更新
+func UpdateDatasetJobProgress(id uint, processed, succeeded int64) error {
+	return DB.Model(&DatasetJob{}).
+		Where("id = ? AND status = ?", id, DatasetJobRunning).
+		Updates(map[string]interface{}{
+			"processed": processed,
+			"succeeded": succeeded,
+		}).Error
+}
+
+// StartDatasetJob 将等待中的任务标记为运行中，任务已被取消时返回 false
+func StartDatasetJob(id uint) (bool, error) {
+	now := time.Now()
+	result := DB.Model(&DatasetJob{}).
+		Where("id = ? AND status = ?", id, DatasetJobPending).
+		Updates(map[string]interface{}{
+			"status":     DatasetJobRunning,
+			"started_at": &now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FinishDatasetJob 以指定状态结束任务，updates 为需要一并保存的结果字段
+// 已结束的任务(如已被取消)不会被覆盖
+func FinishDatasetJob(id uint, status string, updates map[string]interface{}) error {
+	now := time.Now()
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = status
+	updates["finished_at"] = &now
+	return DB.Model(&DatasetJob{}).
+		Where("id = ? AND status IN ?", id, []string{DatasetJobPending, DatasetJobRunning}).
+		Updates(updates).Error
+}
+
+// FailInterruptedDatasetJobs 将服务重启前未完成的任务标记为失败
+func FailInterruptedDatasetJobs() (int64, error) {
+	now := time.Now()
+	result := DB.Model(&DatasetJob{}).
+		Where("status IN ?", []string{DatasetJobPending, DatasetJobRunning}).
+		Updates(map[string]interface{}{
+			"status":      DatasetJobFailed,
+			"message":     "interrupted by server restart",
+			"finished_at": &now,
+		})
+	return result.RowsAffected, result.Error
+}
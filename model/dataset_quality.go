@@ -0,0 +1,103 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrDatasetQualityReportNotFound = errors.New("dataset quality report not found")
+
+// DatasetQualityReport 数据集质量分析报告，每个数据集只保留最近一次的报告
+// Report 为 services.QualityReport 的 JSON，其中的条目列表只是示例，完整的问题条目保存在 DatasetQualityFlag 中
+type DatasetQualityReport struct {
+	ID        uint `json:"id" gorm:"primaryKey"`
+	DatasetID uint `json:"dataset_id" gorm:"not null;index"`
+	JobID     uint `json:"job_id"`
+	UserID    uint `json:"user_id"`
+	// 分析开始时数据集的条目数和条目最近一次被修改的时间，批量删除前据此判断数据集是否已被修改
+	DatasetEntryCount       int64      `json:"dataset_entry_count"`
+	DatasetContentUpdatedAt *time.Time `json:"dataset_content_updated_at"`
+	Report                  string     `json:"-" gorm:"type:text"`
+	CreatedAt               time.Time  `json:"created_at"`
+}
+
+// IsStale 报告生成后数据集条目已被修改时返回 true，此时不能再按报告删除条目
+// 只比较条目数无法发现不改变条目数的修改，因此同时比较条目最近一次被修改的时间
+func (r *DatasetQualityReport) IsStale(dataset *Dataset) bool {
+	if r.DatasetEntryCount != dataset.EntryCount {
+		return true
+	}
+	if r.DatasetContentUpdatedAt == nil || dataset.ContentUpdatedAt == nil {
+		return r.DatasetContentUpdatedAt != dataset.ContentUpdatedAt
+	}
+	return !r.DatasetContentUpdatedAt.Equal(*dataset.ContentUpdatedAt)
+}
+
+// DatasetQualityFlag 质量报告标记的问题条目
+type DatasetQualityFlag struct {
+	ReportID   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Issue      string `gorm:"primaryKey;size:20"`
+	EntryIndex int    `gorm:"primaryKey;autoIncrement:false"`
+}
+
+// 每批写入的问题条目数
+const datasetQualityFlagBatchSize = 500
+
+// SaveDatasetQualityReport 保存质量报告和问题条目，并删除该数据集之前的报告
+func SaveDatasetQualityReport(report *DatasetQualityReport, flagged map[string][]int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteDatasetQualityReports(tx, report.DatasetID); err != nil {
+			return err
+		}
+		if err := tx.Create(report).Error; err != nil {
+			return err
+		}
+		var flags []DatasetQualityFlag
+		for issue, indexes := range flagged {
+			for _, index := range indexes {
+				flags = append(flags, DatasetQualityFlag{ReportID: report.ID, Issue: issue, EntryIndex: index})
+			}
+		}
+		if len(flags) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(flags, datasetQualityFlagBatchSize).Error
+	})
+}
+
+// GetLatestDatasetQualityReport 获取数据集最近一次的质量报告
+func GetLatestDatasetQualityReport(datasetID uint) (*DatasetQualityReport, error) {
+	var report DatasetQualityReport
+	if err := DB.Where("dataset_id = ?", datasetID).Order("id DESC").First(&report).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDatasetQualityReportNotFound
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetDatasetQualityFlags 返回报告中属于 issues 任一类问题的条目，按 entry_index 升序去重
+func GetDatasetQualityFlags(reportID uint, issues []string) ([]int, error) {
+	var indexes []int
+	err := DB.Model(&DatasetQualityFlag{}).
+		Where("report_id = ? AND issue IN ?", reportID, issues).
+		Distinct("entry_index").Order("entry_index ASC").
+		Pluck("entry_index", &indexes).Error
+	return indexes, err
+}
+
+// DeleteDatasetQualityReports 删除数据集的全部质量报告
+func DeleteDatasetQualityReports(datasetID uint) error {
+	return deleteDatasetQualityReports(DB, datasetID)
+}
+
+func deleteDatasetQualityReports(tx *gorm.DB, datasetID uint) error {
+	reportIDs := tx.Model(&DatasetQualityReport{}).Select("id").Where("dataset_id = ?", datasetID)
+	if err := tx.Where("report_id IN (?)", reportIDs).Delete(&DatasetQualityFlag{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("dataset_id = ?", datasetID).Delete(&DatasetQualityReport{}).Error
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"MLcore-Engine/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 数据集条目全文索引的实现方式
+const (
+	SearchBackendNone  = ""      // 数据库不支持全文索引，检索退化为 LIKE 或逐条扫描
+	SearchBackendFTS5  = "fts5"  // SQLite FTS5 虚拟表 dataset_entry_fts
+	SearchBackendMySQL = "mysql" // MySQL dataset_search_docs 表上的 ngram FULLTEXT 索引
+)
+
+// SearchBackend 当前数据库使用的全文索引，InitDB 时确定
+var SearchBackend = SearchBackendNone
+
+const datasetSearchFTSTable = "dataset_entry_fts"
+
+// DatasetSearchDoc 条目的检索文档，数据库和MinIO存储的数据集都按 entry_index 建立索引
+// SQLite 下保存在 FTS5 虚拟表中，rowid 由数据集ID和 entry_index 组成
+type DatasetSearchDoc struct {
+	DatasetID   uint   `gorm:"primaryKey;autoIncrement:false"`
+	EntryIndex  int    `gorm:"primaryKey;autoIncrement:false"`
+	Instruction string `gorm:"type:text"`
+	Input       string `gorm:"type:text"`
+	Output      string `gorm:"type:text"`
+}
+
+// datasetSearchRowID 返回检索文档在 FTS5 表中的 rowid，同一数据集的文档 rowid 连续且按 entry_index 排序
+func datasetSearchRowID(datasetID uint, entryIndex int) int64 {
+	return int64(datasetID)<<32 | int64(uint32(entryIndex))
+}
+
+func datasetSearchRowRange(datasetID uint) (int64, int64) {
+	return datasetSearchRowID(datasetID, 0), datasetSearchRowID(datasetID, -1)
+}
+
+// initDatasetSearch 创建全文索引，数据库不支持时只记录日志
+func initDatasetSearch(db *gorm.DB) {
+	switch db.Dialector.Name() {
+	case "sqlite":
+		err := db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS " + datasetSearchFTSTable + " USING fts5(instruction, input, output)").Error
+		if err != nil {
+			common.SysLog("SQLite FTS5 is not available, dataset search falls back to LIKE: " + err.Error())
+			return
+		}
+		SearchBackend = SearchBackendFTS5
+	case "mysql":
+		if err := db.AutoMigrate(&DatasetSearchDoc{}); err != nil {
+			common.SysError("failed to migrate dataset search table: " + err.Error())
+			return
+		}
+		indexes := map[string]string{
+			"idx_search_all":         "instruction, input, output",
+			"idx_search_instruction": "instruction",
+			"idx_search_input":       "input",
+			"idx_search_output":      "output",
+		}
+		for name, columns := range indexes {
+			if db.Migrator().HasIndex(&DatasetSearchDoc{}, name) {
+				continue
+			}
+			err := db.Exec("CREATE FULLTEXT INDEX " + name + " ON dataset_search_docs (" + columns + ") WITH PARSER ngram").Error
+			if err != nil {
+				common.SysError("failed to create dataset search index: " + err.Error())
+				return
+			}
+		}
+		SearchBackend = SearchBackendMySQL
+	}
+}
+
+// SaveDatasetSearchDocs 写入条目的检索文档，已存在的同一条目文档被覆盖
+func SaveDatasetSearchDocs(docs []DatasetSearchDoc) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		switch SearchBackend {
+		case SearchBackendFTS5:
+			for _, doc := range docs {
+				rowID := datasetSearchRowID(doc.DatasetID, doc.EntryIndex)
+				if err := tx.Exec("DELETE FROM "+datasetSearchFTSTable+" WHERE rowid = ?", rowID).Error; err != nil {
+					return err
+				}
+				err := tx.Exec("INSERT INTO "+datasetSearchFTSTable+" (rowid, instruction, input, output) VALUES (?, ?, ?, ?)",
+					rowID, doc.Instruction, doc.Input, doc.Output).Error
+				if err != nil {
+					return err
+				}
+			}
+		case SearchBackendMySQL:
+			for i := range docs {
+				if err := tx.Save(&docs[i]).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteDatasetSearchDocs 删除数据集的检索文档，entryIndexes 为空时删除全部
+func DeleteDatasetSearchDocs(datasetID uint, entryIndexes ...int) error {
+	switch SearchBackend {
+	case SearchBackendFTS5:
+		if len(entryIndexes) == 0 {
+			from, to := datasetSearchRowRange(datasetID)
+			return DB.Exec("DELETE FROM "+datasetSearchFTSTable+" WHERE rowid BETWEEN ? AND ?", from, to).Error
+		}
+		rowIDs := make([]int64, len(entryIndexes))
+		for i, index := range entryIndexes {
+			rowIDs[i] = datasetSearchRowID(datasetID, index)
+		}
+		return DB.Exec("DELETE FROM "+datasetSearchFTSTable+" WHERE rowid IN ?", rowIDs).Error
+	case SearchBackendMySQL:
+		query := DB.Where("dataset_id = ?", datasetID)
+		if len(entryIndexes) > 0 {
+			query = query.Where("entry_index IN ?", entryIndexes)
+		}
+		return query.Delete(&DatasetSearchDoc{}).Error
+	}
+	return nil
+}
+
+// SearchDatasetFTS 用 FTS5 的 MATCH 表达式检索数据集，返回按 entry_index 排序的一页结果和命中总数
+func SearchDatasetFTS(datasetID uint, expr string, offset, limit int) ([]int, int64, error) {
+	from, to := datasetSearchRowRange(datasetID)
+	where := " FROM " + datasetSearchFTSTable + " WHERE " + datasetSearchFTSTable + " MATCH ? AND rowid BETWEEN ? AND ?"
+
+	var total int64
+	if err := DB.Raw("SELECT COUNT(*)"+where, expr, from, to).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rowIDs []int64
+	err := DB.Raw("SELECT rowid"+where+" ORDER BY rowid LIMIT ? OFFSET ?", expr, from, to, limit, offset).
+		Scan(&rowIDs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	indexes := make([]int, len(rowIDs))
+	for i, rowID := range rowIDs {
+		indexes[i] = int(int32(rowID))
+	}
+	return indexes, total, nil
+}
+
+// SearchDatasetFulltext 用 MySQL 全文检索条件检索数据集，返回按 entry_index 排序的一页结果和命中总数
+func SearchDatasetFulltext(datasetID uint, condition string, args []interface{}, offset, limit int) ([]int, int64, error) {
+	query := DB.Model(&DatasetSearchDoc{}).Where("dataset_id = ?", datasetID).Where(condition, args...)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var indexes []int
+	err := query.Order("entry_index ASC").Offset(offset).Limit(limit).Pluck("entry_index", &indexes).Error
+	return indexes, total, err
+}
+
+// MarkDatasetSearchIndexed 记录数据集检索索引的重建时间
+func MarkDatasetSearchIndexed(datasetID uint) error {
+	now := time.Now()
+	return DB.Model(&Dataset{}).Where("id = ?", datasetID).UpdateColumn("search_indexed_at", &now).Error
+}
+
+// MarkDatasetSearchStale 标记数据集的检索索引需要重建，下次检索时重新生成
+func MarkDatasetSearchStale(datasetID uint) error {
+	return DB.Model(&Dataset{}).Where("id = ?", datasetID).UpdateColumn("search_indexed_at", nil).Error
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 数据集在MinIO中的存储布局
+const (
+	DatasetLayoutSingle  = ""        // 旧版：整个数据集保存为 ObjectPath 一个JSONL对象
+	DatasetLayoutSharded = "sharded" // 按行切分为多个 shard 对象，ObjectPath 为对象前缀
+)
+
+// DatasetShard MinIO存储的数据集分片，每个分片是一个JSONL对象
+// 行号即 entry_index，删除的条目保留 {} 占位行，因此各分片的 StartIndex 只在追加时变化
+type DatasetShard struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	DatasetID   uint      `json:"dataset_id" gorm:"not null;index"`
+	Seq         int       `json:"seq" gorm:"not null"`         // 分片序号，从 0 开始
+	StartIndex  int       `json:"start_index" gorm:"not null"` // 分片第一行的 entry_index
+	LineCount   int       `json:"line_count"`
+	Size        int64     `json:"size"`
+	ObjectPath  string    `json:"object_path" gorm:"size:255"`
+	LineOffsets string    `json:"-" gorm:"type:text"` // 稀疏行偏移索引 []int64 的 JSON
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Offsets 返回分片的行偏移索引
+func (s *DatasetShard) Offsets() []int64 {
+	var offsets []int64
+	if s.LineOffsets != "" {
+		_ = json.Unmarshal([]byte(s.LineOffsets), &offsets)
+	}
+	return offsets
+}
+
+// SetOffsets 保存分片的行偏移索引
+func (s *DatasetShard) SetOffsets(offsets []int64) {
+	data, _ := json.Marshal(offsets)
+	s.LineOffsets = string(data)
+}
+
+// GetDatasetShards 按顺序获取数据集的全部分片
+func GetDatasetShards(datasetID uint) ([]DatasetShard, error) {
+	var shards []DatasetShard
+	err := DB.Where("dataset_id = ?", datasetID).Order("seq ASC").Find(&shards).Error
+	return shards, err
+}
+
+// ReplaceDatasetShards 用 shards 替换数据集的全部分片记录
+func ReplaceDatasetShards(tx *gorm.DB, datasetID uint, shards []DatasetShard) error {
+	if err := tx.Where("dataset_id = ?", datasetID).Delete(&DatasetShard{}).Error; err != nil {
+		return err
+	}
+	if len(shards) == 0 {
+		return nil
+	}
+	return tx.Create(&shards).Error
+}
+
+// SaveDatasetShards 保存新增或重写的分片记录
+func SaveDatasetShards(tx *gorm.DB, shards []DatasetShard) error {
+	for i := range shards {
+		if err := tx.Save(&shards[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,20 +1,27 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // model/dataset_version.go
 type DatasetVersion struct {
 	ID          uint    `json:"id" gorm:"primarykey"`
-	DatasetID   uint    `json:"dataset_id" gorm:"not null;index;constraint:OnDelete:CASCADE"`
+	DatasetID   uint    `json:"dataset_id" gorm:"not null;index;uniqueIndex:idx_dataset_version_name;constraint:OnDelete:CASCADE"`
 	Dataset     Dataset `json:"-" gorm:"foreignKey:DatasetID;references:ID"`
-	Version     string  `json:"version" gorm:"size:50;not null"`
+	Version     string  `json:"version" gorm:"size:50;not null;uniqueIndex:idx_dataset_version_name"`
 	Description string  `json:"description" gorm:"type:text"`
 	IsActive    bool    `json:"is_active" gorm:"default:false"`
 	BucketName  string  `json:"bucket_name" gorm:"size:255"`
 	ObjectPath  string  `json:"object_path" gorm:"size:255"`
 	EntryCount  int64   `json:"entry_count" gorm:"default:0"`
+	TotalSize   int64   `json:"total_size" gorm:"default:0"` // 快照大小(字节)
+	// 快照只包含审核通过的条目，其余条目以 {} 占位
+	AcceptedOnly bool `json:"accepted_only" gorm:"default:false"`
 
 	// 创建者关联
 	UserID uint `json:"user_id" gorm:"not null;index;constraint:OnDelete:RESTRICT"`
@@ -22,3 +29,70 @@ type DatasetVersion struct {
 
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// GetDatasetVersions 获取数据集的全部版本，最新的在前
+func GetDatasetVersions(datasetID uint) ([]DatasetVersion, error) {
+	var versions []DatasetVersion
+	err := DB.Where("dataset_id = ?", datasetID).Order("id DESC").Find(&versions).Error
+	return versions, err
+}
+
+// GetDatasetVersion 获取数据集的指定版本
+func GetDatasetVersion(datasetID, versionID uint) (*DatasetVersion, error) {
+	var version DatasetVersion
+	err := DB.Where("dataset_id = ? AND id = ?", datasetID, versionID).First(&version).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("dataset version not found")
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// GetActiveDatasetVersion 获取数据集当前激活的版本
+func GetActiveDatasetVersion(datasetID uint) (*DatasetVersion, error) {
+	var version DatasetVersion
+	err := DB.Where("dataset_id = ? AND is_active = ?", datasetID, true).First(&version).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("dataset has no active version")
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// NextDatasetVersionName 分配下一个版本号，形如 v1、v2
+// 版本号由数据集上的计数器分配，并发创建不会得到相同的版本号，删除的版本号也不会再次使用
+func NextDatasetVersionName(tx *gorm.DB, datasetID uint) (string, error) {
+	var seq int64
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 计数器加入之前创建的版本没有计数，从已有版本数之后开始
+		var count int64
+		if err := tx.Model(&DatasetVersion{}).Where("dataset_id = ?", datasetID).Count(&count).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Dataset{}).Where("id = ?", datasetID).UpdateColumn("version_seq",
+			gorm.Expr("CASE WHEN version_seq > ? THEN version_seq + 1 ELSE ? END", count, count+1)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Dataset{}).Where("id = ?", datasetID).Select("version_seq").Scan(&seq).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d", seq), nil
+}
+
+// SetActiveDatasetVersion 将指定版本设为激活状态，同一数据集的其他版本全部取消激活
+func SetActiveDatasetVersion(tx *gorm.DB, datasetID, versionID uint) error {
+	if err := tx.Model(&DatasetVersion{}).
+		Where("dataset_id = ? AND id <> ?", datasetID, versionID).
+		Update("is_active", false).Error; err != nil {
+		return err
+	}
+	return tx.Model(&DatasetVersion{}).
+		Where("dataset_id = ? AND id = ?", datasetID, versionID).
+		Update("is_active", true).Error
+}
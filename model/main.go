@@ -95,9 +95,38 @@ func InitDB() (err error) {
 			return err
 		}
 
+		if err := db.AutoMigrate(&RefreshToken{}, &RevokedToken{}, &UserTokenRevocation{}, &PersonalAccessToken{}); err != nil {
+			return err
+		}
+
 		if err := db.AutoMigrate(&DatasetEntry{}); err != nil {
 			return err
 		}
+		if err := db.AutoMigrate(&DatasetShard{}); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&DatasetJob{}); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&DatasetQualityReport{}, &DatasetQualityFlag{}); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&DatasetAnnotation{}, &DatasetAnnotationTask{}); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&DatasetEntryRevision{}); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&ProjectQuota{}); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&AuditLog{}); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&Cluster{}); err != nil {
+			return err
+		}
+		initDatasetSearch(db)
 
 		err = createRootAccountIfNeed()
 		return err
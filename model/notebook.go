@@ -15,6 +15,7 @@ type Notebook struct {
 	User            User      `json:"user" gorm:"foreignKey:UserID;references:ID"`
 	Name            string    `json:"name" gorm:"size:200;unique"`
 	Describe        string    `json:"describe" gorm:"size:200"`
+	ClusterID       uint      `json:"cluster_id" gorm:"index;default:0"` // 0 表示 config.yaml 中的集群
 	Namespace       string    `json:"namespace" gorm:"size:200;default:jupyter"`
 	Image           string    `json:"image" gorm:"size:200;default:''"`
 	IDEType         string    `json:"ide_type" gorm:"size:100;default:jupyter"`
@@ -31,8 +32,18 @@ type Notebook struct {
 	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 	AccessURL       string    `json:"access_url" gorm:"size:500"`
+
+	// 空闲回收与定时启停策略
+	IdleTimeoutMinutes int        `json:"idle_timeout_minutes" gorm:"default:0"`   // 0 表示不自动回收
+	ScheduleStop       string     `json:"schedule_stop" gorm:"size:5;default:''"`  // 每日停止时间 HH:MM
+	ScheduleStart      string     `json:"schedule_start" gorm:"size:5;default:''"` // 每日启动时间 HH:MM
+	LastActivityAt     *time.Time `json:"last_activity_at"`
+	StoppedAt          *time.Time `json:"stopped_at"`
 }
 
+// NotebookStatusStopped Pod 已删除，PVC 子目录和数据库记录保留，可再次启动
+const NotebookStatusStopped = "Stopped"
+
 // Insert creates a new Notebook
 func (n *Notebook) Insert() error {
 	return DB.Create(n).Error
@@ -104,3 +115,70 @@ func (n *Notebook) Reset() error {
 	n.UpdatedAt = time.Now()
 	return DB.Save(n).Error
 }
+
+// MarkStopped records that the Notebook pod has been removed
+func (n *Notebook) MarkStopped() error {
+	now := time.Now()
+	n.Status = NotebookStatusStopped
+	n.StoppedAt = &now
+	return DB.Model(n).Updates(map[string]interface{}{
+		"status":     n.Status,
+		"stopped_at": n.StoppedAt,
+	}).Error
+}
+
+// ErrNotebookNotStopped is returned when a start is reserved for a Notebook that is no longer stopped
+var ErrNotebookNotStopped = errors.New("notebook is not stopped")
+
+// ReserveStart checks the project quota and moves a stopped Notebook to Creating in one transaction,
+// so concurrent starts in the same project cannot exceed the quota together
+func (n *Notebook) ReserveStart(requested ResourceUsage) error {
+	return ReserveProjectQuota(n.ProjectID, requested, func(tx *gorm.DB) error {
+		result := tx.Model(&Notebook{}).Where("id = ? AND status = ?", n.ID, NotebookStatusStopped).
+			Update("status", "Creating")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotebookNotStopped
+		}
+		return nil
+	})
+}
+
+// CancelStart returns a Notebook whose reserved start failed to the stopped state
+func (n *Notebook) CancelStart() error {
+	return DB.Model(n).Update("status", NotebookStatusStopped).Error
+}
+
+// SaveResources writes the resource fields of the Notebook, including zero values
+func (n *Notebook) SaveResources(db *gorm.DB) error {
+	return db.Model(n).Select("resource_cpu", "resource_memory", "resource_gpu").Updates(n).Error
+}
+
+// MarkStarted records that the Notebook pod has been recreated
+func (n *Notebook) MarkStarted() error {
+	now := time.Now()
+	n.Status = "Creating"
+	n.StoppedAt = nil
+	n.LastActivityAt = &now
+	return DB.Model(n).Updates(map[string]interface{}{
+		"status":           n.Status,
+		"stopped_at":       nil,
+		"last_activity_at": n.LastActivityAt,
+		"access_url":       n.AccessURL,
+	}).Error
+}
+
+// TouchActivity records the last Jupyter activity observed for the Notebook
+func (n *Notebook) TouchActivity(t time.Time) error {
+	n.LastActivityAt = &t
+	return DB.Model(n).Update("last_activity_at", t).Error
+}
+
+// GetNotebooksWithLifecyclePolicy returns Notebooks that have an idle timeout or a stop/start schedule
+func GetNotebooksWithLifecyclePolicy() ([]Notebook, error) {
+	var notebooks []Notebook
+	err := DB.Where("idle_timeout_minutes > 0 OR schedule_stop <> '' OR schedule_start <> ''").Find(&notebooks).Error
+	return notebooks, err
+}
@@ -9,6 +9,7 @@ type Project struct {
 	gorm.Model
 	Name        string     `json:"name" gorm:"uniqueIndex;not null" validate:"required,max=50"`
 	Description string     `json:"description" validate:"max=500"`
+	ClusterID   uint       `json:"cluster_id" gorm:"index;default:0"` // 项目默认集群，0 表示使用全局默认集群
 	Users       []User     `json:"users" gorm:"many2many:user_projects"`
 	Notebooks   []Notebook `json:"notebooks" gorm:"foreignKey:ProjectID;constraint:OnDelete:RESTRICT"`
 }
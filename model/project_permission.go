@@ -0,0 +1,123 @@
+package model
+
+import (
+	"MLcore-Engine/common"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ProjectAction 项目内资源的操作类型
+type ProjectAction string
+
+const (
+	ActionView   ProjectAction = "view"
+	ActionCreate ProjectAction = "create"
+	ActionUpdate ProjectAction = "update"
+	ActionDelete ProjectAction = "delete"
+	ActionManage ProjectAction = "manage" // 管理项目本身及其成员、配额
+)
+
+// 项目资源类型
+const (
+	ResourceProject      = "project"
+	ResourceDataset      = "dataset"
+	ResourceNotebook     = "notebook"
+	ResourceTrainingJob  = "training_job"
+	ResourceTritonDeploy = "triton_deploy"
+	ResourceDatasetJob   = "dataset_job"
+)
+
+// ProjectPermissionTable 各操作所需的最低项目角色(UserProject.Role)
+// 资源创建者对自己的资源始终拥有 update/delete 权限
+var ProjectPermissionTable = map[ProjectAction]int{
+	ActionView:   RoleCommon,
+	ActionCreate: RoleCommon,
+	ActionUpdate: RoleAdmin,
+	ActionDelete: RoleAdmin,
+	ActionManage: RoleAdmin,
+}
+
+var ErrResourceNotFound = errors.New("resource not found")
+
+// ResourceOwner 资源所属的项目和创建者，ProjectID 为 0 表示个人资源
+type ResourceOwner struct {
+	ProjectID uint
+	UserID    uint
+}
+
+// GetResourceOwner 查询资源所属的项目和创建者
+func GetResourceOwner(resource string, id uint) (*ResourceOwner, error) {
+	var owner ResourceOwner
+	var query *gorm.DB
+	switch resource {
+	case ResourceProject:
+		var project Project
+		if err := DB.Select("id").First(&project, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrResourceNotFound
+			}
+			return nil, err
+		}
+		return &ResourceOwner{ProjectID: project.ID}, nil
+	case ResourceDataset:
+		query = DB.Model(&Dataset{})
+	case ResourceNotebook:
+		query = DB.Model(&Notebook{})
+	case ResourceTrainingJob:
+		query = DB.Model(&TrainingJob{})
+	case ResourceTritonDeploy:
+		query = DB.Model(&TritonDeploy{})
+	case ResourceDatasetJob:
+		query = DB.Model(&DatasetJob{})
+	default:
+		return nil, fmt.Errorf("unknown resource type %q", resource)
+	}
+
+	result := query.Select("project_id", "user_id").Where("id = ?", id).Limit(1).Scan(&owner)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrResourceNotFound
+	}
+	return &owner, nil
+}
+
+// GetProjectRole 返回用户在项目中的角色，不是项目成员时返回 0
+func GetProjectRole(projectID, userID uint) (int, error) {
+	var userProject UserProject
+	err := DB.Where("project_id = ? AND user_id = ?", projectID, userID).First(&userProject).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userProject.Role, nil
+}
+
+// CheckProjectPermission 判断用户能否对资源执行操作
+// 系统超级管理员不受限制；个人资源(ProjectID 为 0)只有创建者可以访问
+func CheckProjectPermission(userID uint, userRole int, owner *ResourceOwner, action ProjectAction) (bool, error) {
+	if userRole >= common.RoleRootUser {
+		return true, nil
+	}
+	if owner.UserID != 0 && owner.UserID == userID && action != ActionManage {
+		return true, nil
+	}
+	if owner.ProjectID == 0 {
+		return false, nil
+	}
+
+	minRole, ok := ProjectPermissionTable[action]
+	if !ok {
+		return false, fmt.Errorf("unknown project action %q", action)
+	}
+	role, err := GetProjectRole(owner.ProjectID, userID)
+	if err != nil {
+		return false, err
+	}
+	return role != 0 && role >= minRole, nil
+}
@@ -0,0 +1,295 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ProjectQuota 项目级资源配额，任一字段为 0 表示不限制
+type ProjectQuota struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	ProjectID        uint      `json:"project_id" gorm:"uniqueIndex;not null"`
+	Project          Project   `json:"-" gorm:"foreignKey:ProjectID;references:ID;constraint:OnDelete:CASCADE"`
+	CPU              float64   `json:"cpu" gorm:"default:0"`    // CPU 核数
+	Memory           float64   `json:"memory" gorm:"default:0"` // 内存(GiB)
+	GPU              int64     `json:"gpu" gorm:"default:0"`
+	MaxNotebooks     int64     `json:"max_notebooks" gorm:"default:0"`
+	MaxTrainingJobs  int64     `json:"max_training_jobs" gorm:"default:0"`
+	MaxTritonDeploys int64     `json:"max_triton_deploys" gorm:"default:0"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ResourceUsage 表示一组工作负载占用(或申请)的资源
+type ResourceUsage struct {
+	CPU           float64 `json:"cpu"`
+	Memory        float64 `json:"memory"`
+	GPU           int64   `json:"gpu"`
+	Notebooks     int64   `json:"notebooks"`
+	TrainingJobs  int64   `json:"training_jobs"`
+	TritonDeploys int64   `json:"triton_deploys"`
+}
+
+// Add 累加另一组资源
+func (u *ResourceUsage) Add(other ResourceUsage) {
+	u.CPU += other.CPU
+	u.Memory += other.Memory
+	u.GPU += other.GPU
+	u.Notebooks += other.Notebooks
+	u.TrainingJobs += other.TrainingJobs
+	u.TritonDeploys += other.TritonDeploys
+}
+
+// Sub 减去另一组资源，用于计算更新前后的增量
+func (u ResourceUsage) Sub(other ResourceUsage) ResourceUsage {
+	return ResourceUsage{
+		CPU:           u.CPU - other.CPU,
+		Memory:        u.Memory - other.Memory,
+		GPU:           u.GPU - other.GPU,
+		Notebooks:     u.Notebooks - other.Notebooks,
+		TrainingJobs:  u.TrainingJobs - other.TrainingJobs,
+		TritonDeploys: u.TritonDeploys - other.TritonDeploys,
+	}
+}
+
+// QuotaViolation 描述某一项超出配额的资源
+type QuotaViolation struct {
+	Resource  string  `json:"resource"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"`
+	Requested float64 `json:"requested"`
+}
+
+// QuotaExceededError 申请的资源超出项目配额
+type QuotaExceededError struct {
+	ProjectID  uint             `json:"project_id"`
+	Violations []QuotaViolation `json:"violations"`
+}
+
+func (e *QuotaExceededError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = fmt.Sprintf("%s (limit %g, used %g, requested %g)", v.Resource, v.Limit, v.Used, v.Requested)
+	}
+	return fmt.Sprintf("project %d quota exceeded: %s", e.ProjectID, strings.Join(parts, "; "))
+}
+
+// 已结束的训练任务不再占用资源
+var finishedTrainingJobStatus = []string{"Succeeded", "Failed", "Completed"}
+
+// GetProjectQuota 获取项目配额，未设置时返回不限制的配额
+func GetProjectQuota(projectID uint) (*ProjectQuota, error) {
+	return getProjectQuota(DB, projectID)
+}
+
+func getProjectQuota(db *gorm.DB, projectID uint) (*ProjectQuota, error) {
+	quota := ProjectQuota{ProjectID: projectID}
+	err := db.Where("project_id = ?", projectID).First(&quota).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// SaveProjectQuota 创建或更新项目配额
+func SaveProjectQuota(quota *ProjectQuota) error {
+	var existing ProjectQuota
+	err := DB.Where("project_id = ?", quota.ProjectID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DB.Create(quota).Error
+	}
+	if err != nil {
+		return err
+	}
+	quota.ID = existing.ID
+	quota.CreatedAt = existing.CreatedAt
+	return DB.Save(quota).Error
+}
+
+// parseQuantity 将 Kubernetes 资源字符串(如 "4"、"500m"、"8Gi")解析为数值
+// 内存以 GiB 为单位返回
+func parseQuantity(value string, memory bool) float64 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0
+	}
+	if memory {
+		return float64(q.Value()) / float64(1<<30)
+	}
+	return q.AsApproximateFloat64()
+}
+
+// NotebookUsage 计算单个 Notebook 申请的资源，未填写的字段按数据库默认值计算
+func NotebookUsage(n *Notebook) ResourceUsage {
+	cpu, memory := n.ResourceCPU, n.ResourceMemory
+	if cpu == "" {
+		cpu = "4"
+	}
+	if memory == "" {
+		memory = "8G"
+	}
+	return ResourceUsage{
+		CPU:       parseQuantity(cpu, false),
+		Memory:    parseQuantity(memory, true),
+		GPU:       n.ResourceGPU,
+		Notebooks: 1,
+	}
+}
+
+// TrainingJobUsage 计算训练任务所有副本申请的资源
+func TrainingJobUsage(j *TrainingJob) ResourceUsage {
+	master, worker := int64(j.MasterReplicas), int64(j.WorkerReplicas)
+	if master == 0 {
+		master = 1
+	}
+	if worker == 0 {
+		worker = 1
+	}
+	cpu, memory := j.CPULimit, j.MemoryLimit
+	if cpu == "" {
+		cpu = "4"
+	}
+	if memory == "" {
+		memory = "8Gi"
+	}
+	replicas := master + worker
+	return ResourceUsage{
+		CPU:          parseQuantity(cpu, false) * float64(replicas),
+		Memory:       parseQuantity(memory, true) * float64(replicas),
+		GPU:          j.GPUsPerNode * replicas,
+		TrainingJobs: 1,
+	}
+}
+
+// TritonDeployUsage 计算 Triton 部署所有副本申请的资源(内存单位为 GiB)
+func TritonDeployUsage(t *TritonDeploy) ResourceUsage {
+	replicas, cpu, memory := int64(t.Replicas), t.CPU, t.Memory
+	if replicas == 0 {
+		replicas = 1
+	}
+	if cpu == 0 {
+		cpu = 2
+	}
+	if memory == 0 {
+		memory = 4
+	}
+	return ResourceUsage{
+		CPU:           float64(cpu * replicas),
+		Memory:        float64(memory * replicas),
+		GPU:           t.GPU * replicas,
+		TritonDeploys: 1,
+	}
+}
+
+// GetProjectUsage 汇总项目当前在数据库中登记的资源占用
+func GetProjectUsage(projectID uint) (ResourceUsage, error) {
+	return getProjectUsage(DB, projectID)
+}
+
+func getProjectUsage(db *gorm.DB, projectID uint) (ResourceUsage, error) {
+	var usage ResourceUsage
+
+	var notebooks []Notebook
+	if err := db.Where("project_id = ?", projectID).Find(&notebooks).Error; err != nil {
+		return usage, err
+	}
+	for i := range notebooks {
+		// 已停止的 Notebook 不占用计算资源，但仍计入 Notebook 数量
+		if notebooks[i].Status == NotebookStatusStopped {
+			usage.Notebooks++
+			continue
+		}
+		usage.Add(NotebookUsage(&notebooks[i]))
+	}
+
+	var jobs []TrainingJob
+	if err := db.Where("project_id = ? AND status NOT IN ?", projectID, finishedTrainingJobStatus).Find(&jobs).Error; err != nil {
+		return usage, err
+	}
+	for i := range jobs {
+		usage.Add(TrainingJobUsage(&jobs[i]))
+	}
+
+	var deploys []TritonDeploy
+	if err := db.Where("project_id = ?", projectID).Find(&deploys).Error; err != nil {
+		return usage, err
+	}
+	for i := range deploys {
+		usage.Add(TritonDeployUsage(&deploys[i]))
+	}
+
+	return usage, nil
+}
+
+// CheckProjectQuota 校验在项目当前占用的基础上再申请 requested 是否超出配额
+// 超出时返回 *QuotaExceededError。只用于预先提示，实际占用资源须通过 ReserveProjectQuota
+func CheckProjectQuota(projectID uint, requested ResourceUsage) error {
+	return checkProjectQuota(DB, projectID, requested)
+}
+
+// ReserveProjectQuota 锁定项目后校验配额，通过时在同一事务中执行 reserve(写入占用资源的工作负载记录)
+// 同一项目的并发申请依次校验，不会各自通过后共同超出配额；超出时返回 *QuotaExceededError
+func ReserveProjectQuota(projectID uint, requested ResourceUsage, reserve func(tx *gorm.DB) error) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if projectID != 0 {
+			// 空更新即可取得项目行的写锁，SQLite 和 MySQL 通用
+			if err := tx.Model(&Project{}).Where("id = ?", projectID).
+				UpdateColumn("id", gorm.Expr("id")).Error; err != nil {
+				return err
+			}
+		}
+		if err := checkProjectQuota(tx, projectID, requested); err != nil {
+			return err
+		}
+		return reserve(tx)
+	})
+}
+
+func checkProjectQuota(db *gorm.DB, projectID uint, requested ResourceUsage) error {
+	if projectID == 0 {
+		return nil
+	}
+	quota, err := getProjectQuota(db, projectID)
+	if err != nil {
+		return err
+	}
+	if quota.ID == 0 {
+		return nil
+	}
+	used, err := getProjectUsage(db, projectID)
+	if err != nil {
+		return err
+	}
+
+	quotaErr := &QuotaExceededError{ProjectID: projectID}
+	check := func(name string, limit, used, requested float64) {
+		if limit > 0 && requested > 0 && used+requested > limit {
+			quotaErr.Violations = append(quotaErr.Violations, QuotaViolation{
+				Resource:  name,
+				Limit:     limit,
+				Used:      used,
+				Requested: requested,
+			})
+		}
+	}
+	check("cpu", quota.CPU, used.CPU, requested.CPU)
+	check("memory", quota.Memory, used.Memory, requested.Memory)
+	check("gpu", float64(quota.GPU), float64(used.GPU), float64(requested.GPU))
+	check("notebooks", float64(quota.MaxNotebooks), float64(used.Notebooks), float64(requested.Notebooks))
+	check("training_jobs", float64(quota.MaxTrainingJobs), float64(used.TrainingJobs), float64(requested.TrainingJobs))
+	check("triton_deploys", float64(quota.MaxTritonDeploys), float64(used.TritonDeploys), float64(requested.TritonDeploys))
+
+	if len(quotaErr.Violations) > 0 {
+		return quotaErr
+	}
+	return nil
+}
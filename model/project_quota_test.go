@@ -0,0 +1,104 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func setupQuotaDB(t *testing.T) {
+	setupTestDB(t, &Project{}, &ProjectQuota{}, &Notebook{}, &TrainingJob{}, &TritonDeploy{})
+}
+
+func TestWorkloadUsage(t *testing.T) {
+	notebook := NotebookUsage(&Notebook{ResourceMemory: "16Gi", ResourceGPU: 1})
+	if notebook != (ResourceUsage{CPU: 4, Memory: 16, GPU: 1, Notebooks: 1}) {
+		t.Errorf("notebook usage %+v", notebook)
+	}
+
+	job := TrainingJobUsage(&TrainingJob{CPULimit: "500m", MemoryLimit: "2Gi", GPUsPerNode: 1, WorkerReplicas: 3})
+	if job != (ResourceUsage{CPU: 2, Memory: 8, GPU: 4, TrainingJobs: 1}) {
+		t.Errorf("training job usage %+v", job)
+	}
+
+	deploy := TritonDeployUsage(&TritonDeploy{Replicas: 2, GPU: 1})
+	if deploy != (ResourceUsage{CPU: 4, Memory: 8, GPU: 2, TritonDeploys: 1}) {
+		t.Errorf("triton deploy usage %+v", deploy)
+	}
+
+	if delta := NotebookUsage(&Notebook{ResourceCPU: "8"}).Sub(NotebookUsage(&Notebook{})); delta != (ResourceUsage{CPU: 4}) {
+		t.Errorf("update delta %+v", delta)
+	}
+}
+
+func TestGetProjectUsage(t *testing.T) {
+	setupQuotaDB(t)
+	DB.Create(&Project{Model: gorm.Model{ID: 1}, Name: "p1"})
+	DB.Create(&Notebook{ProjectID: 1, UserID: 1, Name: "running", ResourceCPU: "2", ResourceMemory: "4Gi", Status: "Running"})
+	DB.Create(&Notebook{ProjectID: 1, UserID: 1, Name: "stopped", ResourceCPU: "8", ResourceMemory: "8Gi", Status: NotebookStatusStopped})
+	DB.Create(&TrainingJob{ProjectID: 1, Name: "running", CPULimit: "1", MemoryLimit: "1Gi", Status: "Running"})
+	DB.Create(&TrainingJob{ProjectID: 1, Name: "done", CPULimit: "1", MemoryLimit: "1Gi", Status: "Succeeded"})
+
+	usage, err := GetProjectUsage(1)
+	if err != nil {
+		t.Fatalf("GetProjectUsage: %v", err)
+	}
+	want := ResourceUsage{CPU: 4, Memory: 6, Notebooks: 2, TrainingJobs: 1}
+	if usage != want {
+		t.Errorf("usage %+v, want %+v", usage, want)
+	}
+}
+
+func TestReserveProjectQuota(t *testing.T) {
+	setupQuotaDB(t)
+	DB.Create(&Project{Model: gorm.Model{ID: 1}, Name: "p1"})
+	if err := SaveProjectQuota(&ProjectQuota{ProjectID: 1, CPU: 6, MaxNotebooks: 2}); err != nil {
+		t.Fatalf("SaveProjectQuota: %v", err)
+	}
+
+	create := func(name string) error {
+		notebook := Notebook{ProjectID: 1, UserID: 1, Name: name, ResourceCPU: "3"}
+		return ReserveProjectQuota(1, NotebookUsage(&notebook), func(tx *gorm.DB) error {
+			return tx.Create(&notebook).Error
+		})
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := create(name); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+
+	err := create("c")
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected QuotaExceededError, got %v", err)
+	}
+	if len(quotaErr.Violations) != 2 || quotaErr.Violations[0].Resource != "cpu" || quotaErr.Violations[0].Used != 6 {
+		t.Errorf("violations %+v", quotaErr.Violations)
+	}
+	var count int64
+	DB.Model(&Notebook{}).Count(&count)
+	if count != 2 {
+		t.Errorf("rejected reservation inserted a notebook, count %d", count)
+	}
+
+	// A stopped notebook only frees compute resources, and starting it reserves them again
+	var notebook Notebook
+	DB.Where("name = ?", "b").First(&notebook)
+	if err := notebook.MarkStopped(); err != nil {
+		t.Fatalf("MarkStopped: %v", err)
+	}
+	start := NotebookUsage(&notebook)
+	start.Notebooks = 0
+	if err := notebook.ReserveStart(start); err != nil {
+		t.Fatalf("ReserveStart: %v", err)
+	}
+	if err := notebook.ReserveStart(start); err == nil {
+		t.Error("second start exceeded the quota")
+	}
+	running := Notebook{ID: notebook.ID - 1}
+	if err := running.ReserveStart(ResourceUsage{}); !errors.Is(err, ErrNotebookNotStopped) {
+		t.Errorf("ReserveStart of a running notebook returned %v", err)
+	}
+}
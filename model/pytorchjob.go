@@ -8,30 +8,33 @@ import (
 )
 
 type TrainingJob struct {
-	ID              uint           `json:"id" gorm:"primaryKey"`
-	UserID          uint           `json:"user_id" gorm:"not null;index;constraint:OnDelete:RESTRICT"`
-	User            User           `json:"user" gorm:"foreignKey:UserID;references:ID"`
-	ProjectID       uint           `json:"project_id" gorm:"not null;index;constraint:OnDelete:RESTRICT"`
-	Project         Project        `json:"project" gorm:"foreignKey:ProjectID;references:ID"`
-	Name            string         `json:"name" gorm:"size:200;unique"`
-	Parameters      string         `json:"parameters" gorm:"type:text"` // JSON string for parameters
-	Image           string         `json:"image" gorm:"size:200;default:'''"`
-	ImagePullPolicy string         `json:"image_pull_policy" gorm:"size:200;default:'IfNotPresent'"`
-	Status          string         `json:"status" gorm:"size:50;default:'Pending'"`
-	Namespace       string         `json:"namespace" gorm:"size:200;default:'train'"`
-	RestartPolicy   string         `json:"restart_policy" gorm:"size:200;default:'OnFailure'"`
-	Command         string         `json:"command" gorm:"type:text"`        // JSON-encoded array of commands
-	Args            string         `json:"args,omitempty" gorm:"type:text"` // JSON-encoded array of arguments
-	MasterReplicas  int32          `json:"master_replicas"`                 // Number of master replicas
-	WorkerReplicas  int32          `json:"worker_replicas"`                 // Number of worker replicas
-	GPUsPerNode     int64          `json:"gpus_per_node"`                   // Number of GPUs per node
-	CPULimit        string         `json:"cpu_limit"`                       // CPU limit per container
-	MemoryLimit     string         `json:"memory_limit"`                    // Memory limit per container
-	NodeSelector    string         `json:"node_selector" gorm:"type:json"`  // JSON-encoded node selector
-	Env             string         `json:"env" gorm:"type:json"`            // JSON-encoded environment variables
-	CreatedAt       time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	UserID           uint           `json:"user_id" gorm:"not null;index;constraint:OnDelete:RESTRICT"`
+	User             User           `json:"user" gorm:"foreignKey:UserID;references:ID"`
+	ProjectID        uint           `json:"project_id" gorm:"not null;index;constraint:OnDelete:RESTRICT"`
+	Project          Project        `json:"project" gorm:"foreignKey:ProjectID;references:ID"`
+	Name             string         `json:"name" gorm:"size:200;unique"`
+	Parameters       string         `json:"parameters" gorm:"type:text"` // JSON string for parameters
+	Image            string         `json:"image" gorm:"size:200;default:'''"`
+	ImagePullPolicy  string         `json:"image_pull_policy" gorm:"size:200;default:'IfNotPresent'"`
+	Status           string         `json:"status" gorm:"size:50;default:'Pending'"`
+	ClusterID        uint           `json:"cluster_id" gorm:"index;default:0"` // 0 表示 config.yaml 中的集群
+	Namespace        string         `json:"namespace" gorm:"size:200;default:'train'"`
+	RestartPolicy    string         `json:"restart_policy" gorm:"size:200;default:'OnFailure'"`
+	Command          string         `json:"command" gorm:"type:text"`            // JSON-encoded array of commands
+	Args             string         `json:"args,omitempty" gorm:"type:text"`     // JSON-encoded array of arguments
+	MasterReplicas   int32          `json:"master_replicas"`                     // Number of master replicas
+	WorkerReplicas   int32          `json:"worker_replicas"`                     // Number of worker replicas
+	GPUsPerNode      int64          `json:"gpus_per_node"`                       // Number of GPUs per node
+	CPULimit         string         `json:"cpu_limit"`                           // CPU limit per container
+	MemoryLimit      string         `json:"memory_limit"`                        // Memory limit per container
+	NodeSelector     string         `json:"node_selector" gorm:"type:json"`      // JSON-encoded node selector
+	Env              string         `json:"env" gorm:"type:json"`                // JSON-encoded environment variables
+	DatasetID        uint           `json:"dataset_id" gorm:"index;default:0"`   // 训练使用的数据集
+	DatasetVersionID uint           `json:"dataset_version_id" gorm:"default:0"` // 固定的数据集版本，保证可复现
+	CreatedAt        time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // Insert creates a new TrainingJob
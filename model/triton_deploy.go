@@ -9,6 +9,7 @@ import (
 type TritonDeploy struct {
 	gorm.Model
 	Name      string  `json:"name" gorm:"size:200;unique"`
+	ClusterID uint    `json:"cluster_id" gorm:"index;default:0"` // 0 表示 config.yaml 中的集群
 	Namespace string  `json:"namespace" gorm:"size:200;default:'triton-serving'"`
 	Image     string  `json:"image" gorm:"size:200"`
 	Replicas  int32   `json:"replicas" gorm:"default:1"`
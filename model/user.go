@@ -121,8 +121,10 @@ func (user *User) Delete() error {
 	if user.ID == 0 {
 		return errors.New("id 为空！")
 	}
-	err := DB.Delete(user).Error
-	return err
+	if err := DB.Delete(user).Error; err != nil {
+		return err
+	}
+	return RevokeUserTokens(int(user.ID))
 }
 
 // ValidateAndFill check password & user status
@@ -279,5 +281,18 @@ func ResetUserPasswordByEmail(email string, password string) error {
 		return err
 	}
 	err = DB.Model(&User{}).Where("email = ?", email).Update("password", hashedPassword).Error
-	return err
+	if err != nil {
+		return err
+	}
+	// 重置密码后已签发的令牌全部失效
+	var ids []int
+	if err := DB.Model(&User{}).Where("email = ?", email).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := RevokeUserTokens(id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
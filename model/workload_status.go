@@ -0,0 +1,27 @@
+package model
+
+// WorkloadStatusStore 将 Kubernetes 中观察到的状态写回数据库
+// 只有状态发生变化时才会更新，避免 informer 重新同步时产生无意义的写入
+type WorkloadStatusStore struct{}
+
+// SetNotebookStatus updates the status of the Notebook with the given name
+// Stopped notebooks are left alone so late pod events do not overwrite the status
+func (WorkloadStatusStore) SetNotebookStatus(name, status string) error {
+	return DB.Model(&Notebook{}).
+		Where("name = ? AND status <> ? AND status <> ?", name, status, NotebookStatusStopped).
+		Update("status", status).Error
+}
+
+// SetTrainingJobStatus updates the status of the TrainingJob with the given name
+func (WorkloadStatusStore) SetTrainingJobStatus(name, status string) error {
+	return DB.Model(&TrainingJob{}).
+		Where("name = ? AND status <> ?", name, status).
+		Update("status", status).Error
+}
+
+// SetTritonDeployStatus updates the status of the TritonDeploy with the given name
+func (WorkloadStatusStore) SetTritonDeployStatus(name, status string) error {
+	return DB.Model(&TritonDeploy{}).
+		Where("name = ? AND status <> ?", name, status).
+		Update("status", status).Error
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"MLcore-Engine/controller"
 	"MLcore-Engine/middleware"
+	"MLcore-Engine/model"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,6 +13,7 @@ func SetApiRouter(router *gin.Engine) {
 	router.Use(middleware.URLNormalizer())
 	apiRouter := router.Group("/api")
 	apiRouter.Use(middleware.GlobalAPIRateLimit())
+	apiRouter.Use(middleware.AuditLog())
 	{
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
@@ -30,6 +32,8 @@ func SetApiRouter(router *gin.Engine) {
 			userRoute.POST("/register", middleware.CriticalRateLimit(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), controller.Login)
 			userRoute.GET("/logout", controller.Logout)
+			userRoute.POST("/logout", controller.Logout)
+			userRoute.POST("/refresh", middleware.CriticalRateLimit(), controller.RefreshToken)
 
 			selfRoute := userRoute.Group("/")
 			{
@@ -38,6 +42,15 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 			}
 
+			// 个人访问令牌
+			tokenRoute := userRoute.Group("/self/tokens")
+			tokenRoute.Use(middleware.UserAuth())
+			{
+				tokenRoute.GET("/", controller.ListAccessTokens)
+				tokenRoute.POST("/", controller.CreateAccessToken)
+				tokenRoute.DELETE("/:id", controller.RevokeAccessToken)
+			}
+
 			userManageRoute := userRoute.Group("/manage")
 			userManageRoute.Use(middleware.AdminAuth())
 			{
@@ -80,9 +93,9 @@ func SetApiRouter(router *gin.Engine) {
 		projectRoute.Use(middleware.UserAuth())
 		{
 			projectRoute.POST("/", controller.CreateProject)
-			projectRoute.PUT("/:id", controller.UpdateProject)
-			projectRoute.DELETE("/:id", controller.DeleteProject)
-			projectRoute.GET("/:id", controller.GetProject)
+			projectRoute.PUT("/:id", middleware.ProjectPermission(model.ResourceProject, "id", model.ActionManage), controller.UpdateProject)
+			projectRoute.DELETE("/:id", middleware.ProjectPermission(model.ResourceProject, "id", model.ActionManage), controller.DeleteProject)
+			projectRoute.GET("/:id", middleware.ProjectPermission(model.ResourceProject, "id", model.ActionView), controller.GetProject)
 			projectRoute.GET("/get-all", controller.ListProjects)
 		}
 
@@ -90,66 +103,157 @@ func SetApiRouter(router *gin.Engine) {
 		projectMembersRoute.Use(middleware.UserAuth())
 		{
 			projectMembersRoute.GET("/user/:userId", controller.GetUserProjects)
-			projectMembersRoute.POST("/", controller.AddUserToProject)
-			projectMembersRoute.DELETE("/:projectId/:userId", controller.RemoveUserFromProject)
-			projectMembersRoute.PUT("/", controller.UpdateUserProjectRole)
-			projectMembersRoute.GET("/project/:projectId", controller.GetProjectMembers)
+			projectMembersRoute.POST("/", middleware.ProjectPermissionFromBody("projectId", model.ActionManage), controller.AddUserToProject)
+			projectMembersRoute.DELETE("/:projectId/:userId", middleware.ProjectPermission(model.ResourceProject, "projectId", model.ActionManage), controller.RemoveUserFromProject)
+			projectMembersRoute.PUT("/", middleware.ProjectPermissionFromBody("projectId", model.ActionManage), controller.UpdateUserProjectRole)
+			projectMembersRoute.GET("/project/:projectId", middleware.ProjectPermission(model.ResourceProject, "projectId", model.ActionView), controller.GetProjectMembers)
+		}
+
+		quotaRoute := apiRouter.Group("/quota")
+		quotaRoute.Use(middleware.AdminAuth())
+		{
+			quotaRoute.GET("/", controller.ListProjectQuotas)
+			quotaRoute.GET("/:projectId", controller.GetProjectQuota)
+			quotaRoute.PUT("/:projectId", controller.UpdateProjectQuota)
+		}
+
+		auditRoute := apiRouter.Group("/audit")
+		{
+			auditRoute.GET("/", middleware.AdminAuth(), controller.ListAuditLogs)
+			auditRoute.GET("/project/:projectId", middleware.UserAuth(), middleware.ProjectPermission(model.ResourceProject, "projectId", model.ActionManage), controller.ListProjectAuditLogs)
+		}
+
+		clusterRoute := apiRouter.Group("/cluster")
+		clusterRoute.Use(middleware.AdminAuth())
+		{
+			clusterRoute.GET("/", controller.ListClusters)
+			clusterRoute.POST("/", controller.CreateCluster)
+			clusterRoute.GET("/:id", controller.GetCluster)
+			clusterRoute.PUT("/:id", controller.UpdateCluster)
+			clusterRoute.DELETE("/:id", controller.DeleteCluster)
+			clusterRoute.GET("/nodes", controller.ListClusterNodes)
+			clusterRoute.PUT("/nodes/labels", controller.LabelClusterNodes)
 		}
 
 		notebookRoute := apiRouter.Group("/notebook")
 		notebookRoute.Use(middleware.UserAuth())
 		{
-			notebookRoute.POST("/", controller.CreateNotebook)
+			notebookRoute.POST("/", middleware.ProjectPermissionFromBody("project_id", model.ActionCreate), controller.CreateNotebook)
 			// notebookRoute.PUT("/:id", controller.UpdateNotebook)
-			notebookRoute.DELETE("/:id", controller.DeleteNotebook)
+			notebookRoute.DELETE("/:id", notebookPermission(model.ActionDelete), controller.DeleteNotebook)
 			// notebookRoute.GET("/:id", controller.GetNotebook)
 			notebookRoute.GET("/get-all", controller.ListNotebooks)
-			notebookRoute.GET("/reset/:id", controller.ResetNotebook)
+			notebookRoute.GET("/reset/:id", notebookPermission(model.ActionUpdate), controller.ResetNotebook)
+			notebookRoute.GET("/:id/logs", notebookPermission(model.ActionView), controller.GetNotebookLogs)
+			notebookRoute.POST("/:id/stop", notebookPermission(model.ActionUpdate), controller.StopNotebook)
+			notebookRoute.POST("/:id/start", notebookPermission(model.ActionUpdate), controller.StartNotebook)
+			notebookRoute.PUT("/:id/policy", notebookPermission(model.ActionUpdate), controller.UpdateNotebookPolicy)
 		}
 
 		pytorchJobRoute := apiRouter.Group("/pytorchtrain")
-		pytorchJobRoute.Use(middleware.UserAuth())
+		pytorchJobRoute.Use(middleware.ScopedUserAuth(model.ScopeTrainSubmit, model.ScopeTrainSubmit))
 		{
-			pytorchJobRoute.POST("/", controller.CreateTrainingJob)
-			pytorchJobRoute.DELETE("/:id", controller.DeleteTrainingJob)
-			pytorchJobRoute.GET("/:id", controller.GetTrainingJob)
+			pytorchJobRoute.POST("/", middleware.ProjectPermissionFromBody("project_id", model.ActionCreate), controller.CreateTrainingJob)
+			pytorchJobRoute.DELETE("/:id", trainingJobPermission(model.ActionDelete), controller.DeleteTrainingJob)
+			pytorchJobRoute.GET("/:id", trainingJobPermission(model.ActionView), controller.GetTrainingJob)
 			pytorchJobRoute.GET("/get-all", controller.ListTrainingJobs)
+			pytorchJobRoute.GET("/:id/logs", trainingJobPermission(model.ActionView), controller.GetTrainingJobLogs)
 		}
 
 		tritonDeployRoute := apiRouter.Group("/triton")
-		tritonDeployRoute.Use(middleware.UserAuth())
+		tritonDeployRoute.Use(middleware.ScopedUserAuth(model.ScopeTritonDeploy, model.ScopeTritonDeploy))
 		{
-			tritonDeployRoute.POST("/", controller.CreateTritonDeploy)
-			tritonDeployRoute.DELETE("/:id", controller.DeleteTritonDeploy)
-			tritonDeployRoute.PUT("/:id", controller.UpdateTritonDeploy)
+			tritonDeployRoute.POST("/", middleware.ProjectPermissionFromBody("project_id", model.ActionCreate), controller.CreateTritonDeploy)
+			tritonDeployRoute.DELETE("/:id", tritonPermission(model.ActionDelete), controller.DeleteTritonDeploy)
+			tritonDeployRoute.PUT("/:id", tritonPermission(model.ActionUpdate), controller.UpdateTritonDeploy)
 			// tritonDeployRoute.GET("/:id", controller.GetTritonDeploy)
 			tritonDeployRoute.GET("/get-all", controller.ListTritonDeploys)
 			tritonDeployRoute.GET("/config", controller.GetTritonConfig)
+			tritonDeployRoute.GET("/:id/logs", tritonPermission(model.ActionView), controller.GetTritonDeployLogs)
 		}
 
 		// router/api_router.go 中添加
 		datasetRoute := apiRouter.Group("/dataset")
-		datasetRoute.Use(middleware.UserAuth())
+		datasetRoute.Use(middleware.ScopedUserAuth(model.ScopeDatasetRead, model.ScopeDatasetWrite))
 		{
-			datasetRoute.POST("/", controller.CreateDataset)
+			datasetRoute.POST("/", middleware.ProjectPermissionFromBody("project_id", model.ActionCreate), controller.CreateDataset)
 			datasetRoute.GET("/", controller.ListDatasets)
-			datasetRoute.GET("/:id", controller.GetDataset)
-			datasetRoute.PUT("/:id", controller.UpdateDataset)
-			datasetRoute.DELETE("/:id", controller.DeleteDataset)
+			datasetRoute.GET("/:id", datasetPermission(model.ActionView), controller.GetDataset)
+			datasetRoute.PUT("/:id", datasetPermission(model.ActionUpdate), controller.UpdateDataset)
+			datasetRoute.DELETE("/:id", datasetPermission(model.ActionDelete), controller.DeleteDataset)
 			datasetRoute.GET("/search", controller.SearchDatasets)
-			datasetRoute.GET("/project/:projectId", controller.GetProjectDatasets)
+			datasetRoute.GET("/project/:projectId", middleware.ProjectPermission(model.ResourceProject, "projectId", model.ActionView), controller.GetProjectDatasets)
 
 			// 数据条目相关路由
-			datasetRoute.GET("/:id/entries", controller.GetDatasetEntries)
-			datasetRoute.GET("/:id/entry/:entryId", controller.GetDatasetEntry)
-			datasetRoute.POST("/:id/entry", controller.CreateDatasetEntry)
-			datasetRoute.PUT("/:id/entry/:entryId", controller.UpdateDatasetEntry)
-			datasetRoute.DELETE("/:id/entry/:entryId", controller.DeleteDatasetEntry)
+			datasetRoute.GET("/:id/entries", datasetPermission(model.ActionView), controller.GetDatasetEntries)
+			datasetRoute.GET("/:id/entry/:entryId", datasetPermission(model.ActionView), controller.GetDatasetEntry)
+			datasetRoute.POST("/:id/entry", datasetPermission(model.ActionUpdate), controller.CreateDatasetEntry)
+			datasetRoute.PUT("/:id/entry/:entryId", datasetPermission(model.ActionUpdate), controller.UpdateDatasetEntry)
+			datasetRoute.DELETE("/:id/entry/:entryId", datasetPermission(model.ActionUpdate), controller.DeleteDatasetEntry)
+			datasetRoute.GET("/:id/entry/:entryId/history", datasetPermission(model.ActionView), controller.GetDatasetEntryHistory)
+			datasetRoute.POST("/:id/entry/:entryId/revert", datasetPermission(model.ActionUpdate), controller.RevertDatasetEntry)
+			datasetRoute.POST("/:id/entry/:entryId/restore", datasetPermission(model.ActionUpdate), controller.RestoreDatasetEntry)
+			datasetRoute.POST("/:id/entries/bulk", datasetPermission(model.ActionUpdate), controller.BulkDatasetEntries)
+			datasetRoute.GET("/:id/entries/deleted", datasetPermission(model.ActionView), controller.ListDeletedDatasetEntries)
+			datasetRoute.POST("/:id/entries/deleted/:revisionId/restore", datasetPermission(model.ActionUpdate), controller.RestoreDeletedDatasetEntry)
+			datasetRoute.GET("/:id/changelog", datasetPermission(model.ActionView), controller.GetDatasetChangelog)
 
 			// 导入导出相关路由
-			datasetRoute.POST("/:id/import", middleware.UploadRateLimit(), controller.ImportDataset)
-			datasetRoute.GET("/:id/export", controller.ExportDataset)
+			datasetRoute.POST("/:id/import", middleware.UploadRateLimit(), datasetPermission(model.ActionUpdate), controller.ImportDataset)
+			datasetRoute.GET("/:id/export", datasetPermission(model.ActionView), controller.ExportDataset)
+			datasetRoute.POST("/:id/export", datasetPermission(model.ActionView), controller.CreateDatasetExportJob)
+			datasetRoute.GET("/jobs/:id", datasetJobPermission(model.ActionView), controller.GetDatasetJob)
+			datasetRoute.POST("/jobs/:id/cancel", datasetJobPermission(model.ActionUpdate), controller.CancelDatasetJob)
+
+			// 质量分析相关路由
+			datasetRoute.POST("/:id/analyze", datasetPermission(model.ActionView), controller.AnalyzeDataset)
+			datasetRoute.GET("/:id/analysis", datasetPermission(model.ActionView), controller.GetDatasetAnalysis)
+			datasetRoute.POST("/:id/analysis/delete", datasetPermission(model.ActionUpdate), controller.DeleteFlaggedEntries)
+
+			// 切分和筛选生成新数据集
+			datasetRoute.POST("/:id/split", datasetPermission(model.ActionCreate), controller.SplitDataset)
+			datasetRoute.POST("/:id/subset", datasetPermission(model.ActionCreate), controller.SubsetDataset)
+
+			// 合并处理多个数据集
+			datasetRoute.POST("/pipeline", middleware.ProjectPermissionFromBody("project_id", model.ActionCreate), controller.RunDatasetPipeline)
+			datasetRoute.POST("/:id/pipeline/rerun", datasetPermission(model.ActionView), controller.RerunDatasetPipeline)
+
+			// 标注相关路由
+			datasetRoute.POST("/:id/annotation/next", datasetPermission(model.ActionCreate), controller.ClaimDatasetAnnotation)
+			datasetRoute.GET("/:id/annotation/entry/:entryIndex", datasetPermission(model.ActionView), controller.GetDatasetAnnotation)
+			datasetRoute.POST("/:id/annotation/entry/:entryIndex/submit", datasetPermission(model.ActionCreate), controller.SubmitDatasetAnnotation)
+			datasetRoute.POST("/:id/annotation/entry/:entryIndex/review", datasetPermission(model.ActionUpdate), controller.ReviewDatasetAnnotation)
+			datasetRoute.GET("/:id/annotations", datasetPermission(model.ActionView), controller.ListDatasetAnnotations)
+			datasetRoute.GET("/:id/annotation/stats", datasetPermission(model.ActionView), controller.GetDatasetAnnotationStats)
+			datasetRoute.GET("/:id/annotation/disagreements", datasetPermission(model.ActionView), controller.GetDatasetAnnotationDisagreements)
+
+			// 版本相关路由
+			datasetRoute.POST("/:id/versions", datasetPermission(model.ActionUpdate), controller.CreateDatasetVersion)
+			datasetRoute.GET("/:id/versions", datasetPermission(model.ActionView), controller.ListDatasetVersions)
+			datasetRoute.GET("/:id/versions/diff", datasetPermission(model.ActionView), controller.DiffDatasetVersions)
+			datasetRoute.PUT("/:id/versions/:vid/activate", datasetPermission(model.ActionUpdate), controller.ActivateDatasetVersion)
 		}
 
 	}
 }
+
+// 各资源路由统一使用路径参数 id 解析所属项目
+func datasetPermission(action model.ProjectAction) gin.HandlerFunc {
+	return middleware.ProjectPermission(model.ResourceDataset, "id", action)
+}
+
+func datasetJobPermission(action model.ProjectAction) gin.HandlerFunc {
+	return middleware.ProjectPermission(model.ResourceDatasetJob, "id", action)
+}
+
+func notebookPermission(action model.ProjectAction) gin.HandlerFunc {
+	return middleware.ProjectPermission(model.ResourceNotebook, "id", action)
+}
+
+func trainingJobPermission(action model.ProjectAction) gin.HandlerFunc {
+	return middleware.ProjectPermission(model.ResourceTrainingJob, "id", action)
+}
+
+func tritonPermission(action model.ProjectAction) gin.HandlerFunc {
+	return middleware.ProjectPermission(model.ResourceTritonDeploy, "id", action)
+}
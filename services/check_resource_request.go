@@ -3,98 +3,184 @@ package services
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// DefaultGPUResourceName 未配置集群 GPU 资源名时使用的扩展资源名
+const DefaultGPUResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+// ResourceRequest 描述单个 Pod 的资源申请
+// Replicas 为需要同时调度的 Pod 数量(默认 1)，NodeSelector 为空时检查所有节点
 type ResourceRequest struct {
-	CPU    resource.Quantity
-	Memory resource.Quantity
-	GPU    resource.Quantity
+	CPU          resource.Quantity
+	Memory       resource.Quantity
+	GPU          resource.Quantity
+	Replicas     int
+	NodeSelector map[string]string
+}
+
+// NodeFreeResource 节点剩余可分配的资源
+type NodeFreeResource struct {
+	Name   string `json:"name"`
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+	GPU    int64  `json:"gpu"`
+
+	cpu    resource.Quantity
+	memory resource.Quantity
+	gpu    resource.Quantity
+	fits   int // 该节点最多可容纳的副本数
+}
+
+// CapacityResult 集群容量检查结果
+// BestFit 为能容纳最多副本的节点，资源不足时用于提示用户
+type CapacityResult struct {
+	Fits    bool              `json:"fits"`
+	BestFit *NodeFreeResource `json:"best_fit_node,omitempty"`
 }
 
 func (k *K8s) CheckClusterResource(request ResourceRequest) (bool, error) {
+	result, err := k.CheckClusterCapacity(request)
+	if err != nil {
+		return false, err
+	}
+	return result.Fits, nil
+}
 
-	nodes, err := k.clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+// CheckClusterCapacity 检查匹配 NodeSelector 的节点剩余资源能否容纳全部副本
+func (k *K8s) CheckClusterCapacity(request ResourceRequest) (*CapacityResult, error) {
+	if request.Replicas <= 0 {
+		request.Replicas = 1
+	}
+
+	nodes, err := k.clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(request.NodeSelector).String(),
+	})
 	if err != nil {
-		return false, fmt.Errorf("failed to get nodes: %v", err)
+		return nil, fmt.Errorf("failed to get nodes: %v", err)
 	}
 
-	for _, node := range nodes.Items {
-		if k.canScheduleOnNode(&node, request) {
-			return true, nil
+	pods, err := k.clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pods: %v", err)
+	}
+	podsByNode := make(map[string][]corev1.Pod)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
 		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
 	}
-	return false, nil
 
-}
+	result := &CapacityResult{}
+	candidates := make([]*NodeFreeResource, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if node.Spec.Unschedulable {
+			continue
+		}
+		free := nodeFreeResource(node, podsByNode[node.Name], k.GPUResourceName())
+		free.fits = replicasOnNode(free, request)
+		candidates = append(candidates, free)
+	}
+	if len(candidates) == 0 {
+		return result, nil
+	}
 
-func (k *K8s) canScheduleOnNode(node *corev1.Node, request ResourceRequest) bool {
+	// 容纳副本最多的节点优先，其次比较 GPU、CPU、内存余量
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.fits != b.fits {
+			return a.fits > b.fits
+		}
+		if c := a.gpu.Cmp(b.gpu); c != 0 {
+			return c > 0
+		}
+		if c := a.cpu.Cmp(b.cpu); c != 0 {
+			return c > 0
+		}
+		return a.memory.Cmp(b.memory) > 0
+	})
+	result.BestFit = candidates[0]
 
-	allocatable := node.Status.Allocatable
-	if allocatable.Cpu().Cmp(request.CPU) < 0 ||
-		allocatable.Memory().Cmp(request.Memory) < 0 {
-		return false
+	placed := 0
+	for _, candidate := range candidates {
+		placed += candidate.fits
+		if placed >= request.Replicas {
+			result.Fits = true
+			break
+		}
 	}
+	return result, nil
+}
 
-	// 检查 GPU 资源
-	if request.GPU.Cmp(resource.MustParse("0")) > 0 {
-		if gpuQuantity, exists := allocatable["nvidia.com/gpu"]; exists {
-			if (&gpuQuantity).Cmp(request.GPU) < 0 {
-				return false
-			}
-		} else if !request.GPU.IsZero() {
-			return false
-		}
+// nodeFreeResource 计算节点可分配资源减去已调度 Pod 的申请量
+func nodeFreeResource(node *corev1.Node, pods []corev1.Pod, gpuResourceName corev1.ResourceName) *NodeFreeResource {
+	allocatable := node.Status.Allocatable
+	free := &NodeFreeResource{
+		Name:   node.Name,
+		cpu:    allocatable.Cpu().DeepCopy(),
+		memory: allocatable.Memory().DeepCopy(),
 	}
-	// 获取节点上运行的所有 Pod
-	pods, err := k.clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
-		FieldSelector: "spec.nodeName=" + node.Name,
-	})
-	if err != nil {
-		// 处理错误，这里简单地返回 false
-		return false
+	if gpuQuantity, exists := allocatable[gpuResourceName]; exists {
+		free.gpu = gpuQuantity.DeepCopy()
 	}
 
-	// 计算节点上已使用的资源
-	var usedCPU, usedMemory, usedGPU resource.Quantity
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			usedCPU.Add(*container.Resources.Requests.Cpu())
-			usedMemory.Add(*container.Resources.Requests.Memory())
-			if gpuQuantity, exists := container.Resources.Requests["nvidia.com/gpu"]; exists {
-				usedGPU.Add(gpuQuantity)
-			}
+	for i := range pods {
+		requests := podRequests(&pods[i])
+		free.cpu.Sub(*requests.Cpu())
+		free.memory.Sub(*requests.Memory())
+		if gpuQuantity, exists := requests[gpuResourceName]; exists {
+			free.gpu.Sub(gpuQuantity)
 		}
 	}
 
-	// 计算剩余可用资源
-	availableCPU := allocatable.Cpu().DeepCopy()
-	availableCPU.Sub(usedCPU)
-	availableMemory := allocatable.Memory().DeepCopy()
-	availableMemory.Sub(usedMemory)
+	free.CPU = free.cpu.String()
+	free.Memory = free.memory.String()
+	free.GPU = free.gpu.Value()
+	return free
+}
 
-	// 检查是否有足够的剩余 CPU 和内存资源
-	if availableCPU.Cmp(request.CPU) < 0 || availableMemory.Cmp(request.Memory) < 0 {
-		return false
+// podRequests 汇总 Pod 内所有容器申请的资源
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			total := requests[name]
+			total.Add(quantity)
+			requests[name] = total
+		}
 	}
+	return requests
+}
 
-	// 只有当请求的 GPU 大于 0 时才检查 GPU 资源
-	if request.GPU.Cmp(resource.MustParse("0")) > 0 {
-		if gpuQuantity, exists := allocatable["nvidia.com/gpu"]; exists {
-			availableGPU := gpuQuantity.DeepCopy()
-			availableGPU.Sub(usedGPU)
-			if availableGPU.Cmp(request.GPU) < 0 {
-				return false
-			}
-		} else {
-			// 如果请求 GPU 但节点没有 GPU 资源，返回 false
-			return false
+// replicasOnNode 返回节点剩余资源最多可容纳的副本数
+func replicasOnNode(free *NodeFreeResource, request ResourceRequest) int {
+	fits := -1
+	limit := func(available, requested resource.Quantity) {
+		if requested.Sign() <= 0 {
+			return
+		}
+		n := 0
+		if available.Sign() > 0 {
+			n = int(available.MilliValue() / requested.MilliValue())
+		}
+		if fits < 0 || n < fits {
+			fits = n
 		}
 	}
+	limit(free.cpu, request.CPU)
+	limit(free.memory, request.Memory)
+	limit(free.gpu, request.GPU)
 
-	// 如果所有检查都通过，返回 true
-	return true
+	if fits < 0 {
+		// 不申请任何资源时不受节点容量限制
+		return request.Replicas
+	}
+	return fits
 }
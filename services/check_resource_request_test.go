@@ -3,8 +3,11 @@ package services
 import (
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -74,3 +77,144 @@ func TestCheckClusterResource(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckClusterCapacity(t *testing.T) {
+	newNode := func(name string, labels map[string]string, cpu, memory, gpu string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+					"nvidia.com/gpu":      resource.MustParse(gpu),
+				},
+			},
+		}
+	}
+
+	clientset := fake.NewSimpleClientset(
+		newNode("cpu-node", map[string]string{"notebook": "true"}, "8", "32Gi", "0"),
+		newNode("gpu-node", map[string]string{"train": "true"}, "16", "64Gi", "2"),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "busy", Namespace: "train"},
+			Spec: corev1.PodSpec{
+				NodeName: "gpu-node",
+				Containers: []corev1.Container{{
+					Name: "busy",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("4"),
+						corev1.ResourceMemory: resource.MustParse("16Gi"),
+						"nvidia.com/gpu":      resource.MustParse("1"),
+					}},
+				}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "finished", Namespace: "train"},
+			Spec: corev1.PodSpec{
+				NodeName: "cpu-node",
+				Containers: []corev1.Container{{
+					Name: "finished",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("8"),
+					}},
+				}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodSucceeded},
+		},
+	)
+	k8s := &K8s{clientset: clientset}
+
+	testCases := []struct {
+		name         string
+		request      ResourceRequest
+		expectedFits bool
+		expectedNode string
+	}{
+		{
+			name: "Fits on selected node ignoring finished pods",
+			request: ResourceRequest{
+				CPU: resource.MustParse("8"), Memory: resource.MustParse("8Gi"),
+				NodeSelector: map[string]string{"notebook": "true"},
+			},
+			expectedFits: true,
+			expectedNode: "cpu-node",
+		},
+		{
+			name: "GPU not available on selected node",
+			request: ResourceRequest{
+				CPU: resource.MustParse("1"), Memory: resource.MustParse("1Gi"), GPU: resource.MustParse("1"),
+				NodeSelector: map[string]string{"notebook": "true"},
+			},
+			expectedFits: false,
+			expectedNode: "cpu-node",
+		},
+		{
+			name: "Replicas exceed remaining GPUs",
+			request: ResourceRequest{
+				CPU: resource.MustParse("2"), Memory: resource.MustParse("4Gi"), GPU: resource.MustParse("1"),
+				Replicas: 2,
+			},
+			expectedFits: false,
+			expectedNode: "gpu-node",
+		},
+		{
+			name: "Replicas spread across nodes",
+			request: ResourceRequest{
+				CPU: resource.MustParse("6"), Memory: resource.MustParse("8Gi"),
+				Replicas: 3,
+			},
+			expectedFits: true,
+			expectedNode: "gpu-node",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := k8s.CheckClusterCapacity(tc.request)
+			if err != nil {
+				t.Fatalf("Error checking cluster capacity: %v", err)
+			}
+			if result.Fits != tc.expectedFits {
+				t.Errorf("Expected fits %v, but got %v", tc.expectedFits, result.Fits)
+			}
+			if result.BestFit == nil || result.BestFit.Name != tc.expectedNode {
+				t.Errorf("Expected best fit node '%s', got %+v", tc.expectedNode, result.BestFit)
+			}
+		})
+	}
+}
+
+func TestCheckClusterCapacityCustomGPUResource(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "amd-node"},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("16"),
+				corev1.ResourceMemory: resource.MustParse("64Gi"),
+				"amd.com/gpu":         resource.MustParse("2"),
+			},
+		},
+	})
+	k8s := &K8s{clientset: clientset}
+	request := ResourceRequest{CPU: resource.MustParse("1"), Memory: resource.MustParse("1Gi"), GPU: resource.MustParse("2")}
+
+	// 默认按 nvidia.com/gpu 统计，AMD 节点上没有可用 GPU
+	result, err := k8s.CheckClusterCapacity(request)
+	if err != nil {
+		t.Fatalf("CheckClusterCapacity failed: %v", err)
+	}
+	if result.Fits {
+		t.Errorf("expected request not to fit with default GPU resource name")
+	}
+
+	k8s.SetGPUResourceName("amd.com/gpu")
+	result, err = k8s.CheckClusterCapacity(request)
+	if err != nil {
+		t.Fatalf("CheckClusterCapacity failed: %v", err)
+	}
+	if !result.Fits || result.BestFit.GPU != 2 {
+		t.Errorf("expected request to fit on amd-node with 2 GPUs, got %+v", result.BestFit)
+	}
+}
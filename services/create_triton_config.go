@@ -36,6 +36,7 @@ type TritonConfig struct {
 	// GPU Configuration
 	GpuMemoryFraction             float64 `json:"gpu_memory_fraction"`
 	MinSupportedComputeCapability float64 `json:"min_supported_compute_capability"`
+	GPUResourceName               string  `json:"gpu_resource_name,omitempty"` // 为空时使用 nvidia.com/gpu
 
 	// Logging Configuration
 	LogVerbose int  `json:"log_verbose"`
@@ -51,6 +52,10 @@ func GetTritonDeployment(name, namespace, image string, replicas int32, labels s
 	if err := json.Unmarshal([]byte(labels), &labelsMap); err != nil {
 		return nil, fmt.Errorf("failed to parse labels: %v", err)
 	}
+	gpuResourceName := DefaultGPUResourceName
+	if config.GPUResourceName != "" {
+		gpuResourceName = corev1.ResourceName(config.GPUResourceName)
+	}
 
 	args := []string{
 		fmt.Sprintf("--model-repository=%s", config.ModelRepository),
@@ -141,12 +146,12 @@ func GetTritonDeployment(name, namespace, image string, replicas int32, labels s
 								Limits: corev1.ResourceList{
 									corev1.ResourceCPU:    resourceQuantity(cpu),
 									corev1.ResourceMemory: resourceQuantity(memory * 1024 * 1024 * 1024), // Convert to bytes
-									"nvidia.com/gpu":      resourceQuantity(gpu),
+									gpuResourceName:       resourceQuantity(gpu),
 								},
 								Requests: corev1.ResourceList{
 									corev1.ResourceCPU:    resourceQuantity(cpu),
 									corev1.ResourceMemory: resourceQuantity(memory * 1024 * 1024 * 1024), // Convert to bytes
-									"nvidia.com/gpu":      resourceQuantity(gpu),
+									gpuResourceName:       resourceQuantity(gpu),
 								},
 							},
 						},
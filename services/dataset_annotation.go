@@ -0,0 +1,70 @@
+package services
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// AnnotationDiff 返回多位标注人提交的记录中取值不同的字段，按字段名排序；全部一致时返回 nil
+// 字符串去掉首尾空白后比较，缺少的字段视为空值，其他类型按 JSON 编码比较
+func AnnotationDiff(submissions []map[string]interface{}) []string {
+	if len(submissions) < 2 {
+		return nil
+	}
+	keys := map[string]struct{}{}
+	for _, fields := range submissions {
+		for key := range fields {
+			keys[key] = struct{}{}
+		}
+	}
+
+	var diff []string
+	for key := range keys {
+		first := annotationValue(submissions[0][key])
+		for _, fields := range submissions[1:] {
+			if annotationValue(fields[key]) != first {
+				diff = append(diff, key)
+				break
+			}
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func annotationValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// AnnotationAgreement 多人标注的一致性统计
+type AnnotationAgreement struct {
+	Entries int64   `json:"entries"` // 已有多位标注人提交的条目数
+	Agreed  int64   `json:"agreed"`  // 各标注人结果完全一致的条目数
+	Rate    float64 `json:"rate"`    // Agreed / Entries，没有多人标注的条目时为 0
+	// 各字段出现不一致的条目数
+	FieldDisagreements map[string]int64 `json:"field_disagreements"`
+}
+
+// Add 计入一个条目的比较结果，diff 为 AnnotationDiff 的返回值
+func (a *AnnotationAgreement) Add(diff []string) {
+	if a.FieldDisagreements == nil {
+		a.FieldDisagreements = map[string]int64{}
+	}
+	a.Entries++
+	if len(diff) == 0 {
+		a.Agreed++
+	}
+	for _, field := range diff {
+		a.FieldDisagreements[field]++
+	}
+	a.Rate = float64(a.Agreed) / float64(a.Entries)
+}
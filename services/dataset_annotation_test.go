@@ -0,0 +1,43 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnnotationDiff(t *testing.T) {
+	agreed := []map[string]interface{}{
+		{"instruction": "Translate", "output": " Bonjour ", "tags": []interface{}{"fr"}},
+		{"instruction": "Translate", "output": "Bonjour", "tags": []interface{}{"fr"}},
+	}
+	if diff := AnnotationDiff(agreed); diff != nil {
+		t.Errorf("expected no diff, got %v", diff)
+	}
+
+	disagreed := []map[string]interface{}{
+		{"instruction": "Translate", "output": "Bonjour", "label": "good"},
+		{"instruction": "Translate", "output": "Salut", "tags": []interface{}{"fr"}},
+	}
+	if diff := AnnotationDiff(disagreed); !reflect.DeepEqual(diff, []string{"label", "output", "tags"}) {
+		t.Errorf("diff %v", diff)
+	}
+
+	if diff := AnnotationDiff(disagreed[:1]); diff != nil {
+		t.Errorf("single submission should not differ, got %v", diff)
+	}
+}
+
+func TestAnnotationAgreement(t *testing.T) {
+	var agreement AnnotationAgreement
+	agreement.Add(nil)
+	agreement.Add([]string{"output"})
+	agreement.Add([]string{"label", "output"})
+	agreement.Add(nil)
+
+	if agreement.Entries != 4 || agreement.Agreed != 2 || agreement.Rate != 0.5 {
+		t.Errorf("agreement %+v", agreement)
+	}
+	if !reflect.DeepEqual(agreement.FieldDisagreements, map[string]int64{"output": 2, "label": 1}) {
+		t.Errorf("field disagreements %v", agreement.FieldDisagreements)
+	}
+}
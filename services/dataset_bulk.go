@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// 批量操作的类型
+const (
+	BulkOpCreate = "create"
+	BulkOpUpdate = "update"
+	BulkOpDelete = "delete"
+)
+
+// BulkEntryOperation 批量修改数据集条目中的一项操作
+type BulkEntryOperation struct {
+	Op         string          `json:"op"`          // create/update/delete
+	EntryIndex *int            `json:"entry_index"` // update/delete 的目标条目
+	Data       json.RawMessage `json:"data"`        // create/update 的条目内容，update 为整条替换
+}
+
+// ValidateBulkOperations 检查各项操作的类型和参数，同一条目在一次批量操作中只能出现一次
+func ValidateBulkOperations(ops []BulkEntryOperation) error {
+	seen := make(map[int]int)
+	for i, op := range ops {
+		switch op.Op {
+		case BulkOpCreate:
+			if op.EntryIndex != nil {
+				return fmt.Errorf("operation %d: create does not take entry_index", i)
+			}
+		case BulkOpUpdate, BulkOpDelete:
+			if op.EntryIndex == nil || *op.EntryIndex < 0 {
+				return fmt.Errorf("operation %d: %s requires a non-negative entry_index", i, op.Op)
+			}
+			if j, ok := seen[*op.EntryIndex]; ok {
+				return fmt.Errorf("operation %d: entry %d is already modified by operation %d", i, *op.EntryIndex, j)
+			}
+			seen[*op.EntryIndex] = i
+		default:
+			return fmt.Errorf("operation %d: unsupported op %q", i, op.Op)
+		}
+		hasData := len(op.Data) > 0 && string(op.Data) != "null"
+		if op.Op == BulkOpDelete && hasData {
+			return fmt.Errorf("operation %d: delete does not take data", i)
+		}
+		if op.Op != BulkOpDelete && !hasData {
+			return fmt.Errorf("operation %d: %s requires data", i, op.Op)
+		}
+	}
+	return nil
+}
+
+// MergeRecordFields 将 set 中的字段合并到条目的字段中，值为 null 的字段被删除，fields 不会被修改
+func MergeRecordFields(fields, set map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(fields)+len(set))
+	for key, value := range fields {
+		merged[key] = value
+	}
+	for key, value := range set {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// IndexShift 压缩 entry_index 时 (After, Before) 区间内的索引统一减去 By，Before 为 -1 表示没有上界
+type IndexShift struct {
+	After  int
+	Before int
+	By     int
+}
+
+// CompactionShifts 返回移除 removed 中的索引后，其余索引需要的平移区间
+// removed 须升序且不重复；相邻的移除索引之间没有条目，不产生区间
+func CompactionShifts(removed []int) []IndexShift {
+	var shifts []IndexShift
+	for i, index := range removed {
+		before := -1
+		if i+1 < len(removed) {
+			before = removed[i+1]
+			if before == index+1 {
+				continue
+			}
+		}
+		shifts = append(shifts, IndexShift{After: index, Before: before, By: i + 1})
+	}
+	return shifts
+}
+
+// CompactedIndex 返回移除 removed 中的索引后 index 的新位置，removed 须升序且不重复
+func CompactedIndex(removed []int, index int) int {
+	return index - sort.SearchInts(removed, index)
+}
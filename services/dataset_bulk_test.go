@@ -0,0 +1,68 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateBulkOperations(t *testing.T) {
+	index := func(i int) *int { return &i }
+	data := json.RawMessage(`{"instruction":"hi"}`)
+
+	valid := []BulkEntryOperation{
+		{Op: BulkOpCreate, Data: data},
+		{Op: BulkOpUpdate, EntryIndex: index(0), Data: data},
+		{Op: BulkOpDelete, EntryIndex: index(3)},
+	}
+	if err := ValidateBulkOperations(valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := map[string][]BulkEntryOperation{
+		"unsupported op":       {{Op: "upsert", Data: data}},
+		"requires data":        {{Op: BulkOpCreate, Data: json.RawMessage("null")}},
+		"non-negative":         {{Op: BulkOpDelete}},
+		"does not take data":   {{Op: BulkOpDelete, EntryIndex: index(1), Data: data}},
+		"already modified":     {{Op: BulkOpUpdate, EntryIndex: index(1), Data: data}, {Op: BulkOpDelete, EntryIndex: index(1)}},
+		"create does not take": {{Op: BulkOpCreate, EntryIndex: index(1), Data: data}},
+	}
+	for want, ops := range cases {
+		err := ValidateBulkOperations(ops)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestMergeRecordFields(t *testing.T) {
+	fields := map[string]interface{}{"instruction": "a", "label": "old", "score": 1.0}
+	merged := MergeRecordFields(fields, map[string]interface{}{"label": "new", "score": nil, "tags": []interface{}{"x"}})
+
+	want := map[string]interface{}{"instruction": "a", "label": "new", "tags": []interface{}{"x"}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged %v", merged)
+	}
+	if fields["label"] != "old" || fields["score"] != 1.0 {
+		t.Errorf("source fields modified: %v", fields)
+	}
+}
+
+func TestCompactionShifts(t *testing.T) {
+	shifts := CompactionShifts([]int{2, 3, 7})
+	want := []IndexShift{{After: 3, Before: 7, By: 2}, {After: 7, Before: -1, By: 3}}
+	if !reflect.DeepEqual(shifts, want) {
+		t.Errorf("shifts %+v", shifts)
+	}
+	if shifts := CompactionShifts(nil); shifts != nil {
+		t.Errorf("expected no shifts, got %+v", shifts)
+	}
+
+	removed := []int{2, 3, 7}
+	for index, want := range map[int]int{0: 0, 1: 1, 4: 2, 6: 4, 8: 5, 20: 17} {
+		if got := CompactedIndex(removed, index); got != want {
+			t.Errorf("CompactedIndex(%d) = %d, want %d", index, got, want)
+		}
+	}
+}
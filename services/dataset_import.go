@@ -0,0 +1,511 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// DatasetImportFormat 数据集导入文件格式
+type DatasetImportFormat string
+
+const (
+	ImportFormatJSONL   DatasetImportFormat = "jsonl"
+	ImportFormatJSON    DatasetImportFormat = "json" // 顶层为数组的 JSON 文件，不是数组时按 JSONL 解析
+	ImportFormatCSV     DatasetImportFormat = "csv"
+	ImportFormatTSV     DatasetImportFormat = "tsv"
+	ImportFormatParquet DatasetImportFormat = "parquet"
+)
+
+// 导入报告中最多保留的错误行数，超出部分只计数
+const maxImportErrors = 1000
+
+// JSONL 单行最大长度
+const maxImportLineSize = 16 * 1024 * 1024
+
+// ImportFieldMapping 源字段到条目字段的映射，为空时使用同名字段
+// Messages 为对话格式中的消息列表字段，为空时自动识别 messages 和 conversations
+type ImportFieldMapping struct {
+	Instruction string `json:"instruction"`
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Messages    string `json:"messages"`
+}
+
+func (m ImportFieldMapping) withDefaults() ImportFieldMapping {
+	if m.Instruction == "" {
+		m.Instruction = "instruction"
+	}
+	if m.Input == "" {
+		m.Input = "input"
+	}
+	if m.Output == "" {
+		m.Output = "output"
+	}
+	return m
+}
+
+// ImportRecord 解析出的一条数据，RawContent 保留原始记录(CSV/Parquet 转为 JSON 对象)
+type ImportRecord struct {
+	Line        int
+	Instruction string
+	Input       string
+	Output      string
+	RawContent  string
+}
+
+// ImportLineError 导入失败的行，JSON 数组和 Parquet 文件中 Line 为记录序号(从 1 开始)
+type ImportLineError struct {
+	Line    int          `json:"line"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"` // 未通过数据集 Schema 校验的字段
+}
+
+// ImportReport 导入结果
+type ImportReport struct {
+	TotalRecords    int               `json:"total_records"`
+	Imported        int               `json:"imported"`
+	Failed          int               `json:"failed"`
+	Errors          []ImportLineError `json:"errors"`
+	ErrorsTruncated bool              `json:"errors_truncated,omitempty"`
+}
+
+func (r *ImportReport) fail(line int, format string, args ...interface{}) {
+	r.Failed++
+	if len(r.Errors) >= maxImportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportLineError{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// failRecord 记录校验失败的行，字段级错误一并保存
+func (r *ImportReport) failRecord(line int, err error) {
+	r.fail(line, "%v", err)
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) && !r.ErrorsTruncated {
+		r.Errors[len(r.Errors)-1].Fields = fieldErrs
+	}
+}
+
+// ImportSource 导入文件，Parquet 需要随机读取
+type ImportSource interface {
+	io.Reader
+	io.ReaderAt
+}
+
+// DetectImportFormat 根据显式指定的格式或文件扩展名确定导入格式
+// format 为 chat/sharegpt 时按扩展名选择 JSON 或 JSONL，并返回对应的默认消息字段
+func DetectImportFormat(filename, format string) (DatasetImportFormat, string, error) {
+	messagesField := ""
+	switch strings.ToLower(format) {
+	case "":
+	case "chat":
+		messagesField = "messages"
+	case "sharegpt":
+		messagesField = "conversations"
+	default:
+		switch f := DatasetImportFormat(strings.ToLower(format)); f {
+		case ImportFormatJSONL, ImportFormatJSON, ImportFormatCSV, ImportFormatTSV, ImportFormatParquet:
+			return f, "", nil
+		}
+		return "", "", fmt.Errorf("unsupported import format %q", format)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl":
+		return ImportFormatJSONL, messagesField, nil
+	case ".json":
+		return ImportFormatJSON, messagesField, nil
+	}
+	if messagesField != "" {
+		return ImportFormatJSONL, messagesField, nil
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return ImportFormatCSV, "", nil
+	case ".tsv":
+		return ImportFormatTSV, "", nil
+	case ".parquet":
+		return ImportFormatParquet, "", nil
+	}
+	return "", "", fmt.Errorf("unsupported file type %q, expected .jsonl, .json, .csv, .tsv or .parquet", filepath.Ext(filename))
+}
+
+// ParseDatasetFile 逐条解析导入文件，按数据集 Schema 校验后调用 handle 保存，schema 为 nil 时不校验
+// 解析失败、校验失败或 handle 返回错误的记录写入报告，不中断导入；只有文件无法读取或 ctx 被取消时返回错误
+func ParseDatasetFile(ctx context.Context, src ImportSource, size int64, format DatasetImportFormat, schema *DatasetSchema, mapping ImportFieldMapping, handle func(ImportRecord) error) (*ImportReport, error) {
+	report := &ImportReport{Errors: []ImportLineError{}}
+	emit := func(line int, fields map[string]interface{}, raw string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report.TotalRecords++
+		record, err := schema.Record(fields, mapping)
+		if err != nil {
+			report.failRecord(line, err)
+			return nil
+		}
+		record.Line = line
+		record.RawContent = raw
+		if err := handle(record); err != nil {
+			report.fail(line, "%v", err)
+			return nil
+		}
+		report.Imported++
+		return nil
+	}
+
+	var err error
+	switch format {
+	case ImportFormatJSONL:
+		err = parseJSONLines(src, report, emit)
+	case ImportFormatJSON:
+		err = parseJSONArray(src, report, emit)
+	case ImportFormatCSV:
+		err = parseDelimited(src, ',', report, emit)
+	case ImportFormatTSV:
+		err = parseDelimited(src, '\t', report, emit)
+	case ImportFormatParquet:
+		err = parseParquet(src, size, emit)
+	default:
+		err = fmt.Errorf("unsupported import format %q", format)
+	}
+	return report, err
+}
+
+// emitFunc 处理一条记录，返回错误时停止解析
+type emitFunc func(line int, fields map[string]interface{}, raw string) error
+
+func parseJSONLines(src io.Reader, report *ImportReport, emit emitFunc) error {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+		fields, err := decodeJSONObject([]byte(text))
+		if err != nil {
+			report.TotalRecords++
+			report.fail(line, "invalid JSON: %v", err)
+			continue
+		}
+		if err := emit(line, fields, text); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseJSONArray 解析顶层数组，文件不是数组时按 JSONL 解析以兼容旧的 .json 导入
+func parseJSONArray(src ImportSource, report *ImportReport, emit emitFunc) error {
+	reader := bufio.NewReader(src)
+	first, err := firstNonSpace(reader)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	if first != '[' {
+		return parseJSONLines(reader, report, emit)
+	}
+
+	decoder := json.NewDecoder(reader)
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for index := 1; decoder.More(); index++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return fmt.Errorf("invalid JSON at record %d: %w", index, err)
+		}
+		fields, err := decodeJSONObject(raw)
+		if err != nil {
+			report.TotalRecords++
+			report.fail(index, "%v", err)
+			continue
+		}
+		if err := emit(index, fields, string(raw)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		// UTF-8 BOM
+		if b == 0xEF {
+			if bom, err := reader.Peek(2); err == nil && bom[0] == 0xBB && bom[1] == 0xBF {
+				_, _ = reader.Discard(2)
+				continue
+			}
+		}
+		return b, reader.UnreadByte()
+	}
+}
+
+func decodeJSONObject(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("record is not a JSON object")
+	}
+	return fields, nil
+}
+
+// parseDelimited 解析带表头的 CSV/TSV，每行转为 表头->值 的对象
+func parseDelimited(src io.Reader, comma rune, report *ImportReport, emit emitFunc) error {
+	reader := csv.NewReader(src)
+	reader.Comma = comma
+	if comma == '\t' {
+		reader.LazyQuotes = true
+	}
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.TotalRecords++
+			report.fail(parseErr.StartLine, "%v", parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		fields := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			fields[name] = row[i]
+		}
+		raw, _ := json.Marshal(fields)
+		if err := emit(line, fields, string(raw)); err != nil {
+			return err
+		}
+	}
+}
+
+func parseParquet(src io.ReaderAt, size int64, emit emitFunc) error {
+	file, err := parquet.OpenFile(src, size)
+	if err != nil {
+		return fmt.Errorf("invalid parquet file: %w", err)
+	}
+	reader := parquet.NewGenericReader[map[string]interface{}](file, file.Schema())
+	defer reader.Close()
+
+	index := 0
+	rows := make([]map[string]interface{}, 64)
+	for {
+		for i := range rows {
+			rows[i] = map[string]interface{}{}
+		}
+		n, err := reader.Read(rows)
+		for _, row := range rows[:n] {
+			index++
+			raw, _ := json.Marshal(row)
+			if err := emit(index, row, string(raw)); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read parquet row %d: %w", index+1, err)
+		}
+	}
+}
+
+// recordFromFields 按字段映射生成条目，含消息列表的记录按对话格式处理
+func recordFromFields(fields map[string]interface{}, mapping ImportFieldMapping) (ImportRecord, error) {
+	if messages, ok := chatMessages(fields, mapping); ok {
+		return chatRecord(messages)
+	}
+
+	record := ImportRecord{
+		Instruction: stringifyImportValue(fields[mapping.Instruction]),
+		Input:       stringifyImportValue(fields[mapping.Input]),
+		Output:      stringifyImportValue(fields[mapping.Output]),
+	}
+	if record.Instruction == "" {
+		return record, fmt.Errorf("missing %q field", mapping.Instruction)
+	}
+	return record, nil
+}
+
+func chatMessages(fields map[string]interface{}, mapping ImportFieldMapping) ([]interface{}, bool) {
+	if messages, ok := fields[mapping.Messages].([]interface{}); ok && mapping.Messages != "" {
+		return messages, true
+	}
+	if _, ok := fields[mapping.Instruction]; ok {
+		return nil, false
+	}
+	if mapping.Messages != "" {
+		// 指定了消息字段但记录中没有，按对话格式报错
+		return nil, true
+	}
+	for _, key := range []string{"messages", "conversations"} {
+		if messages, ok := fields[key].([]interface{}); ok {
+			return messages, true
+		}
+	}
+	return nil, false
+}
+
+// chatRecord 将对话转为单条指令数据:
+// 最后一条 assistant 回复为 Output，其前一条 user 消息为 Instruction，system 提示为 Input
+// 支持 {role, content} 和 ShareGPT 的 {from, value} 两种消息格式，完整对话保留在 RawContent 中
+func chatRecord(messages []interface{}) (ImportRecord, error) {
+	var record ImportRecord
+	if len(messages) == 0 {
+		return record, errors.New("conversation has no messages")
+	}
+
+	type message struct{ role, content string }
+	parsed := make([]message, 0, len(messages))
+	var system []string
+	for i, item := range messages {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return record, fmt.Errorf("message %d is not an object", i+1)
+		}
+		role := stringifyImportValue(fields["role"])
+		if role == "" {
+			role = stringifyImportValue(fields["from"])
+		}
+		content, ok := fields["content"]
+		if !ok {
+			content = fields["value"]
+		}
+		m := message{role: normalizeChatRole(role), content: chatContent(content)}
+		if m.role == "" {
+			return record, fmt.Errorf("message %d has unknown role %q", i+1, role)
+		}
+		if m.role == "system" {
+			system = append(system, m.content)
+			continue
+		}
+		parsed = append(parsed, m)
+	}
+
+	for i := len(parsed) - 1; i >= 0; i-- {
+		if parsed[i].role != "assistant" {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if parsed[j].role == "user" {
+				record.Instruction = parsed[j].content
+				record.Output = parsed[i].content
+				record.Input = strings.Join(system, "\n")
+				if record.Instruction == "" {
+					return record, errors.New("user message is empty")
+				}
+				return record, nil
+			}
+		}
+		return record, errors.New("assistant reply has no preceding user message")
+	}
+	return record, errors.New("conversation has no assistant reply")
+}
+
+func normalizeChatRole(role string) string {
+	switch strings.ToLower(role) {
+	case "system":
+		return "system"
+	case "user", "human":
+		return "user"
+	case "assistant", "gpt", "bot", "model":
+		return "assistant"
+	}
+	return ""
+}
+
+// chatContent 支持字符串和 [{type: text, text: ...}] 形式的多段内容
+func chatContent(content interface{}) string {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return stringifyImportValue(content)
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if fields, ok := part.(map[string]interface{}); ok {
+			if text, ok := fields["text"].(string); ok {
+				texts = append(texts, text)
+				continue
+			}
+		}
+		texts = append(texts, stringifyImportValue(part))
+	}
+	return strings.Join(texts, "\n")
+}
+
+// stringifyImportValue 将任意字段值转为字符串，数字保持原样，嵌套对象和数组序列化为 JSON
+func stringifyImportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
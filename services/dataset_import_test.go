@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func parseImport(t *testing.T, content []byte, format DatasetImportFormat, mapping ImportFieldMapping) ([]ImportRecord, *ImportReport) {
+	t.Helper()
+	var records []ImportRecord
+	report, err := ParseDatasetFile(context.Background(), bytes.NewReader(content), int64(len(content)), format, nil, mapping, func(record ImportRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseDatasetFile failed: %v", err)
+	}
+	return records, report
+}
+
+func TestParseDatasetFileJSONL(t *testing.T) {
+	content := strings.Join([]string{
+		`{"instruction": "add", "input": {"a": 1, "b": 2}, "output": 3}`,
+		``,
+		`not json`,
+		`{"input": "missing instruction"}`,
+		`{"instruction": "echo", "output": "hi"}`,
+	}, "\n")
+
+	records, report := parseImport(t, []byte(content), ImportFormatJSONL, ImportFieldMapping{})
+	if report.TotalRecords != 4 || report.Imported != 2 || report.Failed != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.Errors[0].Line != 3 || report.Errors[1].Line != 4 {
+		t.Errorf("unexpected error lines %+v", report.Errors)
+	}
+
+	// 嵌套对象和数字不再被丢弃
+	if records[0].Input != `{"a":1,"b":2}` || records[0].Output != "3" {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+	if records[0].RawContent != `{"instruction": "add", "input": {"a": 1, "b": 2}, "output": 3}` {
+		t.Errorf("raw content not preserved: %s", records[0].RawContent)
+	}
+	if records[1].Line != 5 {
+		t.Errorf("expected line 5, got %d", records[1].Line)
+	}
+}
+
+func TestParseDatasetFileJSONArray(t *testing.T) {
+	content := `[{"q": "1+1", "a": "2"}, "bad", {"q": "2+2", "a": "4"}]`
+	mapping := ImportFieldMapping{Instruction: "q", Output: "a"}
+
+	records, report := parseImport(t, []byte(content), ImportFormatJSON, mapping)
+	if report.Imported != 2 || report.Failed != 1 || report.Errors[0].Line != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if records[1].Instruction != "2+2" || records[1].Output != "4" || records[1].Line != 3 {
+		t.Errorf("unexpected record %+v", records[1])
+	}
+
+	// .json 文件内容为 JSONL 时按行解析
+	records, report = parseImport(t, []byte("{\"instruction\": \"a\"}\n{\"instruction\": \"b\"}\n"), ImportFormatJSON, ImportFieldMapping{})
+	if report.Imported != 2 || records[1].Instruction != "b" {
+		t.Errorf("expected JSONL fallback, got %+v", report)
+	}
+}
+
+func TestParseDatasetFileCSV(t *testing.T) {
+	content := "\ufeffquestion,context,answer\n" +
+		"\"What is 1+1?\",\"math, easy\",2\n" +
+		"only,two\n" +
+		",,empty\n"
+	mapping := ImportFieldMapping{Instruction: "question", Input: "context", Output: "answer"}
+
+	records, report := parseImport(t, []byte(content), ImportFormatCSV, mapping)
+	if report.TotalRecords != 3 || report.Imported != 1 || report.Failed != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if records[0].Instruction != "What is 1+1?" || records[0].Input != "math, easy" || records[0].Output != "2" || records[0].Line != 2 {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+	if records[0].RawContent != `{"answer":"2","context":"math, easy","question":"What is 1+1?"}` {
+		t.Errorf("unexpected raw content %s", records[0].RawContent)
+	}
+	if report.Errors[0].Line != 3 || report.Errors[1].Line != 4 {
+		t.Errorf("unexpected error lines %+v", report.Errors)
+	}
+
+	records, report = parseImport(t, []byte("instruction\toutput\nsay \"hi\"\thi\n"), ImportFormatTSV, ImportFieldMapping{})
+	if report.Imported != 1 || records[0].Instruction != `say "hi"` {
+		t.Errorf("unexpected TSV result %+v %+v", report, records)
+	}
+}
+
+func TestParseDatasetFileChat(t *testing.T) {
+	content := strings.Join([]string{
+		`{"messages": [{"role": "system", "content": "be brief"}, {"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}, {"role": "user", "content": "bye"}, {"role": "assistant", "content": [{"type": "text", "text": "see you"}]}]}`,
+		`{"conversations": [{"from": "human", "value": "ping"}, {"from": "gpt", "value": "pong"}]}`,
+		`{"messages": [{"role": "user", "content": "no reply"}]}`,
+		`{"messages": [{"role": "tool", "content": "x"}]}`,
+	}, "\n")
+
+	records, report := parseImport(t, []byte(content), ImportFormatJSONL, ImportFieldMapping{})
+	if report.Imported != 2 || report.Failed != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if records[0].Instruction != "bye" || records[0].Output != "see you" || records[0].Input != "be brief" {
+		t.Errorf("unexpected chat record %+v", records[0])
+	}
+	if records[1].Instruction != "ping" || records[1].Output != "pong" {
+		t.Errorf("unexpected sharegpt record %+v", records[1])
+	}
+
+	// 自定义消息字段
+	records, report = parseImport(t, []byte(`{"dialog": [{"role": "user", "content": "q"}, {"role": "assistant", "content": "a"}]}`),
+		ImportFormatJSONL, ImportFieldMapping{Messages: "dialog"})
+	if report.Imported != 1 || records[0].Output != "a" {
+		t.Errorf("unexpected result %+v", report)
+	}
+}
+
+func TestParseDatasetFileParquet(t *testing.T) {
+	type row struct {
+		Prompt   string  `parquet:"prompt"`
+		Response string  `parquet:"response"`
+		Score    float64 `parquet:"score"`
+	}
+	var buf bytes.Buffer
+	writer := parquet.NewGenericWriter[row](&buf)
+	if _, err := writer.Write([]row{{"hello", "world", 0.5}, {"", "no prompt", 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, report := parseImport(t, buf.Bytes(), ImportFormatParquet, ImportFieldMapping{Instruction: "prompt", Input: "score", Output: "response"})
+	if report.TotalRecords != 2 || report.Imported != 1 || report.Errors[0].Line != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if records[0].Instruction != "hello" || records[0].Output != "world" || records[0].Input != "0.5" {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+}
+
+func TestDetectImportFormat(t *testing.T) {
+	testCases := []struct {
+		filename, format string
+		expected         DatasetImportFormat
+		messages         string
+		wantErr          bool
+	}{
+		{"data.jsonl", "", ImportFormatJSONL, "", false},
+		{"data.JSON", "", ImportFormatJSON, "", false},
+		{"data.csv", "", ImportFormatCSV, "", false},
+		{"data.parquet", "", ImportFormatParquet, "", false},
+		{"data.txt", "tsv", ImportFormatTSV, "", false},
+		{"data.json", "sharegpt", ImportFormatJSON, "conversations", false},
+		{"data.jsonl", "chat", ImportFormatJSONL, "messages", false},
+		{"data.xlsx", "", "", "", true},
+		{"data.jsonl", "xml", "", "", true},
+	}
+	for _, tc := range testCases {
+		format, messages, err := DetectImportFormat(tc.filename, tc.format)
+		if (err != nil) != tc.wantErr || format != tc.expected || messages != tc.messages {
+			t.Errorf("DetectImportFormat(%q, %q) = %q, %q, %v", tc.filename, tc.format, format, messages, err)
+		}
+	}
+}
+
+func TestParseDatasetFileCanceled(t *testing.T) {
+	content := []byte("{\"instruction\": \"a\"}\n{\"instruction\": \"b\"}\n{\"instruction\": \"c\"}\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	imported := 0
+	report, err := ParseDatasetFile(ctx, bytes.NewReader(content), int64(len(content)), ImportFormatJSONL, nil, ImportFieldMapping{}, func(record ImportRecord) error {
+		imported++
+		if imported == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if imported != 2 || report.Imported != 2 {
+		t.Errorf("expected parsing to stop after 2 records, got %d %+v", imported, report)
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+// 数据处理流水线的步骤类型
+const (
+	PipelineStepRename       = "rename"        // 重命名或删除原始记录中的字段
+	PipelineStepFilterRegex  = "filter_regex"  // 按正则表达式保留(或排除)条目
+	PipelineStepFilterLength = "filter_length" // 按字符数或近似 token 数保留条目
+	PipelineStepDedupe       = "dedupe"        // 去除归一化后完全相同的条目，保留第一条
+	PipelineStepSample       = "sample"        // 按比例随机抽样，或只保留前若干条
+)
+
+// 一条流水线最多包含的步骤数
+const maxPipelineSteps = 50
+
+// PipelineStep 流水线中的一个步骤，各类型使用的参数见字段说明
+// 字段名可以是原始记录中的字段，也可以是 instruction/input/output，后者在原始记录中没有时按输出数据集的模板映射读取
+type PipelineStep struct {
+	Type string `json:"type"`
+
+	// rename: 源字段 -> 新字段名；drop: 删除的字段
+	Rename map[string]string `json:"rename,omitempty"`
+	Drop   []string          `json:"drop,omitempty"`
+
+	// filter_regex: 字段为空时匹配 instruction/input/output 中任一个；exclude 为 true 时丢弃匹配的条目
+	Field   string `json:"field,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Exclude bool   `json:"exclude,omitempty"`
+
+	// filter_length: 字段为空时统计 instruction/input/output 之和；unit 为 chars(默认)或 tokens，0 表示不限制
+	Unit string `json:"unit,omitempty"`
+	Min  int    `json:"min,omitempty"`
+	Max  int    `json:"max,omitempty"`
+
+	// dedupe: 比较的字段，默认 instruction/input/output
+	Fields []string `json:"fields,omitempty"`
+
+	// sample: rate 为保留比例(0,1]，按 seed 和条目来源确定是否保留；limit 为最多保留的条目数
+	Rate  float64 `json:"rate,omitempty"`
+	Seed  int64   `json:"seed,omitempty"`
+	Limit int     `json:"limit,omitempty"`
+}
+
+// PipelineRecord 流水线处理的一条记录，SourceID/Index 标识来源条目
+type PipelineRecord struct {
+	SourceID uint
+	Index    int
+	Fields   map[string]interface{}
+}
+
+// PipelineStepStats 步骤的处理统计
+type PipelineStepStats struct {
+	Type    string `json:"type"`
+	Input   int64  `json:"input"`
+	Dropped int64  `json:"dropped"`
+}
+
+// Pipeline 编译后的流水线，不能并发使用
+type Pipeline struct {
+	schema *DatasetSchema
+	steps  []*pipelineStep
+}
+
+type pipelineStep struct {
+	PipelineStep
+	re     *regexp.Regexp
+	seen   map[uint64]struct{}
+	kept   int
+	stats  PipelineStepStats
+	fields []string
+}
+
+// CompilePipeline 检查并编译流水线步骤，schema 为输出数据集的模板，用于读取 instruction/input/output
+func CompilePipeline(steps []PipelineStep, schema *DatasetSchema) (*Pipeline, error) {
+	if len(steps) > maxPipelineSteps {
+		return nil, fmt.Errorf("pipeline can have at most %d steps", maxPipelineSteps)
+	}
+	p := &Pipeline{schema: schema}
+	for i, step := range steps {
+		compiled := &pipelineStep{PipelineStep: step, stats: PipelineStepStats{Type: step.Type}}
+		if err := compiled.compile(); err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, step.Type, err)
+		}
+		p.steps = append(p.steps, compiled)
+	}
+	return p, nil
+}
+
+func (s *pipelineStep) compile() error {
+	switch s.Type {
+	case PipelineStepRename:
+		if len(s.Rename) == 0 && len(s.Drop) == 0 {
+			return errors.New("rename or drop is required")
+		}
+		for from, to := range s.Rename {
+			if from == "" || to == "" {
+				return errors.New("field names must not be empty")
+			}
+		}
+	case PipelineStepFilterRegex:
+		if s.Pattern == "" {
+			return errors.New("pattern is required")
+		}
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		s.re = re
+	case PipelineStepFilterLength:
+		if s.Unit == "" {
+			s.Unit = "chars"
+		}
+		if s.Unit != "chars" && s.Unit != "tokens" {
+			return fmt.Errorf("unsupported unit %q", s.Unit)
+		}
+		if s.Min < 0 || s.Max < 0 || (s.Max > 0 && s.Min > s.Max) {
+			return errors.New("invalid length range")
+		}
+	case PipelineStepDedupe:
+		s.fields = s.Fields
+		if len(s.fields) == 0 {
+			s.fields = SearchFields
+		}
+		s.seen = map[uint64]struct{}{}
+	case PipelineStepSample:
+		if s.Rate == 0 && s.Limit == 0 {
+			return errors.New("rate or limit is required")
+		}
+		if s.Rate < 0 || s.Rate > 1 || s.Limit < 0 {
+			return errors.New("rate must be in (0, 1] and limit must not be negative")
+		}
+	default:
+		return fmt.Errorf("unsupported step type %q", s.Type)
+	}
+	return nil
+}
+
+// Process 依次执行各步骤，返回处理后的记录；被某个步骤丢弃时返回 false
+func (p *Pipeline) Process(record PipelineRecord) (map[string]interface{}, bool) {
+	fields := record.Fields
+	for _, step := range p.steps {
+		step.stats.Input++
+		keep := true
+		switch step.Type {
+		case PipelineStepRename:
+			fields = step.rename(fields)
+		case PipelineStepFilterRegex:
+			keep = step.matchRegex(p.values(fields, step.Field))
+		case PipelineStepFilterLength:
+			keep = step.matchLength(p.values(fields, step.Field))
+		case PipelineStepDedupe:
+			keep = step.dedupe(p, fields)
+		case PipelineStepSample:
+			keep = step.sample(record)
+		}
+		if !keep {
+			step.stats.Dropped++
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// Done 流水线中的 limit 已达到上限，之后的记录都会被丢弃
+func (p *Pipeline) Done() bool {
+	for _, step := range p.steps {
+		if step.Type == PipelineStepSample && step.Limit > 0 && step.kept >= step.Limit {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats 返回各步骤的处理统计
+func (p *Pipeline) Stats() []PipelineStepStats {
+	stats := make([]PipelineStepStats, len(p.steps))
+	for i, step := range p.steps {
+		stats[i] = step.stats
+	}
+	return stats
+}
+
+// values 读取字段的文本值，field 为空时返回 instruction/input/output 三列
+func (p *Pipeline) values(fields map[string]interface{}, field string) []string {
+	if field == "" {
+		record := p.schema.Columns(fields)
+		return []string{record.Instruction, record.Input, record.Output}
+	}
+	return []string{p.value(fields, field)}
+}
+
+func (p *Pipeline) value(fields map[string]interface{}, field string) string {
+	value, ok := fields[field]
+	if !ok && isSearchField(field) {
+		record := p.schema.Columns(fields)
+		return map[string]string{"instruction": record.Instruction, "input": record.Input, "output": record.Output}[field]
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func (s *pipelineStep) rename(fields map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		result[key] = value
+	}
+	for _, key := range s.Drop {
+		delete(result, key)
+	}
+	// 先取出全部源字段再写入，a->b、b->a 可以交换字段
+	moved := map[string]interface{}{}
+	for from, to := range s.Rename {
+		if value, ok := fields[from]; ok {
+			moved[to] = value
+			delete(result, from)
+		}
+	}
+	for to, value := range moved {
+		result[to] = value
+	}
+	return result
+}
+
+func (s *pipelineStep) matchRegex(values []string) bool {
+	matched := false
+	for _, value := range values {
+		if s.re.MatchString(value) {
+			matched = true
+			break
+		}
+	}
+	return matched != s.Exclude
+}
+
+func (s *pipelineStep) matchLength(values []string) bool {
+	length := 0
+	for _, value := range values {
+		if s.Unit == "tokens" {
+			length += ApproxTokens(value)
+		} else {
+			length += len([]rune(value))
+		}
+	}
+	return length >= s.Min && (s.Max == 0 || length <= s.Max)
+}
+
+func (s *pipelineStep) dedupe(p *Pipeline, fields map[string]interface{}) bool {
+	parts := make([]string, len(s.fields))
+	for i, field := range s.fields {
+		parts[i] = strings.Join(strings.Fields(strings.ToLower(p.value(fields, field))), " ")
+	}
+	hash := fnvHash(strings.Join(parts, "\x1f"))
+	if _, ok := s.seen[hash]; ok {
+		return false
+	}
+	s.seen[hash] = struct{}{}
+	return true
+}
+
+// sample 按 seed 和来源条目计算哈希决定是否保留，结果与条目的处理顺序无关
+func (s *pipelineStep) sample(record PipelineRecord) bool {
+	if s.Rate > 0 && s.Rate < 1 {
+		var key [20]byte
+		binary.LittleEndian.PutUint64(key[0:], uint64(s.Seed))
+		binary.LittleEndian.PutUint32(key[8:], uint32(record.SourceID))
+		binary.LittleEndian.PutUint64(key[12:], uint64(record.Index))
+		h := fnv.New64a()
+		h.Write(key[:])
+		if float64(h.Sum64()>>11)/float64(1<<53) >= s.Rate {
+			return false
+		}
+	}
+	if s.Limit > 0 {
+		if s.kept >= s.Limit {
+			return false
+		}
+		s.kept++
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func runPipeline(t *testing.T, p *Pipeline, records []map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	var kept []map[string]interface{}
+	for i, fields := range records {
+		if result, ok := p.Process(PipelineRecord{SourceID: 1, Index: i, Fields: fields}); ok {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+func TestPipelineRenameAndFilter(t *testing.T) {
+	schema, err := CompileDatasetSchema(TemplateInstructionIO, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := CompilePipeline([]PipelineStep{
+		{Type: PipelineStepRename, Rename: map[string]string{"question": "instruction", "answer": "output"}, Drop: []string{"id"}},
+		{Type: PipelineStepFilterRegex, Field: "instruction", Pattern: `(?i)^as an ai`, Exclude: true},
+		{Type: PipelineStepFilterLength, Field: "output", Min: 2, Max: 10},
+	}, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := runPipeline(t, p, []map[string]interface{}{
+		{"id": 1, "question": "What is Go?", "answer": "A language"},
+		{"id": 2, "question": "As an AI, explain", "answer": "refused"},
+		{"id": 3, "question": "Short", "answer": "x"},
+		{"id": 4, "question": "Long", "answer": "far too long answer"},
+	})
+	want := []map[string]interface{}{{"instruction": "What is Go?", "output": "A language"}}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept %v", kept)
+	}
+
+	stats := p.Stats()
+	if stats[1].Dropped != 1 || stats[2].Input != 3 || stats[2].Dropped != 2 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestPipelineDedupeAndSample(t *testing.T) {
+	p, err := CompilePipeline([]PipelineStep{
+		{Type: PipelineStepDedupe, Fields: []string{"instruction"}},
+		{Type: PipelineStepSample, Limit: 2},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := runPipeline(t, p, []map[string]interface{}{
+		{"instruction": "Hello  World", "output": "a"},
+		{"instruction": "hello world", "output": "b"},
+		{"instruction": "second"},
+		{"instruction": "third"},
+	})
+	if len(kept) != 2 || kept[0]["output"] != "a" || kept[1]["instruction"] != "second" || !p.Done() {
+		t.Errorf("kept %v, done %v", kept, p.Done())
+	}
+
+	sample := func(seed int64) []int {
+		p, _ := CompilePipeline([]PipelineStep{{Type: PipelineStepSample, Rate: 0.3, Seed: seed}}, nil)
+		var indexes []int
+		for i := 0; i < 1000; i++ {
+			if _, ok := p.Process(PipelineRecord{SourceID: 1, Index: i, Fields: map[string]interface{}{}}); ok {
+				indexes = append(indexes, i)
+			}
+		}
+		return indexes
+	}
+	first := sample(5)
+	if !reflect.DeepEqual(first, sample(5)) {
+		t.Error("sampling is not deterministic")
+	}
+	if len(first) < 250 || len(first) > 350 {
+		t.Errorf("sampled %d of 1000 at rate 0.3", len(first))
+	}
+}
+
+func TestCompilePipelineErrors(t *testing.T) {
+	invalid := []PipelineStep{
+		{Type: "unknown"},
+		{Type: PipelineStepRename},
+		{Type: PipelineStepFilterRegex, Pattern: "("},
+		{Type: PipelineStepFilterLength, Unit: "words"},
+		{Type: PipelineStepFilterLength, Min: 10, Max: 5},
+		{Type: PipelineStepSample, Rate: 1.5},
+		{Type: PipelineStepSample},
+	}
+	for _, step := range invalid {
+		if _, err := CompilePipeline([]PipelineStep{step}, nil); err == nil {
+			t.Errorf("expected error for %+v", step)
+		}
+	}
+}
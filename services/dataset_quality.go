@@ -0,0 +1,520 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 质量报告中的问题类型，每个问题都对应一组需要处理的 entry_index
+const (
+	QualityIssueDuplicate     = "duplicate"      // 与更早的条目归一化后完全相同
+	QualityIssueNearDuplicate = "near_duplicate" // 与更早的条目 MinHash 相似度超过阈值
+	QualityIssueEmpty         = "empty"          // 必填字段为空或缺失
+	QualityIssueTooLong       = "too_long"       // 近似 token 数超过上限
+)
+
+// QualityIssues 全部问题类型
+var QualityIssues = []string{QualityIssueDuplicate, QualityIssueNearDuplicate, QualityIssueEmpty, QualityIssueTooLong}
+
+const (
+	// 报告中最多列出的重复组数、每组列出的条目数和各类问题的示例条目数
+	maxReportGroups  = 100
+	maxGroupIndexes  = 20
+	maxSampleIndexes = 100
+
+	// 参与近似去重的最大条目数，MinHash 签名常驻内存
+	maxNearDuplicateEntries = 200000
+	// 与同一分桶中最多多少个条目比较相似度
+	maxBucketCandidates = 50
+
+	minHashSize  = 64
+	minHashBands = 16
+	minHashRows  = minHashSize / minHashBands
+	shingleSize  = 5
+)
+
+// 长度分布的分桶边界，最后一个桶没有上限
+var lengthBucketEdges = []int{0, 1, 32, 128, 512, 2048, 8192, 32768}
+
+// QualityOptions 质量分析参数
+type QualityOptions struct {
+	DedupeFields           []string `json:"dedupe_fields"`            // 判断重复时比较的字段，默认 instruction 和 input
+	RequiredFields         []string `json:"required_fields"`          // 为空时记为问题的字段，默认 instruction 和 output
+	NearDuplicateThreshold float64  `json:"near_duplicate_threshold"` // 近似重复的 Jaccard 相似度阈值，默认 0.8
+	MaxTokens              int      `json:"max_tokens"`               // 单条近似 token 数上限，默认 4096
+}
+
+func (o QualityOptions) withDefaults() QualityOptions {
+	if len(o.DedupeFields) == 0 {
+		o.DedupeFields = []string{"instruction", "input"}
+	}
+	if len(o.RequiredFields) == 0 {
+		o.RequiredFields = []string{"instruction", "output"}
+	}
+	if o.NearDuplicateThreshold <= 0 || o.NearDuplicateThreshold > 1 {
+		o.NearDuplicateThreshold = 0.8
+	}
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = 4096
+	}
+	return o
+}
+
+// Validate 检查字段名是否有效
+func (o QualityOptions) Validate() error {
+	for _, field := range append(append([]string{}, o.DedupeFields...), o.RequiredFields...) {
+		if !isSearchField(field) {
+			return fmt.Errorf("unsupported field %q", field)
+		}
+	}
+	return nil
+}
+
+// QualityEntry 参与分析的条目
+type QualityEntry struct {
+	Index   int
+	Fields  map[string]string // instruction/input/output
+	Missing map[string]bool   // 原始记录中不存在的字段
+}
+
+// HistogramBucket 长度分布的一个分桶，Max 为 -1 时没有上限
+type HistogramBucket struct {
+	Min   int   `json:"min"`
+	Max   int   `json:"max"`
+	Count int64 `json:"count"`
+}
+
+// LengthStats 长度统计
+type LengthStats struct {
+	Min     int               `json:"min"`
+	Max     int               `json:"max"`
+	Mean    float64           `json:"mean"`
+	Buckets []HistogramBucket `json:"buckets"`
+
+	total int64
+	count int64
+}
+
+func newLengthStats() LengthStats {
+	stats := LengthStats{Buckets: make([]HistogramBucket, len(lengthBucketEdges))}
+	for i, edge := range lengthBucketEdges {
+		stats.Buckets[i] = HistogramBucket{Min: edge, Max: -1}
+		if i+1 < len(lengthBucketEdges) {
+			stats.Buckets[i].Max = lengthBucketEdges[i+1] - 1
+		}
+	}
+	return stats
+}
+
+func (s *LengthStats) add(n int) {
+	if s.count == 0 || n < s.Min {
+		s.Min = n
+	}
+	if n > s.Max {
+		s.Max = n
+	}
+	s.count++
+	s.total += int64(n)
+	s.Mean = float64(s.total) / float64(s.count)
+	bucket := sort.Search(len(lengthBucketEdges), func(i int) bool { return lengthBucketEdges[i] > n }) - 1
+	s.Buckets[bucket].Count++
+}
+
+// FieldQuality 单个字段的统计
+type FieldQuality struct {
+	Chars          LengthStats `json:"chars"`
+	Tokens         LengthStats `json:"tokens"`
+	Empty          int64       `json:"empty"`   // 字段存在但内容为空
+	Missing        int64       `json:"missing"` // 原始记录中没有该字段
+	EmptyEntries   []int       `json:"empty_entries"`
+	MissingEntries []int       `json:"missing_entries"`
+}
+
+// DuplicateGroup 一组重复或近似重复的条目，第一个条目之外的都会被标记
+type DuplicateGroup struct {
+	Hash         string  `json:"hash,omitempty"`
+	Similarity   float64 `json:"similarity,omitempty"` // 组内与第一个条目的最低相似度估计
+	Size         int     `json:"size"`
+	EntryIndexes []int   `json:"entry_indexes"`
+}
+
+// QualityReport 数据集质量报告，各列表只保留示例，完整的问题条目由 Flagged 返回
+type QualityReport struct {
+	Options    QualityOptions           `json:"options"`
+	EntryCount int64                    `json:"entry_count"`
+	Fields     map[string]*FieldQuality `json:"fields"`
+	Tokens     LengthStats              `json:"tokens"` // 整条数据的近似 token 数
+
+	DuplicateGroups     []DuplicateGroup `json:"duplicate_groups"`
+	DuplicateGroupCount int              `json:"duplicate_group_count"`
+
+	NearDuplicateClusters     []DuplicateGroup `json:"near_duplicate_clusters"`
+	NearDuplicateClusterCount int              `json:"near_duplicate_cluster_count"`
+	NearDuplicateTruncated    bool             `json:"near_duplicate_truncated,omitempty"` // 条目过多，超出部分未参与近似去重
+
+	TooLongEntries []int `json:"too_long_entries"`
+
+	Languages map[string]int64 `json:"languages"`
+	Issues    map[string]int64 `json:"issues"` // 各类问题标记的条目数
+}
+
+// QualityAnalyzer 逐条累积统计，生成质量报告
+type QualityAnalyzer struct {
+	options QualityOptions
+	report  *QualityReport
+
+	exact      map[uint64]int   // 归一化哈希 -> 第一个条目
+	duplicates map[uint64][]int // 出现重复的哈希 -> 全部条目
+
+	hashA, hashB [minHashSize]uint64
+	signatures   [][minHashSize]uint32
+	sigIndexes   []int
+	buckets      map[uint64][]int32
+	parent       []int32
+	similarity   []float64
+
+	flagged map[string][]int
+}
+
+// NewQualityAnalyzer 创建质量分析器
+func NewQualityAnalyzer(options QualityOptions) *QualityAnalyzer {
+	options = options.withDefaults()
+	a := &QualityAnalyzer{
+		options: options,
+		report: &QualityReport{
+			Options:   options,
+			Fields:    map[string]*FieldQuality{},
+			Tokens:    newLengthStats(),
+			Languages: map[string]int64{},
+			Issues:    map[string]int64{},
+		},
+		exact:      map[uint64]int{},
+		duplicates: map[uint64][]int{},
+		buckets:    map[uint64][]int32{},
+		flagged:    map[string][]int{},
+	}
+	for _, field := range SearchFields {
+		a.report.Fields[field] = &FieldQuality{Chars: newLengthStats(), Tokens: newLengthStats()}
+	}
+	// 固定种子，同一数据集多次分析的结果一致
+	rng := rand.New(rand.NewSource(1))
+	for i := range a.hashA {
+		a.hashA[i] = rng.Uint64() | 1
+		a.hashB[i] = rng.Uint64()
+	}
+	return a
+}
+
+// Add 统计一个条目，条目须按 entry_index 升序加入
+func (a *QualityAnalyzer) Add(entry QualityEntry) {
+	r := a.report
+	r.EntryCount++
+
+	totalTokens := 0
+	for _, field := range SearchFields {
+		text := entry.Fields[field]
+		stats := r.Fields[field]
+		tokens := ApproxTokens(text)
+		totalTokens += tokens
+		stats.Chars.add(len([]rune(text)))
+		stats.Tokens.add(tokens)
+
+		if strings.TrimSpace(text) != "" {
+			continue
+		}
+		if entry.Missing[field] {
+			stats.Missing++
+			stats.MissingEntries = appendSample(stats.MissingEntries, entry.Index)
+		} else {
+			stats.Empty++
+			stats.EmptyEntries = appendSample(stats.EmptyEntries, entry.Index)
+		}
+	}
+	r.Tokens.add(totalTokens)
+	r.Languages[DetectLanguage(entry.Fields["instruction"]+"\n"+entry.Fields["input"]+"\n"+entry.Fields["output"])]++
+
+	for _, field := range a.options.RequiredFields {
+		if strings.TrimSpace(entry.Fields[field]) == "" {
+			a.flag(QualityIssueEmpty, entry.Index)
+			break
+		}
+	}
+	if totalTokens > a.options.MaxTokens {
+		r.TooLongEntries = appendSample(r.TooLongEntries, entry.Index)
+		a.flag(QualityIssueTooLong, entry.Index)
+	}
+
+	normalized := a.dedupeText(entry)
+	if strings.Trim(normalized, "\x1f") == "" {
+		return
+	}
+	hash := fnvHash(normalized)
+	if first, ok := a.exact[hash]; ok {
+		if len(a.duplicates[hash]) == 0 {
+			a.duplicates[hash] = []int{first}
+		}
+		a.duplicates[hash] = append(a.duplicates[hash], entry.Index)
+		a.flag(QualityIssueDuplicate, entry.Index)
+		return
+	}
+	a.exact[hash] = entry.Index
+	a.addMinHash(entry.Index, normalized)
+}
+
+func (a *QualityAnalyzer) flag(issue string, index int) {
+	a.flagged[issue] = append(a.flagged[issue], index)
+}
+
+func appendSample(samples []int, index int) []int {
+	if len(samples) < maxSampleIndexes {
+		samples = append(samples, index)
+	}
+	return samples
+}
+
+// dedupeText 拼接去重字段并归一化：转小写、合并空白
+func (a *QualityAnalyzer) dedupeText(entry QualityEntry) string {
+	parts := make([]string, len(a.options.DedupeFields))
+	for i, field := range a.options.DedupeFields {
+		parts[i] = strings.Join(strings.Fields(strings.ToLower(entry.Fields[field])), " ")
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+func fnvHash(text string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return h.Sum64()
+}
+
+// addMinHash 计算字符 shingle 的 MinHash 签名，通过 LSH 分桶找到候选条目并合并相似的条目
+func (a *QualityAnalyzer) addMinHash(index int, text string) {
+	if len(a.signatures) >= maxNearDuplicateEntries {
+		a.report.NearDuplicateTruncated = true
+		return
+	}
+
+	var signature [minHashSize]uint32
+	for i := range signature {
+		signature[i] = ^uint32(0)
+	}
+	runes := []rune(text)
+	n := len(runes) - shingleSize + 1
+	if n < 1 {
+		n = 1
+	}
+	for start := 0; start < n; start++ {
+		end := start + shingleSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		base := fnvHash(string(runes[start:end]))
+		for i := range signature {
+			if h := uint32((a.hashA[i]*base + a.hashB[i]) >> 32); h < signature[i] {
+				signature[i] = h
+			}
+		}
+	}
+
+	id := int32(len(a.signatures))
+	a.signatures = append(a.signatures, signature)
+	a.sigIndexes = append(a.sigIndexes, index)
+	a.parent = append(a.parent, id)
+	a.similarity = append(a.similarity, 1)
+
+	compared := map[int32]bool{}
+	var band [minHashRows*4 + 1]byte
+	for b := 0; b < minHashBands; b++ {
+		band[0] = byte(b)
+		for r := 0; r < minHashRows; r++ {
+			binary.LittleEndian.PutUint32(band[1+r*4:], signature[b*minHashRows+r])
+		}
+		key := fnvHash(string(band[:]))
+		bucket := a.buckets[key]
+		for i, other := range bucket {
+			if i >= maxBucketCandidates {
+				break
+			}
+			if compared[other] {
+				continue
+			}
+			compared[other] = true
+			if sim := a.estimateSimilarity(other, id); sim >= a.options.NearDuplicateThreshold {
+				a.union(other, id, sim)
+			}
+		}
+		a.buckets[key] = append(bucket, id)
+	}
+}
+
+func (a *QualityAnalyzer) estimateSimilarity(x, y int32) float64 {
+	equal := 0
+	for i := 0; i < minHashSize; i++ {
+		if a.signatures[x][i] == a.signatures[y][i] {
+			equal++
+		}
+	}
+	return float64(equal) / minHashSize
+}
+
+func (a *QualityAnalyzer) find(x int32) int32 {
+	for a.parent[x] != x {
+		a.parent[x] = a.parent[a.parent[x]]
+		x = a.parent[x]
+	}
+	return x
+}
+
+// union 合并两个簇，序号较小(entry_index 较小)的根保留
+func (a *QualityAnalyzer) union(x, y int32, sim float64) {
+	rx, ry := a.find(x), a.find(y)
+	if rx == ry {
+		return
+	}
+	if ry < rx {
+		rx, ry = ry, rx
+	}
+	a.parent[ry] = rx
+	a.similarity[rx] = minFloat(a.similarity[rx], minFloat(a.similarity[ry], sim))
+}
+
+func minFloat(x, y float64) float64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// Finish 生成质量报告，并返回各类问题标记的全部 entry_index
+func (a *QualityAnalyzer) Finish() (*QualityReport, map[string][]int) {
+	r := a.report
+
+	for hash, indexes := range a.duplicates {
+		r.DuplicateGroups = append(r.DuplicateGroups, DuplicateGroup{
+			Hash:         fmt.Sprintf("%016x", hash),
+			Size:         len(indexes),
+			EntryIndexes: indexes,
+		})
+	}
+	r.DuplicateGroupCount = len(r.DuplicateGroups)
+	r.DuplicateGroups = topGroups(r.DuplicateGroups)
+
+	clusters := map[int32][]int{}
+	for id := range a.signatures {
+		root := a.find(int32(id))
+		clusters[root] = append(clusters[root], a.sigIndexes[id])
+	}
+	for root, indexes := range clusters {
+		if len(indexes) < 2 {
+			continue
+		}
+		for _, index := range indexes[1:] {
+			a.flag(QualityIssueNearDuplicate, index)
+		}
+		r.NearDuplicateClusters = append(r.NearDuplicateClusters, DuplicateGroup{
+			Similarity:   a.similarity[root],
+			Size:         len(indexes),
+			EntryIndexes: indexes,
+		})
+	}
+	r.NearDuplicateClusterCount = len(r.NearDuplicateClusters)
+	r.NearDuplicateClusters = topGroups(r.NearDuplicateClusters)
+
+	for _, issue := range QualityIssues {
+		sort.Ints(a.flagged[issue])
+		r.Issues[issue] = int64(len(a.flagged[issue]))
+	}
+	return r, a.flagged
+}
+
+// topGroups 按组大小降序保留前 maxReportGroups 组，每组只列出前 maxGroupIndexes 个条目
+func topGroups(groups []DuplicateGroup) []DuplicateGroup {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size > groups[j].Size
+		}
+		return groups[i].EntryIndexes[0] < groups[j].EntryIndexes[0]
+	})
+	if len(groups) > maxReportGroups {
+		groups = groups[:maxReportGroups]
+	}
+	for i := range groups {
+		if len(groups[i].EntryIndexes) > maxGroupIndexes {
+			groups[i].EntryIndexes = groups[i].EntryIndexes[:maxGroupIndexes]
+		}
+	}
+	if groups == nil {
+		groups = []DuplicateGroup{}
+	}
+	return groups
+}
+
+// ApproxTokens 估算文本的 token 数：中日韩字符每字一个 token，其余每个词约每 4 个字符一个 token
+func ApproxTokens(text string) int {
+	tokens, word := 0, 0
+	flush := func() {
+		tokens += (word + 3) / 4
+		word = 0
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flush()
+			tokens++
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			flush()
+			tokens++
+		default:
+			word++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// DetectLanguage 按文字系统粗略判断语言：zh/ja/ko/ru/ar/hi/th，拉丁字母记为 latin，无文字时为 unknown
+func DetectLanguage(text string) string {
+	counts := map[string]int{}
+	kana := 0
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+			counts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			counts["zh"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Latin, r):
+			counts["latin"]++
+		}
+	}
+	// 日文同时使用汉字和假名
+	if kana > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+
+	language, best := "unknown", 0
+	for _, name := range []string{"zh", "ja", "ko", "ru", "ar", "hi", "th", "latin"} {
+		if counts[name] > best {
+			language, best = name, counts[name]
+		}
+	}
+	return language
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func qualityEntry(index int, instruction, input, output string) QualityEntry {
+	return QualityEntry{Index: index, Fields: map[string]string{"instruction": instruction, "input": input, "output": output}}
+}
+
+func TestQualityAnalyzerDuplicates(t *testing.T) {
+	a := NewQualityAnalyzer(QualityOptions{})
+	base := "Summarize the following article about renewable energy adoption in northern Europe during the last decade"
+	a.Add(qualityEntry(0, base, "", "ok"))
+	a.Add(qualityEntry(1, "  SUMMARIZE the following article about renewable energy adoption in northern Europe during the last decade ", "", "other"))
+	a.Add(qualityEntry(2, base+"!", "", "ok"))
+	a.Add(qualityEntry(3, "Translate this sentence into French please", "", "ok"))
+	a.Add(qualityEntry(5, base, "", "again"))
+
+	report, flagged := a.Finish()
+	if !reflect.DeepEqual(flagged[QualityIssueDuplicate], []int{1, 5}) {
+		t.Errorf("duplicates: %v", flagged[QualityIssueDuplicate])
+	}
+	if report.DuplicateGroupCount != 1 || !reflect.DeepEqual(report.DuplicateGroups[0].EntryIndexes, []int{0, 1, 5}) {
+		t.Errorf("duplicate groups: %+v", report.DuplicateGroups)
+	}
+	if !reflect.DeepEqual(flagged[QualityIssueNearDuplicate], []int{2}) {
+		t.Errorf("near duplicates: %v", flagged[QualityIssueNearDuplicate])
+	}
+	if report.NearDuplicateClusterCount != 1 || report.NearDuplicateClusters[0].Similarity < 0.8 {
+		t.Errorf("near duplicate clusters: %+v", report.NearDuplicateClusters)
+	}
+	if report.Issues[QualityIssueDuplicate] != 2 || report.Issues[QualityIssueNearDuplicate] != 1 {
+		t.Errorf("issues: %v", report.Issues)
+	}
+}
+
+func TestQualityAnalyzerFieldsAndLength(t *testing.T) {
+	a := NewQualityAnalyzer(QualityOptions{MaxTokens: 10})
+	missing := qualityEntry(0, "你好世界", "", "")
+	missing.Missing = map[string]bool{"input": true}
+	a.Add(missing)
+	a.Add(qualityEntry(1, "hello world", "context", "answer"))
+	a.Add(qualityEntry(2, "这是一个很长很长很长的问题吗", "", "是"))
+
+	report, flagged := a.Finish()
+	if report.EntryCount != 3 {
+		t.Errorf("entry count: %d", report.EntryCount)
+	}
+	input := report.Fields["input"]
+	if input.Missing != 1 || input.Empty != 1 || !reflect.DeepEqual(input.EmptyEntries, []int{2}) {
+		t.Errorf("input stats: %+v", input)
+	}
+	if !reflect.DeepEqual(flagged[QualityIssueEmpty], []int{0}) {
+		t.Errorf("empty: %v", flagged[QualityIssueEmpty])
+	}
+	if !reflect.DeepEqual(flagged[QualityIssueTooLong], []int{2}) {
+		t.Errorf("too long: %v", flagged[QualityIssueTooLong])
+	}
+	chars := report.Fields["instruction"].Chars
+	if chars.Min != 4 || chars.Max != 14 || chars.Buckets[1].Count != 3 {
+		t.Errorf("instruction chars: %+v", chars)
+	}
+	if report.Languages["zh"] != 2 || report.Languages["latin"] != 1 {
+		t.Errorf("languages: %v", report.Languages)
+	}
+}
+
+func TestQualityAnalyzerCaps(t *testing.T) {
+	a := NewQualityAnalyzer(QualityOptions{})
+	for i := 0; i < 2*maxReportGroups; i++ {
+		for j := 0; j < 2; j++ {
+			a.Add(qualityEntry(i*2+j, fmt.Sprintf("question number %d", i), "", "a"))
+		}
+	}
+	report, flagged := a.Finish()
+	if report.DuplicateGroupCount != 2*maxReportGroups || len(report.DuplicateGroups) != maxReportGroups {
+		t.Errorf("groups: %d listed of %d", len(report.DuplicateGroups), report.DuplicateGroupCount)
+	}
+	if len(flagged[QualityIssueDuplicate]) != 2*maxReportGroups {
+		t.Errorf("flagged: %d", len(flagged[QualityIssueDuplicate]))
+	}
+}
+
+func TestApproxTokens(t *testing.T) {
+	testCases := []struct {
+		text   string
+		tokens int
+	}{
+		{"", 0},
+		{"hello world", 4},
+		{"机器学习", 4},
+		{"hi, 世界", 4},
+	}
+	for _, tc := range testCases {
+		if got := ApproxTokens(tc.text); got != tc.tokens {
+			t.Errorf("ApproxTokens(%q) = %d, want %d", tc.text, got, tc.tokens)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	testCases := map[string]string{
+		"机器学习是什么":       "zh",
+		"これは日本語の文章です":   "ja",
+		"안녕하세요":         "ko",
+		"Привет мир":    "ru",
+		"hello world":   "latin",
+		"12345 !!!":     "unknown",
+		"hello 机器学习是什么": "zh",
+	}
+	for text, want := range testCases {
+		if got := DetectLanguage(text); got != want {
+			t.Errorf("DetectLanguage(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// 数据集模板类型
+const (
+	TemplateInstructionIO  = "instruction_io"
+	TemplateChat           = "chat"
+	TemplatePreference     = "preference"
+	TemplateClassification = "classification"
+)
+
+// DatasetTemplate 数据集模板：模板字段到 instruction/input/output 列的默认映射以及字段约束
+type DatasetTemplate struct {
+	Mapping ImportFieldMapping
+	Schema  string
+	// 模板包含的列，为空时包含 instruction/input/output 全部列
+	Columns []string
+}
+
+// DatasetTemplates 内置的数据集模板
+var DatasetTemplates = map[string]DatasetTemplate{
+	TemplateInstructionIO: {
+		Mapping: ImportFieldMapping{}.withDefaults(),
+		Schema: `{
+			"type": "object",
+			"required": ["instruction"],
+			"properties": {
+				"instruction": {"type": ["string", "number", "boolean"], "minLength": 1}
+			}
+		}`,
+	},
+	TemplateChat: {
+		Mapping: ImportFieldMapping{Messages: "messages"}.withDefaults(),
+		Schema: `{
+			"type": "object",
+			"required": ["messages"],
+			"properties": {
+				"messages": {
+					"type": "array",
+					"minItems": 1,
+					"items": {
+						"anyOf": [
+							{"type": "object", "required": ["role", "content"], "properties": {"role": {"type": "string"}}},
+							{"type": "object", "required": ["from", "value"], "properties": {"from": {"type": "string"}}}
+						]
+					}
+				}
+			}
+		}`,
+	},
+	TemplatePreference: {
+		Mapping: ImportFieldMapping{Instruction: "prompt", Output: "chosen"}.withDefaults(),
+		Columns: []string{"instruction", "output"},
+		Schema: `{
+			"type": "object",
+			"required": ["prompt", "chosen", "rejected"],
+			"properties": {
+				"prompt": {"type": "string", "minLength": 1},
+				"chosen": {"type": "string"},
+				"rejected": {"type": "string"}
+			}
+		}`,
+	},
+	TemplateClassification: {
+		Mapping: ImportFieldMapping{Instruction: "text", Output: "label"}.withDefaults(),
+		Columns: []string{"instruction", "output"},
+		Schema: `{
+			"type": "object",
+			"required": ["text", "label"],
+			"properties": {
+				"text": {"type": "string", "minLength": 1},
+				"label": {"type": ["string", "number", "boolean"]}
+			}
+		}`,
+	},
+}
+
+// FieldError 字段级校验错误，Field 为点分隔的字段路径，如 messages.0.role
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors 条目校验失败的字段列表
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		if fieldErr.Field == "" {
+			messages[i] = fieldErr.Message
+		} else {
+			messages[i] = fieldErr.Field + ": " + fieldErr.Message
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// DatasetSchema 编译后的数据集模板和自定义 JSON Schema
+// 模板约束作用于按字段映射换算后的记录，自定义 Schema 作用于原始记录
+type DatasetSchema struct {
+	TemplateType string
+	template     DatasetTemplate
+	builtin      *jsonschema.Schema
+	custom       *jsonschema.Schema
+}
+
+// CompileDatasetSchema 编译数据集的模板和 JSON Schema 定义，templateType 为空时使用 instruction_io
+func CompileDatasetSchema(templateType, schemaDefinition string) (*DatasetSchema, error) {
+	if templateType == "" {
+		templateType = TemplateInstructionIO
+	}
+	template, ok := DatasetTemplates[templateType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported template type %q", templateType)
+	}
+
+	schema := &DatasetSchema{TemplateType: templateType, template: template}
+	var err error
+	if schema.builtin, err = jsonschema.CompileString(templateType+".json", template.Schema); err != nil {
+		return nil, fmt.Errorf("invalid template schema: %w", err)
+	}
+	if strings.TrimSpace(schemaDefinition) != "" {
+		if schema.custom, err = jsonschema.CompileString("schema.json", schemaDefinition); err != nil {
+			return nil, fmt.Errorf("invalid JSON schema: %w", err)
+		}
+	}
+	return schema, nil
+}
+
+// Mapping 返回导入时使用的字段映射，override 中非空的字段覆盖模板默认值
+func (s *DatasetSchema) Mapping(override ImportFieldMapping) ImportFieldMapping {
+	mapping := ImportFieldMapping{}.withDefaults()
+	if s != nil {
+		mapping = s.template.Mapping
+	}
+	if override.Instruction != "" {
+		mapping.Instruction = override.Instruction
+	}
+	if override.Input != "" {
+		mapping.Input = override.Input
+	}
+	if override.Output != "" {
+		mapping.Output = override.Output
+	}
+	if override.Messages != "" {
+		mapping.Messages = override.Messages
+	}
+	return mapping
+}
+
+// Record 按字段映射生成条目并校验，校验失败时返回 FieldErrors
+// s 为 nil 时只按默认映射生成条目，不做校验
+func (s *DatasetSchema) Record(fields map[string]interface{}, override ImportFieldMapping) (ImportRecord, error) {
+	mapping := s.Mapping(override)
+	record, recordErr := recordFromFields(fields, mapping)
+	if s == nil {
+		return record, recordErr
+	}
+
+	instance, err := jsonInstance(fields)
+	if err != nil {
+		return record, err
+	}
+	var fieldErrs FieldErrors
+	if s.custom != nil {
+		fieldErrs = append(fieldErrs, validateInstance(s.custom, instance)...)
+	}
+	if _, isChat := chatMessages(fields, mapping); isChat && recordErr != nil {
+		// 对话无法解析出指令时，错误归到消息字段上
+		fieldErrs = append(fieldErrs, FieldError{Field: chatField(fields, mapping), Message: recordErr.Error()})
+	} else {
+		canonical := s.canonical(instance, mapping, record, recordErr == nil)
+		fieldErrs = append(fieldErrs, validateInstance(s.builtin, canonical)...)
+	}
+	if len(fieldErrs) > 0 {
+		return record, dedupeFieldErrors(fieldErrs)
+	}
+	return record, recordErr
+}
+
+// chatField 返回对话记录的消息字段名
+func chatField(fields map[string]interface{}, mapping ImportFieldMapping) string {
+	if mapping.Messages != "" {
+		return mapping.Messages
+	}
+	for _, key := range []string{"messages", "conversations"} {
+		if _, ok := fields[key]; ok {
+			return key
+		}
+	}
+	return "messages"
+}
+
+// Columns 从已保存的记录中读取 instruction/input/output 列，不做校验
+func (s *DatasetSchema) Columns(fields map[string]interface{}) ImportRecord {
+	record, _ := recordFromFields(fields, s.Mapping(ImportFieldMapping{}))
+	return record
+}
+
+// MissingFields 返回原始记录中没有对应源字段的 instruction/input/output 列，对话记录的列由消息推导，不计为缺失
+// 模板不包含的列(如 preference 的 input)不检查
+func (s *DatasetSchema) MissingFields(fields map[string]interface{}) map[string]bool {
+	mapping := s.Mapping(ImportFieldMapping{})
+	if _, isChat := chatMessages(fields, mapping); isChat {
+		return nil
+	}
+	sources := map[string]string{"instruction": mapping.Instruction, "input": mapping.Input, "output": mapping.Output}
+	columns := []string{"instruction", "input", "output"}
+	if s != nil && len(s.template.Columns) > 0 {
+		columns = s.template.Columns
+	}
+	missing := map[string]bool{}
+	for _, column := range columns {
+		if _, ok := fields[sources[column]]; !ok {
+			missing[column] = true
+		}
+	}
+	return missing
+}
+
+// canonical 将记录换算为模板字段名：映射到其他源字段的值复制到模板字段，
+// 按 instruction_io 导入的对话记录用解析出的列补齐模板字段
+func (s *DatasetSchema) canonical(instance map[string]interface{}, mapping ImportFieldMapping, record ImportRecord, derived bool) map[string]interface{} {
+	canonical := make(map[string]interface{}, len(instance)+3)
+	for key, value := range instance {
+		canonical[key] = value
+	}
+
+	columns := []struct {
+		target, source, value string
+	}{
+		{s.template.Mapping.Instruction, mapping.Instruction, record.Instruction},
+		{s.template.Mapping.Input, mapping.Input, record.Input},
+		{s.template.Mapping.Output, mapping.Output, record.Output},
+		{s.template.Mapping.Messages, mapping.Messages, ""},
+	}
+	for _, column := range columns {
+		if column.target == "" {
+			continue
+		}
+		if column.source != column.target {
+			if value, ok := instance[column.source]; ok {
+				canonical[column.target] = value
+				continue
+			}
+		}
+		if _, ok := canonical[column.target]; !ok && derived && column.value != "" {
+			canonical[column.target] = column.value
+		}
+	}
+	return canonical
+}
+
+// jsonInstance 将记录转换为 JSON 解码后的值，Parquet 等来源的 Go 类型据此统一
+func jsonInstance(fields map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("record is not JSON serializable: %w", err)
+	}
+	return decodeJSONObject(data)
+}
+
+func validateInstance(schema *jsonschema.Schema, instance map[string]interface{}) FieldErrors {
+	err := schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return FieldErrors{{Message: err.Error()}}
+	}
+	var fieldErrs FieldErrors
+	collectFieldErrors(validationErr, &fieldErrs)
+	return fieldErrs
+}
+
+// collectFieldErrors 收集叶子错误，required 错误按缺失字段拆分
+func collectFieldErrors(err *jsonschema.ValidationError, out *FieldErrors) {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			collectFieldErrors(cause, out)
+		}
+		return
+	}
+
+	field := instanceField(err.InstanceLocation)
+	if missing, ok := strings.CutPrefix(err.Message, "missing properties: "); ok {
+		for _, name := range strings.Split(missing, ", ") {
+			name = strings.Trim(name, "'")
+			if field != "" {
+				name = field + "." + name
+			}
+			*out = append(*out, FieldError{Field: name, Message: "is required"})
+		}
+		return
+	}
+	*out = append(*out, FieldError{Field: field, Message: err.Message})
+}
+
+// instanceField 将 JSON Pointer 形式的位置转为点分隔路径
+func instanceField(location string) string {
+	location = strings.TrimPrefix(location, "/")
+	if location == "" {
+		return ""
+	}
+	parts := strings.Split(location, "/")
+	for i, part := range parts {
+		parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func dedupeFieldErrors(fieldErrs FieldErrors) FieldErrors {
+	seen := make(map[FieldError]bool, len(fieldErrs))
+	result := fieldErrs[:0]
+	for _, fieldErr := range fieldErrs {
+		if !seen[fieldErr] {
+			seen[fieldErr] = true
+			result = append(result, fieldErr)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Field < result[j].Field })
+	return result
+}
+
+// DecodeDatasetRecord 解析单条记录的 JSON 对象，数字保持原始精度
+func DecodeDatasetRecord(data []byte) (map[string]interface{}, error) {
+	return decodeJSONObject(bytes.TrimSpace(data))
+}
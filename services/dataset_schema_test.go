@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func recordFields(t *testing.T, content string) map[string]interface{} {
+	t.Helper()
+	fields, err := DecodeDatasetRecord([]byte(content))
+	if err != nil {
+		t.Fatalf("invalid record %s: %v", content, err)
+	}
+	return fields
+}
+
+func fieldErrorsOf(t *testing.T, err error) FieldErrors {
+	t.Helper()
+	var fieldErrs FieldErrors
+	if !errors.As(err, &fieldErrs) {
+		t.Fatalf("expected field errors, got %v", err)
+	}
+	return fieldErrs
+}
+
+func TestDatasetSchemaTemplates(t *testing.T) {
+	testCases := []struct {
+		template string
+		record   string
+		fields   []string // 期望出错的字段，为空表示校验通过
+	}{
+		{TemplateInstructionIO, `{"instruction": "hi", "output": "hello", "tags": ["a"]}`, nil},
+		{TemplateInstructionIO, `{"messages": [{"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}]}`, nil},
+		{TemplateInstructionIO, `{"input": "x"}`, []string{"instruction"}},
+		{TemplateChat, `{"messages": [{"from": "human", "value": "hi"}, {"from": "gpt", "value": "hello"}]}`, nil},
+		{TemplateChat, `{"messages": []}`, []string{"messages"}},
+		{TemplateChat, `{"messages": [{"role": "user", "content": "hi"}]}`, []string{"messages"}},
+		{TemplatePreference, `{"prompt": "p", "chosen": "a", "rejected": "b"}`, nil},
+		{TemplatePreference, `{"prompt": "p", "chosen": 1}`, []string{"chosen", "rejected"}},
+		{TemplateClassification, `{"text": "good movie", "label": "positive", "source": "imdb"}`, nil},
+		{TemplateClassification, `{"text": ""}`, []string{"label", "text"}},
+	}
+	for _, tc := range testCases {
+		schema, err := CompileDatasetSchema(tc.template, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = schema.Record(recordFields(t, tc.record), ImportFieldMapping{})
+		if len(tc.fields) == 0 {
+			if err != nil {
+				t.Errorf("%s %s: unexpected error %v", tc.template, tc.record, err)
+			}
+			continue
+		}
+		fieldErrs := fieldErrorsOf(t, err)
+		if len(fieldErrs) != len(tc.fields) {
+			t.Errorf("%s %s: expected errors on %v, got %v", tc.template, tc.record, tc.fields, fieldErrs)
+			continue
+		}
+		for i, fieldErr := range fieldErrs {
+			if fieldErr.Field != tc.fields[i] {
+				t.Errorf("%s %s: expected errors on %v, got %v", tc.template, tc.record, tc.fields, fieldErrs)
+				break
+			}
+		}
+	}
+}
+
+func TestDatasetSchemaColumns(t *testing.T) {
+	schema, err := CompileDatasetSchema(TemplateClassification, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := schema.Record(recordFields(t, `{"text": "good movie", "label": 1}`), ImportFieldMapping{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Instruction != "good movie" || record.Output != "1" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	// 字段映射到其他源字段时按模板字段校验
+	record, err = schema.Record(recordFields(t, `{"review": "bad movie", "label": "negative"}`), ImportFieldMapping{Instruction: "review"})
+	if err != nil || record.Instruction != "bad movie" {
+		t.Errorf("unexpected record %+v: %v", record, err)
+	}
+}
+
+func TestDatasetSchemaCustom(t *testing.T) {
+	if _, err := CompileDatasetSchema("unknown", ""); err == nil {
+		t.Error("expected error for unknown template")
+	}
+	if _, err := CompileDatasetSchema(TemplateInstructionIO, `{"type": 1}`); err == nil {
+		t.Error("expected error for invalid schema")
+	}
+
+	schema, err := CompileDatasetSchema(TemplateInstructionIO, `{
+		"type": "object",
+		"required": ["lang"],
+		"properties": {
+			"lang": {"enum": ["zh", "en"]},
+			"meta": {"type": "object", "properties": {"score": {"type": "number", "maximum": 1}}}
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schema.Record(recordFields(t, `{"instruction": "hi", "lang": "zh", "meta": {"score": 0.5}}`), ImportFieldMapping{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	fieldErrs := fieldErrorsOf(t, func() error {
+		_, err := schema.Record(recordFields(t, `{"instruction": "hi", "lang": "fr", "meta": {"score": 2}}`), ImportFieldMapping{})
+		return err
+	}())
+	if len(fieldErrs) != 2 || fieldErrs[0].Field != "lang" || fieldErrs[1].Field != "meta.score" {
+		t.Errorf("unexpected field errors %v", fieldErrs)
+	}
+}
+
+func TestDatasetSchemaMissingFields(t *testing.T) {
+	schema, err := CompileDatasetSchema(TemplatePreference, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// preference 模板没有 input 列，不计为缺失
+	missing := schema.MissingFields(map[string]interface{}{"prompt": "p", "rejected": "r"})
+	if !reflect.DeepEqual(missing, map[string]bool{"output": true}) {
+		t.Errorf("missing: %v", missing)
+	}
+	if missing := (*DatasetSchema)(nil).MissingFields(map[string]interface{}{"instruction": "i"}); !reflect.DeepEqual(missing, map[string]bool{"input": true, "output": true}) {
+		t.Errorf("instruction_io missing: %v", missing)
+	}
+	chat := map[string]interface{}{"messages": []interface{}{}}
+	if missing := (*DatasetSchema)(nil).MissingFields(chat); len(missing) != 0 {
+		t.Errorf("chat record missing: %v", missing)
+	}
+}
+
+func TestParseDatasetFileSchema(t *testing.T) {
+	schema, err := CompileDatasetSchema(TemplatePreference, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "{\"prompt\": \"p\", \"chosen\": \"a\", \"rejected\": \"b\"}\n{\"prompt\": \"p\", \"chosen\": \"a\"}\n"
+
+	var records []ImportRecord
+	report, err := ParseDatasetFile(context.Background(), bytes.NewReader([]byte(content)), int64(len(content)), ImportFormatJSONL, schema, ImportFieldMapping{}, func(record ImportRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || report.Failed != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if records[0].Instruction != "p" || records[0].Output != "a" {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+	if errs := report.Errors[0]; errs.Line != 2 || len(errs.Fields) != 1 || errs.Fields[0].Field != "rejected" {
+		t.Errorf("unexpected error report %+v", report.Errors)
+	}
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 可检索的条目字段
+var SearchFields = []string{"instruction", "input", "output"}
+
+// 高亮片段：首个命中前保留的字符数和片段最大字符数
+const (
+	snippetContext = 40
+	snippetLength  = 160
+)
+
+// SearchTerm 检索词，Field 为空时匹配全部字段
+type SearchTerm struct {
+	Field string
+	Text  string
+
+	re *regexp.Regexp
+}
+
+// SearchQuery 解析后的检索条件：Groups 之间为 AND，组内为 OR，命中 Excluded 中任一项的条目被排除
+type SearchQuery struct {
+	Groups   [][]SearchTerm
+	Excluded []SearchTerm
+}
+
+// ParseSearchQuery 解析检索语句，支持：
+//
+//	机器 学习          同时包含两个词
+//	"machine learning" 短语
+//	cat OR dog         任一词，OR 的优先级高于相邻词之间隐含的 AND
+//	-spam / NOT spam   排除
+//	output:"hello"     只在指定字段(instruction/input/output)中检索
+//
+// defaultField 为未指定字段的检索词使用的字段，为空或 all 时检索全部字段
+func ParseSearchQuery(query, defaultField string) (*SearchQuery, error) {
+	if defaultField == "all" {
+		defaultField = ""
+	}
+	if defaultField != "" && !isSearchField(defaultField) {
+		return nil, fmt.Errorf("unsupported search field %q", defaultField)
+	}
+
+	q := &SearchQuery{}
+	or, not := false, false
+	for _, token := range tokenizeSearchQuery(query) {
+		if !token.quoted {
+			switch token.text {
+			case "OR":
+				or = len(q.Groups) > 0
+				continue
+			case "AND":
+				continue
+			case "NOT":
+				not = true
+				continue
+			}
+		}
+
+		term := SearchTerm{Field: defaultField, Text: token.text}
+		if token.field != "" {
+			term.Field = token.field
+		}
+		if strings.TrimSpace(term.Text) == "" {
+			continue
+		}
+		term.re = regexp.MustCompile("(?i)" + term.pattern())
+		not = not || token.negated
+
+		switch {
+		case not:
+			q.Excluded = append(q.Excluded, term)
+		case or:
+			last := len(q.Groups) - 1
+			q.Groups[last] = append(q.Groups[last], term)
+		default:
+			q.Groups = append(q.Groups, []SearchTerm{term})
+		}
+		or, not = false, false
+	}
+
+	if len(q.Groups) == 0 {
+		return nil, errors.New("search query must contain at least one term that is not excluded")
+	}
+	return q, nil
+}
+
+type searchToken struct {
+	field   string
+	text    string
+	quoted  bool
+	negated bool
+}
+
+// tokenizeSearchQuery 按空白切分，双引号内为一个短语，- 前缀表示排除，field:xxx 前缀识别为字段限定
+func tokenizeSearchQuery(query string) []searchToken {
+	var tokens []searchToken
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		var token searchToken
+		if query[i] == '-' && i+1 < len(query) && !unicode.IsSpace(rune(query[i+1])) {
+			token.negated = true
+			i++
+		}
+		start := i
+		for i < len(query) && query[i] != '"' && query[i] != ':' {
+			r, size := utf8.DecodeRuneInString(query[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		if i < len(query) && query[i] == ':' && isSearchField(query[start:i]) {
+			token.field = query[start:i]
+			i++
+			start = i
+		}
+		if i < len(query) && query[i] == '"' && i == start {
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				end = len(query) - i - 1
+			}
+			token.text = query[i+1 : i+1+end]
+			token.quoted = true
+			i += end + 2
+		} else {
+			for i < len(query) {
+				r, size := utf8.DecodeRuneInString(query[i:])
+				if unicode.IsSpace(r) {
+					break
+				}
+				i += size
+			}
+			token.text = strings.Trim(query[start:i], `"`)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func isSearchField(field string) bool {
+	for _, name := range SearchFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Terms 返回全部需要命中的检索词
+func (q *SearchQuery) Terms() []SearchTerm {
+	var terms []SearchTerm
+	for _, group := range q.Groups {
+		terms = append(terms, group...)
+	}
+	return terms
+}
+
+// pattern 返回检索词的匹配表达式：不区分大小写的子串匹配，短语中的空白可匹配任意空白
+func (t SearchTerm) pattern() string {
+	words := strings.Fields(t.Text)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	return strings.Join(words, `\s+`)
+}
+
+func (t SearchTerm) matches(fields map[string]string) bool {
+	if t.Field != "" {
+		return t.re.MatchString(fields[t.Field])
+	}
+	for _, name := range SearchFields {
+		if t.re.MatchString(fields[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 在内存中判断条目是否满足检索条件，用于没有全文索引时的扫描
+func (q *SearchQuery) Match(fields map[string]string) bool {
+	for _, term := range q.Excluded {
+		if term.matches(fields) {
+			return false
+		}
+	}
+	for _, group := range q.Groups {
+		matched := false
+		for _, term := range group {
+			if term.matches(fields) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// FTS5Expr 生成 SQLite FTS5 的 MATCH 表达式，索引中的文本须经过 SegmentCJK 处理
+func (q *SearchQuery) FTS5Expr() string {
+	term := func(t SearchTerm) string {
+		phrase := `"` + strings.ReplaceAll(SegmentCJK(t.Text), `"`, `""`) + `"`
+		if t.Field != "" {
+			return t.Field + " : " + phrase
+		}
+		return phrase
+	}
+	expr := joinSearchGroups(q.Groups, " OR ", " AND ", term)
+	if len(q.Excluded) > 0 {
+		excluded := make([]string, len(q.Excluded))
+		for i, t := range q.Excluded {
+			excluded[i] = term(t)
+		}
+		expr = "(" + expr + ") NOT (" + strings.Join(excluded, " OR ") + ")"
+	}
+	return expr
+}
+
+// MySQLCondition 生成 MySQL 全文检索的 WHERE 条件，每个字段及三个字段的组合上都需要 FULLTEXT 索引
+func (q *SearchQuery) MySQLCondition() (string, []interface{}) {
+	var args []interface{}
+	term := func(t SearchTerm) string {
+		columns := strings.Join(SearchFields, ", ")
+		if t.Field != "" {
+			columns = t.Field
+		}
+		// 布尔模式下双引号内为短语，短语内无法转义双引号
+		args = append(args, `"`+strings.ReplaceAll(t.Text, `"`, " ")+`"`)
+		return "MATCH(" + columns + ") AGAINST (? IN BOOLEAN MODE)"
+	}
+	return q.sqlCondition(term), args
+}
+
+// LikeCondition 生成 LIKE 子串匹配的 WHERE 条件，用于数据库不支持全文索引时
+func (q *SearchQuery) LikeCondition() (string, []interface{}) {
+	var args []interface{}
+	term := func(t SearchTerm) string {
+		fields := SearchFields
+		if t.Field != "" {
+			fields = []string{t.Field}
+		}
+		conditions := make([]string, len(fields))
+		for i, field := range fields {
+			conditions[i] = field + " LIKE ?"
+			args = append(args, "%"+t.Text+"%")
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")"
+	}
+	return q.sqlCondition(term), args
+}
+
+func (q *SearchQuery) sqlCondition(term func(SearchTerm) string) string {
+	condition := joinSearchGroups(q.Groups, " OR ", " AND ", term)
+	for _, t := range q.Excluded {
+		condition += " AND NOT " + term(t)
+	}
+	return condition
+}
+
+func joinSearchGroups(groups [][]SearchTerm, or, and string, term func(SearchTerm) string) string {
+	parts := make([]string, len(groups))
+	for i, group := range groups {
+		alternatives := make([]string, len(group))
+		for j, t := range group {
+			alternatives[j] = term(t)
+		}
+		parts[i] = "(" + strings.Join(alternatives, or) + ")"
+	}
+	return strings.Join(parts, and)
+}
+
+// Highlights 返回各字段中命中检索词的片段，命中部分用 <mark></mark> 标记，其余内容做 HTML 转义
+func (q *SearchQuery) Highlights(fields map[string]string) map[string]string {
+	highlights := map[string]string{}
+	for _, field := range SearchFields {
+		var patterns []string
+		for _, t := range q.Terms() {
+			if t.Field == "" || t.Field == field {
+				patterns = append(patterns, t.pattern())
+			}
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+		re := regexp.MustCompile("(?i)" + strings.Join(patterns, "|"))
+		if snippet, ok := highlightSnippet(fields[field], re); ok {
+			highlights[field] = snippet
+		}
+	}
+	return highlights
+}
+
+func highlightSnippet(text string, re *regexp.Regexp) (string, bool) {
+	matches := re.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	start := matches[0][0]
+	for n := 0; n < snippetContext && start > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := start
+	for n := 0; n < snippetLength && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	if end < matches[0][1] {
+		end = matches[0][1]
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] >= end {
+			break
+		}
+		if m[1] > end {
+			m[1] = end
+		}
+		b.WriteString(html.EscapeString(text[pos:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// SegmentCJK 在中日韩字符之间插入空格，使 FTS5 的 unicode61 分词器按单字建立索引
+func SegmentCJK(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	prevCJK := false
+	for i, r := range text {
+		cjk := isCJK(r)
+		if i > 0 && (cjk || prevCJK) {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+		prevCJK = cjk
+	}
+	return b.String()
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
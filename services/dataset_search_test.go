@@ -0,0 +1,117 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	testCases := []struct {
+		query    string
+		groups   [][]SearchTerm
+		excluded []SearchTerm
+	}{
+		{`机器 学习`, [][]SearchTerm{{{Text: "机器"}}, {{Text: "学习"}}}, nil},
+		{`"machine learning" -spam`, [][]SearchTerm{{{Text: "machine learning"}}}, []SearchTerm{{Text: "spam"}}},
+		{`a b OR c NOT d`, [][]SearchTerm{{{Text: "a"}}, {{Text: "b"}, {Text: "c"}}}, []SearchTerm{{Text: "d"}}},
+		{`output:"hi there" input:x -instruction:y`, [][]SearchTerm{{{Field: "output", Text: "hi there"}}, {{Field: "input", Text: "x"}}}, []SearchTerm{{Field: "instruction", Text: "y"}}},
+		{`url:http://x "-literal"`, [][]SearchTerm{{{Text: "url:http://x"}}, {{Text: "-literal"}}}, nil},
+	}
+	for _, tc := range testCases {
+		q, err := ParseSearchQuery(tc.query, "all")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.query, err)
+		}
+		for _, group := range q.Groups {
+			for i := range group {
+				group[i].re = nil
+			}
+		}
+		for i := range q.Excluded {
+			q.Excluded[i].re = nil
+		}
+		if !reflect.DeepEqual(q.Groups, tc.groups) || !reflect.DeepEqual(q.Excluded, tc.excluded) {
+			t.Errorf("%s: got %+v excluded %+v", tc.query, q.Groups, q.Excluded)
+		}
+	}
+
+	if _, err := ParseSearchQuery("-spam", "all"); err == nil {
+		t.Error("expected error for query without positive terms")
+	}
+	if _, err := ParseSearchQuery("x", "raw_content"); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if q, _ := ParseSearchQuery("x output:y", "input"); q.Groups[0][0].Field != "input" || q.Groups[1][0].Field != "output" {
+		t.Errorf("default field not applied: %+v", q.Groups)
+	}
+}
+
+func TestSearchQueryRender(t *testing.T) {
+	q, err := ParseSearchQuery(`机器 cat OR output:say"hi -spam`, "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expr, want := q.FTS5Expr(), `(("机 器") AND ("cat" OR output : "say""hi")) NOT ("spam")`; expr != want {
+		t.Errorf("FTS5Expr = %s, want %s", expr, want)
+	}
+
+	condition, args := q.MySQLCondition()
+	want := `(MATCH(instruction, input, output) AGAINST (? IN BOOLEAN MODE)) AND (MATCH(instruction, input, output) AGAINST (? IN BOOLEAN MODE) OR MATCH(output) AGAINST (? IN BOOLEAN MODE)) AND NOT MATCH(instruction, input, output) AGAINST (? IN BOOLEAN MODE)`
+	if condition != want || len(args) != 4 || args[0] != `"机器"` || args[2] != `"say hi"` {
+		t.Errorf("MySQLCondition = %s %v", condition, args)
+	}
+
+	condition, args = q.LikeCondition()
+	if len(args) != 3+3+1+3 || args[6] != `%say"hi%` {
+		t.Errorf("LikeCondition = %s %v", condition, args)
+	}
+}
+
+func TestSearchQueryMatchAndHighlight(t *testing.T) {
+	q, err := ParseSearchQuery(`"hello  world" OR 你好 -spam`, "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := map[string]string{"instruction": "Say Hello\nWorld <b>", "output": "你好，世界"}
+	if !q.Match(entry) {
+		t.Error("expected entry to match")
+	}
+	if q.Match(map[string]string{"instruction": "hello world", "input": "SPAM"}) {
+		t.Error("excluded term matched")
+	}
+	if q.Match(map[string]string{"instruction": "hello"}) {
+		t.Error("partial phrase matched")
+	}
+
+	highlights := q.Highlights(entry)
+	if highlights["instruction"] != "Say <mark>Hello\nWorld</mark> &lt;b&gt;" {
+		t.Errorf("unexpected instruction highlight %q", highlights["instruction"])
+	}
+	if highlights["output"] != "<mark>你好</mark>，世界" {
+		t.Errorf("unexpected output highlight %q", highlights["output"])
+	}
+	if _, ok := highlights["input"]; ok {
+		t.Error("unexpected highlight for empty field")
+	}
+
+	long := make([]rune, 0, 610)
+	for i := 0; i < 300; i++ {
+		long = append(long, '字')
+	}
+	long = append(long, []rune("你好")...)
+	for i := 0; i < 300; i++ {
+		long = append(long, '尾')
+	}
+	snippet := q.Highlights(map[string]string{"input": string(long)})["input"]
+	if []rune(snippet)[0] != '…' || []rune(snippet)[len([]rune(snippet))-1] != '…' {
+		t.Errorf("long snippet not truncated: %s", snippet)
+	}
+}
+
+func TestSegmentCJK(t *testing.T) {
+	if got := SegmentCJK("用GPT-4学习ML"); got != "用 GPT-4 学 习 ML" {
+		t.Errorf("SegmentCJK = %q", got)
+	}
+}
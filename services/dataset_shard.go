@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// DatasetShardLines 每个 shard 对象保存的JSONL行数
+const DatasetShardLines = 10000
+
+// 行偏移索引的步长：每隔多少行记录一次字节偏移
+const shardOffsetStride = 100
+
+// ShardIndex shard 对象的行偏移索引
+// Offsets[k] 为第 k*shardOffsetStride 行的起始字节偏移，分页时据此发起范围读取
+type ShardIndex struct {
+	LineCount int
+	Size      int64
+	Offsets   []int64
+}
+
+// IndexJSONL 计算JSONL内容的行偏移索引，内容须以换行符结尾
+func IndexJSONL(data []byte) ShardIndex {
+	index := ShardIndex{Size: int64(len(data))}
+	for start := 0; start < len(data); {
+		if index.LineCount%shardOffsetStride == 0 {
+			index.Offsets = append(index.Offsets, int64(start))
+		}
+		index.LineCount++
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			break
+		}
+		start += end + 1
+	}
+	return index
+}
+
+// lineRange 返回读取 [from, from+count) 行需要的字节范围(闭区间)以及需要跳过的行数
+func (s ShardIndex) lineRange(from, count int) (start, end int64, skip int) {
+	checkpoint := from / shardOffsetStride
+	start = s.Offsets[checkpoint]
+	skip = from - checkpoint*shardOffsetStride
+
+	last := (from + count + shardOffsetStride - 1) / shardOffsetStride
+	if last < len(s.Offsets) {
+		end = s.Offsets[last] - 1
+	} else {
+		end = s.Size - 1
+	}
+	return start, end, skip
+}
+
+// encodeShard 将行拼接为以换行符结尾的JSONL内容
+func encodeShard(lines []string) []byte {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// splitJSONL 按 shardLines 行切分 reader 中的JSONL，每凑满一个 shard 调用一次 write
+// first 为已有的首个 shard 内容(追加时为最后一个未满的 shard)，可以为空
+func splitJSONL(r io.Reader, first []string, shardLines int, write func(lines []string) error) error {
+	lines := first
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line != "" {
+			lines = append(lines, strings.TrimRight(line, "\r\n"))
+			if len(lines) == shardLines {
+				if err := write(lines); err != nil {
+					return err
+				}
+				lines = make([]string, 0, shardLines)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(lines) > 0 {
+		return write(lines)
+	}
+	return nil
+}
+
+// WriteShard 上传 shard 内容(覆盖同名对象)并返回其索引
+func WriteShard(bucketName, objectPath string, lines []string) (ShardIndex, error) {
+	if err := initMinioClient(); err != nil {
+		return ShardIndex{}, err
+	}
+
+	data := encodeShard(lines)
+	_, err := minioClient.PutObject(context.Background(), bucketName, objectPath, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/jsonl",
+	})
+	if err != nil {
+		return ShardIndex{}, fmt.Errorf("上传shard失败: %v", err)
+	}
+	return IndexJSONL(data), nil
+}
+
+// ReadShard 读取 shard 的全部行
+func ReadShard(bucketName, objectPath string) ([]string, error) {
+	object, err := GetMinioObject(bucketName, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	lines := make([]string, 0, DatasetShardLines)
+	err = splitJSONL(object, nil, -1, func(all []string) error {
+		lines = all
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取shard失败: %v", err)
+	}
+	return lines, nil
+}
+
+// ReadShardLines 通过范围读取获取 shard 中 [from, from+count) 行
+func ReadShardLines(bucketName, objectPath string, index ShardIndex, from, count int) ([]string, error) {
+	if from >= index.LineCount || count <= 0 {
+		return nil, nil
+	}
+	if from+count > index.LineCount {
+		count = index.LineCount - from
+	}
+	if err := initMinioClient(); err != nil {
+		return nil, err
+	}
+
+	start, end, skip := index.lineRange(from, count)
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(start, end); err != nil {
+		return nil, err
+	}
+	object, err := minioClient.GetObject(context.Background(), bucketName, objectPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("获取对象失败: %v", err)
+	}
+	defer object.Close()
+
+	lines := make([]string, 0, count)
+	reader := bufio.NewReader(object)
+	for i := 0; len(lines) < count; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("读取对象内容失败: %v", err)
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		if i >= skip {
+			lines = append(lines, strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return lines, nil
+}
+
+// SplitJSONLToShards 将 reader 中的JSONL切分为多个 shard 上传
+// first 为第一个 shard 已有的行，objectPathFor 返回第 i 个新写入 shard 的对象路径
+func SplitJSONLToShards(bucketName string, r io.Reader, first []string, objectPathFor func(i int) string) ([]ShardIndex, error) {
+	var indexes []ShardIndex
+	err := splitJSONL(r, first, DatasetShardLines, func(lines []string) error {
+		index, err := WriteShard(bucketName, objectPathFor(len(indexes)), lines)
+		if err != nil {
+			return err
+		}
+		indexes = append(indexes, index)
+		return nil
+	})
+	return indexes, err
+}
+
+// MinioObjectExists 判断对象是否存在
+func MinioObjectExists(bucketName, objectPath string) (bool, error) {
+	if err := initMinioClient(); err != nil {
+		return false, err
+	}
+
+	_, err := minioClient.StatObject(context.Background(), bucketName, objectPath, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if code := minio.ToErrorResponse(err).Code; code == "NoSuchKey" || code == "NoSuchBucket" {
+		return false, nil
+	}
+	return false, fmt.Errorf("获取对象信息失败: %v", err)
+}
+
+// RemoveMinioPrefix 删除指定前缀下的全部对象
+func RemoveMinioPrefix(bucketName, prefix string) error {
+	if err := initMinioClient(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	objects := minioClient.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for result := range minioClient.RemoveObjects(ctx, bucketName, objects, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil {
+			return fmt.Errorf("删除对象 %s 失败: %v", result.ObjectName, result.Err)
+		}
+	}
+	return nil
+}
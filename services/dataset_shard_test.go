@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIndexJSONL(t *testing.T) {
+	lines := make([]string, 250)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(`{"i":%d}`, i)
+	}
+	data := encodeShard(lines)
+
+	index := IndexJSONL(data)
+	if index.LineCount != 250 || index.Size != int64(len(data)) {
+		t.Fatalf("unexpected index %+v", index)
+	}
+	if len(index.Offsets) != 3 {
+		t.Fatalf("expected 3 offsets, got %v", index.Offsets)
+	}
+	for k, offset := range index.Offsets {
+		want := fmt.Sprintf(`{"i":%d}`, k*shardOffsetStride)
+		if !strings.HasPrefix(string(data[offset:]), want) {
+			t.Errorf("offset %d points to %q, want %q", k, data[offset:offset+10], want)
+		}
+	}
+
+	if empty := IndexJSONL(nil); empty.LineCount != 0 || len(empty.Offsets) != 0 {
+		t.Errorf("unexpected index for empty shard %+v", empty)
+	}
+}
+
+func TestShardIndexLineRange(t *testing.T) {
+	lines := make([]string, 250)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(`{"i":%d}`, i)
+	}
+	data := encodeShard(lines)
+	index := IndexJSONL(data)
+
+	testCases := []struct {
+		from, count int
+	}{
+		{0, 10},
+		{95, 10},
+		{100, 100},
+		{205, 45},
+		{249, 1},
+	}
+	for _, tc := range testCases {
+		start, end, skip := index.lineRange(tc.from, tc.count)
+		got := strings.Split(strings.TrimSuffix(string(data[start:end+1]), "\n"), "\n")
+		if len(got) < skip+tc.count {
+			t.Fatalf("range for %d+%d too short: %d lines", tc.from, tc.count, len(got))
+		}
+		got = got[skip : skip+tc.count]
+		if got[0] != lines[tc.from] || got[len(got)-1] != lines[tc.from+tc.count-1] {
+			t.Errorf("range for %d+%d returned %q..%q", tc.from, tc.count, got[0], got[len(got)-1])
+		}
+	}
+}
+
+func TestSplitJSONL(t *testing.T) {
+	content := "{\"a\":1}\n{\"a\":2}\r\n{\"a\":3}\n{\"a\":4}\n{\"a\":5}"
+	var shards [][]string
+	err := splitJSONL(strings.NewReader(content), []string{`{"a":0}`}, 4, func(lines []string) error {
+		shards = append(shards, append([]string(nil), lines...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 2 || len(shards[0]) != 4 || len(shards[1]) != 2 {
+		t.Fatalf("unexpected shards %v", shards)
+	}
+	if shards[0][0] != `{"a":0}` || shards[0][2] != `{"a":2}` || shards[1][1] != `{"a":5}` {
+		t.Errorf("unexpected shard content %v", shards)
+	}
+}
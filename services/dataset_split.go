@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// 一次切分最多生成的子集数
+const maxDatasetSplits = 10
+
+// DatasetSplit 切分出的一个子集及其比例，各子集的比例按总和归一化
+type DatasetSplit struct {
+	Name  string  `json:"name"`
+	Ratio float64 `json:"ratio"`
+}
+
+// ValidateSplits 检查子集名称和比例
+func ValidateSplits(splits []DatasetSplit) error {
+	if len(splits) < 2 || len(splits) > maxDatasetSplits {
+		return fmt.Errorf("number of splits must be between 2 and %d", maxDatasetSplits)
+	}
+	names := map[string]bool{}
+	for _, split := range splits {
+		if split.Name == "" {
+			return errors.New("split name is required")
+		}
+		if names[split.Name] {
+			return fmt.Errorf("duplicate split name %q", split.Name)
+		}
+		names[split.Name] = true
+		if split.Ratio <= 0 {
+			return fmt.Errorf("ratio of split %q must be positive", split.Name)
+		}
+	}
+	return nil
+}
+
+// AssignSplits 将条目随机分配到各子集，返回每个子集的 entry_index(升序)
+// indexes 须按 entry_index 升序；同一 seed、同一组条目的结果相同
+// strata 不为 nil 时与 indexes 一一对应，每个分层内分别按比例分配，各子集中的分层比例与原数据一致
+func AssignSplits(indexes []int, strata []string, splits []DatasetSplit, seed int64) [][]int {
+	groups := map[string][]int{}
+	for i, index := range indexes {
+		key := ""
+		if strata != nil {
+			key = strata[i]
+		}
+		groups[key] = append(groups[key], index)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rng := rand.New(rand.NewSource(seed))
+	result := make([][]int, len(splits))
+	for _, key := range keys {
+		group := groups[key]
+		rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		start := 0
+		for i, count := range splitCounts(len(group), splits) {
+			result[i] = append(result[i], group[start:start+count]...)
+			start += count
+		}
+	}
+	for i := range result {
+		sort.Ints(result[i])
+	}
+	return result
+}
+
+// splitCounts 按比例计算各子集的条目数，余数按小数部分从大到小分配，小数部分相同时先分给靠前的子集
+func splitCounts(n int, splits []DatasetSplit) []int {
+	total := 0.0
+	for _, split := range splits {
+		total += split.Ratio
+	}
+	counts := make([]int, len(splits))
+	remainders := make([]float64, len(splits))
+	assigned := 0
+	for i, split := range splits {
+		exact := float64(n) * split.Ratio / total
+		counts[i] = int(exact)
+		remainders[i] = exact - float64(counts[i])
+		assigned += counts[i]
+	}
+	order := make([]int, len(splits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; assigned < n; i++ {
+		counts[order[i%len(order)]]++
+		assigned++
+	}
+	return counts
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssignSplitsDeterministic(t *testing.T) {
+	indexes := make([]int, 100)
+	for i := range indexes {
+		indexes[i] = i * 2
+	}
+	splits := []DatasetSplit{{Name: "train", Ratio: 0.8}, {Name: "validation", Ratio: 0.1}, {Name: "test", Ratio: 0.1}}
+
+	first := AssignSplits(append([]int{}, indexes...), nil, splits, 42)
+	second := AssignSplits(append([]int{}, indexes...), nil, splits, 42)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("same seed produced different splits")
+	}
+	if other := AssignSplits(append([]int{}, indexes...), nil, splits, 7); reflect.DeepEqual(first, other) {
+		t.Error("different seeds produced the same splits")
+	}
+
+	if len(first[0]) != 80 || len(first[1]) != 10 || len(first[2]) != 10 {
+		t.Errorf("split sizes: %d %d %d", len(first[0]), len(first[1]), len(first[2]))
+	}
+	seen := map[int]bool{}
+	for _, split := range first {
+		for i, index := range split {
+			if seen[index] || index%2 != 0 || (i > 0 && split[i-1] >= index) {
+				t.Fatalf("invalid split %v", split)
+			}
+			seen[index] = true
+		}
+	}
+	if len(seen) != len(indexes) {
+		t.Errorf("assigned %d of %d entries", len(seen), len(indexes))
+	}
+}
+
+func TestAssignSplitsStratified(t *testing.T) {
+	var indexes []int
+	var strata []string
+	for i := 0; i < 40; i++ {
+		indexes = append(indexes, i)
+		if i%4 == 0 {
+			strata = append(strata, "positive")
+		} else {
+			strata = append(strata, "negative")
+		}
+	}
+	splits := []DatasetSplit{{Name: "train", Ratio: 3}, {Name: "test", Ratio: 1}}
+	result := AssignSplits(indexes, strata, splits, 1)
+
+	for i, split := range result {
+		positive := 0
+		for _, index := range split {
+			if strata[index] == "positive" {
+				positive++
+			}
+		}
+		// 每个分层的余数都分给第一个子集：positive 7.5/2.5 -> 8/2，negative 22.5/7.5 -> 23/7
+		if want := []int{31, 9}[i]; len(split) != want {
+			t.Errorf("split %d size %d, want %d", i, len(split), want)
+		}
+		if want := []int{8, 2}[i]; positive != want {
+			t.Errorf("split %d has %d positive entries, want %d", i, positive, want)
+		}
+	}
+}
+
+func TestSplitCounts(t *testing.T) {
+	splits := []DatasetSplit{{Name: "a", Ratio: 1}, {Name: "b", Ratio: 1}, {Name: "c", Ratio: 1}}
+	if got := splitCounts(10, splits); !reflect.DeepEqual(got, []int{4, 3, 3}) {
+		t.Errorf("splitCounts(10) = %v", got)
+	}
+	if got := splitCounts(0, splits); !reflect.DeepEqual(got, []int{0, 0, 0}) {
+		t.Errorf("splitCounts(0) = %v", got)
+	}
+}
+
+func TestValidateSplits(t *testing.T) {
+	invalid := [][]DatasetSplit{
+		{{Name: "train", Ratio: 1}},
+		{{Name: "train", Ratio: 1}, {Name: "train", Ratio: 1}},
+		{{Name: "train", Ratio: 1}, {Name: "", Ratio: 1}},
+		{{Name: "train", Ratio: 1}, {Name: "test", Ratio: 0}},
+	}
+	for _, splits := range invalid {
+		if err := ValidateSplits(splits); err == nil {
+			t.Errorf("expected error for %+v", splits)
+		}
+	}
+	if err := ValidateSplits([]DatasetSplit{{Name: "train", Ratio: 0.9}, {Name: "test", Ratio: 0.1}}); err != nil {
+		t.Error(err)
+	}
+}
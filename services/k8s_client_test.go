@@ -0,0 +1,94 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewK8sWithClients(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nb-1", Namespace: "jupyter"},
+	})
+	k8s := NewK8sWithClients(clientset, dfake.NewSimpleDynamicClient(runtime.NewScheme()))
+
+	pod, err := k8s.GetPod("jupyter", "nb-1")
+	if err != nil {
+		t.Fatalf("GetPod failed: %v", err)
+	}
+	if pod.Name != "nb-1" {
+		t.Errorf("expected pod nb-1, got %s", pod.Name)
+	}
+	if err := k8s.DeletePod("jupyter", "nb-1"); err != nil {
+		t.Fatalf("DeletePod failed: %v", err)
+	}
+	if _, err := k8s.GetPod("jupyter", "nb-1"); err == nil {
+		t.Errorf("expected pod to be deleted")
+	}
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test-token
+`
+
+func TestNewK8sFromConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	k8s, err := NewK8sFromConfig(kubeconfig, false)
+	if err != nil {
+		t.Fatalf("NewK8sFromConfig failed: %v", err)
+	}
+	if k8s.config.Host != "https://127.0.0.1:6443" {
+		t.Errorf("unexpected host %s", k8s.config.Host)
+	}
+
+	if _, err := NewK8sFromConfig(filepath.Join(t.TempDir(), "missing"), false); err == nil {
+		t.Errorf("expected error for missing kubeconfig")
+	}
+
+	// 测试环境不在集群内运行
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := NewK8sFromConfig("", true); err == nil {
+		t.Errorf("expected error for in-cluster config outside a cluster")
+	}
+}
+
+func TestNewK8sFromKubeconfig(t *testing.T) {
+	k8s, err := NewK8sFromKubeconfig([]byte(testKubeconfig))
+	if err != nil {
+		t.Fatalf("NewK8sFromKubeconfig failed: %v", err)
+	}
+	if k8s.config.Host != "https://127.0.0.1:6443" {
+		t.Errorf("unexpected host %s", k8s.config.Host)
+	}
+	if k8s.GPUResourceName() != DefaultGPUResourceName {
+		t.Errorf("expected default GPU resource name, got %s", k8s.GPUResourceName())
+	}
+
+	if _, err := NewK8sFromKubeconfig([]byte("not a kubeconfig")); err == nil {
+		t.Errorf("expected error for invalid kubeconfig")
+	}
+}
@@ -31,6 +31,8 @@ type K8s struct {
 	clientset     kubernetes.Interface
 	dynamicClient dynamic.Interface
 	config        *rest.Config
+
+	gpuResourceName corev1.ResourceName
 }
 
 type PodInfo struct {
@@ -93,7 +95,50 @@ func NewK8s(filePath string) (*K8s, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newK8sForConfig(config)
+}
+
+// NewK8sFromConfig 根据 config.yaml 的 kubernetes 配置创建客户端
+// inCluster 为 true 时使用 Pod 的 ServiceAccount，否则使用 kubeconfig 文件(为空时依次尝试 KUBECONFIG、~/.kube/config)
+func NewK8sFromConfig(kubeconfig string, inCluster bool) (*K8s, error) {
+	if !inCluster {
+		return NewK8s(kubeconfig)
+	}
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+	}
+	return newK8sForConfig(config)
+}
+
+// NewK8sFromKubeconfig 使用 kubeconfig 文件内容创建客户端，用于数据库中登记的集群
+func NewK8sFromKubeconfig(kubeconfig []byte) (*K8s, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	return newK8sForConfig(config)
+}
+
+// NewK8sWithClients 使用已有的客户端构造 K8s，测试中可传入 fake.NewSimpleClientset 和 dfake 的客户端
+func NewK8sWithClients(clientset kubernetes.Interface, dynamicClient dynamic.Interface) *K8s {
+	return &K8s{clientset: clientset, dynamicClient: dynamicClient}
+}
+
+// GPUResourceName 集群中 GPU 的扩展资源名，如 nvidia.com/gpu、amd.com/gpu
+func (k *K8s) GPUResourceName() corev1.ResourceName {
+	if k.gpuResourceName == "" {
+		return DefaultGPUResourceName
+	}
+	return k.gpuResourceName
+}
+
+// SetGPUResourceName 设置集群的 GPU 资源名，为空时使用 DefaultGPUResourceName
+func (k *K8s) SetGPUResourceName(name string) {
+	k.gpuResourceName = corev1.ResourceName(name)
+}
 
+func newK8sForConfig(config *rest.Config) (*K8s, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
@@ -173,7 +218,7 @@ func (k *K8s) GetPods(namespace, serviceName, podName string, labels map[string]
 			if container.Resources.Requests != nil {
 				podInfo.Memory += parseMemory(container.Resources.Requests.Memory().String())
 				podInfo.CPU += parseCPU(container.Resources.Requests.Cpu().String())
-				podInfo.GPU += parseGPU(container.Resources.Requests["nvidia.com/gpu"])
+				podInfo.GPU += parseGPU(container.Resources.Requests[k.GPUResourceName()])
 			}
 		}
 
@@ -376,7 +421,7 @@ func (k *K8s) GetNode(label, name, ip string) ([]NodeInfo, error) {
 		// gpuStr := node.Status.Allocatable.Name("nvidia.com/gpu", resource.DecimalSI)
 		// backNode.GPU, _ = strconv.Atoi(gpuStr.String())
 
-		gpuQuantity := node.Status.Allocatable["nvidia.com/gpu"]
+		gpuQuantity := node.Status.Allocatable[k.GPUResourceName()]
 		backNode.GPU = int(gpuQuantity.Value())
 
 		// HostIP
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodePod 调度到节点上的 Pod 及其资源申请(内存单位为 GiB)
+type NodePod struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+	CPU       float64           `json:"cpu"`
+	Memory    float64           `json:"memory"`
+	GPU       int64             `json:"gpu"`
+}
+
+// NodeUsage 节点可分配资源(NodeInfo)与已调度 Pod 申请的资源
+type NodeUsage struct {
+	NodeInfo
+	RequestedCPU    float64   `json:"requested_cpu"`
+	RequestedMemory float64   `json:"requested_memory"`
+	RequestedGPU    int64     `json:"requested_gpu"`
+	Pods            []NodePod `json:"pods"`
+}
+
+// GetNodeUsage 返回匹配 label 的节点及其上运行中 Pod 的资源申请
+func (k *K8s) GetNodeUsage(label string) ([]NodeUsage, error) {
+	nodes, err := k.GetNode(label, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := k.clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pods: %v", err)
+	}
+	podsByNode := make(map[string][]NodePod)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		requests := podRequests(pod)
+		gpuQuantity := requests[k.GPUResourceName()]
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], NodePod{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Labels:    pod.Labels,
+			CPU:       requests.Cpu().AsApproximateFloat64(),
+			Memory:    float64(requests.Memory().Value()) / float64(1<<30),
+			GPU:       gpuQuantity.Value(),
+		})
+	}
+
+	usages := make([]NodeUsage, 0, len(nodes))
+	for _, node := range nodes {
+		usage := NodeUsage{NodeInfo: node, Pods: []NodePod{}}
+		for _, pod := range podsByNode[node.Name] {
+			usage.RequestedCPU += pod.CPU
+			usage.RequestedMemory += pod.Memory
+			usage.RequestedGPU += pod.GPU
+			usage.Pods = append(usage.Pods, pod)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
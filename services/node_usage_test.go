@@ -0,0 +1,98 @@
+package services
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetNodeUsage(t *testing.T) {
+	newPod := func(name, node string, phase corev1.PodPhase, cpu, memory, gpu string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "jupyter", Labels: map[string]string{"app": name}},
+			Spec: corev1.PodSpec{
+				NodeName: node,
+				Containers: []corev1.Container{
+					{Name: "main", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse(cpu),
+						corev1.ResourceMemory: resource.MustParse(memory),
+						"nvidia.com/gpu":      resource.MustParse(gpu),
+					}}},
+					{Name: "sidecar", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("500m"),
+					}}},
+				},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"notebook": "true"}},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.1.1"}},
+				Allocatable: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("8"),
+					corev1.ResourceMemory: resource.MustParse("32Gi"),
+					"nvidia.com/gpu":      resource.MustParse("2"),
+				},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node2"},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("4"),
+					corev1.ResourceMemory: resource.MustParse("8Gi"),
+				},
+			},
+		},
+		newPod("nb-1", "node1", corev1.PodRunning, "2", "4Gi", "1"),
+		newPod("nb-2", "node1", corev1.PodPending, "1", "2Gi", "0"),
+		newPod("done", "node1", corev1.PodSucceeded, "4", "8Gi", "1"),
+		newPod("unscheduled", "", corev1.PodPending, "4", "8Gi", "1"),
+	)
+	k8s := &K8s{clientset: clientset}
+
+	usages, err := k8s.GetNodeUsage("")
+	if err != nil {
+		t.Fatalf("GetNodeUsage() error = %v", err)
+	}
+	if len(usages) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(usages))
+	}
+
+	byName := make(map[string]NodeUsage)
+	for _, usage := range usages {
+		byName[usage.Name] = usage
+	}
+
+	node1 := byName["node1"]
+	if node1.CPU != 8 || node1.Memory != 32 || node1.GPU != 2 || node1.HostIP != "192.168.1.1" {
+		t.Errorf("unexpected allocatable for node1: %+v", node1.NodeInfo)
+	}
+	if node1.RequestedCPU != 4 || node1.RequestedMemory != 6 || node1.RequestedGPU != 1 {
+		t.Errorf("unexpected requested for node1: cpu=%v memory=%v gpu=%v",
+			node1.RequestedCPU, node1.RequestedMemory, node1.RequestedGPU)
+	}
+	if len(node1.Pods) != 2 {
+		t.Errorf("expected 2 pods on node1, got %d", len(node1.Pods))
+	}
+
+	node2 := byName["node2"]
+	if node2.RequestedCPU != 0 || len(node2.Pods) != 0 {
+		t.Errorf("expected node2 to be empty, got %+v", node2)
+	}
+
+	filtered, err := k8s.GetNodeUsage("notebook=true")
+	if err != nil {
+		t.Fatalf("GetNodeUsage() with label error = %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Name != "node1" {
+		t.Errorf("expected only node1 for notebook=true, got %+v", filtered)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// JupyterStatus Jupyter Server /api/status 的响应
+// LastActivity 包含浏览器连接和内核执行的最近活动时间
+type JupyterStatus struct {
+	Started      time.Time `json:"started"`
+	LastActivity time.Time `json:"last_activity"`
+	Connections  int       `json:"connections"`
+	Kernels      int       `json:"kernels"`
+}
+
+// GetJupyterStatus 通过 API Server 的 Service 代理访问 Notebook 的 /api/status
+// 无需平台与 Pod 网络互通
+func (k *K8s) GetJupyterStatus(namespace, serviceName string, port int) (*JupyterStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := k.clientset.CoreV1().Services(namespace).
+		ProxyGet("http", serviceName, strconv.Itoa(port), "api/status", nil).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get jupyter status for %s/%s: %v", namespace, serviceName, err)
+	}
+	return parseJupyterStatus(data)
+}
+
+func parseJupyterStatus(data []byte) (*JupyterStatus, error) {
+	var status JupyterStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("invalid jupyter status response: %v", err)
+	}
+	return &status, nil
+}
+
+// ScheduleDue 判断每日时间点 clock(HH:MM，按 now 所在时区)是否落在 (last, now] 区间内
+func ScheduleDue(clock string, last, now time.Time) (bool, error) {
+	if clock == "" {
+		return false, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return false, fmt.Errorf("invalid schedule time %q, expected HH:MM", clock)
+	}
+	due := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if due.After(now) {
+		due = due.AddDate(0, 0, -1)
+	}
+	return due.After(last), nil
+}
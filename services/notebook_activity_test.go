@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJupyterStatus(t *testing.T) {
+	data := []byte(`{"started": "2024-05-01T08:00:00.000000Z", "last_activity": "2024-05-01T09:30:15.123456Z", "connections": 1, "kernels": 2}`)
+
+	status, err := parseJupyterStatus(data)
+	if err != nil {
+		t.Fatalf("parseJupyterStatus() error = %v", err)
+	}
+	expected := time.Date(2024, 5, 1, 9, 30, 15, 123456000, time.UTC)
+	if !status.LastActivity.Equal(expected) {
+		t.Errorf("LastActivity = %v, want %v", status.LastActivity, expected)
+	}
+	if status.Connections != 1 || status.Kernels != 2 {
+		t.Errorf("unexpected counters: %+v", status)
+	}
+
+	if _, err := parseJupyterStatus([]byte("<html>")); err == nil {
+		t.Errorf("expected error for non-JSON response")
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name        string
+		clock       string
+		last        time.Time
+		now         time.Time
+		expectedDue bool
+		expectedErr bool
+	}{
+		{name: "Empty schedule", clock: "", last: at(1, 19, 0), now: at(1, 21, 0)},
+		{name: "Crossed within the tick", clock: "20:00", last: at(1, 19, 59), now: at(1, 20, 0), expectedDue: true},
+		{name: "Already handled", clock: "20:00", last: at(1, 20, 0), now: at(1, 20, 1)},
+		{name: "Not reached yet", clock: "20:00", last: at(1, 18, 0), now: at(1, 19, 0)},
+		{name: "Crossed over midnight", clock: "23:59", last: at(1, 23, 58), now: at(2, 0, 1), expectedDue: true},
+		{name: "Invalid clock", clock: "25:00", last: at(1, 19, 0), now: at(1, 21, 0), expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			due, err := ScheduleDue(tc.clock, tc.last, tc.now)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("ScheduleDue() error = %v, expectedErr %v", err, tc.expectedErr)
+			}
+			if due != tc.expectedDue {
+				t.Errorf("ScheduleDue() = %v, want %v", due, tc.expectedDue)
+			}
+		})
+	}
+}
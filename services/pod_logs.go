@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PyTorchJob 副本 Pod 上由 training-operator 设置的标签
+const (
+	PyTorchJobNameLabel     = "training.kubeflow.org/job-name"
+	PyTorchReplicaTypeLabel = "training.kubeflow.org/replica-type"
+	PyTorchReplicaIdxLabel  = "training.kubeflow.org/replica-index"
+)
+
+// PodLogOptions 控制读取容器日志的方式
+type PodLogOptions struct {
+	Container    string
+	Follow       bool
+	TailLines    *int64
+	SinceSeconds *int64
+}
+
+// StreamPodLogs 返回 Pod 容器日志的数据流，调用方负责关闭
+// Follow 为 true 时数据流会一直保持，直到 ctx 取消或容器退出
+func (k *K8s) StreamPodLogs(ctx context.Context, namespace, podName string, opts PodLogOptions) (io.ReadCloser, error) {
+	logOptions := &corev1.PodLogOptions{
+		Container:    opts.Container,
+		Follow:       opts.Follow,
+		TailLines:    opts.TailLines,
+		SinceSeconds: opts.SinceSeconds,
+	}
+	return k.clientset.CoreV1().Pods(namespace).GetLogs(podName, logOptions).Stream(ctx)
+}
+
+// PyTorchJobReplicaLabels 将 "master" 或 "worker-N" 转换为选择对应副本 Pod 的标签
+// replica 为空时默认选择 master
+func PyTorchJobReplicaLabels(jobName, replica string) (map[string]string, error) {
+	labels := map[string]string{PyTorchJobNameLabel: jobName}
+
+	replica = strings.ToLower(strings.TrimSpace(replica))
+	switch {
+	case replica == "" || replica == "master":
+		labels[PyTorchReplicaTypeLabel] = "master"
+		labels[PyTorchReplicaIdxLabel] = "0"
+	case strings.HasPrefix(replica, "worker-"):
+		index, err := strconv.Atoi(strings.TrimPrefix(replica, "worker-"))
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid replica: %s", replica)
+		}
+		labels[PyTorchReplicaTypeLabel] = "worker"
+		labels[PyTorchReplicaIdxLabel] = strconv.Itoa(index)
+	default:
+		return nil, fmt.Errorf("invalid replica: %s, expected master or worker-N", replica)
+	}
+	return labels, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStreamPodLogs(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-master-0", Namespace: "train"},
+	})
+	k8s := &K8s{clientset: clientset}
+
+	tailLines := int64(10)
+	stream, err := k8s.StreamPodLogs(context.TODO(), "train", "job-master-0", PodLogOptions{Container: "pytorch", TailLines: &tailLines})
+	if err != nil {
+		t.Fatalf("Error streaming logs: %v", err)
+	}
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("Error reading logs: %v", err)
+	}
+	// fake clientset 固定返回 "fake logs"
+	if string(data) != "fake logs" {
+		t.Errorf("Expected 'fake logs', got '%s'", string(data))
+	}
+}
+
+func TestPyTorchJobReplicaLabels(t *testing.T) {
+	testCases := []struct {
+		replica  string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{"", map[string]string{PyTorchJobNameLabel: "job", PyTorchReplicaTypeLabel: "master", PyTorchReplicaIdxLabel: "0"}, false},
+		{"master", map[string]string{PyTorchJobNameLabel: "job", PyTorchReplicaTypeLabel: "master", PyTorchReplicaIdxLabel: "0"}, false},
+		{"worker-2", map[string]string{PyTorchJobNameLabel: "job", PyTorchReplicaTypeLabel: "worker", PyTorchReplicaIdxLabel: "2"}, false},
+		{"worker-x", nil, true},
+		{"chief", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.replica, func(t *testing.T) {
+			labels, err := PyTorchJobReplicaLabels("job", tc.replica)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for replica '%s'", tc.replica)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(labels, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, labels)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -27,6 +28,21 @@ type PyTorchJobConfig struct {
 	MemoryLimit     string            `json:"memory_limit"`
 	NodeSelector    map[string]string `json:"node_selector"`
 	Env             []EnvVar          `json:"env"`
+	Dataset         *DatasetSource    `json:"dataset,omitempty"`
+	GPUResourceName string            `json:"gpu_resource_name,omitempty"` // 为空时使用集群的 GPU 资源名
+}
+
+// DatasetSource 描述训练开始前由 init 容器下载到共享 emptyDir 的数据集
+type DatasetSource struct {
+	URL       string `json:"url"`        // 数据集JSONL的预签名下载地址
+	MountPath string `json:"mount_path"` // init 容器与训练容器共享的目录
+	FileName  string `json:"file_name"`
+	Image     string `json:"image"` // 下载数据集使用的 init 容器镜像
+}
+
+// Path 返回数据集在训练容器中的完整路径
+func (d *DatasetSource) Path() string {
+	return strings.TrimRight(d.MountPath, "/") + "/" + d.FileName
 }
 
 type EnvVar struct {
@@ -43,6 +59,9 @@ func (k *K8s) CreatePyTorchJob(namespace string, config PyTorchJobConfig) (*unst
 	if namespace == "" {
 		return nil, fmt.Errorf("namespace cannot be empty")
 	}
+	if config.GPUResourceName == "" {
+		config.GPUResourceName = string(k.GPUResourceName())
+	}
 
 	pytorchJob := map[string]interface{}{
 		"apiVersion": "kubeflow.org/v1",
@@ -127,35 +146,68 @@ func createReplicaSpec(config PyTorchJobConfig, replicaType string) map[string]i
 		replicas = config.WorkerReplicas
 	}
 
+	gpuResourceName := config.GPUResourceName
+	if gpuResourceName == "" {
+		gpuResourceName = string(DefaultGPUResourceName)
+	}
+
+	container := map[string]interface{}{
+		"name":            "pytorch",
+		"image":           config.Image,
+		"imagePullPolicy": config.ImagePullPolicy,
+		"command":         config.Command,
+		"args":            config.Args,
+		"resources": map[string]interface{}{
+			"limits": map[string]interface{}{
+				"cpu":           config.CPULimit,
+				"memory":        config.MemoryLimit,
+				gpuResourceName: config.GPUsPerNode,
+			},
+			"requests": map[string]interface{}{
+				"cpu":           config.CPULimit,
+				"memory":        config.MemoryLimit,
+				gpuResourceName: config.GPUsPerNode,
+			},
+		},
+		// "env": createEnvVars(config.Env, replicaType),
+	}
+
+	podSpec := map[string]interface{}{
+		"containers":   []map[string]interface{}{container},
+		"nodeSelector": config.NodeSelector,
+	}
+
+	// 挂载数据集: init 容器下载到 emptyDir，训练容器通过环境变量找到文件
+	if config.Dataset != nil {
+		datasetEnv := []map[string]interface{}{
+			{"name": "DATASET_URL", "value": config.Dataset.URL},
+			{"name": "DATASET_PATH", "value": config.Dataset.Path()},
+		}
+		datasetMount := []map[string]interface{}{
+			{"name": "dataset", "mountPath": config.Dataset.MountPath},
+		}
+
+		container["env"] = datasetEnv
+		container["volumeMounts"] = datasetMount
+		podSpec["initContainers"] = []map[string]interface{}{
+			{
+				"name":         "fetch-dataset",
+				"image":        config.Dataset.Image,
+				"command":      []string{"sh", "-c", `curl -fsSL --retry 3 -o "$DATASET_PATH" "$DATASET_URL"`},
+				"env":          datasetEnv,
+				"volumeMounts": datasetMount,
+			},
+		}
+		podSpec["volumes"] = []map[string]interface{}{
+			{"name": "dataset", "emptyDir": map[string]interface{}{}},
+		}
+	}
+
 	return map[string]interface{}{
 		"replicas":      replicas,
 		"restartPolicy": config.RestartPolicy,
 		"template": map[string]interface{}{
-			"spec": map[string]interface{}{
-				"containers": []map[string]interface{}{
-					{
-						"name":            "pytorch",
-						"image":           config.Image,
-						"imagePullPolicy": config.ImagePullPolicy,
-						"command":         config.Command,
-						"args":            config.Args,
-						"resources": map[string]interface{}{
-							"limits": map[string]interface{}{
-								"cpu":            config.CPULimit,
-								"memory":         config.MemoryLimit,
-								"nvidia.com/gpu": config.GPUsPerNode,
-							},
-							"requests": map[string]interface{}{
-								"cpu":            config.CPULimit,
-								"memory":         config.MemoryLimit,
-								"nvidia.com/gpu": config.GPUsPerNode,
-							},
-						},
-						// "env": createEnvVars(config.Env, replicaType),
-					},
-				},
-				"nodeSelector": config.NodeSelector,
-			},
+			"spec": podSpec,
 		},
 	}
 }
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestCreateReplicaSpecWithDataset(t *testing.T) {
+	config := PyTorchJobConfig{
+		Name:           "alice-pytorchjob-abcde",
+		Image:          "pytorch:latest",
+		MasterReplicas: 1,
+		WorkerReplicas: 2,
+		Dataset: &DatasetSource{
+			URL:       "http://minio/datasets/versions/dataset_1/v1.jsonl?X-Amz-Signature=abc",
+			MountPath: "/data",
+			FileName:  "dataset_1_v1.jsonl",
+			Image:     "curlimages/curl:8.8.0",
+		},
+	}
+
+	spec := createReplicaSpec(config, "Worker")
+	if spec["replicas"] != int32(2) {
+		t.Errorf("Expected 2 replicas, got %v", spec["replicas"])
+	}
+
+	podSpec := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	initContainers, ok := podSpec["initContainers"].([]map[string]interface{})
+	if !ok || len(initContainers) != 1 {
+		t.Fatalf("Expected one init container, got %v", podSpec["initContainers"])
+	}
+	if initContainers[0]["image"] != "curlimages/curl:8.8.0" {
+		t.Errorf("Unexpected init container image: %v", initContainers[0]["image"])
+	}
+
+	container := podSpec["containers"].([]map[string]interface{})[0]
+	env := map[string]interface{}{}
+	for _, e := range container["env"].([]map[string]interface{}) {
+		env[e["name"].(string)] = e["value"]
+	}
+	if env["DATASET_URL"] != config.Dataset.URL {
+		t.Errorf("Expected DATASET_URL '%s', got '%v'", config.Dataset.URL, env["DATASET_URL"])
+	}
+	if env["DATASET_PATH"] != "/data/dataset_1_v1.jsonl" {
+		t.Errorf("Expected DATASET_PATH '/data/dataset_1_v1.jsonl', got '%v'", env["DATASET_PATH"])
+	}
+
+	volumes := podSpec["volumes"].([]map[string]interface{})
+	if len(volumes) != 1 || volumes[0]["emptyDir"] == nil {
+		t.Errorf("Expected an emptyDir dataset volume, got %v", volumes)
+	}
+}
+
+func TestCreateReplicaSpecWithoutDataset(t *testing.T) {
+	spec := createReplicaSpec(PyTorchJobConfig{Image: "pytorch:latest", MasterReplicas: 1}, "Master")
+	podSpec := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	if _, ok := podSpec["initContainers"]; ok {
+		t.Errorf("Expected no init containers without a dataset")
+	}
+	if _, ok := podSpec["volumes"]; ok {
+		t.Errorf("Expected no volumes without a dataset")
+	}
+}
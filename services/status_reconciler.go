@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"MLcore-Engine/common"
+
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// StatusSink 接收对账器观察到的工作负载状态并持久化
+type StatusSink interface {
+	SetNotebookStatus(name, status string) error
+	SetTrainingJobStatus(name, status string) error
+	SetTritonDeployStatus(name, status string) error
+}
+
+// StatusReconciler 通过 informer 监听 Pod、Deployment 和 PyTorchJob，
+// 将其状态同步到数据库中对应的 Notebook、TrainingJob 和 TritonDeploy 记录
+type StatusReconciler struct {
+	k8s    *K8s
+	sink   StatusSink
+	resync time.Duration
+
+	NotebookNamespace string
+	NotebookPodType   string
+	TritonNamespace   string
+	PyTorchJobGVR     schema.GroupVersionResource
+}
+
+// NewStatusReconciler 使用 config.yaml 中的命名空间和 CRD 配置创建对账器
+func NewStatusReconciler(k *K8s, sink StatusSink, resync time.Duration) *StatusReconciler {
+	r := &StatusReconciler{
+		k8s:               k,
+		sink:              sink,
+		resync:            resync,
+		NotebookNamespace: viper.GetString("notebook.namespace"),
+		NotebookPodType:   viper.GetString("notebook.podType"),
+		TritonNamespace:   viper.GetString("triton.namespace"),
+		PyTorchJobGVR: schema.GroupVersionResource{
+			Group:    viper.GetString("crds.pytorchjob.group"),
+			Version:  viper.GetString("crds.pytorchjob.version"),
+			Resource: viper.GetString("crds.pytorchjob.plural"),
+		},
+	}
+	if r.NotebookNamespace == "" {
+		r.NotebookNamespace = "jupyter"
+	}
+	if r.NotebookPodType == "" {
+		r.NotebookPodType = "notebook"
+	}
+	if r.TritonNamespace == "" {
+		r.TritonNamespace = "triton-serving"
+	}
+	if r.PyTorchJobGVR.Resource == "" {
+		r.PyTorchJobGVR = schema.GroupVersionResource{Group: "kubeflow.org", Version: "v1", Resource: "pytorchjobs"}
+	}
+	return r
+}
+
+// Run 启动所有 informer 并阻塞直到 ctx 结束
+func (r *StatusReconciler) Run(ctx context.Context) error {
+	notebookFactory := informers.NewSharedInformerFactoryWithOptions(r.k8s.clientset, r.resync,
+		informers.WithNamespace(r.NotebookNamespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = "pod-type=" + r.NotebookPodType
+		}),
+	)
+	podInformer := notebookFactory.Core().V1().Pods().Informer()
+	if _, err := podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { r.onPod(obj) },
+		UpdateFunc: func(_, obj interface{}) { r.onPod(obj) },
+	}); err != nil {
+		return fmt.Errorf("failed to register pod handler: %v", err)
+	}
+
+	tritonFactory := informers.NewSharedInformerFactoryWithOptions(r.k8s.clientset, r.resync,
+		informers.WithNamespace(r.TritonNamespace),
+	)
+	deploymentInformer := tritonFactory.Apps().V1().Deployments().Informer()
+	if _, err := deploymentInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { r.onDeployment(obj) },
+		UpdateFunc: func(_, obj interface{}) { r.onDeployment(obj) },
+	}); err != nil {
+		return fmt.Errorf("failed to register deployment handler: %v", err)
+	}
+
+	synced := []cache.InformerSynced{podInformer.HasSynced, deploymentInformer.HasSynced}
+
+	var dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+	if r.k8s.dynamicClient != nil {
+		dynamicFactory = dynamicinformer.NewFilteredDynamicSharedInformerFactory(r.k8s.dynamicClient, r.resync, metav1.NamespaceAll, nil)
+		jobInformer := dynamicFactory.ForResource(r.PyTorchJobGVR).Informer()
+		if _, err := jobInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { r.onPyTorchJob(obj) },
+			UpdateFunc: func(_, obj interface{}) { r.onPyTorchJob(obj) },
+		}); err != nil {
+			return fmt.Errorf("failed to register pytorchjob handler: %v", err)
+		}
+		synced = append(synced, jobInformer.HasSynced)
+	}
+
+	notebookFactory.Start(ctx.Done())
+	tritonFactory.Start(ctx.Done())
+	if dynamicFactory != nil {
+		dynamicFactory.Start(ctx.Done())
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		if ctx.Err() != nil {
+			// 启动过程中被取消(如集群配置变更)，不视为错误
+			return nil
+		}
+		return fmt.Errorf("failed to sync informer caches")
+	}
+	common.SysLog("status reconciler started")
+
+	<-ctx.Done()
+	return nil
+}
+
+func (r *StatusReconciler) onPod(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.DeletionTimestamp != nil {
+		return
+	}
+	if err := r.sink.SetNotebookStatus(pod.Name, PodStatus(pod)); err != nil {
+		common.SysError(fmt.Sprintf("failed to sync notebook %s status: %v", pod.Name, err))
+	}
+}
+
+func (r *StatusReconciler) onDeployment(obj interface{}) {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok || deployment.DeletionTimestamp != nil {
+		return
+	}
+	if err := r.sink.SetTritonDeployStatus(deployment.Name, DeploymentStatus(deployment)); err != nil {
+		common.SysError(fmt.Sprintf("failed to sync triton deploy %s status: %v", deployment.Name, err))
+	}
+}
+
+func (r *StatusReconciler) onPyTorchJob(obj interface{}) {
+	job, ok := obj.(*unstructured.Unstructured)
+	if !ok || job.GetDeletionTimestamp() != nil {
+		return
+	}
+	status, err := r.k8s.GetCRDStatus(job, r.PyTorchJobGVR.Group, r.PyTorchJobGVR.Resource)
+	if err != nil || status == "" {
+		// 刚创建的任务还没有 status 字段，保持数据库中的初始状态
+		return
+	}
+	if err := r.sink.SetTrainingJobStatus(job.GetName(), status); err != nil {
+		common.SysError(fmt.Sprintf("failed to sync training job %s status: %v", job.GetName(), err))
+	}
+}
+
+// PodStatus 将 Pod 的状态归纳为界面展示用的状态
+// 容器处于等待状态时优先返回等待原因(例如 ImagePullBackOff)
+func PodStatus(pod *corev1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" && cs.State.Waiting.Reason != "ContainerCreating" {
+			return cs.State.Waiting.Reason
+		}
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status != corev1.ConditionTrue {
+				return "Creating"
+			}
+		}
+		return "Running"
+	case corev1.PodPending:
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+				return "Pending"
+			}
+		}
+		return "Creating"
+	case "":
+		return "Pending"
+	default:
+		return string(pod.Status.Phase)
+	}
+}
+
+// DeploymentStatus 根据副本就绪情况返回 Deployment 的状态
+func DeploymentStatus(deployment *appsv1.Deployment) string {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse {
+			return "Failed"
+		}
+		if condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue {
+			return "Failed"
+		}
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	if desired == 0 {
+		return "Stopped"
+	}
+	if deployment.Status.AvailableReplicas >= desired {
+		return "Running"
+	}
+	return "Creating"
+}
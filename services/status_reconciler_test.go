@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type recordingSink struct {
+	mu           sync.Mutex
+	notebooks    map[string]string
+	trainingJobs map[string]string
+	tritons      map[string]string
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{
+		notebooks:    map[string]string{},
+		trainingJobs: map[string]string{},
+		tritons:      map[string]string{},
+	}
+}
+
+func (s *recordingSink) SetNotebookStatus(name, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notebooks[name] = status
+	return nil
+}
+
+func (s *recordingSink) SetTrainingJobStatus(name, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trainingJobs[name] = status
+	return nil
+}
+
+func (s *recordingSink) SetTritonDeployStatus(name, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tritons[name] = status
+	return nil
+}
+
+func (s *recordingSink) get(m map[string]string, name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return m[name]
+}
+
+func waitForStatus(t *testing.T, get func() string, expected string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if get() == expected {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected status '%s', got '%s'", expected, get())
+}
+
+func TestStatusReconciler(t *testing.T) {
+	replicas := int32(1)
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "alice-abcde",
+				Namespace: "jupyter",
+				Labels:    map[string]string{"pod-type": "notebook"},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+				},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice-trixyz", Namespace: "triton-serving"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{AvailableReplicas: 1},
+		},
+	)
+
+	gvr := schema.GroupVersionResource{Group: "kubeflow.org", Version: "v1", Resource: "pytorchjobs"}
+	job := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "kubeflow.org/v1",
+			"kind":       "PyTorchJob",
+			"metadata": map[string]interface{}{
+				"name":      "alice-pytorchjob-abcde",
+				"namespace": "train",
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Created", "status": "True"},
+					map[string]interface{}{"type": "Running", "status": "True"},
+				},
+			},
+		},
+	}
+	dynamicClient := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "PyTorchJobList"}, job)
+
+	k8s := &K8s{clientset: clientset, dynamicClient: dynamicClient}
+	sink := newRecordingSink()
+	reconciler := NewStatusReconciler(k8s, sink, 0)
+	reconciler.NotebookNamespace = "jupyter"
+	reconciler.NotebookPodType = "notebook"
+	reconciler.TritonNamespace = "triton-serving"
+	reconciler.PyTorchJobGVR = gvr
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := reconciler.Run(ctx); err != nil {
+			t.Errorf("Error running reconciler: %v", err)
+		}
+	}()
+
+	waitForStatus(t, func() string { return sink.get(sink.notebooks, "alice-abcde") }, "Running")
+	waitForStatus(t, func() string { return sink.get(sink.tritons, "alice-trixyz") }, "Running")
+	waitForStatus(t, func() string { return sink.get(sink.trainingJobs, "alice-pytorchjob-abcde") }, "Running")
+
+	// Pod 镜像拉取失败时应反映等待原因
+	pod, err := clientset.CoreV1().Pods("jupyter").Get(context.TODO(), "alice-abcde", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pod: %v", err)
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+	}
+	if _, err := clientset.CoreV1().Pods("jupyter").UpdateStatus(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Error updating pod: %v", err)
+	}
+	waitForStatus(t, func() string { return sink.get(sink.notebooks, "alice-abcde") }, "ImagePullBackOff")
+}
+
+func TestPodStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pod      corev1.Pod
+		expected string
+	}{
+		{"Unscheduled", corev1.Pod{Status: corev1.PodStatus{
+			Phase:      corev1.PodPending,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionFalse}},
+		}}, "Pending"},
+		{"Scheduled but starting", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}}, "Creating"},
+		{"Running not ready", corev1.Pod{Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+		}}, "Creating"},
+		{"Failed", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed}}, "Failed"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := PodStatus(&tc.pod); status != tc.expected {
+				t.Errorf("Expected status '%s', got '%s'", tc.expected, status)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ import { Link, useNavigate } from 'react-router-dom';
 import { useAuth } from '../../context/AuthContext';
 import { GIT_REPO_URL } from '../../constants/common.constant';
 import { Menu, Dropdown, Icon, Container } from 'semantic-ui-react';
-import { getSystemName, isAdmin } from '../../helpers';
+import { API, getSystemName, isAdmin } from '../../helpers';
 import 'semantic-ui-css/semantic.min.css';
 import '../../styles/header.css';
 
@@ -47,7 +47,15 @@ const Header = () => {
   const navigate = useNavigate();
   const systemName = getSystemName();
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    // 通知服务端吊销当前令牌，失败时仍清除本地登录状态
+    try {
+      await API.post('/api/user/logout', {
+        refresh_token: localStorage.getItem('refresh_token') || '',
+      });
+    } catch (e) {
+      // ignore
+    }
     logout();
     navigate('/login');
   };
@@ -88,7 +88,7 @@ const LoginForm = () => {
     );
     const { success, message, data } = res.data;
     if (success) {
-      login(data.user, data.token);
+      login(data.user, data.token, data.refresh_token);
       localStorage.setItem('user', JSON.stringify(data.user));
       localStorage.setItem('token', data.token);
       API.defaults.headers.common['Authorization'] = `Bearer ${data.token}`;
@@ -117,9 +117,9 @@ const LoginForm = () => {
       const { success, message, data } = res.data;
       if (success && data) {
 
-        const { token, user } = data;
+        const { token, refresh_token, user } = data;
 
-        login(user, token);
+        login(user, token, refresh_token);
         localStorage.setItem('user', JSON.stringify(user));
         localStorage.setItem('token', token);
         // 设置 API 请求的默认 Authorization header
@@ -64,7 +64,8 @@ export const AuthProvider = ({ children }) => {
     const userString = localStorage.getItem('user');
     const user = userString ? JSON.parse(userString) : null;
 
-    if (token && isTokenValid(token)) {
+    // 访问令牌过期但仍有刷新令牌时保留登录状态，由 API 拦截器负责刷新
+    if (token && (isTokenValid(token) || localStorage.getItem('refresh_token'))) {
       dispatch({ type: 'setToken', payload: { token, user } });
     } else {
       localStorage.removeItem('token');
@@ -72,8 +73,11 @@ export const AuthProvider = ({ children }) => {
     }
   }, []);
 
-  const login = (userData, token) => {
+  const login = (userData, token, refreshToken) => {
     localStorage.setItem('token', token);
+    if (refreshToken) {
+      localStorage.setItem('refresh_token', refreshToken);
+    }
     localStorage.setItem('user', JSON.stringify(userData));
     dispatch({ type: 'login', payload: { user: userData, token } });
     toast.success('login success！');
@@ -81,6 +85,7 @@ export const AuthProvider = ({ children }) => {
 
   const logout = () => {
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user');
     dispatch({ type: 'logout' });
     toast.info('logout success！');  
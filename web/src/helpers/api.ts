@@ -95,10 +95,61 @@ export const useAPIWithAuth = (): AxiosInstance => {
   return API;
 };
 
+// ========================= 访问令牌刷新 =========================
+
+// 正在进行的刷新请求，多个并发的 401 共用同一次刷新
+let refreshPromise: Promise<string | null> | null = null;
+
+/**
+ * 使用 localStorage 中的刷新令牌换取新的访问令牌
+ * 刷新令牌每次使用后都会轮换，失败时清除本地登录状态
+ */
+const refreshAccessToken = (): Promise<string | null> => {
+  const refreshToken = localStorage.getItem('refresh_token');
+  if (!refreshToken) {
+    return Promise.resolve(null);
+  }
+  if (!refreshPromise) {
+    refreshPromise = axios
+      .post(`${API.defaults.baseURL || ''}/api/user/refresh`, { refresh_token: refreshToken })
+      .then((res) => {
+        const { success, data } = res.data;
+        if (!success || !data) {
+          return null;
+        }
+        localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
+        return data.token as string;
+      })
+      .catch(() => null)
+      .then((token) => {
+        if (!token) {
+          localStorage.removeItem('token');
+          localStorage.removeItem('refresh_token');
+          localStorage.removeItem('user');
+        }
+        refreshPromise = null;
+        return token;
+      });
+  }
+  return refreshPromise;
+};
+
 // 全局响应拦截器
 API.interceptors.response.use(
   (response: AxiosResponse): AxiosResponse => response,
-  (error: AxiosError): Promise<never> => {
+  async (error: AxiosError): Promise<any> => {
+    // 访问令牌过期时自动刷新并重试一次
+    const originalRequest = error.config as (InternalAxiosRequestConfig & { _retry?: boolean }) | undefined;
+    if (error.response?.status === 401 && originalRequest && !originalRequest._retry) {
+      originalRequest._retry = true;
+      const token = await refreshAccessToken();
+      if (token) {
+        originalRequest.headers.set('Authorization', `Bearer ${token}`);
+        return API(originalRequest);
+      }
+    }
+
     // 处理特定HTTP错误
     if (error.response) {
       const status = error.response.status;